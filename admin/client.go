@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type Client struct {
+	socketPath string
+	timeout    time.Duration
+}
+
+// NewClient returns client waiting at most timeout for responses;
+// zero timeout waits until agent responds.
+func NewClient(socketPath string, timeout time.Duration) Client {
+	return Client{socketPath: socketPath, timeout: timeout}
+}
+
+type clientRequest struct {
+	Method    string        `json:"method"`
+	Arguments []interface{} `json:"arguments"`
+	ReplyTo   string        `json:"reply_to"`
+}
+
+// Call returns raw response which contains either value or exception
+func (c Client) Call(method string, arguments []interface{}) ([]byte, error) {
+	if arguments == nil {
+		arguments = []interface{}{}
+	}
+
+	reqBytes, err := json.Marshal(clientRequest{Method: method, Arguments: arguments, ReplyTo: "ctl"})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling request")
+	}
+
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Connecting to %s", c.socketPath)
+	}
+
+	defer conn.Close()
+
+	if c.timeout > 0 {
+		err = conn.SetDeadline(time.Now().Add(c.timeout))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Setting deadline")
+		}
+	}
+
+	_, err = conn.Write(reqBytes)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing request")
+	}
+
+	respBytes, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading response")
+	}
+
+	return respBytes, nil
+}
@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	serverLogTag = "Admin Server"
+
+	// Only root may connect since socket is used without further authentication.
+	// Socket is created with process umask so directory keeps others
+	// from connecting before socket permissions are restricted.
+	socketDirPerm = 0700
+	socketPerm    = 0600
+
+	requestReadTimeout = 10 * time.Second
+)
+
+// DefaultAllowedActions only include actions that do not change VM
+var DefaultAllowedActions = []string{"get_state", "get_task", "info", "list_disk", "ping"}
+
+// Server accepts one request per connection on a Unix domain socket
+// and writes back response in the same format as other handlers.
+type Server struct {
+	socketPath     string
+	allowedActions map[string]bool
+	fs             boshsys.FileSystem
+	logger         boshlog.Logger
+
+	listener net.Listener
+	lock     sync.Mutex
+}
+
+func NewServer(socketPath string, allowedActions []string, fs boshsys.FileSystem, logger boshlog.Logger) *Server {
+	allowed := map[string]bool{}
+	for _, action := range allowedActions {
+		allowed[action] = true
+	}
+
+	return &Server{
+		socketPath:     socketPath,
+		allowedActions: allowed,
+		fs:             fs,
+		logger:         logger,
+	}
+}
+
+// Start returns once socket is listening and serves requests in the background
+func (s *Server) Start(handlerFunc boshhandler.Func) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	socketDir := filepath.Dir(s.socketPath)
+
+	err := s.fs.MkdirAll(socketDir, socketDirPerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating socket directory %s", socketDir)
+	}
+
+	// Directory may have been created with other permissions
+	err = s.fs.Chmod(socketDir, socketDirPerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Restricting permissions of %s", socketDir)
+	}
+
+	// Socket file is left behind when agent is not stopped cleanly
+	err = s.fs.RemoveAll(s.socketPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing stale socket %s", s.socketPath)
+	}
+
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Listening on %s", s.socketPath)
+	}
+
+	err = s.fs.Chmod(s.socketPath, socketPerm)
+	if err != nil {
+		listener.Close()
+		return bosherr.WrapErrorf(err, "Restricting permissions of %s", s.socketPath)
+	}
+
+	s.listener = listener
+
+	go s.serve(listener, handlerFunc)
+
+	s.logger.Info(serverLogTag, "Serving admin requests on %s", s.socketPath)
+
+	return nil
+}
+
+// IsRunning returns true once socket is listening until server is stopped
+func (s *Server) IsRunning() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.listener != nil
+}
+
+func (s *Server) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		err := s.listener.Close()
+		s.listener = nil
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) serve(listener net.Listener, handlerFunc boshhandler.Func) {
+	defer s.logger.HandlePanic("Admin Server")
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.logger.Debug(serverLogTag, "Stopped accepting connections: %s", err.Error())
+			return
+		}
+
+		go s.handleConnection(conn, handlerFunc)
+	}
+}
+
+func (s *Server) handleConnection(conn net.Conn, handlerFunc boshhandler.Func) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			s.logger.Debug(serverLogTag, "Failed to close connection: %s", err.Error())
+		}
+	}()
+
+	err := conn.SetReadDeadline(time.Now().Add(requestReadTimeout))
+	if err != nil {
+		s.logger.Error(serverLogTag, "Setting read deadline: %s", err.Error())
+		return
+	}
+
+	var rawJSON json.RawMessage
+
+	err = json.NewDecoder(conn).Decode(&rawJSON)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Reading request: %s", err.Error())
+		s.writeResponse(conn, boshhandler.NewExceptionResponse(bosherr.WrapError(err, "Reading request")))
+		return
+	}
+
+	respBytes, _, err := boshhandler.PerformHandlerWithJSON(
+		rawJSON,
+		func(req boshhandler.Request) boshhandler.Response {
+			if !s.allowedActions[req.Method] {
+				s.logger.Warn(serverLogTag, "Rejecting action '%s'", req.Method)
+				return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(
+					boshhandler.ErrorCodeActionNotAllowed, "Action '%s' is not allowed over admin socket", req.Method))
+			}
+
+			req.Caller = boshhandler.Caller{Kind: boshhandler.CallerAdminSocket, Name: s.socketPath}
+
+			return handlerFunc(req)
+		},
+		boshhandler.UnlimitedResponseLength,
+		s.logger,
+	)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Handling request: %s", err.Error())
+		s.writeResponse(conn, boshhandler.NewExceptionResponse(err))
+		return
+	}
+
+	_, err = conn.Write(respBytes)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Writing response: %s", err.Error())
+	}
+}
+
+func (s *Server) writeResponse(conn net.Conn, response boshhandler.Response) {
+	respBytes, err := json.Marshal(response)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Marshalling response: %s", err.Error())
+		return
+	}
+
+	_, err = conn.Write(respBytes)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Writing response: %s", err.Error())
+	}
+}
@@ -0,0 +1,151 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/admin"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		tmpDir     string
+		socketPath string
+		server     *Server
+		client     Client
+
+		receivedRequests chan boshhandler.Request
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		tmpDir, err = ioutil.TempDir("", "admin-server")
+		Expect(err).ToNot(HaveOccurred())
+
+		socketPath = filepath.Join(tmpDir, "admin", "agent.sock")
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		server = NewServer(socketPath, DefaultAllowedActions, boshsys.NewOsFileSystem(logger), logger)
+		client = NewClient(socketPath, 0)
+
+		requests := make(chan boshhandler.Request, 1)
+		receivedRequests = requests
+
+		err = server.Start(func(req boshhandler.Request) boshhandler.Response {
+			requests <- req
+			return boshhandler.NewValueResponse("fake-value")
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(server.Stop()).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("only allows owner to connect", func() {
+		info, err := os.Stat(socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		info, err = os.Stat(filepath.Dir(socketPath))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+	})
+
+	It("restricts permissions of existing socket directory before listening", func() {
+		Expect(server.Stop()).To(Succeed())
+		Expect(os.Chmod(filepath.Dir(socketPath), 0755)).To(Succeed())
+
+		err := server.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+		Expect(err).ToNot(HaveOccurred())
+
+		info, err := os.Stat(filepath.Dir(socketPath))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+	})
+
+	It("reports whether it is running", func() {
+		Expect(server.IsRunning()).To(BeTrue())
+
+		Expect(server.Stop()).To(Succeed())
+		Expect(server.IsRunning()).To(BeFalse())
+	})
+
+	It("dispatches allowed actions and responds with their result", func() {
+		respBytes, err := client.Call("get_task", []interface{}{"fake-task-id"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respBytes).To(MatchJSON(`{"value":"fake-value"}`))
+
+		var req boshhandler.Request
+		Eventually(receivedRequests).Should(Receive(&req))
+		Expect(req.Method).To(Equal("get_task"))
+		Expect(req.ReplyTo).To(Equal("ctl"))
+		Expect(req.Caller).To(Equal(boshhandler.Caller{Kind: boshhandler.CallerAdminSocket, Name: socketPath}))
+
+		var payload map[string]interface{}
+		Expect(json.Unmarshal(req.Payload, &payload)).To(Succeed())
+		Expect(payload["arguments"]).To(Equal([]interface{}{"fake-task-id"}))
+	})
+
+	It("rejects actions that are not allowed without dispatching them", func() {
+		respBytes, err := client.Call("stop", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		var response map[string]map[string]interface{}
+		Expect(json.Unmarshal(respBytes, &response)).To(Succeed())
+		Expect(response["exception"]["message"]).To(Equal("Action 'stop' is not allowed over admin socket"))
+		Expect(response["exception"]["code"]).To(Equal("action_not_allowed"))
+
+		Expect(receivedRequests).ToNot(Receive())
+	})
+
+	It("responds with exception to invalid requests", func() {
+		conn, err := net.Dial("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("{invalid"))
+		Expect(err).ToNot(HaveOccurred())
+
+		respBytes, err := ioutil.ReadAll(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(respBytes)).To(ContainSubstring(`"exception"`))
+		Expect(string(respBytes)).To(ContainSubstring("Reading request"))
+	})
+
+	It("replaces socket left behind by previous run", func() {
+		Expect(server.Stop()).To(Succeed())
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		server = NewServer(socketPath, DefaultAllowedActions, boshsys.NewOsFileSystem(logger), logger)
+
+		err := server.Start(func(boshhandler.Request) boshhandler.Response {
+			return boshhandler.NewValueResponse("fake-other-value")
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		respBytes, err := client.Call("ping", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(respBytes).To(MatchJSON(`{"value":"fake-other-value"}`))
+	})
+})
+
+var _ = Describe("Client", func() {
+	It("returns error when agent is not listening", func() {
+		_, err := NewClient("/non-existent/agent.sock", 0).Call("ping", nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Connecting to /non-existent/agent.sock"))
+	})
+})
@@ -7,6 +7,7 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -52,9 +53,11 @@ func (a ApplyAction) IsLoggable() bool {
 	return true
 }
 
-func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
+func (a ApplyAction) Run(progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal, desiredSpec boshas.V1ApplySpec) (string, error) {
 	settings := a.settingsService.GetSettings()
 
+	progress.Report(boshtask.Progress{Percent: 0, Phase: "resolving_networks"})
+
 	resolvedDesiredSpec, err := a.specService.PopulateDHCPNetworks(desiredSpec, settings)
 	if err != nil {
 		return "", bosherr.WrapError(err, "Resolving dynamic networks")
@@ -66,12 +69,16 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 			return "", bosherr.WrapError(err, "Getting current spec")
 		}
 
-		err = a.applier.Apply(currentSpec, resolvedDesiredSpec)
+		progress.Report(boshtask.Progress{Percent: 10, Phase: "applying"})
+
+		err = a.applier.Apply(currentSpec, resolvedDesiredSpec, cancelled)
 		if err != nil {
 			return "", bosherr.WrapError(err, "Applying")
 		}
 	}
 
+	progress.Report(boshtask.Progress{Percent: 90, Phase: "persisting_spec"})
+
 	err = a.specService.Set(resolvedDesiredSpec)
 	if err != nil {
 		return "", bosherr.WrapError(err, "Persisting apply spec")
@@ -85,6 +92,33 @@ func (a ApplyAction) Run(desiredSpec boshas.V1ApplySpec) (string, error) {
 	return "applied", nil
 }
 
+// Plan lists jobs and packages Run would install or remove
+func (a ApplyAction) Plan(desiredSpec boshas.V1ApplySpec) (boshappl.ApplyPlan, error) {
+	plan := boshappl.ApplyPlan{
+		InstallJobs:     []string{},
+		RemoveJobs:      []string{},
+		InstallPackages: []string{},
+		RemovePackages:  []string{},
+	}
+
+	// Same as Run, jobs and packages are only changed when spec includes configuration
+	if desiredSpec.ConfigurationHash == "" {
+		return plan, nil
+	}
+
+	currentSpec, err := a.specService.Get()
+	if err != nil {
+		return plan, bosherr.WrapError(err, "Getting current spec")
+	}
+
+	plan, err = a.applier.PlanApply(currentSpec, desiredSpec)
+	if err != nil {
+		return plan, bosherr.WrapError(err, "Planning apply")
+	}
+
+	return plan, nil
+}
+
 func (a ApplyAction) writeInstanceData(spec boshas.V1ApplySpec) error {
 	err := a.writeInstanceField("id", spec.NodeID)
 	if err != nil {
@@ -130,6 +164,7 @@ func (a ApplyAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel does nothing since Run stops once its cancel signal is closed
 func (a ApplyAction) Cancel() error {
-	return errors.New("not supported")
+	return nil
 }
@@ -8,9 +8,12 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
 	fakeappl "github.com/cloudfoundry/bosh-agent/agent/applier/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
@@ -25,6 +28,7 @@ func init() {
 			specService     *fakeas.FakeV1Service
 			settingsService *fakesettings.FakeSettingsService
 			dirProvider     boshdir.Provider
+			progress        *faketask.FakeProgressReporter
 			action          ApplyAction
 			fs              boshsys.FileSystem
 		)
@@ -35,6 +39,7 @@ func init() {
 			settingsService = &fakesettings.FakeSettingsService{}
 			dirProvider = boshdir.NewProvider("/var/vcap")
 			fs = fakesys.NewFakeFileSystem()
+			progress = faketask.NewFakeProgressReporter()
 			action = NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), fs)
 		})
 
@@ -42,7 +47,7 @@ func init() {
 		AssertActionIsNotPersistent(action)
 		AssertActionIsLoggable(action)
 
-		AssertActionIsNotCancelable(action)
+		AssertActionIsCancelledBySignal(action)
 		AssertActionIsNotResumable(action)
 
 		Describe("Run", func() {
@@ -65,7 +70,7 @@ func init() {
 					})
 
 					It("populates dynamic networks in desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).ToNot(HaveOccurred())
 						Expect(specService.PopulateDHCPNetworksSpec).To(Equal(desiredApplySpec))
 						Expect(specService.PopulateDHCPNetworksSettings).To(Equal(settings))
@@ -77,17 +82,31 @@ func init() {
 						})
 
 						It("runs applier with populated desired spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(applier.Applied).To(BeTrue())
 							Expect(applier.ApplyCurrentApplySpec).To(Equal(currentApplySpec))
 							Expect(applier.ApplyDesiredApplySpec).To(Equal(populatedDesiredApplySpec))
 						})
 
+						It("passes cancel signal to applier", func() {
+							canceller := boshtask.NewCanceller()
+
+							_, err := action.Run(progress, canceller.Signal(), desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(applier.ApplyCancelSignal).To(Equal(canceller.Signal()))
+						})
+
+						It("reports progress while applying desired spec", func() {
+							_, err := action.Run(progress, nil, desiredApplySpec)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(progress.Phases()).To(Equal([]string{"resolving_networks", "applying", "persisting_spec"}))
+						})
+
 						Context("when applier succeeds applying desired spec", func() {
 							Context("when saving desires spec as current spec succeeds", func() {
 								It("returns 'applied' after setting populated desired spec as current spec", func() {
-									value, err := action.Run(desiredApplySpec)
+									value, err := action.Run(progress, nil, desiredApplySpec)
 									Expect(err).ToNot(HaveOccurred())
 									Expect(value).To(Equal("applied"))
 
@@ -102,7 +121,7 @@ func init() {
 									})
 
 									It("returns 'applied' and writes the id, instance name, deployment name, and az to files in the instance directory", func() {
-										value, err := action.Run(desiredApplySpec)
+										value, err := action.Run(progress, nil, desiredApplySpec)
 										Expect(err).ToNot(HaveOccurred())
 										Expect(value).To(Equal("applied"))
 
@@ -131,7 +150,7 @@ func init() {
 								It("returns error because agent was not able to remember that is converged to desired spec", func() {
 									specService.SetErr = errors.New("fake-set-error")
 
-									_, err := action.Run(desiredApplySpec)
+									_, err := action.Run(progress, nil, desiredApplySpec)
 									Expect(err).To(HaveOccurred())
 									Expect(err.Error()).To(ContainSubstring("fake-set-error"))
 								})
@@ -144,13 +163,13 @@ func init() {
 							})
 
 							It("returns error", func() {
-								_, err := action.Run(desiredApplySpec)
+								_, err := action.Run(progress, nil, desiredApplySpec)
 								Expect(err).To(HaveOccurred())
 								Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
 							})
 
 							It("does not save desired spec as current spec", func() {
-								_, err := action.Run(desiredApplySpec)
+								_, err := action.Run(progress, nil, desiredApplySpec)
 								Expect(err).To(HaveOccurred())
 								Expect(specService.Spec).To(Equal(currentApplySpec))
 							})
@@ -163,19 +182,19 @@ func init() {
 						})
 
 						It("returns error", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-populate-dynamic-networks-err"))
 						})
 
 						It("does not apply desired spec as current spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
 
 						It("does not save desired spec as current spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(specService.Spec).To(Equal(currentApplySpec))
 						})
@@ -189,19 +208,19 @@ func init() {
 					})
 
 					It("returns error and does not apply desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-get-error"))
 					})
 
 					It("does not run applier with desired spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(applier.Applied).To(BeFalse())
 					})
 
 					It("does not save desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(specService.Spec).To(Equal(currentApplySpec))
 					})
@@ -222,7 +241,7 @@ func init() {
 				}
 
 				It("populates dynamic networks in desired spec", func() {
-					_, err := action.Run(desiredApplySpec)
+					_, err := action.Run(progress, nil, desiredApplySpec)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(specService.PopulateDHCPNetworksSpec).To(Equal(desiredApplySpec))
 					Expect(specService.PopulateDHCPNetworksSettings).To(Equal(settings))
@@ -235,7 +254,7 @@ func init() {
 
 					Context("when saving desires spec as current spec succeeds", func() {
 						It("returns 'applied' after setting desired spec as current spec", func() {
-							value, err := action.Run(desiredApplySpec)
+							value, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(value).To(Equal("applied"))
 
@@ -243,7 +262,7 @@ func init() {
 						})
 
 						It("does not try to apply desired spec since it does not have jobs and packages", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
@@ -255,13 +274,13 @@ func init() {
 						})
 
 						It("returns error because agent was not able to remember that is converged to desired spec", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("fake-set-error"))
 						})
 
 						It("does not try to apply desired spec since it does not have jobs and packages", func() {
-							_, err := action.Run(desiredApplySpec)
+							_, err := action.Run(progress, nil, desiredApplySpec)
 							Expect(err).To(HaveOccurred())
 							Expect(applier.Applied).To(BeFalse())
 						})
@@ -274,24 +293,75 @@ func init() {
 					})
 
 					It("returns error", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(err.Error()).To(ContainSubstring("fake-populate-dynamic-networks-err"))
 					})
 
 					It("does not apply desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(applier.Applied).To(BeFalse())
 					})
 
 					It("does not save desired spec as current spec", func() {
-						_, err := action.Run(desiredApplySpec)
+						_, err := action.Run(progress, nil, desiredApplySpec)
 						Expect(err).To(HaveOccurred())
 						Expect(specService.Spec).ToNot(Equal(desiredApplySpec))
 					})
 				})
 			})
 		})
+
+		Describe("Plan", func() {
+			currentApplySpec := boshas.V1ApplySpec{ConfigurationHash: "fake-current-config-hash"}
+			desiredApplySpec := boshas.V1ApplySpec{ConfigurationHash: "fake-desired-config-hash"}
+
+			BeforeEach(func() {
+				specService.Spec = currentApplySpec
+			})
+
+			It("returns plan for current and desired spec without applying or saving spec", func() {
+				applier.PlanApplyPlan = boshappl.ApplyPlan{InstallJobs: []string{"fake-job/fake-version"}}
+
+				plan, err := action.Plan(desiredApplySpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(plan).To(Equal(boshappl.ApplyPlan{InstallJobs: []string{"fake-job/fake-version"}}))
+
+				Expect(applier.PlanApplyCurrentApplySpec).To(Equal(currentApplySpec))
+				Expect(applier.PlanApplyDesiredApplySpec).To(Equal(desiredApplySpec))
+				Expect(applier.Applied).To(BeFalse())
+				Expect(specService.Spec).To(Equal(currentApplySpec))
+			})
+
+			It("returns empty plan when desired spec does not have a configuration hash", func() {
+				plan, err := action.Plan(boshas.V1ApplySpec{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(plan).To(Equal(boshappl.ApplyPlan{
+					InstallJobs:     []string{},
+					RemoveJobs:      []string{},
+					InstallPackages: []string{},
+					RemovePackages:  []string{},
+				}))
+
+				Expect(applier.PlanApplyDesiredApplySpec).To(BeNil())
+			})
+
+			It("returns error when current spec cannot be retrieved", func() {
+				specService.GetErr = errors.New("fake-get-spec-err")
+
+				_, err := action.Plan(desiredApplySpec)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-get-spec-err"))
+			})
+
+			It("returns error when planning fails", func() {
+				applier.PlanApplyError = errors.New("fake-plan-apply-err")
+
+				_, err := action.Plan(desiredApplySpec)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-plan-apply-err"))
+			})
+		})
 	})
 }
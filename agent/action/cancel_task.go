@@ -4,7 +4,7 @@ import (
 	"errors"
 
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
-	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 )
 
 type CancelTaskAction struct {
@@ -31,7 +31,7 @@ func (a CancelTaskAction) IsLoggable() bool {
 func (a CancelTaskAction) Run(taskID string) (string, error) {
 	task, found := a.taskService.FindTaskWithID(taskID)
 	if !found {
-		return "", bosherr.Errorf("Task with id %s could not be found", taskID)
+		return "", boshhandler.NewErrorf(boshhandler.ErrorCodeTaskNotFound, "Task with id %s could not be found", taskID)
 	}
 
 	return "canceled", task.Cancel()
@@ -5,6 +5,7 @@ import (
 
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -30,7 +31,7 @@ func (a CompilePackageAction) IsLoggable() bool {
 	return true
 }
 
-func (a CompilePackageAction) Run(blobID string, multiDigest boshcrypto.MultipleDigest, name, version string, deps boshcomp.Dependencies) (val map[string]interface{}, err error) {
+func (a CompilePackageAction) Run(progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal, blobID string, multiDigest boshcrypto.MultipleDigest, name, version string, deps boshcomp.Dependencies) (val map[string]interface{}, err error) {
 	pkg := boshcomp.Package{
 		BlobstoreID: blobID,
 		Name:        name,
@@ -51,7 +52,7 @@ func (a CompilePackageAction) Run(blobID string, multiDigest boshcrypto.Multiple
 		})
 	}
 
-	uploadedBlobID, uploadedDigest, err := a.compiler.Compile(pkg, modelsDeps)
+	uploadedBlobID, uploadedDigest, err := a.compiler.Compile(pkg, modelsDeps, progress, cancelled)
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Compiling package %s", pkg.Name)
 		return
@@ -72,6 +73,7 @@ func (a CompilePackageAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel does nothing since Run stops once its cancel signal is closed
 func (a CompilePackageAction) Cancel() error {
-	return errors.New("not supported")
+	return nil
 }
@@ -11,6 +11,8 @@ import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
 	fakecomp "github.com/cloudfoundry/bosh-agent/agent/compiler/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 )
 
@@ -36,14 +38,21 @@ func getCompileActionArguments() (blobID string, multiDigest boshcrypto.Multiple
 	return
 }
 
+func runCompileAction(action CompilePackageAction, progress boshtask.ProgressReporter) (map[string]interface{}, error) {
+	blobID, multiDigest, name, version, deps := getCompileActionArguments()
+	return action.Run(progress, nil, blobID, multiDigest, name, version, deps)
+}
+
 var _ = Describe("CompilePackageAction", func() {
 	var (
 		compiler *fakecomp.FakeCompiler
+		progress *faketask.FakeProgressReporter
 		action   CompilePackageAction
 	)
 
 	BeforeEach(func() {
 		compiler = fakecomp.NewFakeCompiler()
+		progress = faketask.NewFakeProgressReporter()
 		action = NewCompilePackage(compiler)
 	})
 
@@ -51,7 +60,7 @@ var _ = Describe("CompilePackageAction", func() {
 	AssertActionIsNotPersistent(action)
 	AssertActionIsLoggable(action)
 
-	AssertActionIsNotCancelable(action)
+	AssertActionIsCancelledBySignal(action)
 	AssertActionIsNotResumable(action)
 
 	Describe("Run", func() {
@@ -111,20 +120,32 @@ var _ = Describe("CompilePackageAction", func() {
 				},
 			}
 
-			value, err := action.Run(getCompileActionArguments())
+			value, err := runCompileAction(action, progress)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(value).To(Equal(expectedValue))
 
 			Expect(compiler.CompilePkg).To(Equal(expectedPkg))
+			Expect(compiler.CompileProgress).To(Equal(progress))
 
 			// Using ConsistOf since package dependencies are specified as a hash (no order)
 			Expect(compiler.CompileDeps).To(ConsistOf(expectedDeps))
 		})
 
+		It("passes cancel signal to compiler", func() {
+			compiler.CompileDigest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "some checksum")
+
+			canceller := boshtask.NewCanceller()
+			blobID, multiDigest, name, version, deps := getCompileActionArguments()
+
+			_, err := action.Run(progress, canceller.Signal(), blobID, multiDigest, name, version, deps)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiler.CompileSignal).To(Equal(canceller.Signal()))
+		})
+
 		It("returns error when compile fails", func() {
 			compiler.CompileErr = errors.New("fake-compile-error")
 
-			_, err := action.Run(getCompileActionArguments())
+			_, err := runCompileAction(action, progress)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-compile-error"))
 		})
@@ -32,6 +32,7 @@ func NewFactory(
 	jobSupervisor boshjobsuper.JobSupervisor,
 	specService boshas.V1Service,
 	jobScriptProvider boshscript.JobScriptProvider,
+	adminSocket AdminSocket,
 	logger boshlog.Logger,
 ) (factory Factory) {
 	compressor := platform.GetCompressor()
@@ -41,53 +42,57 @@ func NewFactory(
 	certManager := platform.GetCertManager()
 	ntpService := boshntp.NewConcreteService(platform.GetFs(), dirProvider)
 
-	factory = concreteFactory{
-		availableActions: map[string]Action{
-			// Task management
-			"ping":        NewPing(),
-			"get_task":    NewGetTask(taskService),
-			"cancel_task": NewCancelTask(taskService),
-
-			// VM admin
-			"ssh":             NewSSH(settingsService, platform, dirProvider, logger),
-			"fetch_logs":      NewFetchLogs(compressor, copier, blobstore, dirProvider),
-			"update_settings": NewUpdateSettings(settingsService, platform, certManager, logger),
-
-			// Job management
-			"prepare":    NewPrepare(applier),
-			"apply":      NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), platform.GetFs()),
-			"start":      NewStart(jobSupervisor, applier, specService),
-			"stop":       NewStop(jobSupervisor),
-			"drain":      NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger),
-			"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService),
-			"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), platform.GetRunner(), logger),
-			"run_script": NewRunScript(jobScriptProvider, specService, logger),
-
-			// Compilation
-			"compile_package":    NewCompilePackage(compiler),
-			"release_apply_spec": NewReleaseApplySpec(platform),
-
-			// Rendered Templates
-			"upload_blob": NewUploadBlobAction(blobManager),
-
-			// Disk management
-			"list_disk":    NewListDisk(settingsService, platform, logger),
-			"migrate_disk": NewMigrateDisk(platform, dirProvider),
-			"mount_disk":   NewMountDisk(settingsService, platform, dirProvider, logger),
-			"unmount_disk": NewUnmountDisk(settingsService, platform),
-
-			// ARP cache management
-			"delete_arp_entries": NewDeleteARPEntries(platform),
-
-			// Networking
-			"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller()),
-			"prepare_configure_networks": NewPrepareConfigureNetworks(platform, settingsService),
-			"configure_networks":         NewConfigureNetworks(NewAgentKiller()),
-
-			// DNS
-			"sync_dns": NewSyncDNS(blobstore, settingsService, platform, logger),
-		},
+	availableActions := map[string]Action{
+		// Task management
+		"ping":        NewPing(),
+		"get_task":    NewGetTask(taskService),
+		"cancel_task": NewCancelTask(taskService),
+
+		// VM admin
+		"ssh":              NewSSH(settingsService, platform, dirProvider, logger),
+		"fetch_logs":       NewFetchLogs(compressor, copier, blobstore, dirProvider),
+		"update_settings":  NewUpdateSettings(settingsService, platform, certManager, logger),
+		"refresh_settings": NewRefreshSettings(settingsService),
+
+		// Job management
+		"prepare":    NewPrepare(applier),
+		"apply":      NewApply(applier, specService, settingsService, dirProvider.InstanceDir(), platform.GetFs()),
+		"start":      NewStart(jobSupervisor, applier, specService),
+		"stop":       NewStop(jobSupervisor),
+		"drain":      NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger),
+		"get_state":  NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService, taskService),
+		"run_errand": NewRunErrand(specService, dirProvider.JobsDir(), platform.GetRunner(), logger),
+		"run_script": NewRunScript(jobScriptProvider, specService, logger),
+
+		// Compilation
+		"compile_package":    NewCompilePackage(compiler),
+		"release_apply_spec": NewReleaseApplySpec(platform),
+
+		// Rendered Templates
+		"upload_blob": NewUploadBlobAction(blobManager),
+
+		// Disk management
+		"list_disk":    NewListDisk(settingsService, platform, logger),
+		"migrate_disk": NewMigrateDisk(platform, dirProvider),
+		"mount_disk":   NewMountDisk(settingsService, platform, dirProvider, logger),
+		"unmount_disk": NewUnmountDisk(settingsService, platform),
+
+		// ARP cache management
+		"delete_arp_entries": NewDeleteARPEntries(platform),
+
+		// Networking
+		"prepare_network_change":     NewPrepareNetworkChange(platform.GetFs(), settingsService, NewAgentKiller()),
+		"prepare_configure_networks": NewPrepareConfigureNetworks(platform, settingsService),
+		"configure_networks":         NewConfigureNetworks(NewAgentKiller()),
+
+		// DNS
+		"sync_dns": NewSyncDNS(blobstore, settingsService, platform, logger),
 	}
+
+	// Describes all actions including itself
+	availableActions["info"] = NewInfo(availableActions, platform.GetRunner(), settingsService, adminSocket)
+
+	factory = concreteFactory{availableActions: availableActions}
 	return
 }
 
@@ -68,6 +68,7 @@ var _ = Describe("concreteFactory", func() {
 			jobSupervisor,
 			specService,
 			jobScriptProvider,
+			nil,
 			logger,
 		)
 	})
@@ -113,7 +114,7 @@ var _ = Describe("concreteFactory", func() {
 		ntpService := boshntp.NewConcreteService(platform.GetFs(), platform.GetDirProvider())
 		action, err := factory.Create("get_state")
 		Expect(err).ToNot(HaveOccurred())
-		Expect(action).To(Equal(NewGetState(settingsService, specService, jobSupervisor, platform.GetVitalsService(), ntpService)))
+		Expect(action).To(Equal(NewGetState(settingsService, specService, jobSupervisor, platform.GetVitalsService(), ntpService, taskService)))
 	})
 
 	It("list_disk", func() {
@@ -134,6 +135,13 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewMountDisk(settingsService, platform, platform.GetDirProvider(), logger)))
 	})
 
+	It("info", func() {
+		action, err := factory.Create("info")
+		Expect(err).ToNot(HaveOccurred())
+		// Cannot do equality check since action refers to all available actions
+		Expect(action).To(BeAssignableToTypeOf(InfoAction{}))
+	})
+
 	It("ping", func() {
 		action, err := factory.Create("ping")
 		Expect(err).ToNot(HaveOccurred())
@@ -164,6 +172,12 @@ var _ = Describe("concreteFactory", func() {
 		Expect(action).To(Equal(NewSSH(settingsService, platform, platform.GetDirProvider(), logger)))
 	})
 
+	It("refresh_settings", func() {
+		action, err := factory.Create("refresh_settings")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(action).To(Equal(NewRefreshSettings(settingsService)))
+	})
+
 	It("start", func() {
 		action, err := factory.Create("start")
 		Expect(err).ToNot(HaveOccurred())
@@ -2,10 +2,12 @@ package action
 
 import (
 	"errors"
+	"fmt"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -62,7 +64,7 @@ func (a DrainAction) IsLoggable() bool {
 	return true
 }
 
-func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (int, error) {
+func (a DrainAction) Run(progress boshtask.ProgressReporter, drainType DrainType, newSpecs ...boshas.V1ApplySpec) (int, error) {
 	currentSpec, err := a.specService.Get()
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Getting current spec")
@@ -75,6 +77,8 @@ func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (i
 
 	a.logger.Debug(a.logTag, "Unmonitoring")
 
+	progress.Report(boshtask.Progress{Percent: 0, Phase: "unmonitoring"})
+
 	err = a.jobSupervisor.Unmonitor()
 	if err != nil {
 		return 0, bosherr.WrapError(err, "Unmonitoring services")
@@ -89,6 +93,12 @@ func (a DrainAction) Run(drainType DrainType, newSpecs ...boshas.V1ApplySpec) (i
 
 	script := a.jobScriptProvider.NewParallelScript("drain", scripts)
 
+	progress.Report(boshtask.Progress{
+		Percent: 10,
+		Phase:   "draining",
+		Message: fmt.Sprintf("Running drain scripts for %d jobs", len(scripts)),
+	})
+
 	resultsCh := make(chan error, 1)
 	go func() { resultsCh <- script.Run() }()
 	select {
@@ -14,6 +14,7 @@ import (
 	boshdrain "github.com/cloudfoundry/bosh-agent/agent/script/drain"
 	fakedrain "github.com/cloudfoundry/bosh-agent/agent/script/drain/fakes"
 	fakescript "github.com/cloudfoundry/bosh-agent/agent/script/fakes"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakenotif "github.com/cloudfoundry/bosh-agent/notification/fakes"
 	"github.com/cloudfoundry/bosh-utils/crypto"
@@ -27,6 +28,7 @@ var _ = Describe("DrainAction", func() {
 		jobScriptProvider *fakescript.FakeJobScriptProvider
 		fakeScripts       map[string]*fakedrain.FakeScript
 		jobSupervisor     *fakejobsuper.FakeJobSupervisor
+		progress          *faketask.FakeProgressReporter
 		action            DrainAction
 		logger            boshlog.Logger
 	)
@@ -38,6 +40,7 @@ var _ = Describe("DrainAction", func() {
 		specService = fakeas.NewFakeV1Service()
 		jobScriptProvider = &fakescript.FakeJobScriptProvider{}
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
+		progress = faketask.NewFakeProgressReporter()
 		action = NewDrain(notifier, specService, jobScriptProvider, jobSupervisor, logger)
 	})
 
@@ -90,7 +93,7 @@ var _ = Describe("DrainAction", func() {
 			})
 
 			act := func() (int, error) {
-				return action.Run(DrainTypeUpdate, newSpec)
+				return action.Run(progress, DrainTypeUpdate, newSpec)
 			}
 
 			Context("when current agent has a job spec template", func() {
@@ -115,6 +118,14 @@ var _ = Describe("DrainAction", func() {
 					Expect(jobSupervisor.Unmonitored).To(BeTrue())
 				})
 
+				It("reports unmonitoring and draining progress", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(progress.Phases()).To(Equal([]string{"unmonitoring", "draining"}))
+					Expect(progress.Reported[1].Message).To(Equal("Running drain scripts for 2 jobs"))
+				})
+
 				Context("when unmonitoring services succeeds", func() {
 					It("does not notify of job shutdown", func() {
 						value, err := act()
@@ -170,7 +181,7 @@ var _ = Describe("DrainAction", func() {
 
 					Context("when apply spec is not provided", func() {
 						It("returns error", func() {
-							value, err := action.Run(DrainTypeUpdate)
+							value, err := action.Run(progress, DrainTypeUpdate)
 							Expect(err).To(HaveOccurred())
 							Expect(err.Error()).To(ContainSubstring("Drain update requires new spec"))
 							Expect(value).To(Equal(0))
@@ -204,7 +215,7 @@ var _ = Describe("DrainAction", func() {
 		})
 
 		Context("when drain shutdown is requested", func() {
-			act := func() (int, error) { return action.Run(DrainTypeShutdown) }
+			act := func() (int, error) { return action.Run(progress, DrainTypeShutdown) }
 
 			Context("when current agent has a job spec template", func() {
 				var (
@@ -319,7 +330,7 @@ var _ = Describe("DrainAction", func() {
 		})
 
 		Context("when drain status is requested", func() {
-			act := func() (int, error) { return action.Run(DrainTypeStatus) }
+			act := func() (int, error) { return action.Run(progress, DrainTypeStatus) }
 
 			It("returns an error", func() {
 				value, err := act()
@@ -365,7 +376,7 @@ var _ = Describe("DrainAction", func() {
 
 		Context("when action was not canceled yet", func() {
 			It("cancel action", func() {
-				_, err := action.Run(DrainTypeShutdown, newSpec)
+				_, err := action.Run(progress, DrainTypeShutdown, newSpec)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = action.Cancel()
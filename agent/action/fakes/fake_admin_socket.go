@@ -0,0 +1,9 @@
+package fakes
+
+type FakeAdminSocket struct {
+	Running bool
+}
+
+func (s *FakeAdminSocket) IsRunning() bool {
+	return s.Running
+}
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeRunner struct {
@@ -10,22 +11,53 @@ type FakeRunner struct {
 	RunProtocolVersion boshaction.ProtocolVersion
 	RunValue           interface{}
 	RunErr             error
+	RunCallCount       int
+	RunProgress        boshtask.ProgressReporter
+	RunCancelSignal    boshtask.CancelSignal
+	RunStarted         chan struct{}
+	RunBlock           chan struct{}
 
 	ResumeAction  boshaction.Action
 	ResumePayload []byte
 	ResumeValue   interface{}
 	ResumeErr     error
+
+	PlanAction          boshaction.Action
+	PlanPayload         []byte
+	PlanProtocolVersion boshaction.ProtocolVersion
+	PlanValue           interface{}
+	PlanErr             error
 }
 
 func (runner *FakeRunner) Run(action boshaction.Action, payload []byte, version boshaction.ProtocolVersion) (interface{}, error) {
+	runner.RunCallCount++
 	runner.RunAction = action
 	runner.RunPayload = payload
 	runner.RunProtocolVersion = version
+	if runner.RunStarted != nil {
+		runner.RunStarted <- struct{}{}
+	}
+	if runner.RunBlock != nil {
+		<-runner.RunBlock
+	}
 	return runner.RunValue, runner.RunErr
 }
 
+func (runner *FakeRunner) RunTask(action boshaction.Action, payload []byte, version boshaction.ProtocolVersion, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (interface{}, error) {
+	runner.RunProgress = progress
+	runner.RunCancelSignal = cancelled
+	return runner.Run(action, payload, version)
+}
+
 func (runner *FakeRunner) Resume(action boshaction.Action, payload []byte) (interface{}, error) {
 	runner.ResumeAction = action
 	runner.ResumePayload = payload
 	return runner.ResumeValue, runner.ResumeErr
 }
+
+func (runner *FakeRunner) Plan(action boshaction.Action, payload []byte, version boshaction.ProtocolVersion) (interface{}, error) {
+	runner.PlanAction = action
+	runner.PlanPayload = payload
+	runner.PlanProtocolVersion = version
+	return runner.PlanValue, runner.PlanErr
+}
@@ -3,6 +3,9 @@ package action
 import (
 	"errors"
 
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -41,7 +44,7 @@ func (a FetchLogsAction) IsLoggable() bool {
 	return true
 }
 
-func (a FetchLogsAction) Run(logType string, filters []string) (value map[string]string, err error) {
+func (a FetchLogsAction) Run(progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal, logType string, filters []string) (value map[string]string, err error) {
 	var logsDir string
 
 	switch logType {
@@ -60,6 +63,8 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 		return
 	}
 
+	progress.Report(boshtask.Progress{Percent: 0, Phase: "copying", Message: logsDir})
+
 	tmpDir, err := a.copier.FilteredCopyToTemp(logsDir, filters)
 	if err != nil {
 		err = bosherr.WrapError(err, "Copying filtered files to temp directory")
@@ -68,6 +73,13 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 
 	defer a.copier.CleanUp(tmpDir)
 
+	err = cancelled.Check()
+	if err != nil {
+		return
+	}
+
+	progress.Report(boshtask.Progress{Percent: 40, Phase: "compressing"})
+
 	tarball, err := a.compressor.CompressFilesInDir(tmpDir)
 	if err != nil {
 		err = bosherr.WrapError(err, "Making logs tarball")
@@ -78,9 +90,20 @@ func (a FetchLogsAction) Run(logType string, filters []string) (value map[string
 		_ = a.compressor.CleanUp(tarball)
 	}()
 
-	blobID, _, err := a.blobstore.Create(tarball)
+	progress.Report(boshtask.Progress{Percent: 70, Phase: "uploading"})
+
+	err = cancelled.Check()
+	if err != nil {
+		return
+	}
+
+	blobID, _, err := boshagentblob.CreateUnlessCancelled(a.blobstore, tarball, cancelled)
+	if boshtask.IsCancelled(err) {
+		return
+	}
+
 	if err != nil {
-		err = bosherr.WrapError(err, "Create file on blobstore")
+		err = boshhandler.NewError(boshhandler.ErrorCodeBlobstoreUnavailable, bosherr.WrapError(err, "Create file on blobstore"))
 		return
 	}
 
@@ -92,6 +115,7 @@ func (a FetchLogsAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
 
+// Cancel does nothing since Run stops once its cancel signal is closed
 func (a FetchLogsAction) Cancel() error {
-	return errors.New("not supported")
+	return nil
 }
@@ -7,6 +7,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
@@ -20,6 +22,7 @@ var _ = Describe("FetchLogsAction", func() {
 		copier      *fakecmd.FakeCopier
 		blobstore   *fakeblobstore.FakeDigestBlobstore
 		dirProvider boshdirs.Provider
+		progress    *faketask.FakeProgressReporter
 		action      FetchLogsAction
 	)
 
@@ -28,6 +31,7 @@ var _ = Describe("FetchLogsAction", func() {
 		blobstore = &fakeblobstore.FakeDigestBlobstore{}
 		dirProvider = boshdirs.NewProvider("/fake/dir")
 		copier = fakecmd.NewFakeCopier()
+		progress = faketask.NewFakeProgressReporter()
 		action = NewFetchLogs(compressor, copier, blobstore, dirProvider)
 	})
 
@@ -36,7 +40,7 @@ var _ = Describe("FetchLogsAction", func() {
 	AssertActionIsLoggable(action)
 
 	AssertActionIsNotResumable(action)
-	AssertActionIsNotCancelable(action)
+	AssertActionIsCancelledBySignal(action)
 
 	Describe("Run", func() {
 		testLogs := func(logType string, filters []string, expectedFilters []string) {
@@ -46,7 +50,7 @@ var _ = Describe("FetchLogsAction", func() {
 				return "my-blob-id", boshcrypto.MultipleDigest{}, nil
 			}
 
-			logs, err := action.Run(logType, filters)
+			logs, err := action.Run(progress, nil, logType, filters)
 			Expect(err).ToNot(HaveOccurred())
 
 			var expectedPath string
@@ -66,10 +70,12 @@ var _ = Describe("FetchLogsAction", func() {
 			Expect(compressor.CompressFilesInDirTarballPath).To(Equal(blobstore.CreateArgsForCall(0)))
 
 			boshassert.MatchesJSONString(GinkgoT(), logs, `{"blobstore_id":"my-blob-id"}`)
+
+			Expect(progress.Phases()).To(Equal([]string{"copying", "compressing", "uploading"}))
 		}
 
 		It("logs errs if given invalid log type", func() {
-			_, err := action.Run("other-logs", []string{})
+			_, err := action.Run(progress, nil, "other-logs", []string{})
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -97,6 +103,19 @@ var _ = Describe("FetchLogsAction", func() {
 			testLogs("job", filters, expectedFilters)
 		})
 
+		It("does not compress or upload logs when cancelled", func() {
+			canceller := boshtask.NewCanceller()
+			canceller.Cancel()
+
+			_, err := action.Run(progress, canceller.Signal(), "job", []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(boshtask.IsCancelled(err)).To(BeTrue())
+
+			Expect(compressor.CompressFilesInDirDir).To(BeEmpty())
+			Expect(blobstore.CreateCallCount()).To(Equal(0))
+			Expect(copier.CleanUpTempDir).To(Equal(copier.FilteredCopyToTempTempDir))
+		})
+
 		It("cleans up compressed package after uploading it to blobstore", func() {
 			var beforeCleanUpTarballPath, afterCleanUpTarballPath string
 
@@ -108,7 +127,7 @@ var _ = Describe("FetchLogsAction", func() {
 				return "my-blob-id", boshcrypto.MultipleDigest{}, nil
 			}
 
-			_, err := action.Run("job", []string{})
+			_, err := action.Run(progress, nil, "job", []string{})
 			Expect(err).ToNot(HaveOccurred())
 
 			// Logs are not cleaned up before blobstore upload
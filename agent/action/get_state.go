@@ -4,6 +4,8 @@ import (
 	"errors"
 
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
@@ -17,6 +19,7 @@ type GetStateAction struct {
 	jobSupervisor   boshjobsuper.JobSupervisor
 	vitalsService   boshvitals.Service
 	ntpService      boshntp.Service
+	taskService     boshtask.Service
 }
 
 func NewGetState(
@@ -25,12 +28,14 @@ func NewGetState(
 	jobSupervisor boshjobsuper.JobSupervisor,
 	vitalsService boshvitals.Service,
 	ntpService boshntp.Service,
+	taskService boshtask.Service,
 ) (action GetStateAction) {
 	action.settingsService = settingsService
 	action.specService = specService
 	action.jobSupervisor = jobSupervisor
 	action.vitalsService = vitalsService
 	action.ntpService = ntpService
+	action.taskService = taskService
 	return
 }
 
@@ -56,6 +61,7 @@ type GetStateV1ApplySpec struct {
 	Processes    []boshjobsuper.Process `json:"processes,omitempty"`
 	VM           boshsettings.VM        `json:"vm"`
 	Ntp          boshntp.Info           `json:"ntp"`
+	Tasks        *boshtask.Stats        `json:"tasks,omitempty"`
 }
 
 func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
@@ -66,6 +72,7 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 
 	var vitals boshvitals.Vitals
 	var vitalsReference *boshvitals.Vitals
+	var tasksReference *boshtask.Stats
 
 	if len(filters) > 0 && filters[0] == "full" {
 		vitals, err = a.vitalsService.Get()
@@ -73,11 +80,14 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 			return GetStateV1ApplySpec{}, bosherr.WrapError(err, "Building full vitals")
 		}
 		vitalsReference = &vitals
+
+		tasks := a.taskService.Stats()
+		tasksReference = &tasks
 	}
 
 	processes, err := a.jobSupervisor.Processes()
 	if err != nil {
-		return GetStateV1ApplySpec{}, bosherr.WrapError(err, "Getting processes status")
+		return GetStateV1ApplySpec{}, boshhandler.NewError(boshhandler.ErrorCodeJobSupervisorUnavailable, bosherr.WrapError(err, "Getting processes status"))
 	}
 
 	settings := a.settingsService.GetSettings()
@@ -91,6 +101,7 @@ func (a GetStateAction) Run(filters ...string) (GetStateV1ApplySpec, error) {
 		processes,
 		settings.VM,
 		a.ntpService.GetInfo(),
+		tasksReference,
 	}
 
 	if value.NetworkSpecs == nil {
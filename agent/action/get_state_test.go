@@ -9,6 +9,8 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	fakeas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	boshntp "github.com/cloudfoundry/bosh-agent/platform/ntp"
@@ -26,6 +28,7 @@ var _ = Describe("GetState", func() {
 		specService     *fakeas.FakeV1Service
 		jobSupervisor   *fakejobsuper.FakeJobSupervisor
 		vitalsService   *fakevitals.FakeService
+		taskService     *faketask.FakeService
 		action          GetStateAction
 	)
 
@@ -34,13 +37,14 @@ var _ = Describe("GetState", func() {
 		jobSupervisor = fakejobsuper.NewFakeJobSupervisor()
 		specService = fakeas.NewFakeV1Service()
 		vitalsService = fakevitals.NewFakeService()
+		taskService = faketask.NewFakeService()
 		ntpService := &fakentp.FakeService{
 			GetOffsetNTPOffset: boshntp.Info{
 				Offset:    "0.34958",
 				Timestamp: "12 Oct 17:37:58",
 			},
 		}
-		action = NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService)
+		action = NewGetState(settingsService, specService, jobSupervisor, vitalsService, ntpService, taskService)
 	})
 
 	AssertActionIsNotAsynchronous(action)
@@ -87,6 +91,7 @@ var _ = Describe("GetState", func() {
 					Expect(state.JobState).To(Equal(expectedSpec.JobState))
 					Expect(state.Deployment).To(Equal(expectedSpec.Deployment))
 					boshassert.LacksJSONKey(GinkgoT(), state, "vitals")
+					boshassert.LacksJSONKey(GinkgoT(), state, "tasks")
 
 					Expect(state).To(Equal(expectedSpec))
 				})
@@ -140,6 +145,20 @@ var _ = Describe("GetState", func() {
 					boshassert.MatchesJSONMap(GinkgoT(), state.VM, expectedVM)
 				})
 
+				It("returns task queue depth in full format", func() {
+					taskService.StatsResult = boshtask.Stats{
+						Running:        1,
+						Queued:         2,
+						QueuedByAction: map[string]int{"compile_package": 2},
+					}
+
+					state, err := action.Run("full")
+					Expect(err).ToNot(HaveOccurred())
+
+					boshassert.MatchesJSONString(GinkgoT(), state.Tasks,
+						`{"running":1,"queued":2,"queued_by_action":{"compile_package":2}}`)
+				})
+
 				Describe("non-populated field formatting", func() {
 					It("returns network as empty hash if not set", func() {
 						specService.Spec = boshas.V1ApplySpec{NetworkSpecs: nil}
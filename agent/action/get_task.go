@@ -4,9 +4,18 @@ import (
 	"errors"
 
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+// QueuedTaskStateProtocolVersion is the first protocol version
+// in which clients are told that a task is queued.
+const QueuedTaskStateProtocolVersion = ProtocolVersion(4)
+
+// CancelledTaskStateProtocolVersion is the first protocol version
+// in which clients are told that a task is cancelled instead of failed.
+const CancelledTaskStateProtocolVersion = ProtocolVersion(5)
+
 type GetTaskAction struct {
 	taskService boshtask.Service
 }
@@ -28,19 +37,31 @@ func (a GetTaskAction) IsLoggable() bool {
 	return true
 }
 
-func (a GetTaskAction) Run(taskID string) (interface{}, error) {
+func (a GetTaskAction) Run(protocolVersion ProtocolVersion, taskID string) (interface{}, error) {
 	task, found := a.taskService.FindTaskWithID(taskID)
 	if !found {
-		return nil, bosherr.Errorf("Task with id %s could not be found", taskID)
+		return nil, boshhandler.NewErrorf(boshhandler.ErrorCodeTaskNotFound, "Task with id %s could not be found", taskID)
 	}
 
-	if task.State == boshtask.StateRunning {
+	if !task.State.IsFinished() {
+		state := task.State
+
+		// Older clients consider any state other than running to be final
+		if state == boshtask.StateQueued && protocolVersion < QueuedTaskStateProtocolVersion {
+			state = boshtask.StateRunning
+		}
+
 		return boshtask.StateValue{
 			AgentTaskID: task.ID,
-			State:       task.State,
+			State:       state,
+			Progress:    task.Progress,
 		}, nil
 	}
 
+	if task.State == boshtask.StateCancelled && protocolVersion >= CancelledTaskStateProtocolVersion {
+		return boshtask.StateValue{AgentTaskID: task.ID, State: task.State}, nil
+	}
+
 	if task.Error != nil {
 		return task.Value, bosherr.WrapErrorf(task.Error, "Task %s result", taskID)
 	}
@@ -9,6 +9,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 )
 
@@ -36,7 +37,7 @@ var _ = Describe("GetTask", func() {
 			State: boshtask.StateRunning,
 		}
 
-		taskValue, err := action.Run("fake-task-id")
+		taskValue, err := action.Run(ProtocolVersion(3), "fake-task-id")
 		Expect(err).ToNot(HaveOccurred())
 
 		// Check JSON key casing
@@ -44,6 +45,49 @@ var _ = Describe("GetTask", func() {
 			`{"agent_task_id":"fake-task-id","state":"running"}`)
 	})
 
+	It("returns progress of a running task", func() {
+		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+			ID:    "fake-task-id",
+			State: boshtask.StateRunning,
+			Progress: &boshtask.Progress{
+				Percent: 30,
+				Phase:   "compiling",
+				Message: "fake-message",
+			},
+		}
+
+		taskValue, err := action.Run(ProtocolVersion(3), "fake-task-id")
+		Expect(err).ToNot(HaveOccurred())
+
+		boshassert.MatchesJSONString(GinkgoT(), taskValue,
+			`{"agent_task_id":"fake-task-id","state":"running","progress":{"percent":30,"phase":"compiling","message":"fake-message"}}`)
+	})
+
+	Context("when task is queued", func() {
+		BeforeEach(func() {
+			taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+				ID:    "fake-task-id",
+				State: boshtask.StateQueued,
+			}
+		})
+
+		It("returns a queued task to clients that understand queued state", func() {
+			taskValue, err := action.Run(QueuedTaskStateProtocolVersion, "fake-task-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			boshassert.MatchesJSONString(GinkgoT(), taskValue,
+				`{"agent_task_id":"fake-task-id","state":"queued"}`)
+		})
+
+		It("returns a running task to older clients", func() {
+			taskValue, err := action.Run(ProtocolVersion(3), "fake-task-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			boshassert.MatchesJSONString(GinkgoT(), taskValue,
+				`{"agent_task_id":"fake-task-id","state":"running"}`)
+		})
+	})
+
 	It("returns a failed task", func() {
 		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
 			ID:    "fake-task-id",
@@ -51,12 +95,37 @@ var _ = Describe("GetTask", func() {
 			Error: errors.New("fake-task-error"),
 		}
 
-		taskValue, err := action.Run("fake-task-id")
+		taskValue, err := action.Run(ProtocolVersion(3), "fake-task-id")
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("Task fake-task-id result: fake-task-error"))
 		Expect(taskValue).To(BeNil())
 	})
 
+	Context("when task is cancelled", func() {
+		BeforeEach(func() {
+			taskService.StartedTasks["fake-task-id"] = boshtask.Task{
+				ID:    "fake-task-id",
+				State: boshtask.StateCancelled,
+				Error: boshtask.ErrCancelled,
+			}
+		})
+
+		It("returns a cancelled task to clients that understand cancelled state", func() {
+			taskValue, err := action.Run(CancelledTaskStateProtocolVersion, "fake-task-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			boshassert.MatchesJSONString(GinkgoT(), taskValue,
+				`{"agent_task_id":"fake-task-id","state":"cancelled"}`)
+		})
+
+		It("returns a failed task to older clients", func() {
+			taskValue, err := action.Run(QueuedTaskStateProtocolVersion, "fake-task-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Task fake-task-id result: Task was cancelled"))
+			Expect(taskValue).To(BeNil())
+		})
+	})
+
 	It("returns a successful task", func() {
 		taskService.StartedTasks["fake-task-id"] = boshtask.Task{
 			ID:    "fake-task-id",
@@ -64,7 +133,7 @@ var _ = Describe("GetTask", func() {
 			Value: "some-task-value",
 		}
 
-		taskValue, err := action.Run("fake-task-id")
+		taskValue, err := action.Run(ProtocolVersion(3), "fake-task-id")
 		Expect(err).ToNot(HaveOccurred())
 		Expect(taskValue).To(Equal("some-task-value"))
 	})
@@ -72,8 +141,12 @@ var _ = Describe("GetTask", func() {
 	It("returns error when task is not found", func() {
 		taskService.StartedTasks = map[string]boshtask.Task{}
 
-		_, err := action.Run("fake-task-id")
+		_, err := action.Run(ProtocolVersion(3), "fake-task-id")
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("Task with id fake-task-id could not be found"))
+
+		codedErr, found := boshhandler.FindError(err)
+		Expect(found).To(BeTrue())
+		Expect(codedErr.Code).To(Equal(boshhandler.ErrorCodeTaskNotFound))
 	})
 })
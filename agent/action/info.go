@@ -0,0 +1,254 @@
+package action
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	MinProtocolVersion = ProtocolVersion(0)
+	MaxProtocolVersion = CancelledTaskStateProtocolVersion
+)
+
+// externalBlobstoreProviders are only supported
+// when corresponding bosh-blobstore-<provider> executable is present.
+var externalBlobstoreProviders = []string{"dav", "s3", "gcs"}
+
+// builtInFeatures lists optional behaviour of the action dispatcher
+// and task service that every agent provides.
+var builtInFeatures = []string{
+	"request_id",
+	"task_journal",
+	"queued_tasks",
+	"task_progress",
+	"error_codes",
+	"batch",
+	"dry_run",
+}
+
+// actionFeatures are only provided when their action is available
+var actionFeatures = map[string]string{
+	"cancel_task":      "task_cancellation",
+	"refresh_settings": "refresh_settings",
+}
+
+// AdminSocket reports whether admin socket accepts requests
+type AdminSocket interface {
+	IsRunning() bool
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type InfoAction struct {
+	actions         map[string]Action
+	runner          boshsys.CmdRunner
+	settingsService boshsettings.Service
+	adminSocket     AdminSocket
+}
+
+type InfoResult struct {
+	Protocol           ProtocolRange `json:"protocol"`
+	Actions            []ActionInfo  `json:"actions"`
+	BlobstoreProviders []string      `json:"blobstore_providers"`
+	FilesystemTypes    []string      `json:"filesystem_types"`
+	Features           []string      `json:"features"`
+}
+
+type ProtocolRange struct {
+	Min ProtocolVersion `json:"min"`
+	Max ProtocolVersion `json:"max"`
+}
+
+type ActionInfo struct {
+	Name         string         `json:"name"`
+	Asynchronous bool           `json:"asynchronous"`
+	Arguments    []ArgumentInfo `json:"arguments"`
+}
+
+type ArgumentInfo struct {
+	// Type is the JSON type of the argument: string, number, boolean, array, object or any
+	Type     string `json:"type"`
+	GoType   string `json:"go_type"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// NewInfo describes given actions. Map is expected to be
+// the same map that actions are dispatched from so that info action is included.
+// Admin socket is not advertised when it is nil.
+func NewInfo(
+	actions map[string]Action,
+	runner boshsys.CmdRunner,
+	settingsService boshsettings.Service,
+	adminSocket AdminSocket,
+) InfoAction {
+	return InfoAction{
+		actions:         actions,
+		runner:          runner,
+		settingsService: settingsService,
+		adminSocket:     adminSocket,
+	}
+}
+
+func (a InfoAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return false
+}
+
+func (a InfoAction) IsPersistent() bool {
+	return false
+}
+
+func (a InfoAction) IsLoggable() bool {
+	return true
+}
+
+func (a InfoAction) Run(protocolVersion ProtocolVersion) (InfoResult, error) {
+	result := InfoResult{
+		Protocol: ProtocolRange{
+			Min: MinProtocolVersion,
+			Max: MaxProtocolVersion,
+		},
+		Actions:            []ActionInfo{},
+		BlobstoreProviders: a.blobstoreProviders(),
+		FilesystemTypes:    []string{string(boshdisk.FileSystemExt4), string(boshdisk.FileSystemXFS)},
+		Features:           a.features(),
+	}
+
+	for name, action := range a.actions {
+		result.Actions = append(result.Actions, ActionInfo{
+			Name:         name,
+			Asynchronous: action.IsAsynchronous(protocolVersion),
+			Arguments:    describeRunArguments(action),
+		})
+	}
+
+	sort.Sort(actionInfosByName(result.Actions))
+
+	return result, nil
+}
+
+func (a InfoAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a InfoAction) Cancel() error {
+	return errors.New("not supported")
+}
+
+// features only lists behaviour that is available with current settings and wiring
+func (a InfoAction) features() []string {
+	features := append([]string{}, builtInFeatures...)
+
+	for actionName, feature := range actionFeatures {
+		if _, found := a.actions[actionName]; found {
+			features = append(features, feature)
+		}
+	}
+
+	if a.settingsService.GetSettings().RequestSigning.IsEnabled() {
+		features = append(features, "request_signing")
+	}
+
+	if a.adminSocket != nil && a.adminSocket.IsRunning() {
+		features = append(features, "admin_socket")
+	}
+
+	sort.Strings(features)
+
+	return features
+}
+
+func (a InfoAction) blobstoreProviders() []string {
+	providers := []string{boshblob.BlobstoreTypeDummy, boshblob.BlobstoreTypeLocal}
+
+	for _, provider := range externalBlobstoreProviders {
+		if a.runner.CommandExists(fmt.Sprintf("bosh-blobstore-%s", provider)) {
+			providers = append(providers, provider)
+		}
+	}
+
+	return providers
+}
+
+// describeRunArguments lists arguments that have to be given
+// in request payload the same way concreteRunner extracts them.
+func describeRunArguments(action Action) []ArgumentInfo {
+	args := []ArgumentInfo{}
+
+	runMethodValue := reflect.ValueOf(action).MethodByName("Run")
+	if runMethodValue.Kind() != reflect.Func {
+		return args
+	}
+
+	runMethodType := runMethodValue.Type()
+	numberOfArgs := runMethodType.NumIn()
+
+	for i := numberOfInjectedArgs(runMethodType); i < numberOfArgs; i++ {
+		argType := runMethodType.In(i)
+		optional := false
+
+		if runMethodType.IsVariadic() && i == numberOfArgs-1 {
+			argType = argType.Elem()
+			optional = true
+		}
+
+		args = append(args, ArgumentInfo{
+			Type:     jsonTypeName(argType),
+			GoType:   argType.String(),
+			Optional: optional,
+		})
+	}
+
+	return args
+}
+
+func jsonTypeName(t reflect.Type) string {
+	if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return "any"
+	}
+
+	if t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	default:
+		return "any"
+	}
+}
+
+type actionInfosByName []ActionInfo
+
+func (s actionInfosByName) Len() int           { return len(s) }
+func (s actionInfosByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s actionInfosByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,165 @@
+package action_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshassert "github.com/cloudfoundry/bosh-utils/assert"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("InfoAction", func() {
+	var (
+		actions         map[string]Action
+		runner          *fakesys.FakeCmdRunner
+		settingsService *fakesettings.FakeSettingsService
+		adminSocket     *fakeaction.FakeAdminSocket
+		action          InfoAction
+	)
+
+	BeforeEach(func() {
+		actions = map[string]Action{
+			"fake-sync-action":  &actionWithProtocolVersion{},
+			"fake-async-action": &actionWithProgress{},
+			"fake-typed-action": &actionWithTypes{},
+		}
+		runner = fakesys.NewFakeCmdRunner()
+		settingsService = &fakesettings.FakeSettingsService{}
+		adminSocket = &fakeaction.FakeAdminSocket{}
+		action = NewInfo(actions, runner, settingsService, adminSocket)
+		actions["info"] = action
+	})
+
+	AssertActionIsNotAsynchronous(NewInfo(nil, nil, nil, nil))
+	AssertActionIsNotPersistent(NewInfo(nil, nil, nil, nil))
+	AssertActionIsLoggable(NewInfo(nil, nil, nil, nil))
+
+	AssertActionIsNotResumable(NewInfo(nil, nil, nil, nil))
+	AssertActionIsNotCancelable(NewInfo(nil, nil, nil, nil))
+
+	It("returns supported protocol range", func() {
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Protocol).To(Equal(ProtocolRange{Min: MinProtocolVersion, Max: MaxProtocolVersion}))
+	})
+
+	It("returns all actions sorted by name with arguments expected in payload", func() {
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(result.Actions).To(Equal([]ActionInfo{
+			{
+				Name:         "fake-async-action",
+				Asynchronous: true,
+				Arguments:    []ArgumentInfo{{Type: "string", GoType: "string"}},
+			},
+			{
+				Name:      "fake-sync-action",
+				Arguments: []ArgumentInfo{{Type: "string", GoType: "string"}},
+			},
+			{
+				Name:      "fake-typed-action",
+				Arguments: []ArgumentInfo{{Type: "object", GoType: "action_test.argumentWithTypes"}},
+			},
+			{
+				Name:      "info",
+				Arguments: []ArgumentInfo{},
+			},
+		}))
+	})
+
+	It("marks variadic arguments as optional", func() {
+		actions["fake-optional-action"] = &actionWithOptionalRunArgument{}
+
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(result.Actions[1]).To(Equal(ActionInfo{
+			Name: "fake-optional-action",
+			Arguments: []ArgumentInfo{
+				{Type: "string", GoType: "string"},
+				{Type: "object", GoType: "action_test.argsType", Optional: true},
+			},
+		}))
+	})
+
+	It("asks actions whether they are asynchronous for requested protocol version", func() {
+		testAction := &fakeaction.TestAction{}
+		actions["fake-test-action"] = testAction
+
+		_, err := action.Run(ProtocolVersion(3))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testAction.ProtocolVersion).To(Equal(ProtocolVersion(3)))
+	})
+
+	It("returns built-in blobstore providers and external ones that are installed", func() {
+		runner.AvailableCommands = map[string]bool{"bosh-blobstore-s3": true}
+
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.BlobstoreProviders).To(Equal([]string{"dummy", "local", "s3"}))
+	})
+
+	It("returns supported filesystem types and features", func() {
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.FilesystemTypes).To(Equal([]string{"ext4", "xfs"}))
+		Expect(result.Features).To(ConsistOf(
+			"request_id",
+			"task_journal",
+			"queued_tasks",
+			"task_progress",
+			"error_codes",
+			"batch",
+			"dry_run",
+		))
+	})
+
+	It("returns features of available actions", func() {
+		actions["cancel_task"] = &fakeaction.TestAction{}
+		actions["refresh_settings"] = &fakeaction.TestAction{}
+
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Features).To(ContainElement("task_cancellation"))
+		Expect(result.Features).To(ContainElement("refresh_settings"))
+	})
+
+	It("returns request signing feature only when request signing is configured", func() {
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Features).ToNot(ContainElement("request_signing"))
+
+		settingsService.Settings.RequestSigning = boshsettings.RequestSigning{
+			Keys: []boshsettings.RequestSigningKey{{ID: "fake-key-id", Algorithm: "hmac-sha256", Secret: "fake-secret"}},
+		}
+
+		result, err = action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Features).To(ContainElement("request_signing"))
+	})
+
+	It("returns admin socket feature only when admin socket is running", func() {
+		result, err := action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Features).ToNot(ContainElement("admin_socket"))
+
+		adminSocket.Running = true
+
+		result, err = action.Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Features).To(ContainElement("admin_socket"))
+	})
+
+	It("can be serialized to JSON", func() {
+		result, err := NewInfo(map[string]Action{}, runner, settingsService, nil).Run(ProtocolVersion(1))
+		Expect(err).ToNot(HaveOccurred())
+
+		boshassert.MatchesJSONString(GinkgoT(), result.Protocol, `{"min":0,"max":5}`)
+		boshassert.MatchesJSONString(GinkgoT(), ArgumentInfo{Type: "string", GoType: "string"}, `{"type":"string","go_type":"string"}`)
+	})
+})
@@ -42,10 +42,10 @@ func init() {
 			platform.MountedDevicePaths = []string{"/dev/sdb", "/dev/sdc"}
 
 			settingsService.Settings.Disks = boshsettings.Disks{
-				Persistent: map[string]interface{}{
-					"volume-1": "/dev/sda",
-					"volume-2": "/dev/sdb",
-					"volume-3": "/dev/sdc",
+				Persistent: map[string]boshsettings.DiskSettings{
+					"volume-1": {Path: "/dev/sda", VolumeID: "/dev/sda"},
+					"volume-2": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+					"volume-3": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
 				},
 			}
 
@@ -68,10 +68,10 @@ func init() {
 			It("should return an error", func() {
 				platform.MountedDevicePaths = []string{"/dev/sdb", "/dev/sdc"}
 				settingsService.Settings.Disks = boshsettings.Disks{
-					Persistent: map[string]interface{}{
-						"volume-1": "/dev/sda",
-						"volume-2": "/dev/sdb",
-						"volume-3": "/dev/sdc",
+					Persistent: map[string]boshsettings.DiskSettings{
+						"volume-1": {Path: "/dev/sda", VolumeID: "/dev/sda"},
+						"volume-2": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+						"volume-3": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
 					},
 				}
 
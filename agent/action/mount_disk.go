@@ -3,7 +3,9 @@ package action
 import (
 	"errors"
 
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -12,6 +14,7 @@ import (
 
 type diskMounter interface {
 	MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) error
+	PlanMountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (boshplatform.PersistentDiskMountPlan, error)
 }
 
 type MountDiskAction struct {
@@ -48,16 +51,9 @@ func (a MountDiskAction) IsLoggable() bool {
 }
 
 func (a MountDiskAction) Run(diskCid string) (interface{}, error) {
-	err := a.settingsService.LoadSettings()
+	diskSettings, err := a.diskSettings(diskCid)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Refreshing the settings")
-	}
-
-	settings := a.settingsService.GetSettings()
-
-	diskSettings, found := settings.PersistentDiskSettings(diskCid)
-	if !found {
-		return nil, bosherr.Errorf("Persistent disk with volume id '%s' could not be found", diskCid)
+		return nil, err
 	}
 
 	mountPoint := a.dirProvider.StoreDir()
@@ -70,6 +66,37 @@ func (a MountDiskAction) Run(diskCid string) (interface{}, error) {
 	return map[string]string{}, nil
 }
 
+// Plan returns partition and mount Run would create for the disk
+func (a MountDiskAction) Plan(diskCid string) (boshplatform.PersistentDiskMountPlan, error) {
+	diskSettings, err := a.diskSettings(diskCid)
+	if err != nil {
+		return boshplatform.PersistentDiskMountPlan{}, err
+	}
+
+	plan, err := a.diskMounter.PlanMountPersistentDisk(diskSettings, a.dirProvider.StoreDir())
+	if err != nil {
+		return plan, bosherr.WrapError(err, "Planning persistent disk mount")
+	}
+
+	return plan, nil
+}
+
+func (a MountDiskAction) diskSettings(diskCid string) (boshsettings.DiskSettings, error) {
+	err := a.settingsService.LoadSettings()
+	if err != nil {
+		return boshsettings.DiskSettings{}, bosherr.WrapError(err, "Refreshing the settings")
+	}
+
+	settings := a.settingsService.GetSettings()
+
+	diskSettings, found := settings.PersistentDiskSettings(diskCid)
+	if !found {
+		return boshsettings.DiskSettings{}, boshhandler.NewErrorf(boshhandler.ErrorCodeDiskNotFound, "Persistent disk with volume id '%s' could not be found", diskCid)
+	}
+
+	return diskSettings, nil
+}
+
 func (a MountDiskAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
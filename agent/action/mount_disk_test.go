@@ -7,6 +7,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -42,10 +44,10 @@ var _ = Describe("MountDiskAction", func() {
 		Context("when settings can be loaded", func() {
 			Context("when disk cid can be resolved to a device path from infrastructure settings", func() {
 				BeforeEach(func() {
-					settingsService.Settings.Disks.Persistent = map[string]interface{}{
-						"fake-disk-cid": map[string]interface{}{
-							"path":      "fake-device-path",
-							"volume_id": "fake-volume-id",
+					settingsService.Settings.Disks.Persistent = map[string]boshsettings.DiskSettings{
+						"fake-disk-cid": {
+							Path:     "fake-device-path",
+							VolumeID: "fake-volume-id",
 						},
 					}
 				})
@@ -78,8 +80,8 @@ var _ = Describe("MountDiskAction", func() {
 
 			Context("when disk cid cannot be resolved to a device path from infrastructure settings", func() {
 				BeforeEach(func() {
-					settingsService.Settings.Disks.Persistent = map[string]interface{}{
-						"fake-known-disk-cid": "/dev/sdf",
+					settingsService.Settings.Disks.Persistent = map[string]boshsettings.DiskSettings{
+						"fake-known-disk-cid": {Path: "/dev/sdf", VolumeID: "/dev/sdf"},
 					}
 				})
 
@@ -87,6 +89,10 @@ var _ = Describe("MountDiskAction", func() {
 					_, err := action.Run("fake-unknown-disk-cid")
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(Equal("Persistent disk with volume id 'fake-unknown-disk-cid' could not be found"))
+
+					codedErr, found := boshhandler.FindError(err)
+					Expect(found).To(BeTrue())
+					Expect(codedErr.Code).To(Equal(boshhandler.ErrorCodeDiskNotFound))
 				})
 			})
 		})
@@ -101,4 +107,48 @@ var _ = Describe("MountDiskAction", func() {
 			})
 		})
 	})
+
+	Describe("Plan", func() {
+		BeforeEach(func() {
+			settingsService.Settings.Disks.Persistent = map[string]boshsettings.DiskSettings{
+				"fake-disk-cid": {
+					Path:     "fake-device-path",
+					VolumeID: "fake-volume-id",
+				},
+			}
+		})
+
+		It("returns mount plan for store directory without mounting", func() {
+			platform.PlanMountPersistentDiskPlan = boshplatform.PersistentDiskMountPlan{
+				DevicePath:  "/dev/sdf",
+				MountedPath: "/dev/sdf1",
+				MountPoint:  "/fake-base-dir/store",
+			}
+
+			plan, err := action.Plan("fake-disk-cid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan).To(Equal(platform.PlanMountPersistentDiskPlan))
+
+			Expect(platform.PlanMountPersistentDiskSettings.ID).To(Equal("fake-disk-cid"))
+			Expect(platform.PlanMountPersistentDiskMountPoint).To(boshassert.MatchPath("/fake-base-dir/store"))
+			Expect(platform.MountPersistentDiskCalled).To(BeFalse())
+		})
+
+		It("returns error when planning fails", func() {
+			platform.PlanMountPersistentDiskErr = errors.New("fake-plan-err")
+
+			_, err := action.Plan("fake-disk-cid")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-plan-err"))
+		})
+
+		It("returns disk not found error when disk is unknown", func() {
+			_, err := action.Plan("fake-unknown-disk-cid")
+			Expect(err).To(HaveOccurred())
+
+			codedErr, found := boshhandler.FindError(err)
+			Expect(found).To(BeTrue())
+			Expect(codedErr.Code).To(Equal(boshhandler.ErrorCodeDiskNotFound))
+		})
+	})
 })
@@ -0,0 +1,168 @@
+package action
+
+import (
+	"encoding/json"
+	"strings"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// AnyAction allows caller to run every action without argument restrictions
+const AnyAction = "*"
+
+// Policy maps callers to actions they may run.
+// Policy without rules allows every caller to run every action.
+type Policy struct {
+	Rules []PolicyRule
+}
+
+// PolicyRule allows callers matching any of its identities to run its actions.
+// Actions of all rules matching a caller are allowed.
+type PolicyRule struct {
+	// SigningKeyID matches requests whose signature was verified with the key
+	SigningKeyID string
+
+	// e.g. director. matches requests asking for replies on director.<uuid>.
+	// Reply subjects are chosen by the sender so subject prefixes are advisory;
+	// use SigningKeyID when NATS or dial out transports are reachable by other clients.
+	NATSSubjectPrefix    string
+	DialOutSubjectPrefix string
+
+	HTTPSUser   string
+	MTLSSubject string
+
+	Actions []PolicyAction
+}
+
+type PolicyAction struct {
+	Name string
+
+	// Arguments optionally restricts positional string arguments to listed values,
+	// e.g. [["job"]] only allows fetch_logs of job logs. Empty list does not restrict argument.
+	Arguments [][]string
+}
+
+func (p Policy) IsEnabled() bool {
+	return len(p.Rules) > 0
+}
+
+// Validate makes sure rules identify callers and only refer to known actions
+func (p Policy) Validate(factory Factory) error {
+	for i, rule := range p.Rules {
+		if rule.SigningKeyID == "" && rule.NATSSubjectPrefix == "" && rule.DialOutSubjectPrefix == "" &&
+			rule.HTTPSUser == "" && rule.MTLSSubject == "" {
+			return bosherr.Errorf("Policy rule %d must specify SigningKeyID, NATSSubjectPrefix, DialOutSubjectPrefix, HTTPSUser or MTLSSubject", i)
+		}
+
+		for _, action := range rule.Actions {
+			if action.Name == AnyAction {
+				continue
+			}
+
+			_, err := factory.Create(action.Name)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Policy rule %d refers to unknown action '%s'", i, action.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Authorize returns action not allowed error unless caller may run method with payload arguments.
+// Admin socket callers are not restricted since admin socket only allows read-only actions.
+func (p Policy) Authorize(caller boshhandler.Caller, method string, payload []byte) error {
+	if !p.IsEnabled() || caller.Kind == boshhandler.CallerAdminSocket {
+		return nil
+	}
+
+	var actionFound bool
+
+	for _, rule := range p.Rules {
+		if !rule.matches(caller) {
+			continue
+		}
+
+		for _, action := range rule.Actions {
+			if action.Name != method && action.Name != AnyAction {
+				continue
+			}
+
+			actionFound = true
+
+			if action.allowsArguments(payload) {
+				return nil
+			}
+		}
+	}
+
+	if actionFound {
+		return boshhandler.NewErrorf(boshhandler.ErrorCodeActionNotAllowed,
+			"Caller %s '%s' is not allowed to run action '%s' with given arguments", caller.Kind, caller.Name, method)
+	}
+
+	return boshhandler.NewErrorf(boshhandler.ErrorCodeActionNotAllowed,
+		"Caller %s '%s' is not allowed to run action '%s'", caller.Kind, caller.Name, method)
+}
+
+func (r PolicyRule) matches(caller boshhandler.Caller) bool {
+	switch caller.Kind {
+	case boshhandler.CallerSigningKey:
+		return r.SigningKeyID != "" && r.SigningKeyID == caller.Name
+	case boshhandler.CallerNATS:
+		return r.NATSSubjectPrefix != "" && strings.HasPrefix(caller.Name, r.NATSSubjectPrefix)
+	case boshhandler.CallerDialOut:
+		return r.DialOutSubjectPrefix != "" && strings.HasPrefix(caller.Name, r.DialOutSubjectPrefix)
+	case boshhandler.CallerHTTPSUser:
+		return r.HTTPSUser != "" && r.HTTPSUser == caller.Name
+	case boshhandler.CallerMTLS:
+		return r.MTLSSubject != "" && r.MTLSSubject == caller.Name
+	}
+
+	return false
+}
+
+func (a PolicyAction) allowsArguments(payload []byte) bool {
+	if len(a.Arguments) == 0 {
+		return true
+	}
+
+	var request struct {
+		Arguments []json.RawMessage `json:"arguments"`
+	}
+
+	err := json.Unmarshal(payload, &request)
+	if err != nil {
+		return false
+	}
+
+	for i, allowedValues := range a.Arguments {
+		if len(allowedValues) == 0 {
+			continue
+		}
+
+		if i >= len(request.Arguments) {
+			return false
+		}
+
+		var value string
+
+		err = json.Unmarshal(request.Arguments[i], &value)
+		if err != nil || !containsString(allowedValues, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
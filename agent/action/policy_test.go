@@ -0,0 +1,161 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+
+	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+var _ = Describe("Policy", func() {
+	var (
+		policy Policy
+	)
+
+	natsCaller := boshhandler.Caller{Kind: boshhandler.CallerNATS, Name: "director.fake-director-id.fake-uuid"}
+	httpsCaller := boshhandler.Caller{Kind: boshhandler.CallerHTTPSUser, Name: "fake-user"}
+	mtlsCaller := boshhandler.Caller{Kind: boshhandler.CallerMTLS, Name: "fake-common-name"}
+
+	BeforeEach(func() {
+		policy = Policy{
+			Rules: []PolicyRule{
+				{
+					NATSSubjectPrefix: "director.",
+					Actions:           []PolicyAction{{Name: AnyAction}},
+				},
+				{
+					HTTPSUser: "fake-user",
+					Actions: []PolicyAction{
+						{Name: "get_state"},
+						{Name: "fetch_logs", Arguments: [][]string{{"job"}}},
+					},
+				},
+				{
+					MTLSSubject: "fake-common-name",
+					Actions:     []PolicyAction{{Name: "ping"}},
+				},
+			},
+		}
+	})
+
+	Describe("Authorize", func() {
+		It("allows every caller to run every action without rules", func() {
+			err := Policy{}.Authorize(boshhandler.Caller{}, "apply", []byte(`{}`))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("allows NATS callers whose reply subject starts with prefix", func() {
+			Expect(policy.Authorize(natsCaller, "apply", []byte(`{}`))).To(Succeed())
+
+			otherCaller := boshhandler.Caller{Kind: boshhandler.CallerNATS, Name: "hm.fake-uuid"}
+			Expect(policy.Authorize(otherCaller, "apply", []byte(`{}`))).ToNot(Succeed())
+		})
+
+		It("allows HTTPS users listed actions only", func() {
+			Expect(policy.Authorize(httpsCaller, "get_state", []byte(`{}`))).To(Succeed())
+
+			err := policy.Authorize(httpsCaller, "apply", []byte(`{}`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Caller https_user 'fake-user' is not allowed to run action 'apply'"))
+
+			codedErr, found := boshhandler.FindError(err)
+			Expect(found).To(BeTrue())
+			Expect(codedErr.Code).To(Equal(boshhandler.ErrorCodeActionNotAllowed))
+		})
+
+		It("restricts arguments of actions", func() {
+			Expect(policy.Authorize(httpsCaller, "fetch_logs", []byte(`{"arguments":["job",["fake-filter"]]}`))).To(Succeed())
+
+			err := policy.Authorize(httpsCaller, "fetch_logs", []byte(`{"arguments":["agent",["fake-filter"]]}`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Caller https_user 'fake-user' is not allowed to run action 'fetch_logs' with given arguments"))
+
+			Expect(policy.Authorize(httpsCaller, "fetch_logs", []byte(`{"arguments":[]}`))).ToNot(Succeed())
+			Expect(policy.Authorize(httpsCaller, "fetch_logs", []byte(`{"arguments":[["job"]]}`))).ToNot(Succeed())
+			Expect(policy.Authorize(httpsCaller, "fetch_logs", []byte(`not-json`))).ToNot(Succeed())
+		})
+
+		It("matches mTLS subjects exactly", func() {
+			Expect(policy.Authorize(mtlsCaller, "ping", []byte(`{}`))).To(Succeed())
+
+			otherCaller := boshhandler.Caller{Kind: boshhandler.CallerMTLS, Name: "fake-common-name-other"}
+			Expect(policy.Authorize(otherCaller, "ping", []byte(`{}`))).ToNot(Succeed())
+		})
+
+		It("matches signing key IDs exactly", func() {
+			policy.Rules = append(policy.Rules, PolicyRule{
+				SigningKeyID: "fake-key-id",
+				Actions:      []PolicyAction{{Name: "apply"}},
+			})
+
+			caller := boshhandler.Caller{Kind: boshhandler.CallerSigningKey, Name: "fake-key-id"}
+			Expect(policy.Authorize(caller, "apply", []byte(`{}`))).To(Succeed())
+
+			otherCaller := boshhandler.Caller{Kind: boshhandler.CallerSigningKey, Name: "fake-other-key-id"}
+			Expect(policy.Authorize(otherCaller, "apply", []byte(`{}`))).ToNot(Succeed())
+		})
+
+		It("does not apply NATS subject prefixes to dial out callers", func() {
+			caller := boshhandler.Caller{Kind: boshhandler.CallerDialOut, Name: "director.fake-uuid"}
+			Expect(policy.Authorize(caller, "apply", []byte(`{}`))).ToNot(Succeed())
+
+			policy.Rules = append(policy.Rules, PolicyRule{
+				DialOutSubjectPrefix: "director.",
+				Actions:              []PolicyAction{{Name: "apply"}},
+			})
+			Expect(policy.Authorize(caller, "apply", []byte(`{}`))).To(Succeed())
+		})
+
+		It("does not match identities of other caller kinds", func() {
+			caller := boshhandler.Caller{Kind: boshhandler.CallerMTLS, Name: "fake-user"}
+			Expect(policy.Authorize(caller, "get_state", []byte(`{}`))).ToNot(Succeed())
+		})
+
+		It("denies callers without identity", func() {
+			Expect(policy.Authorize(boshhandler.Caller{}, "ping", []byte(`{}`))).ToNot(Succeed())
+		})
+
+		It("allows admin socket callers", func() {
+			caller := boshhandler.Caller{Kind: boshhandler.CallerAdminSocket, Name: "/fake-socket"}
+			Expect(policy.Authorize(caller, "get_state", []byte(`{}`))).To(Succeed())
+		})
+	})
+
+	Describe("Validate", func() {
+		var (
+			factory *fakeaction.FakeFactory
+		)
+
+		BeforeEach(func() {
+			factory = fakeaction.NewFakeFactory()
+			factory.RegisterAction("get_state", &fakeaction.TestAction{})
+			factory.RegisterAction("fetch_logs", &fakeaction.TestAction{})
+			factory.RegisterAction("ping", &fakeaction.TestAction{})
+		})
+
+		It("succeeds when rules refer to known actions", func() {
+			Expect(policy.Validate(factory)).To(Succeed())
+		})
+
+		It("returns error when rule refers to unknown action", func() {
+			factory.RegisterActionErr("ping", errors.New("fake-create-err"))
+
+			err := policy.Validate(factory)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Policy rule 2 refers to unknown action 'ping'"))
+		})
+
+		It("returns error when rule does not identify callers", func() {
+			policy.Rules = append(policy.Rules, PolicyRule{Actions: []PolicyAction{{Name: "ping"}}})
+
+			err := policy.Validate(factory)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Policy rule 3 must specify"))
+		})
+	})
+})
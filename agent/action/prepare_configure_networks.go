@@ -23,6 +23,11 @@ func NewPrepareConfigureNetworks(
 	}
 }
 
+// NetworkingPlan lists network configuration files agent would rewrite
+type NetworkingPlan struct {
+	ChangedFiles []string `json:"changed_files"`
+}
+
 func (a PrepareConfigureNetworksAction) IsAsynchronous(_ ProtocolVersion) bool {
 	return false
 }
@@ -49,6 +54,22 @@ func (a PrepareConfigureNetworksAction) Run() (string, error) {
 	return "ok", nil
 }
 
+// Plan compares configuration agent writes once it is restarted
+// with networks from refreshed settings against current files.
+func (a PrepareConfigureNetworksAction) Plan() (NetworkingPlan, error) {
+	err := a.settingsService.LoadSettings()
+	if err != nil {
+		return NetworkingPlan{}, bosherr.WrapError(err, "Refreshing the settings")
+	}
+
+	changedFiles, err := a.platform.PlanNetworking(a.settingsService.GetSettings().Networks)
+	if err != nil {
+		return NetworkingPlan{}, bosherr.WrapError(err, "Planning networking")
+	}
+
+	return NetworkingPlan{ChangedFiles: changedFiles}, nil
+}
+
 func (a PrepareConfigureNetworksAction) Resume() (interface{}, error) {
 	return nil, errors.New("not supported")
 }
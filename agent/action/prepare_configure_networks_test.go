@@ -8,6 +8,7 @@ import (
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
 )
 
@@ -81,4 +82,42 @@ var _ = Describe("prepareConfigureNetworks", func() {
 			})
 		})
 	})
+
+	Describe("Plan", func() {
+		It("returns network configuration files platform would rewrite for refreshed settings", func() {
+			settingsService.Settings.Networks = boshsettings.Networks{"fake-net": boshsettings.Network{IP: "10.0.0.2"}}
+			platform.PlanNetworkingPaths = []string{"/etc/network/interfaces"}
+
+			plan, err := action.Plan()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan).To(Equal(NetworkingPlan{ChangedFiles: []string{"/etc/network/interfaces"}}))
+
+			Expect(settingsService.SettingsWereLoaded).To(BeTrue())
+			Expect(platform.PlanNetworkingNetworks).To(Equal(settingsService.Settings.Networks))
+		})
+
+		It("does not invalidate settings or prepare platform for networking change", func() {
+			_, err := action.Plan()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(settingsService.SettingsWereInvalidated).To(BeFalse())
+			Expect(platform.PrepareForNetworkingChangeCalled).To(BeFalse())
+		})
+
+		It("returns error if loading settings fails", func() {
+			settingsService.LoadSettingsError = errors.New("fake-load-error")
+
+			_, err := action.Plan()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-load-error"))
+		})
+
+		It("returns error if planning networking fails", func() {
+			platform.PlanNetworkingErr = errors.New("fake-plan-error")
+
+			_, err := action.Plan()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-plan-error"))
+		})
+	})
 })
@@ -0,0 +1,50 @@
+package action
+
+import (
+	"errors"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+// RefreshSettingsAction re-fetches settings from settings source
+// so that changed settings are applied without restarting agent
+type RefreshSettingsAction struct {
+	settingsService boshsettings.Service
+}
+
+type RefreshSettingsResult struct {
+	Changes boshsettings.Changes `json:"changes"`
+}
+
+func NewRefreshSettings(settingsService boshsettings.Service) RefreshSettingsAction {
+	return RefreshSettingsAction{settingsService: settingsService}
+}
+
+func (a RefreshSettingsAction) IsAsynchronous(_ ProtocolVersion) bool {
+	return true
+}
+
+func (a RefreshSettingsAction) IsPersistent() bool {
+	return false
+}
+
+func (a RefreshSettingsAction) IsLoggable() bool {
+	return true
+}
+
+func (a RefreshSettingsAction) Run() (RefreshSettingsResult, error) {
+	changes, err := a.settingsService.RefreshSettings()
+	if err != nil {
+		return RefreshSettingsResult{}, err
+	}
+
+	return RefreshSettingsResult{Changes: changes}, nil
+}
+
+func (a RefreshSettingsAction) Resume() (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a RefreshSettingsAction) Cancel() error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,50 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+)
+
+func init() {
+	Describe("RefreshSettings", func() {
+		var (
+			settingsService *fakesettings.FakeSettingsService
+			action          RefreshSettingsAction
+		)
+
+		BeforeEach(func() {
+			settingsService = &fakesettings.FakeSettingsService{}
+			action = NewRefreshSettings(settingsService)
+		})
+
+		AssertActionIsAsynchronous(action)
+		AssertActionIsNotPersistent(action)
+		AssertActionIsLoggable(action)
+
+		AssertActionIsNotCancelable(action)
+		AssertActionIsNotResumable(action)
+
+		It("returns fields that changed", func() {
+			settingsService.RefreshSettingsChanges = boshsettings.Changes{boshsettings.FieldNtp}
+
+			result, err := action.Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(RefreshSettingsResult{Changes: boshsettings.Changes{boshsettings.FieldNtp}}))
+			Expect(settingsService.RefreshSettingsCallCount()).To(Equal(1))
+		})
+
+		It("returns error when settings cannot be refreshed", func() {
+			settingsService.RefreshSettingsErr = errors.New("fake-refresh-err")
+
+			_, err := action.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-refresh-err"))
+		})
+	})
+}
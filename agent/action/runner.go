@@ -5,14 +5,32 @@ import (
 	"encoding/json"
 	"reflect"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type Runner interface {
 	Run(action Action, payload []byte, protocolVersion ProtocolVersion) (value interface{}, err error)
+
+	// RunTask passes progress reporter and cancel signal of a task to actions
+	// whose Run method accepts boshtask.ProgressReporter and/or boshtask.CancelSignal
+	// as its first arguments (after optional ProtocolVersion).
+	RunTask(action Action, payload []byte, protocolVersion ProtocolVersion, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (value interface{}, err error)
+
 	Resume(action Action, payload []byte) (value interface{}, err error)
+
+	// Plan calls Plan method of actions that support dry runs.
+	// Plan method accepts the same arguments as Run method
+	// and describes changes Run would make without making them.
+	Plan(action Action, payload []byte, protocolVersion ProtocolVersion) (value interface{}, err error)
 }
 
+var (
+	progressReporterType = reflect.TypeOf((*boshtask.ProgressReporter)(nil)).Elem()
+	cancelSignalType     = reflect.TypeOf(boshtask.CancelSignal(nil))
+)
+
 func NewRunner() Runner {
 	return concreteRunner{}
 }
@@ -20,28 +38,45 @@ func NewRunner() Runner {
 type concreteRunner struct{}
 
 func (r concreteRunner) Run(action Action, payloadBytes []byte, protocolVersion ProtocolVersion) (value interface{}, err error) {
+	return r.RunTask(action, payloadBytes, protocolVersion, boshtask.NewNopProgressReporter(), nil)
+}
+
+func (r concreteRunner) RunTask(action Action, payloadBytes []byte, protocolVersion ProtocolVersion, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (value interface{}, err error) {
+	return r.call(action, "Run", payloadBytes, protocolVersion, progress, cancelled)
+}
+
+func (r concreteRunner) Plan(action Action, payloadBytes []byte, protocolVersion ProtocolVersion) (value interface{}, err error) {
+	if reflect.ValueOf(action).MethodByName("Plan").Kind() != reflect.Func {
+		err = boshhandler.NewErrorf(boshhandler.ErrorCodeInvalidArguments, "Action does not support dry run")
+		return
+	}
+
+	return r.call(action, "Plan", payloadBytes, protocolVersion, boshtask.NewNopProgressReporter(), nil)
+}
+
+func (r concreteRunner) call(action Action, methodName string, payloadBytes []byte, protocolVersion ProtocolVersion, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (value interface{}, err error) {
 	payloadArgs, err := r.extractJSONArguments(payloadBytes)
 	if err != nil {
-		err = bosherr.WrapError(err, "Extracting json arguments")
+		err = boshhandler.NewError(boshhandler.ErrorCodeInvalidArguments, bosherr.WrapError(err, "Extracting json arguments"))
 		return
 	}
 
 	actionValue := reflect.ValueOf(action)
-	runMethodValue := actionValue.MethodByName("Run")
+	runMethodValue := actionValue.MethodByName(methodName)
 	if runMethodValue.Kind() != reflect.Func {
-		err = bosherr.Error("Run method not found")
+		err = bosherr.Errorf("%s method not found", methodName)
 		return
 	}
 
 	runMethodType := runMethodValue.Type()
 	if r.invalidReturnTypes(runMethodType) {
-		err = bosherr.Error("Run method should return a value and an error")
+		err = bosherr.Errorf("%s method should return a value and an error", methodName)
 		return
 	}
 
-	methodArgs, err := r.extractMethodArgs(runMethodType, protocolVersion, payloadArgs)
+	methodArgs, err := r.extractMethodArgs(runMethodType, protocolVersion, progress, cancelled, payloadArgs)
 	if err != nil {
-		err = bosherr.WrapError(err, "Extracting method arguments from payload")
+		err = boshhandler.NewError(boshhandler.ErrorCodeInvalidArguments, bosherr.WrapError(err, "Extracting method arguments from payload"))
 		return
 	}
 
@@ -88,7 +123,7 @@ func (r concreteRunner) invalidReturnTypes(methodType reflect.Type) (valid bool)
 	return
 }
 
-func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, protocolVersion ProtocolVersion, args []interface{}) (methodArgs []reflect.Value, err error) {
+func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, protocolVersion ProtocolVersion, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal, args []interface{}) (methodArgs []reflect.Value, err error) {
 	numberOfArgs := runMethodType.NumIn()
 	numberOfReqArgs := numberOfArgs
 
@@ -96,16 +131,18 @@ func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, protocolVe
 		numberOfReqArgs--
 	}
 
-	argsOffset := 0
-
-	if numberOfArgs > 0 {
-		firstArgType := runMethodType.In(0)
+	argsOffset := numberOfInjectedArgs(runMethodType)
 
-		if firstArgType.Name() == "ProtocolVersion" {
+	for i := 0; i < argsOffset; i++ {
+		switch runMethodType.In(i) {
+		case progressReporterType:
+			methodArgs = append(methodArgs, reflect.ValueOf(&progress).Elem())
+		case cancelSignalType:
+			methodArgs = append(methodArgs, reflect.ValueOf(cancelled))
+		default:
 			methodArgs = append(methodArgs, reflect.ValueOf(protocolVersion))
-			numberOfReqArgs--
-			argsOffset++
 		}
+		numberOfReqArgs--
 	}
 
 	if len(args) < numberOfReqArgs {
@@ -140,6 +177,27 @@ func (r concreteRunner) extractMethodArgs(runMethodType reflect.Type, protocolVe
 	return
 }
 
+// numberOfInjectedArgs returns the number of leading Run method arguments
+// that are provided by the runner instead of the request payload.
+func numberOfInjectedArgs(runMethodType reflect.Type) int {
+	numberOfArgs := runMethodType.NumIn()
+	argsOffset := 0
+
+	if numberOfArgs > argsOffset && runMethodType.In(argsOffset).Name() == "ProtocolVersion" {
+		argsOffset++
+	}
+
+	if numberOfArgs > argsOffset && runMethodType.In(argsOffset) == progressReporterType {
+		argsOffset++
+	}
+
+	if numberOfArgs > argsOffset && runMethodType.In(argsOffset) == cancelSignalType {
+		argsOffset++
+	}
+
+	return argsOffset
+}
+
 func (r concreteRunner) getMethodArgType(methodType reflect.Type, index int) (argType reflect.Type, found bool) {
 	numberOfArgs := methodType.NumIn()
 
@@ -159,8 +217,8 @@ func (r concreteRunner) getMethodArgType(methodType reflect.Type, index int) (ar
 func (r concreteRunner) extractReturns(values []reflect.Value) (value interface{}, err error) {
 	errValue := values[1]
 	if !errValue.IsNil() {
-		errorValues := errValue.MethodByName("Error").Call([]reflect.Value{})
-		err = bosherr.Error(errorValues[0].String())
+		// Keep original error so that error codes reach the response
+		err = errValue.Interface().(error)
 	}
 
 	value = values[0].Interface()
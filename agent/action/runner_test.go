@@ -10,6 +10,8 @@ import (
 
 	. "github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 )
 
 type valueType struct {
@@ -248,6 +250,65 @@ func (a *actionWithProtocolVersion) Cancel() error {
 	return nil
 }
 
+type actionWithProgress struct {
+	ProtocolVersion ProtocolVersion
+	SubAction       string
+}
+
+func (a *actionWithProgress) IsAsynchronous(_ ProtocolVersion) bool {
+	return true
+}
+
+func (a *actionWithProgress) IsPersistent() bool {
+	return false
+}
+
+func (a *actionWithProgress) IsLoggable() bool {
+	return true
+}
+
+func (a *actionWithProgress) Run(protocolVersion ProtocolVersion, progress boshtask.ProgressReporter, subAction string) (valueType, error) {
+	a.ProtocolVersion = protocolVersion
+	a.SubAction = subAction
+
+	progress.Report(boshtask.Progress{Percent: 50, Phase: "fake-phase"})
+
+	return valueType{}, nil
+}
+
+func (a *actionWithProgress) Resume() (interface{}, error) {
+	return nil, nil
+}
+
+func (a *actionWithProgress) Cancel() error {
+	return nil
+}
+
+type actionWithCancelSignal struct {
+	actionWithProgress
+
+	Cancelled boshtask.CancelSignal
+}
+
+func (a *actionWithCancelSignal) Run(progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal, subAction string) (valueType, error) {
+	a.Cancelled = cancelled
+	a.SubAction = subAction
+	return valueType{}, nil
+}
+
+type actionWithPlan struct {
+	actionWithProgress
+
+	Planned     bool
+	PlanSubject string
+}
+
+func (a *actionWithPlan) Plan(subject string) (valueType, error) {
+	a.Planned = true
+	a.PlanSubject = subject
+	return valueType{ID: 7}, nil
+}
+
 func init() {
 	Describe("concreteRunner", func() {
 		It("runner run parses the payload", func() {
@@ -425,5 +486,94 @@ func init() {
 			Expect(action.ProtocolVersion).To(Equal(ProtocolVersion(1)))
 			Expect(action.SubAction).To(Equal("setup"))
 		})
+
+		It("passes progress reporter to run method", func() {
+			runner := NewRunner()
+			progress := faketask.NewFakeProgressReporter()
+
+			action := &actionWithProgress{}
+			payload := `{"arguments":["setup"]}`
+
+			_, err := runner.RunTask(action, []byte(payload), 2, progress, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(action.ProtocolVersion).To(Equal(ProtocolVersion(2)))
+			Expect(action.SubAction).To(Equal("setup"))
+			Expect(progress.Phases()).To(Equal([]string{"fake-phase"}))
+		})
+
+		It("passes no-op progress reporter to run method when running without progress", func() {
+			runner := NewRunner()
+
+			action := &actionWithProgress{}
+			payload := `{"arguments":["setup"]}`
+
+			_, err := runner.Run(action, []byte(payload), 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(action.SubAction).To(Equal("setup"))
+		})
+
+		It("passes cancel signal of task to run method", func() {
+			runner := NewRunner()
+			canceller := boshtask.NewCanceller()
+
+			action := &actionWithCancelSignal{}
+			payload := `{"arguments":["setup"]}`
+
+			_, err := runner.RunTask(action, []byte(payload), 2, faketask.NewFakeProgressReporter(), canceller.Signal())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(action.Cancelled).To(Equal(canceller.Signal()))
+			Expect(action.SubAction).To(Equal("setup"))
+		})
+
+		It("passes nil cancel signal to run method when running without task", func() {
+			runner := NewRunner()
+
+			action := &actionWithCancelSignal{}
+			payload := `{"arguments":["setup"]}`
+
+			_, err := runner.Run(action, []byte(payload), 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(action.Cancelled).To(BeNil())
+			Expect(action.SubAction).To(Equal("setup"))
+		})
+
+		It("does not count progress reporter as a payload argument", func() {
+			runner := NewRunner()
+
+			action := &actionWithProgress{}
+			payload := `{"arguments":[]}`
+
+			_, err := runner.Run(action, []byte(payload), 1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Not enough arguments"))
+		})
+
+		Describe("Plan", func() {
+			It("calls Plan method with payload arguments instead of Run", func() {
+				runner := NewRunner()
+
+				action := &actionWithPlan{}
+
+				value, err := runner.Plan(action, []byte(`{"arguments":["setup"]}`), 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(valueType{ID: 7}))
+
+				Expect(action.Planned).To(BeTrue())
+				Expect(action.PlanSubject).To(Equal("setup"))
+				Expect(action.SubAction).To(BeEmpty())
+			})
+
+			It("returns invalid arguments error when action does not support dry run", func() {
+				runner := NewRunner()
+
+				_, err := runner.Plan(&actionWithProgress{}, []byte(`{"arguments":["setup"]}`), 1)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Action does not support dry run"))
+			})
+		})
 	})
 }
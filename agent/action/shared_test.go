@@ -62,6 +62,13 @@ func AssertActionIsNotCancelable(action Action) {
 	})
 }
 
+func AssertActionIsCancelledBySignal(action Action) {
+	It("can be cancelled through cancel signal of its task", func() {
+		err := action.Cancel()
+		Expect(err).ToNot(HaveOccurred())
+	})
+}
+
 func AssertActionIsResumable(action Action) {
 	It("can be resumed", func() {
 		value, err := action.Resume()
@@ -5,6 +5,7 @@ import (
 
 	boshappl "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -51,7 +52,7 @@ func (a StartAction) Run() (value string, err error) {
 
 	err = a.jobSupervisor.Start()
 	if err != nil {
-		err = bosherr.WrapError(err, "Starting Monitored Services")
+		err = boshhandler.NewError(boshhandler.ErrorCodeJobSupervisorUnavailable, bosherr.WrapError(err, "Starting Monitored Services"))
 		return
 	}
 
@@ -3,6 +3,7 @@ package action
 import (
 	"errors"
 
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -38,7 +39,7 @@ func (a StopAction) Run(protocolVersion ProtocolVersion) (value string, err erro
 	}
 
 	if err != nil {
-		err = bosherr.WrapError(err, "Stopping Monitored Services")
+		err = boshhandler.NewError(boshhandler.ErrorCodeJobSupervisorUnavailable, bosherr.WrapError(err, "Stopping Monitored Services"))
 		return
 	}
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -40,7 +41,7 @@ func (a UnmountDiskAction) Run(diskID string) (value interface{}, err error) {
 
 	diskSettings, found := settings.PersistentDiskSettings(diskID)
 	if !found {
-		err = bosherr.Errorf("Persistent disk with volume id '%s' could not be found", diskID)
+		err = boshhandler.NewErrorf(boshhandler.ErrorCodeDiskNotFound, "Persistent disk with volume id '%s' could not be found", diskID)
 		return
 	}
 
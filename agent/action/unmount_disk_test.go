@@ -26,12 +26,12 @@ var _ = Describe("UnmountDiskAction", func() {
 		settingsService := &fakesettings.FakeSettingsService{
 			Settings: boshsettings.Settings{
 				Disks: boshsettings.Disks{
-					Persistent: map[string]interface{}{
-						"vol-123": map[string]interface{}{
-							"volume_id":      "2",
-							"path":           "/dev/sdf",
-							"lun":            "0",
-							"host_device_id": "fake-host-device-id",
+					Persistent: map[string]boshsettings.DiskSettings{
+						"vol-123": {
+							VolumeID:     "2",
+							Path:         "/dev/sdf",
+							Lun:          "0",
+							HostDeviceID: "fake-host-device-id",
 						},
 					},
 				},
@@ -64,7 +64,7 @@ var _ = Describe("UnmountDiskAction", func() {
 
 		result, err := action.Run("vol-123")
 		Expect(err).ToNot(HaveOccurred())
-		boshassert.MatchesJSONString(GinkgoT(), result, `{"message":"Unmounted partition of {ID:vol-123 DeviceID: VolumeID:2 Lun:0 HostDeviceID:fake-host-device-id Path:/dev/sdf FileSystemType:ext4}"}`)
+		boshassert.MatchesJSONString(GinkgoT(), result, `{"message":"Unmounted partition of {ID:vol-123 DeviceID: VolumeID:2 Lun:0 HostDeviceID:fake-host-device-id Path:/dev/sdf FileSystemType:ext4 FileSystemOptions:[] MountOptions:[] Encryption:\u003cnil\u003e}"}`)
 
 		Expect(platform.UnmountPersistentDiskSettings).To(Equal(expectedDiskSettings))
 	})
@@ -74,7 +74,7 @@ var _ = Describe("UnmountDiskAction", func() {
 
 		result, err := action.Run("vol-123")
 		Expect(err).ToNot(HaveOccurred())
-		boshassert.MatchesJSONString(GinkgoT(), result, `{"message":"Partition of {ID:vol-123 DeviceID: VolumeID:2 Lun:0 HostDeviceID:fake-host-device-id Path:/dev/sdf FileSystemType:ext4} is not mounted"}`)
+		boshassert.MatchesJSONString(GinkgoT(), result, `{"message":"Partition of {ID:vol-123 DeviceID: VolumeID:2 Lun:0 HostDeviceID:fake-host-device-id Path:/dev/sdf FileSystemType:ext4 FileSystemOptions:[] MountOptions:[] Encryption:\u003cnil\u003e} is not mounted"}`)
 
 		Expect(platform.UnmountPersistentDiskSettings).To(Equal(expectedDiskSettings))
 	})
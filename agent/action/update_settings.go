@@ -28,6 +28,19 @@ func NewUpdateSettings(service boshsettings.Service, platform platform.Platform,
 	}
 }
 
+// UpdateSettingsPlan describes changes UpdateSettingsAction would make
+type UpdateSettingsPlan struct {
+	DiskAssociations    []DiskAssociationPlan `json:"disk_associations"`
+	TrustedCertsChanged bool                  `json:"trusted_certs_changed"`
+	UpdateSettingsPath  string                `json:"update_settings_path"`
+}
+
+type DiskAssociationPlan struct {
+	Name     string `json:"name"`
+	DiskCID  string `json:"disk_cid"`
+	LinkPath string `json:"link_path"`
+}
+
 func (a UpdateSettingsAction) IsAsynchronous(_ ProtocolVersion) bool {
 	return true
 }
@@ -60,23 +73,101 @@ func (a UpdateSettingsAction) Run(newUpdateSettings boshsettings.UpdateSettings)
 		}
 	}
 
+	if newUpdateSettings.MbusTLS != nil {
+		err = newUpdateSettings.MbusTLS.Validate()
+		if err != nil {
+			return "", bosherr.WrapError(err, "Validating mbus TLS settings")
+		}
+	}
+
+	if newUpdateSettings.HTTPSTLS != nil {
+		err = newUpdateSettings.HTTPSTLS.Validate()
+		if err != nil {
+			return "", bosherr.WrapError(err, "Validating https TLS settings")
+		}
+	}
+
 	err = a.trustedCertManager.UpdateCertificates(newUpdateSettings.TrustedCerts)
 	if err != nil {
 		return "", err
 	}
 
-	updateSettingsJSON, err := json.Marshal(newUpdateSettings)
+	err = a.settingsService.SaveUpdateSettings(newUpdateSettings)
 	if err != nil {
-		return "", bosherr.WrapError(err, "Marshalling updateSettings json")
+		return "", bosherr.WrapError(err, "Saving update settings")
 	}
 
-	updateSettingsPath := filepath.Join(a.platform.GetDirProvider().BoshDir(), "update_settings.json")
-	err = a.platform.GetFs().WriteFile(updateSettingsPath, updateSettingsJSON)
+	return "updated", nil
+}
+
+// Plan validates settings like Run does and describes changes without making them
+func (a UpdateSettingsAction) Plan(newUpdateSettings boshsettings.UpdateSettings) (UpdateSettingsPlan, error) {
+	err := a.settingsService.LoadSettings()
 	if err != nil {
-		return "", bosherr.WrapError(err, "writing update settings json")
+		return UpdateSettingsPlan{}, err
 	}
 
-	return "updated", nil
+	currentSettings := a.settingsService.GetSettings()
+	dirProvider := a.platform.GetDirProvider()
+
+	plan := UpdateSettingsPlan{
+		DiskAssociations:   []DiskAssociationPlan{},
+		UpdateSettingsPath: filepath.Join(dirProvider.BoshDir(), "update_settings.json"),
+	}
+
+	for _, diskAssociation := range newUpdateSettings.DiskAssociations {
+		_, found := currentSettings.PersistentDiskSettings(diskAssociation.DiskCID)
+		if !found {
+			return UpdateSettingsPlan{}, bosherr.Errorf("Persistent disk settings contains no disk with CID: %s", diskAssociation.DiskCID)
+		}
+
+		plan.DiskAssociations = append(plan.DiskAssociations, DiskAssociationPlan{
+			Name:     diskAssociation.Name,
+			DiskCID:  diskAssociation.DiskCID,
+			LinkPath: filepath.Join(dirProvider.DisksDir(), diskAssociation.Name),
+		})
+	}
+
+	if newUpdateSettings.MbusTLS != nil {
+		err = newUpdateSettings.MbusTLS.Validate()
+		if err != nil {
+			return UpdateSettingsPlan{}, bosherr.WrapError(err, "Validating mbus TLS settings")
+		}
+	}
+
+	if newUpdateSettings.HTTPSTLS != nil {
+		err = newUpdateSettings.HTTPSTLS.Validate()
+		if err != nil {
+			return UpdateSettingsPlan{}, bosherr.WrapError(err, "Validating https TLS settings")
+		}
+	}
+
+	plan.TrustedCertsChanged = a.trustedCertsChanged(plan.UpdateSettingsPath, newUpdateSettings.TrustedCerts)
+
+	return plan, nil
+}
+
+// trustedCertsChanged compares certs against previously saved update settings
+func (a UpdateSettingsAction) trustedCertsChanged(updateSettingsPath, trustedCerts string) bool {
+	fs := a.platform.GetFs()
+
+	if !fs.FileExists(updateSettingsPath) {
+		return trustedCerts != ""
+	}
+
+	var previousUpdateSettings boshsettings.UpdateSettings
+
+	updateSettingsJSON, err := fs.ReadFile(updateSettingsPath)
+	if err != nil {
+		return true
+	}
+
+	err = json.Unmarshal(updateSettingsJSON, &previousUpdateSettings)
+	if err != nil {
+		return true
+	}
+
+	return previousUpdateSettings.TrustedCerts != trustedCerts
 }
 
 func (a UpdateSettingsAction) Resume() (interface{}, error) {
@@ -48,17 +48,18 @@ var _ = Describe("UpdateSettings", func() {
 			Expect(result).To(Equal("updated"))
 		})
 
-		It("writes the updated settings to a file", func() {
-			action.Run(newUpdateSettings)
-			expectedPath := filepath.Join(platform.GetDirProvider().BoshDir(), "update_settings.json")
-			exists := platform.GetFs().FileExists(expectedPath)
-			Expect(exists).To(Equal(true))
+		It("saves the updated settings with settings service", func() {
+			newUpdateSettings.TrustedCerts = "fake-certs"
+
+			_, err := action.Run(newUpdateSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settingsService.SavedUpdateSettings).To(Equal(&newUpdateSettings))
 		})
 	})
 
-	Context("when it cannot write the update settings file", func() {
+	Context("when it cannot save the update settings", func() {
 		BeforeEach(func() {
-			platform.Fs.WriteFileError = errors.New("Fake write error")
+			settingsService.SaveUpdateSettingsErr = errors.New("Fake write error")
 		})
 
 		It("returns an error", func() {
@@ -83,6 +84,34 @@ var _ = Describe("UpdateSettings", func() {
 		})
 	})
 
+	Context("when mbus TLS settings are invalid", func() {
+		BeforeEach(func() {
+			newUpdateSettings.MbusTLS = &boshsettings.MbusTLS{CA: "fake-invalid-ca"}
+		})
+
+		It("returns an error without writing update settings", func() {
+			_, err := action.Run(newUpdateSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating mbus TLS settings"))
+
+			Expect(settingsService.SavedUpdateSettings).To(BeNil())
+		})
+	})
+
+	Context("when https TLS settings are invalid", func() {
+		BeforeEach(func() {
+			newUpdateSettings.HTTPSTLS = &boshsettings.HTTPSTLS{MinVersion: "fake-version"}
+		})
+
+		It("returns an error without writing update settings", func() {
+			_, err := action.Run(newUpdateSettings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating https TLS settings"))
+
+			Expect(settingsService.SavedUpdateSettings).To(BeNil())
+		})
+	})
+
 	It("loads settings", func() {
 		_, err := action.Run(newUpdateSettings)
 		Expect(err).ToNot(HaveOccurred())
@@ -123,20 +152,20 @@ var _ = Describe("UpdateSettings", func() {
 	It("associates the disks", func() {
 		settingsService.Settings = boshsettings.Settings{
 			Disks: boshsettings.Disks{
-				Persistent: map[string]interface{}{
-					"fake-disk-id": map[string]interface{}{
-						"volume_id":      "fake-disk-volume-id",
-						"id":             "fake-disk-device-id",
-						"path":           "fake-disk-path",
-						"lun":            "fake-disk-lun",
-						"host_device_id": "fake-disk-host-device-id",
+				Persistent: map[string]boshsettings.DiskSettings{
+					"fake-disk-id": {
+						VolumeID:     "fake-disk-volume-id",
+						DeviceID:     "fake-disk-device-id",
+						Path:         "fake-disk-path",
+						Lun:          "fake-disk-lun",
+						HostDeviceID: "fake-disk-host-device-id",
 					},
-					"fake-disk-id-2": map[string]interface{}{
-						"volume_id":      "fake-disk-volume-id-2",
-						"id":             "fake-disk-device-id-2",
-						"path":           "fake-disk-path-2",
-						"lun":            "fake-disk-lun-2",
-						"host_device_id": "fake-disk-host-device-id-2",
+					"fake-disk-id-2": {
+						VolumeID:     "fake-disk-volume-id-2",
+						DeviceID:     "fake-disk-device-id-2",
+						Path:         "fake-disk-path-2",
+						Lun:          "fake-disk-lun-2",
+						HostDeviceID: "fake-disk-host-device-id-2",
 					},
 				},
 			},
@@ -187,4 +216,64 @@ var _ = Describe("UpdateSettings", func() {
 		}))
 
 	})
+
+	Describe("Plan", func() {
+		var updateSettingsPath string
+
+		BeforeEach(func() {
+			updateSettingsPath = filepath.Join(platform.GetDirProvider().BoshDir(), "update_settings.json")
+
+			settingsService.Settings = boshsettings.Settings{
+				Disks: boshsettings.Disks{
+					Persistent: map[string]boshsettings.DiskSettings{
+						"fake-disk-id": {Path: "fake-disk-path"},
+					},
+				},
+			}
+		})
+
+		It("describes disk associations, certificate changes and update settings path without changing them", func() {
+			plan, err := action.Plan(boshsettings.UpdateSettings{
+				DiskAssociations: []boshsettings.DiskAssociation{{Name: "fake-disk-name", DiskCID: "fake-disk-id"}},
+				TrustedCerts:     "fake-certs",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan).To(Equal(UpdateSettingsPlan{
+				DiskAssociations: []DiskAssociationPlan{{
+					Name:     "fake-disk-name",
+					DiskCID:  "fake-disk-id",
+					LinkPath: filepath.Join(platform.GetDirProvider().DisksDir(), "fake-disk-name"),
+				}},
+				TrustedCertsChanged: true,
+				UpdateSettingsPath:  updateSettingsPath,
+			}))
+
+			Expect(platform.AssociateDiskCallCount).To(Equal(0))
+			Expect(certManager.UpdateCertificatesCallCount()).To(Equal(0))
+			Expect(platform.GetFs().FileExists(updateSettingsPath)).To(BeFalse())
+		})
+
+		It("reports unchanged certificates when they match previously saved update settings", func() {
+			err := platform.GetFs().WriteFileString(updateSettingsPath, `{"trusted_certs":"fake-certs"}`)
+			Expect(err).ToNot(HaveOccurred())
+
+			plan, err := action.Plan(boshsettings.UpdateSettings{TrustedCerts: "fake-certs"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.TrustedCertsChanged).To(BeFalse())
+		})
+
+		It("returns an error when settings do not contain the disk", func() {
+			_, err := action.Plan(boshsettings.UpdateSettings{
+				DiskAssociations: []boshsettings.DiskAssociation{{Name: "fake-disk-name", DiskCID: "fake-unknown-disk-id"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-unknown-disk-id"))
+		})
+
+		It("returns an error when mbus TLS settings are invalid", func() {
+			_, err := action.Plan(boshsettings.UpdateSettings{MbusTLS: &boshsettings.MbusTLS{CA: "fake-invalid-ca"}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating mbus TLS settings"))
+		})
+	})
 })
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"errors"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	"github.com/cloudfoundry/bosh-utils/blobstore"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 
@@ -57,7 +58,10 @@ func (a UploadBlobAction) Run(content UploadBlobSpec) (string, error) {
 func (a UploadBlobAction) validatePayload(payload []byte, payloadDigest boshcrypto.Digest) error {
 	err := payloadDigest.Verify(bytes.NewReader(payload))
 	if err != nil {
-		return bosherr.WrapErrorf(err, "Payload corrupted. Checksum mismatch. Expected '%s'", payloadDigest.String())
+		return boshhandler.NewError(
+			boshhandler.ErrorCodeChecksumMismatch,
+			bosherr.WrapErrorf(err, "Payload corrupted. Checksum mismatch. Expected '%s'", payloadDigest.String()),
+		)
 	}
 
 	return nil
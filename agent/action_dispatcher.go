@@ -1,9 +1,16 @@
 package agent
 
 import (
+	"encoding/json"
+	"sync"
+	"time"
+
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshreqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -21,6 +28,12 @@ type concreteActionDispatcher struct {
 	taskManager   boshtask.Manager
 	actionFactory boshaction.Factory
 	actionRunner  boshaction.Runner
+	requestCache  boshreqcache.Cache
+	policy        boshaction.Policy
+	auditLogger   boshplatform.AuditLogger
+	requestLocks  *requestLocks
+
+	dispatchDuration boshmetrics.Histogram
 }
 
 func NewActionDispatcher(
@@ -29,6 +42,10 @@ func NewActionDispatcher(
 	taskManager boshtask.Manager,
 	actionFactory boshaction.Factory,
 	actionRunner boshaction.Runner,
+	requestCache boshreqcache.Cache,
+	policy boshaction.Policy,
+	auditLogger boshplatform.AuditLogger,
+	metrics *boshmetrics.Registry,
 ) (dispatcher ActionDispatcher) {
 	return concreteActionDispatcher{
 		logger:        logger,
@@ -36,10 +53,30 @@ func NewActionDispatcher(
 		taskManager:   taskManager,
 		actionFactory: actionFactory,
 		actionRunner:  actionRunner,
+		requestCache:  requestCache,
+		policy:        policy,
+		auditLogger:   auditLogger,
+		requestLocks:  newRequestLocks(),
+
+		dispatchDuration: metrics.Histogram(
+			"bosh_agent_action_dispatch_duration_seconds",
+			"Time to respond to action requests; asynchronous actions respond once their task is started.",
+			boshmetrics.DefaultDurationBuckets,
+			"action",
+		),
 	}
 }
 
 func (dispatcher concreteActionDispatcher) ResumePreviouslyDispatchedTasks() {
+	// Finished tasks are restored first so that persistent tasks
+	// resumed below replace their interrupted records.
+	err := dispatcher.taskService.RestoreTasks()
+	if err != nil {
+		// API consumers will encounter unknown task id error
+		// when they request get_task for tasks finished before restart.
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+	}
+
 	taskInfos, err := dispatcher.taskManager.GetInfos()
 	if err != nil {
 		// Ignore failure of resuming tasks because there is nothing we can do.
@@ -69,23 +106,58 @@ func (dispatcher concreteActionDispatcher) ResumePreviouslyDispatchedTasks() {
 			func(_ boshtask.Task) error { return action.Cancel() },
 			dispatcher.removeInfo,
 		)
+		task.Method = taskInfo.Method
 
 		dispatcher.taskService.StartTask(task)
 	}
 }
 
 func (dispatcher concreteActionDispatcher) Dispatch(req boshhandler.Request) boshhandler.Response {
+	// Batch is not authorized itself since each of its entries is
+	if req.Method == BatchMethod {
+		return dispatcher.dispatchBatch(req)
+	}
+
+	// Unknown actions are denied too so that callers cannot find out which actions exist
+	err := dispatcher.policy.Authorize(req.Caller, req.Method, req.GetPayload())
+	if err != nil {
+		dispatcher.auditDenial(req, err)
+		return boshhandler.NewExceptionResponse(err)
+	}
+
 	action, err := dispatcher.actionFactory.Create(req.Method)
 	if err != nil {
 		dispatcher.logger.Error(actionDispatcherLogTag, "Unknown action %s", req.Method)
-		return boshhandler.NewExceptionResponse(bosherr.Errorf("unknown message %s", req.Method))
+		return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(boshhandler.ErrorCodeUnknownAction, "unknown message %s", req.Method))
 	}
 
+	// Only known actions are measured so that requests cannot add arbitrary labels
+	startedAt := time.Now()
+	defer func() {
+		dispatcher.dispatchDuration.Observe(time.Since(startedAt).Seconds(), req.Method)
+	}()
+
 	dispatcher.logger.Info(actionDispatcherLogTag, "Received request with action %s", req.Method)
 	if action.IsLoggable() {
 		dispatcher.logger.DebugWithDetails(actionDispatcherLogTag, "Payload", req.Payload)
 	}
 
+	// Plans are not cached since they only describe current state
+	if req.DryRun {
+		return dispatcher.dispatchDryRun(action, req)
+	}
+
+	if req.RequestID != "" {
+		// Retries arriving while the first request is still dispatched
+		// wait for its outcome instead of running the action again
+		unlock := dispatcher.requestLocks.Lock(req.RequestID)
+		defer unlock()
+
+		if resp, found := dispatcher.findCachedResponse(req); found {
+			return resp
+		}
+	}
+
 	if action.IsAsynchronous(boshaction.ProtocolVersion(req.ProtocolVersion)) {
 		return dispatcher.dispatchAsynchronousAction(action, req)
 	}
@@ -93,6 +165,70 @@ func (dispatcher concreteActionDispatcher) Dispatch(req boshhandler.Request) bos
 	return dispatcher.dispatchSynchronousAction(action, req)
 }
 
+func (dispatcher concreteActionDispatcher) auditDenial(req boshhandler.Request, denyErr error) {
+	dispatcher.logger.Error(actionDispatcherLogTag, denyErr.Error())
+
+	cef := boshhandler.NewCommonEventFormat()
+
+	cefString, err := cef.ProduceActionDeniedEventLog(req.Caller, req.Method, denyErr.Error())
+	if err != nil {
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		return
+	}
+
+	dispatcher.auditLogger.Err(cefString)
+}
+
+func (dispatcher concreteActionDispatcher) findCachedResponse(req boshhandler.Request) (boshhandler.Response, bool) {
+	entry, found, err := dispatcher.requestCache.Get(req.RequestID)
+	if err != nil {
+		// Running the action again is the best we can do without a cache
+		dispatcher.logger.Error(actionDispatcherLogTag, "Failed to look up request %s: %s", req.RequestID, err.Error())
+		return nil, false
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	if entry.Method != req.Method {
+		err = boshhandler.NewErrorf(boshhandler.ErrorCodeRequestIDConflict, "Request ID %s was already used for %s", req.RequestID, entry.Method)
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		return boshhandler.NewExceptionResponse(err), true
+	}
+
+	if entry.TaskID != "" {
+		task, found := dispatcher.taskService.FindTaskWithID(entry.TaskID)
+		if !found {
+			dispatcher.logger.Warn(actionDispatcherLogTag, "Task %s for request %s is no longer known", entry.TaskID, req.RequestID)
+			return nil, false
+		}
+
+		dispatcher.logger.Info(actionDispatcherLogTag, "Returning task %s for retried request %s", task.ID, req.RequestID)
+
+		return boshhandler.NewValueResponse(boshtask.StateValue{
+			AgentTaskID: task.ID,
+			State:       task.State,
+		}), true
+	}
+
+	dispatcher.logger.Info(actionDispatcherLogTag, "Returning cached response for retried request %s", req.RequestID)
+
+	return cachedResponse(entry.Response), true
+}
+
+func (dispatcher concreteActionDispatcher) cacheEntry(entry boshreqcache.Entry) {
+	if entry.RequestID == "" {
+		return
+	}
+
+	err := dispatcher.requestCache.Add(entry)
+	if err != nil {
+		// Failing to cache only means that a retried request will run again.
+		dispatcher.logger.Error(actionDispatcherLogTag, "Failed to cache request %s: %s", entry.RequestID, err.Error())
+	}
+}
+
 func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	action boshaction.Action,
 	req boshhandler.Request,
@@ -102,11 +238,18 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 	var task boshtask.Task
 	var err error
 
+	canceller := boshtask.NewCanceller()
+
 	runTask := func() (interface{}, error) {
-		return dispatcher.actionRunner.Run(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion))
+		progress := dispatcher.taskService.NewProgressReporter(task.ID)
+		return dispatcher.actionRunner.RunTask(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion), progress, canceller.Signal())
 	}
 
-	cancelTask := func(_ boshtask.Task) error { return action.Cancel() }
+	// Actions either watch the cancel signal of their task or cancel themselves
+	cancelTask := func(_ boshtask.Task) error {
+		canceller.Cancel()
+		return action.Cancel()
+	}
 
 	// Certain long-running tasks (e.g. configure_networks) must be resumed
 	// after agent restart so that API consumers do not need to know
@@ -141,28 +284,71 @@ func (dispatcher concreteActionDispatcher) dispatchAsynchronousAction(
 		}
 	}
 
+	task.Method = req.Method
+
 	dispatcher.taskService.StartTask(task)
 
+	dispatcher.cacheEntry(boshreqcache.Entry{
+		RequestID: req.RequestID,
+		Method:    req.Method,
+		TaskID:    task.ID,
+	})
+
 	return boshhandler.NewValueResponse(boshtask.StateValue{
 		AgentTaskID: task.ID,
 		State:       task.State,
 	})
 }
 
+// dispatchDryRun plans asynchronous actions synchronously
+// since planning does not make any changes.
+func (dispatcher concreteActionDispatcher) dispatchDryRun(
+	action boshaction.Action,
+	req boshhandler.Request,
+) boshhandler.Response {
+	dispatcher.logger.Info(actionDispatcherLogTag, "Planning action %s", req.Method)
+
+	value, err := dispatcher.actionRunner.Plan(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion))
+	if err != nil {
+		err = bosherr.WrapErrorf(err, "Planning action %s", req.Method)
+		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
+		return boshhandler.NewExceptionResponse(err)
+	}
+
+	return boshhandler.NewValueResponse(value)
+}
+
 func (dispatcher concreteActionDispatcher) dispatchSynchronousAction(
 	action boshaction.Action,
 	req boshhandler.Request,
 ) boshhandler.Response {
 	dispatcher.logger.Info(actionDispatcherLogTag, "Running sync action %s", req.Method)
 
+	var resp boshhandler.Response
+
 	value, err := dispatcher.actionRunner.Run(action, req.GetPayload(), boshaction.ProtocolVersion(req.ProtocolVersion))
 	if err != nil {
 		err = bosherr.WrapErrorf(err, "Action Failed %s", req.Method)
 		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
-		return boshhandler.NewExceptionResponse(err)
+		resp = boshhandler.NewExceptionResponse(err)
+	} else {
+		resp = boshhandler.NewValueResponse(value)
 	}
 
-	return boshhandler.NewValueResponse(value)
+	if req.RequestID != "" {
+		respJSON, err := json.Marshal(resp)
+		if err != nil {
+			dispatcher.logger.Error(actionDispatcherLogTag, "Failed to marshal response for request %s: %s", req.RequestID, err.Error())
+		} else {
+			dispatcher.cacheEntry(boshreqcache.Entry{
+				RequestID: req.RequestID,
+				Method:    req.Method,
+				Response:  respJSON,
+			})
+		}
+	}
+
+	return resp
 }
 
 func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
@@ -173,3 +359,54 @@ func (dispatcher concreteActionDispatcher) removeInfo(task boshtask.Task) {
 		dispatcher.logger.Error(actionDispatcherLogTag, err.Error())
 	}
 }
+
+// requestLocks serializes dispatching of requests with the same request ID
+type requestLocks struct {
+	lock  sync.Mutex
+	locks map[string]*requestLock
+}
+
+type requestLock struct {
+	sync.Mutex
+	waiting int
+}
+
+func newRequestLocks() *requestLocks {
+	return &requestLocks{locks: map[string]*requestLock{}}
+}
+
+func (l *requestLocks) Lock(requestID string) func() {
+	l.lock.Lock()
+	reqLock, found := l.locks[requestID]
+	if !found {
+		reqLock = &requestLock{}
+		l.locks[requestID] = reqLock
+	}
+	reqLock.waiting++
+	l.lock.Unlock()
+
+	reqLock.Lock()
+
+	return func() {
+		reqLock.Unlock()
+
+		l.lock.Lock()
+		reqLock.waiting--
+		if reqLock.waiting == 0 {
+			delete(l.locks, requestID)
+		}
+		l.lock.Unlock()
+	}
+}
+
+// cachedResponse is a response that was already marshalled
+// when its request was dispatched the first time.
+type cachedResponse json.RawMessage
+
+func (r cachedResponse) MarshalJSON() ([]byte, error) {
+	return []byte(r), nil
+}
+
+func (r cachedResponse) Shorten() boshhandler.Response {
+	return r
+}
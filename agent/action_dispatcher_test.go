@@ -1,23 +1,59 @@
 package agent_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	. "github.com/cloudfoundry/bosh-agent/agent"
 	"github.com/cloudfoundry/bosh-agent/agent/action"
 	fakeaction "github.com/cloudfoundry/bosh-agent/agent/action/fakes"
+	boshreqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache"
+	fakereqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache/fakes"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	"github.com/cloudfoundry/bosh-agent/logger/fakes"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
 
+// actionsFactory creates actions that are not fakes
+type actionsFactory map[string]action.Action
+
+func (f actionsFactory) Create(method string) (action.Action, error) {
+	if a, found := f[method]; found {
+		return a, nil
+	}
+	return nil, errors.New("Action not found")
+}
+
+// cancellableAction runs until its task is cancelled
+type cancellableAction struct{}
+
+func (a *cancellableAction) IsAsynchronous(_ action.ProtocolVersion) bool { return true }
+func (a *cancellableAction) IsPersistent() bool                           { return false }
+func (a *cancellableAction) IsLoggable() bool                             { return true }
+func (a *cancellableAction) Resume() (interface{}, error)                 { return nil, nil }
+func (a *cancellableAction) Cancel() error                                { return nil }
+
+func (a *cancellableAction) Run(cancelled boshtask.CancelSignal) (interface{}, error) {
+	<-cancelled
+	return nil, bosherr.WrapError(cancelled.Check(), "Running fake action")
+}
+
 func init() {
 	Describe("actionDispatcher", func() {
 		var (
@@ -26,7 +62,10 @@ func init() {
 			taskManager   *faketask.FakeManager
 			actionFactory *fakeaction.FakeFactory
 			actionRunner  *fakeaction.FakeRunner
+			requestCache  *fakereqcache.FakeCache
+			auditLogger   *fakeplatform.FakeAuditLogger
 			dispatcher    ActionDispatcher
+			metrics       *boshmetrics.Registry
 		)
 
 		BeforeEach(func() {
@@ -35,7 +74,10 @@ func init() {
 			taskManager = faketask.NewFakeManager()
 			actionFactory = fakeaction.NewFakeFactory()
 			actionRunner = &fakeaction.FakeRunner{}
-			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner)
+			requestCache = fakereqcache.NewFakeCache()
+			auditLogger = fakeplatform.NewFakeAuditLogger()
+			metrics = boshmetrics.NewRegistry()
+			dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, requestCache, action.Policy{}, auditLogger, metrics)
 		})
 
 		It("responds with exception when the method is unknown", func() {
@@ -43,7 +85,11 @@ func init() {
 
 			req := boshhandler.NewRequest("fake-reply", "fake-action", []byte{}, 0)
 			resp := dispatcher.Dispatch(req)
-			boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"unknown message fake-action"}}`)
+			boshassert.MatchesJSONString(GinkgoT(), resp, `{"exception":{"message":"unknown message fake-action","code":"unknown_action","category":"client"}}`)
+
+			buf := &bytes.Buffer{}
+			Expect(metrics.WriteText(buf)).To(Succeed())
+			Expect(buf.String()).ToNot(ContainSubstring(`action="fake-action"`))
 		})
 
 		Context("Action Payload Logging", func() {
@@ -123,6 +169,17 @@ func init() {
 				Expect(actionRunner.RunProtocolVersion).To(Equal(action.ProtocolVersion(99)))
 			})
 
+			It("runs action with progress reporter for the started task", func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte("fake-payload"), boshhandler.ProtocolVersion(99))
+				dispatcher.Dispatch(req)
+
+				_, err := taskService.StartedTasks["fake-generated-task-id"].Func()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(actionRunner.RunProgress).ToNot(BeNil())
+				Expect(actionRunner.RunProgress).To(Equal(taskService.ProgressReporters["fake-generated-task-id"]))
+			})
+
 		})
 
 		Context("when request contains protocol version and action is Synchronous", func() {
@@ -172,6 +229,15 @@ func init() {
 				Expect(boshhandler.NewValueResponse("fake-value")).To(Equal(resp))
 			})
 
+			It("measures dispatch duration per action", func() {
+				dispatcher.Dispatch(req)
+				dispatcher.Dispatch(req)
+
+				buf := &bytes.Buffer{}
+				Expect(metrics.WriteText(buf)).To(Succeed())
+				Expect(buf.String()).To(ContainSubstring(`bosh_agent_action_dispatch_duration_seconds_count{action="fake-action"} 2`))
+			})
+
 			It("handles synchronous action when err", func() {
 				actionRunner.RunErr = errors.New("fake-run-error")
 
@@ -203,6 +269,18 @@ func init() {
 					Expect(action.Canceled).To(BeTrue())
 				})
 
+				It("closes cancel signal passed to the action when task is cancelled", func() {
+					dispatcher.Dispatch(req)
+
+					task := taskService.StartedTasks["fake-generated-task-id"]
+					_, err := task.Func()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(actionRunner.RunCancelSignal.Check()).To(Succeed())
+
+					Expect(task.Cancel()).To(Succeed())
+					Expect(actionRunner.RunCancelSignal.Check()).To(Equal(boshtask.ErrCancelled))
+				})
+
 				It("returns error from cancelling task if canceling task fails", func() {
 					action.CancelErr = errors.New("fake-cancel-err")
 					dispatcher.Dispatch(req)
@@ -361,6 +439,357 @@ func init() {
 			})
 		})
 
+		Context("when request is a dry run", func() {
+			var (
+				req        boshhandler.Request
+				testAction *fakeaction.TestAction
+			)
+
+			BeforeEach(func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte("fake-payload"), 2)
+				req.DryRun = true
+				req.RequestID = "fake-request-id"
+				testAction = &fakeaction.TestAction{Asynchronous: true}
+				actionFactory.RegisterAction("fake-action", testAction)
+			})
+
+			It("responds with plan without running action or starting a task", func() {
+				actionRunner.PlanValue = "fake-plan"
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-plan"}`)
+
+				Expect(actionRunner.PlanAction).To(Equal(testAction))
+				Expect(actionRunner.PlanPayload).To(Equal(req.GetPayload()))
+				Expect(actionRunner.PlanProtocolVersion).To(Equal(action.ProtocolVersion(2)))
+
+				Expect(actionRunner.RunCallCount).To(Equal(0))
+				Expect(taskService.StartedTasks).To(BeEmpty())
+				Expect(requestCache.Entries).To(BeEmpty())
+			})
+
+			It("responds with exception when planning fails", func() {
+				actionRunner.PlanErr = errors.New("fake-plan-error")
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"exception":{"message":"Planning action fake-action: fake-plan-error"}}`)
+			})
+		})
+
+		Context("when request is a batch", func() {
+			BeforeEach(func() {
+				actionFactory.RegisterAction("fake-sync-action", &fakeaction.TestAction{})
+				actionFactory.RegisterAction("fake-async-action", &fakeaction.TestAction{Asynchronous: true})
+				actionFactory.RegisterActionErr("fake-unknown-action", errors.New("fake-create-error"))
+				actionRunner.RunValue = "fake-value"
+			})
+
+			It("dispatches entries in order and responds with response per entry", func() {
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[
+					{"method":"fake-sync-action","arguments":["fake-arg"]},
+					{"method":"fake-unknown-action","arguments":[]},
+					{"method":"fake-async-action","arguments":[]}
+				]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+
+				Expect(actionRunner.RunPayload).To(MatchJSON(`{"method":"fake-sync-action","arguments":["fake-arg"]}`))
+				Expect(taskService.StartedTasks).To(HaveLen(1))
+
+				Expect(json.Marshal(resp)).To(MatchJSON(`{"value":[
+					{"value":"fake-value"},
+					{"exception":{"message":"unknown message fake-unknown-action","code":"unknown_action","category":"client"}},
+					{"value":{"agent_task_id":"fake-generated-task-id","state":"running"}}
+				]}`))
+			})
+
+			It("dispatches entries in parallel when asked to", func() {
+				payload := []byte(`{"method":"batch","arguments":[{"parallel":true,"entries":[
+					{"method":"fake-sync-action","arguments":[]},
+					{"method":"fake-unknown-action","arguments":[]}
+				]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+
+				Expect(actionRunner.RunCallCount).To(Equal(1))
+				Expect(json.Marshal(resp)).To(MatchJSON(`{"value":[
+					{"value":"fake-value"},
+					{"exception":{"message":"unknown message fake-unknown-action","code":"unknown_action","category":"client"}}
+				]}`))
+			})
+
+			It("dispatches all entries of parallel batch with more entries than run at a time", func() {
+				entries := make([]string, 20)
+				expectedResps := make([]string, 20)
+
+				for i := range entries {
+					entries[i] = `{"method":"fake-unknown-action","arguments":[]}`
+					expectedResps[i] = `{"exception":{"message":"unknown message fake-unknown-action","code":"unknown_action","category":"client"}}`
+				}
+
+				payload := []byte(`{"method":"batch","arguments":[{"parallel":true,"entries":[` + strings.Join(entries, ",") + `]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+				Expect(json.Marshal(resp)).To(MatchJSON(`{"value":[` + strings.Join(expectedResps, ",") + `]}`))
+			})
+
+			It("responds with exception when batch has too many entries", func() {
+				entries := make([]string, 101)
+				for i := range entries {
+					entries[i] = `{"method":"fake-sync-action","arguments":[]}`
+				}
+
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[` + strings.Join(entries, ",") + `]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"exception":{"message":"Batch has 101 entries, at most 100 are allowed","code":"invalid_arguments","category":"client"}}`)
+
+				Expect(actionRunner.RunCallCount).To(Equal(0))
+			})
+
+			It("caches entries of batch with request id under derived request ids", func() {
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[{"method":"fake-sync-action","arguments":[]}]}]}`)
+				req := boshhandler.NewRequest("fake-reply", "batch", payload, 2)
+				req.RequestID = "fake-request-id"
+
+				dispatcher.Dispatch(req)
+				dispatcher.Dispatch(req)
+
+				Expect(actionRunner.RunCallCount).To(Equal(1))
+				Expect(requestCache.Entries).To(HaveKey("fake-request-id.0"))
+			})
+
+			It("authorizes each entry with caller of batch", func() {
+				policy := action.Policy{
+					Rules: []action.PolicyRule{
+						{HTTPSUser: "fake-user", Actions: []action.PolicyAction{{Name: "fake-sync-action"}}},
+					},
+				}
+
+				dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, requestCache, policy, auditLogger, metrics)
+
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[
+					{"method":"fake-sync-action","arguments":[]},
+					{"method":"fake-async-action","arguments":[]}
+				]}]}`)
+				req := boshhandler.NewRequest("fake-reply", "batch", payload, 2)
+				req.Caller = boshhandler.Caller{Kind: boshhandler.CallerHTTPSUser, Name: "fake-user"}
+
+				resp := dispatcher.Dispatch(req)
+				Expect(json.Marshal(resp)).To(MatchJSON(`{"value":[
+					{"value":"fake-value"},
+					{"exception":{"message":"Caller https_user 'fake-user' is not allowed to run action 'fake-async-action'","code":"action_not_allowed","category":"unauthorized"}}
+				]}`))
+			})
+
+			It("responds with exception when entries are nested batches", func() {
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[{"method":"batch","arguments":[]}]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"exception":{"message":"Batch entry 0 cannot be a batch","code":"invalid_arguments","category":"client"}}`)
+			})
+
+			It("responds with exception when arguments are invalid", func() {
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", []byte(`{"arguments":[]}`), 2))
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"exception":{"message":"Batch expects a single argument with entries","code":"invalid_arguments","category":"client"}}`)
+			})
+
+			It("shortens responses of entries", func() {
+				payload := []byte(`{"method":"batch","arguments":[{"entries":[{"method":"fake-sync-action","arguments":[]}]}]}`)
+
+				resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "batch", payload, 2))
+				boshassert.MatchesJSONString(GinkgoT(), resp.Shorten(), `{"value":[{"value":"fake-value"}]}`)
+			})
+		})
+
+		Context("when authorization policy is configured", func() {
+			var (
+				req        boshhandler.Request
+				testAction *fakeaction.TestAction
+			)
+
+			BeforeEach(func() {
+				policy := action.Policy{
+					Rules: []action.PolicyRule{
+						{
+							HTTPSUser: "fake-user",
+							Actions:   []action.PolicyAction{{Name: "fake-action"}},
+						},
+					},
+				}
+
+				dispatcher = NewActionDispatcher(logger, taskService, taskManager, actionFactory, actionRunner, requestCache, policy, auditLogger, metrics)
+
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte("fake-payload"), 2)
+				testAction = &fakeaction.TestAction{}
+				actionFactory.RegisterAction("fake-action", testAction)
+			})
+
+			It("runs action allowed for caller", func() {
+				req.Caller = boshhandler.Caller{Kind: boshhandler.CallerHTTPSUser, Name: "fake-user"}
+				actionRunner.RunValue = "fake-value"
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-value"}`)
+				Expect(auditLogger.GetErrMsgs()).To(BeEmpty())
+			})
+
+			It("responds with exception and audits denial without running action", func() {
+				req.Caller = boshhandler.Caller{Kind: boshhandler.CallerHTTPSUser, Name: "other-user"}
+
+				resp := dispatcher.Dispatch(req)
+				boshassert.MatchesJSONString(GinkgoT(), resp,
+					`{"exception":{"message":"Caller https_user 'other-user' is not allowed to run action 'fake-action'","code":"action_not_allowed","category":"unauthorized"}}`)
+
+				Expect(actionRunner.RunCallCount).To(Equal(0))
+				Expect(auditLogger.GetErrMsgs()).To(ConsistOf(ContainSubstring("|fake-action|7|duser=other-user")))
+			})
+		})
+
+		Context("when request contains request id", func() {
+			var (
+				req    boshhandler.Request
+				action *fakeaction.TestAction
+			)
+
+			BeforeEach(func() {
+				req = boshhandler.NewRequest("fake-reply", "fake-action", []byte("fake-payload"), 0)
+				req.RequestID = "fake-request-id"
+				action = &fakeaction.TestAction{}
+				actionFactory.RegisterAction("fake-action", action)
+			})
+
+			Context("when action is synchronous", func() {
+				It("runs the action only once for retried requests", func() {
+					actionRunner.RunValue = "fake-value"
+
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-value"}`)
+
+					actionRunner.RunValue = "fake-other-value"
+
+					resp = dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-value"}`)
+					Expect(actionRunner.RunCallCount).To(Equal(1))
+				})
+
+				It("runs the action only once for requests retried while the first one is running", func() {
+					actionRunner.RunValue = "fake-value"
+					actionRunner.RunStarted = make(chan struct{}, 2)
+					actionRunner.RunBlock = make(chan struct{})
+
+					resps := make(chan boshhandler.Response, 2)
+					go func() { resps <- dispatcher.Dispatch(req) }()
+					Eventually(actionRunner.RunStarted).Should(Receive())
+
+					go func() { resps <- dispatcher.Dispatch(req) }()
+					Consistently(resps).ShouldNot(Receive())
+
+					close(actionRunner.RunBlock)
+
+					for i := 0; i < 2; i++ {
+						var resp boshhandler.Response
+						Eventually(resps).Should(Receive(&resp))
+						boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-value"}`)
+					}
+					Expect(actionRunner.RunCallCount).To(Equal(1))
+				})
+
+				It("returns the cached exception for retried requests that failed", func() {
+					actionRunner.RunErr = errors.New("fake-run-error")
+
+					dispatcher.Dispatch(req)
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp,
+						`{"exception":{"message":"Action Failed fake-action: fake-run-error"}}`)
+					Expect(actionRunner.RunCallCount).To(Equal(1))
+				})
+
+				It("runs the action when caching the response fails", func() {
+					requestCache.AddErr = errors.New("fake-add-error")
+					actionRunner.RunValue = "fake-value"
+
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"fake-value"}`)
+				})
+
+				It("runs the action when looking up the cache fails", func() {
+					requestCache.GetErr = errors.New("fake-get-error")
+
+					dispatcher.Dispatch(req)
+					dispatcher.Dispatch(req)
+					Expect(actionRunner.RunCallCount).To(Equal(2))
+				})
+
+				It("does not cache requests without request id", func() {
+					req.RequestID = ""
+
+					dispatcher.Dispatch(req)
+					dispatcher.Dispatch(req)
+					Expect(actionRunner.RunCallCount).To(Equal(2))
+					Expect(requestCache.Entries).To(BeEmpty())
+				})
+
+				It("returns an exception when request id was used for another method", func() {
+					requestCache.Entries["fake-request-id"] = boshreqcache.Entry{
+						RequestID: "fake-request-id",
+						Method:    "fake-other-action",
+						Response:  []byte(`{"value":"fake-value"}`),
+					}
+
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp,
+						`{"exception":{"message":"Request ID fake-request-id was already used for fake-other-action","code":"request_id_conflict","category":"client"}}`)
+					Expect(actionRunner.RunCallCount).To(Equal(0))
+				})
+			})
+
+			Context("when action is asynchronous", func() {
+				BeforeEach(func() {
+					action.Asynchronous = true
+				})
+
+				It("returns the existing task for retried requests", func() {
+					dispatcher.Dispatch(req)
+					Expect(requestCache.Entries["fake-request-id"].TaskID).To(Equal("fake-generated-task-id"))
+
+					task := taskService.StartedTasks["fake-generated-task-id"]
+					task.State = boshtask.StateDone
+					taskService.StartedTasks["fake-generated-task-id"] = task
+
+					taskService.CreateTaskErr = errors.New("fake-create-task-error")
+
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp,
+						`{"value":{"agent_task_id":"fake-generated-task-id","state":"done"}}`)
+				})
+
+				It("starts a new task when existing task is no longer known", func() {
+					requestCache.Entries["fake-request-id"] = boshreqcache.Entry{
+						RequestID: "fake-request-id",
+						Method:    "fake-action",
+						TaskID:    "fake-unknown-task-id",
+					}
+
+					resp := dispatcher.Dispatch(req)
+					boshassert.MatchesJSONString(GinkgoT(), resp,
+						`{"value":{"agent_task_id":"fake-generated-task-id","state":"running"}}`)
+					Expect(requestCache.Entries["fake-request-id"].TaskID).To(Equal("fake-generated-task-id"))
+				})
+
+				It("does not cache requests whose task could not be created", func() {
+					taskService.CreateTaskErr = errors.New("fake-create-task-error")
+
+					dispatcher.Dispatch(req)
+					Expect(requestCache.Entries).To(BeEmpty())
+				})
+			})
+		})
+
 		Describe("ResumePreviouslyDispatchedTasks", func() {
 			var firstAction, secondAction *fakeaction.TestAction
 
@@ -383,6 +812,20 @@ func init() {
 				secondAction = &fakeaction.TestAction{}
 			})
 
+			It("restores tasks recorded before agent restart", func() {
+				dispatcher.ResumePreviouslyDispatchedTasks()
+				Expect(taskService.RestoreTasksCalled).To(BeTrue())
+			})
+
+			It("resumes tasks saved in a task manager even if restoring recorded tasks fails", func() {
+				taskService.RestoreTasksErr = errors.New("fake-restore-error")
+				actionFactory.RegisterAction("fake-action-1", firstAction)
+				actionFactory.RegisterAction("fake-action-2", secondAction)
+
+				dispatcher.ResumePreviouslyDispatchedTasks()
+				Expect(len(taskService.StartedTasks)).To(Equal(2))
+			})
+
 			It("calls resume on each task that was saved in a task manager", func() {
 				actionFactory.RegisterAction("fake-action-1", firstAction)
 				actionFactory.RegisterAction("fake-action-2", secondAction)
@@ -514,4 +957,52 @@ func init() {
 			})
 		})
 	})
+
+	Describe("actionDispatcher with concrete runner", func() {
+		var (
+			taskService   boshtask.Service
+			actionFactory actionsFactory
+			dispatcher    ActionDispatcher
+		)
+
+		BeforeEach(func() {
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			timeService := fakeclock.NewFakeClock(time.Now())
+			metrics := boshmetrics.NewRegistry()
+			journal := boshtask.NewJournal(logger, fakesys.NewFakeFileSystem(), "/fake-task-journal.json", time.Hour, timeService)
+
+			taskService = boshtask.NewAsyncTaskService(fakeuuid.NewFakeGenerator(), journal, boshtask.DefaultConcurrencyPolicy(), timeService, logger, metrics)
+			actionFactory = actionsFactory{
+				"get_task":    action.NewGetTask(taskService),
+				"cancel_task": action.NewCancelTask(taskService),
+			}
+
+			dispatcher = NewActionDispatcher(logger, taskService, faketask.NewFakeManager(), actionFactory, action.NewRunner(), fakereqcache.NewFakeCache(), action.Policy{}, fakeplatform.NewFakeAuditLogger(), metrics)
+		})
+
+		It("responds with error code returned by action", func() {
+			req := boshhandler.NewRequest("fake-reply", "get_task", []byte(`{"arguments":["fake-unknown-task-id"]}`), 0)
+
+			resp := dispatcher.Dispatch(req)
+			boshassert.MatchesJSONString(GinkgoT(), resp,
+				`{"exception":{"message":"Action Failed get_task: Task with id fake-unknown-task-id could not be found","code":"task_not_found","category":"not_found"}}`)
+		})
+
+		It("reports tasks stopped by cancellation as cancelled", func() {
+			actionFactory["fake-action"] = &cancellableAction{}
+
+			resp := dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "fake-action", []byte(`{"arguments":[]}`), 0))
+			boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":{"agent_task_id":"fake-uuid-0","state":"running"}}`)
+
+			resp = dispatcher.Dispatch(boshhandler.NewRequest("fake-reply", "cancel_task", []byte(`{"arguments":["fake-uuid-0"]}`), 0))
+			boshassert.MatchesJSONString(GinkgoT(), resp, `{"value":"canceled"}`)
+
+			getTaskReq := boshhandler.NewRequest("fake-reply", "get_task", []byte(`{"arguments":["fake-uuid-0"]}`), boshhandler.ProtocolVersion(action.CancelledTaskStateProtocolVersion))
+			Eventually(func() string {
+				respJSON, err := json.Marshal(dispatcher.Dispatch(getTaskReq))
+				Expect(err).ToNot(HaveOccurred())
+				return string(respJSON)
+			}).Should(Equal(`{"value":{"agent_task_id":"fake-uuid-0","state":"cancelled"}}`))
+		})
+	})
 }
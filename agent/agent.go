@@ -33,6 +33,9 @@ type Agent struct {
 	settingsService   boshsettings.Service
 	uuidGenerator     boshuuid.Generator
 	timeService       clock.Clock
+
+	// settingsRefreshInterval disables periodic refresh when zero
+	settingsRefreshInterval time.Duration
 }
 
 func New(
@@ -47,6 +50,7 @@ func New(
 	settingsService boshsettings.Service,
 	uuidGenerator boshuuid.Generator,
 	timeService clock.Clock,
+	settingsRefreshInterval time.Duration,
 ) Agent {
 	return Agent{
 		logger:            logger,
@@ -60,6 +64,8 @@ func New(
 		settingsService:   settingsService,
 		uuidGenerator:     uuidGenerator,
 		timeService:       timeService,
+
+		settingsRefreshInterval: settingsRefreshInterval,
 	}
 }
 
@@ -72,6 +78,10 @@ func (a Agent) Run() error {
 
 	go a.generateHeartbeats(errCh)
 
+	if a.settingsRefreshInterval > 0 {
+		go a.refreshSettings()
+	}
+
 	go func() {
 		err := a.jobSupervisor.MonitorJobFailures(a.handleJobFailure(errCh))
 		if err != nil {
@@ -120,6 +130,22 @@ func (a Agent) generateHeartbeats(errCh chan error) {
 	}
 }
 
+// refreshSettings keeps agent running when settings cannot be refreshed
+// since current settings remain usable
+func (a Agent) refreshSettings() {
+	defer a.logger.HandlePanic("Agent Refresh Settings")
+
+	ticker := a.timeService.NewTicker(a.settingsRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		_, err := a.settingsService.RefreshSettings()
+		if err != nil {
+			a.logger.Error(agentLogTag, "Failed refreshing settings: %s", err.Error())
+		}
+	}
+}
+
 func (a Agent) sendHeartbeat(errCh chan error) {
 	heartbeat, err := a.getHeartbeat()
 	if err != nil {
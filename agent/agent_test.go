@@ -65,6 +65,7 @@ func init() {
 				settingsService,
 				uuidGenerator,
 				timeService,
+				0,
 			)
 		})
 
@@ -94,6 +95,34 @@ func init() {
 				Expect(resumedBeforeStartingToDispatch).To(BeTrue())
 			})
 
+			It("periodically refreshes settings when refresh interval is configured", func() {
+				agent = New(
+					logger,
+					handler,
+					platform,
+					actionDispatcher,
+					jobSupervisor,
+					specService,
+					syslogServer,
+					5*time.Hour,
+					settingsService,
+					uuidGenerator,
+					timeService,
+					time.Minute,
+				)
+
+				settingsService.RefreshSettingsErr = errors.New("fake-refresh-err")
+
+				err := agent.Run()
+				Expect(err).ToNot(HaveOccurred())
+
+				timeService.WaitForWatcherAndIncrement(time.Minute)
+				Eventually(settingsService.RefreshSettingsCallCount).Should(Equal(1))
+
+				timeService.Increment(time.Minute)
+				Eventually(settingsService.RefreshSettingsCallCount).Should(Equal(2))
+			})
+
 			Context("when heartbeats can be sent", func() {
 				BeforeEach(func() {
 					handler.KeepOnRunning()
@@ -144,6 +173,7 @@ func init() {
 						settingsService,
 						uuidGenerator,
 						timeService,
+						0,
 					)
 
 					// Immediately exit after sending initial heartbeat
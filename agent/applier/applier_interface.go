@@ -2,10 +2,25 @@ package applier
 
 import (
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type Applier interface {
 	Prepare(desiredApplySpec boshas.ApplySpec) error
 	ConfigureJobs(desiredApplySpec boshas.ApplySpec) error
-	Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec) error
+
+	// Apply removes jobs and packages it installed if it is cancelled
+	Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec, cancelled boshtask.CancelSignal) error
+
+	// PlanApply describes changes Apply would make without making them
+	PlanApply(currentApplySpec, desiredApplySpec boshas.ApplySpec) (ApplyPlan, error)
+}
+
+// ApplyPlan lists jobs and packages as name/version that would be installed
+// and install paths of jobs and packages that would be removed
+type ApplyPlan struct {
+	InstallJobs     []string `json:"install_jobs"`
+	RemoveJobs      []string `json:"remove_jobs"`
+	InstallPackages []string `json:"install_packages"`
+	RemovePackages  []string `json:"remove_packages"`
 }
@@ -4,6 +4,7 @@ import (
 	as "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -52,7 +53,19 @@ func (a *concreteApplier) Prepare(desiredApplySpec as.ApplySpec) error {
 	return nil
 }
 
-func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec) error {
+func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec, cancelled boshtask.CancelSignal) error {
+	err := a.apply(currentApplySpec, desiredApplySpec, cancelled)
+	if boshtask.IsCancelled(err) {
+		cleanupErr := a.restoreBundles(currentApplySpec)
+		if cleanupErr != nil {
+			return bosherr.WrapError(cleanupErr, "Cleaning up after cancelled apply")
+		}
+	}
+
+	return err
+}
+
+func (a *concreteApplier) apply(currentApplySpec, desiredApplySpec as.ApplySpec, cancelled boshtask.CancelSignal) error {
 	err := a.jobSupervisor.RemoveAllJobs()
 	if err != nil {
 		return bosherr.WrapError(err, "Removing all jobs")
@@ -60,7 +73,12 @@ func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec)
 
 	jobs := desiredApplySpec.Jobs()
 	for _, job := range jobs {
-		err = a.jobApplier.Apply(job)
+		err = cancelled.Check()
+		if err != nil {
+			return err
+		}
+
+		err = a.jobApplier.Apply(job, cancelled)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying job %s", job.Name)
 		}
@@ -72,7 +90,12 @@ func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec)
 	}
 
 	for _, pkg := range desiredApplySpec.Packages() {
-		err = a.packageApplier.Apply(pkg)
+		err = cancelled.Check()
+		if err != nil {
+			return err
+		}
+
+		err = a.packageApplier.Apply(pkg, cancelled)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying package %s", pkg.Name)
 		}
@@ -91,6 +114,83 @@ func (a *concreteApplier) Apply(currentApplySpec, desiredApplySpec as.ApplySpec)
 	return a.setUpLogrotate(desiredApplySpec)
 }
 
+// restoreBundles removes jobs and packages installed by a cancelled apply
+// and enables bundles of the current apply spec again since a cancelled apply
+// may have already enabled other versions of the same jobs and packages
+func (a *concreteApplier) restoreBundles(currentApplySpec as.ApplySpec) error {
+	err := a.jobApplier.KeepOnly(currentApplySpec.Jobs())
+	if err != nil {
+		return bosherr.WrapError(err, "Keeping only current jobs")
+	}
+
+	err = a.packageApplier.KeepOnly(currentApplySpec.Packages())
+	if err != nil {
+		return bosherr.WrapError(err, "Keeping only current packages")
+	}
+
+	// Current bundles are installed so applying them only enables them
+	for _, job := range currentApplySpec.Jobs() {
+		err = a.jobApplier.Apply(job, nil)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Enabling current job %s", job.Name)
+		}
+	}
+
+	for _, pkg := range currentApplySpec.Packages() {
+		err = a.packageApplier.Apply(pkg, nil)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Enabling current package %s", pkg.Name)
+		}
+	}
+
+	return nil
+}
+
+func (a *concreteApplier) PlanApply(currentApplySpec, desiredApplySpec as.ApplySpec) (ApplyPlan, error) {
+	plan := ApplyPlan{
+		InstallJobs:     []string{},
+		InstallPackages: []string{},
+	}
+
+	for _, job := range desiredApplySpec.Jobs() {
+		installed, err := a.jobApplier.IsInstalled(job)
+		if err != nil {
+			return ApplyPlan{}, bosherr.WrapErrorf(err, "Planning job %s", job.Name)
+		}
+
+		if !installed {
+			plan.InstallJobs = append(plan.InstallJobs, job.Name+"/"+job.Version)
+		}
+	}
+
+	removedJobs, err := a.jobApplier.PlanKeepOnly(append(currentApplySpec.Jobs(), desiredApplySpec.Jobs()...))
+	if err != nil {
+		return ApplyPlan{}, bosherr.WrapError(err, "Planning to keep only needed jobs")
+	}
+
+	plan.RemoveJobs = removedJobs
+
+	for _, pkg := range desiredApplySpec.Packages() {
+		installed, err := a.packageApplier.IsInstalled(pkg)
+		if err != nil {
+			return ApplyPlan{}, bosherr.WrapErrorf(err, "Planning package %s", pkg.Name)
+		}
+
+		if !installed {
+			plan.InstallPackages = append(plan.InstallPackages, pkg.Name+"/"+pkg.Version)
+		}
+	}
+
+	removedPackages, err := a.packageApplier.PlanKeepOnly(append(currentApplySpec.Packages(), desiredApplySpec.Packages()...))
+	if err != nil {
+		return ApplyPlan{}, bosherr.WrapError(err, "Planning to keep only needed packages")
+	}
+
+	plan.RemovePackages = removedPackages
+
+	return plan, nil
+}
+
 func (a *concreteApplier) ConfigureJobs(desiredApplySpec as.ApplySpec) error {
 
 	jobs := desiredApplySpec.Jobs()
@@ -14,6 +14,7 @@ import (
 	fakejobs "github.com/cloudfoundry/bosh-agent/agent/applier/jobs/fakes"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -148,7 +149,7 @@ func init() {
 
 		Describe("Apply", func() {
 			It("removes all jobs from job supervisor", func() {
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(jobSupervisor.RemovedAllJobs).To(BeTrue())
@@ -162,6 +163,7 @@ func init() {
 				applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					nil,
 				)
 
 				// check that jobs were not applied before removing all other jobs
@@ -171,7 +173,7 @@ func init() {
 			It("returns error if removing all jobs from job supervisor fails", func() {
 				jobSupervisor.RemovedAllJobsErr = errors.New("fake-remove-all-jobs-error")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-all-jobs-error"))
 			})
@@ -182,6 +184,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					nil,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(jobApplier.AppliedJobs).To(Equal([]models.Job{job}))
@@ -195,6 +198,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{job}},
+					nil,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-job-error"))
@@ -207,6 +211,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}},
+					nil,
 				)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -222,6 +227,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
 					&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}},
+					nil,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
@@ -234,6 +240,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{pkg1, pkg2}},
+					nil,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{pkg1, pkg2}))
@@ -247,6 +254,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{pkg}},
+					nil,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-package-error"))
@@ -259,6 +267,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{currentPkg}},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{desiredPkg}},
+					nil,
 				)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.KeptOnlyPackages).To(Equal([]models.Package{currentPkg, desiredPkg}))
@@ -273,6 +282,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{currentPkg}},
 					&fakeas.FakeApplySpec{PackageResults: []models.Package{desiredPkg}},
+					nil,
 				)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
@@ -283,7 +293,7 @@ func init() {
 				job2 := models.Job{Name: "fake-job-name-2", Version: "fake-version-name-2"}
 				jobs := []models.Job{job1, job2}
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs}, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(jobApplier.ConfiguredJobs).To(BeEmpty())
 
@@ -294,7 +304,7 @@ func init() {
 				jobs := []models.Job{}
 				jobSupervisor.ReloadErr = errors.New("error reloading monit")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: jobs}, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("error reloading monit"))
 			})
@@ -303,6 +313,7 @@ func init() {
 				err := applier.Apply(
 					&fakeas.FakeApplySpec{},
 					&fakeas.FakeApplySpec{MaxLogFileSizeResult: "fake-size"},
+					nil,
 				)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -313,13 +324,160 @@ func init() {
 				})
 			})
 
+			Context("when cancelled", func() {
+				var (
+					canceller  *boshtask.Canceller
+					currentJob models.Job
+					currentPkg models.Package
+				)
+
+				BeforeEach(func() {
+					canceller = boshtask.NewCanceller()
+					currentJob = buildJob()
+					currentPkg = buildPackage()
+				})
+
+				It("passes cancel signal to job and package appliers", func() {
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}, PackageResults: []models.Package{buildPackage()}},
+						canceller.Signal(),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(jobApplier.ApplyCancelSignal).To(Equal(canceller.Signal()))
+					Expect(packageApplier.ApplyCancelSignal).To(Equal(canceller.Signal()))
+				})
+
+				It("stops applying and keeps only jobs and packages of current spec", func() {
+					canceller.Cancel()
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}, PackageResults: []models.Package{currentPkg}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}, PackageResults: []models.Package{buildPackage()}},
+						canceller.Signal(),
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(boshtask.IsCancelled(err)).To(BeTrue())
+
+					Expect(jobApplier.AppliedJobs).To(Equal([]models.Job{currentJob}))
+					Expect(packageApplier.AppliedPackages).To(Equal([]models.Package{currentPkg}))
+					Expect(jobApplier.KeepOnlyJobs).To(Equal([]models.Job{currentJob}))
+					Expect(packageApplier.KeptOnlyPackages).To(Equal([]models.Package{currentPkg}))
+					Expect(jobSupervisor.Reloaded).To(BeFalse())
+				})
+
+				It("keeps only current spec when package applier is cancelled", func() {
+					packageApplier.ApplyError = boshtask.ErrCancelled
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{PackageResults: []models.Package{currentPkg}},
+						&fakeas.FakeApplySpec{PackageResults: []models.Package{buildPackage()}},
+						canceller.Signal(),
+					)
+					Expect(boshtask.IsCancelled(err)).To(BeTrue())
+					Expect(packageApplier.KeptOnlyPackages).To(Equal([]models.Package{currentPkg}))
+				})
+
+				It("enables current version of jobs again when cancelled after enabling desired version", func() {
+					desiredJob := currentJob
+					desiredJob.Version = "fake-desired-version"
+					packageApplier.ApplyError = boshtask.ErrCancelled
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{desiredJob}, PackageResults: []models.Package{buildPackage()}},
+						canceller.Signal(),
+					)
+					Expect(boshtask.IsCancelled(err)).To(BeTrue())
+
+					Expect(jobApplier.KeepOnlyJobs).To(Equal([]models.Job{currentJob}))
+					Expect(jobApplier.AppliedJobs).To(Equal([]models.Job{desiredJob, currentJob}))
+				})
+
+				It("returns error when cleaning up fails", func() {
+					canceller.Cancel()
+					jobApplier.KeepOnlyErr = errors.New("fake-keep-only-error")
+
+					err := applier.Apply(
+						&fakeas.FakeApplySpec{},
+						&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+						canceller.Signal(),
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Cleaning up after cancelled apply"))
+					Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
+				})
+			})
+
 			It("apply errs if setup logrotate fails", func() {
 				logRotateDelegate.SetupLogrotateErr = errors.New("fake-set-up-logrotate-error")
 
-				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				err := applier.Apply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{}, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-set-up-logrotate-error"))
 			})
 		})
+
+		Describe("PlanApply", func() {
+			It("lists desired jobs and packages that are not installed and paths that would be removed", func() {
+				installedJob := buildJob()
+				newJob := buildJob()
+				installedPkg := buildPackage()
+				newPkg := buildPackage()
+
+				jobApplier.InstalledJobs = []models.Job{installedJob}
+				jobApplier.PlanKeepOnlyPaths = []string{"/fake-jobs/old-job"}
+				packageApplier.InstalledPackages = []models.Package{installedPkg}
+				packageApplier.PlanKeepOnlyPaths = []string{"/fake-packages/old-pkg"}
+
+				currentJob := buildJob()
+				currentPkg := buildPackage()
+
+				plan, err := applier.PlanApply(
+					&fakeas.FakeApplySpec{JobResults: []models.Job{currentJob}, PackageResults: []models.Package{currentPkg}},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{installedJob, newJob}, PackageResults: []models.Package{installedPkg, newPkg}},
+				)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(plan).To(Equal(ApplyPlan{
+					InstallJobs:     []string{newJob.Name + "/" + newJob.Version},
+					RemoveJobs:      []string{"/fake-jobs/old-job"},
+					InstallPackages: []string{newPkg.Name + "/" + newPkg.Version},
+					RemovePackages:  []string{"/fake-packages/old-pkg"},
+				}))
+
+				Expect(jobApplier.PlanKeepOnlyJobs).To(Equal([]models.Job{currentJob, installedJob, newJob}))
+				Expect(packageApplier.PlanKeepOnlyPackages).To(Equal([]models.Package{currentPkg, installedPkg, newPkg}))
+			})
+
+			It("does not apply, keep only or reload anything", func() {
+				_, err := applier.PlanApply(
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}},
+					&fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}, PackageResults: []models.Package{buildPackage()}},
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(jobApplier.AppliedJobs).To(BeEmpty())
+				Expect(jobApplier.KeepOnlyJobs).To(BeNil())
+				Expect(packageApplier.AppliedPackages).To(BeEmpty())
+				Expect(packageApplier.KeptOnlyPackages).To(BeNil())
+				Expect(jobSupervisor.RemovedAllJobs).To(BeFalse())
+			})
+
+			It("returns error when checking whether job is installed fails", func() {
+				jobApplier.IsInstalledErr = errors.New("fake-is-installed-error")
+
+				_, err := applier.PlanApply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{JobResults: []models.Job{buildJob()}})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-is-installed-error"))
+			})
+
+			It("returns error when planning to keep only packages fails", func() {
+				packageApplier.PlanKeepOnlyErr = errors.New("fake-plan-keep-only-error")
+
+				_, err := applier.PlanApply(&fakeas.FakeApplySpec{}, &fakeas.FakeApplySpec{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-plan-keep-only-error"))
+			})
+		})
 	})
 }
@@ -1,8 +1,10 @@
 package fakes
 
 import (
+	boshapplier "github.com/cloudfoundry/bosh-agent/agent/applier"
 	boshas "github.com/cloudfoundry/bosh-agent/agent/applier/applyspec"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeApplier struct {
@@ -13,12 +15,18 @@ type FakeApplier struct {
 	Applied               bool
 	ApplyCurrentApplySpec boshas.ApplySpec
 	ApplyDesiredApplySpec boshas.ApplySpec
+	ApplyCancelSignal     boshtask.CancelSignal
 	ApplyError            error
 
 	Configured                 bool
 	ConfiguredDesiredApplySpec boshas.ApplySpec
 	ConfiguredJobs             []models.Job
 	ConfiguredError            error
+
+	PlanApplyCurrentApplySpec boshas.ApplySpec
+	PlanApplyDesiredApplySpec boshas.ApplySpec
+	PlanApplyPlan             boshapplier.ApplyPlan
+	PlanApplyError            error
 }
 
 func NewFakeApplier() *FakeApplier {
@@ -37,9 +45,16 @@ func (s *FakeApplier) ConfigureJobs(desiredApplySpec boshas.ApplySpec) error {
 	return s.ConfiguredError
 }
 
-func (s *FakeApplier) Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec) error {
+func (s *FakeApplier) Apply(currentApplySpec, desiredApplySpec boshas.ApplySpec, cancelled boshtask.CancelSignal) error {
 	s.Applied = true
 	s.ApplyCurrentApplySpec = currentApplySpec
 	s.ApplyDesiredApplySpec = desiredApplySpec
+	s.ApplyCancelSignal = cancelled
 	return s.ApplyError
 }
+
+func (s *FakeApplier) PlanApply(currentApplySpec, desiredApplySpec boshas.ApplySpec) (boshapplier.ApplyPlan, error) {
+	s.PlanApplyCurrentApplySpec = currentApplySpec
+	s.PlanApplyDesiredApplySpec = desiredApplySpec
+	return s.PlanApplyPlan, s.PlanApplyError
+}
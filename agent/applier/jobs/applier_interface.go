@@ -2,11 +2,16 @@ package jobs
 
 import (
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type Applier interface {
 	Prepare(job models.Job) error
-	Apply(job models.Job) error
+	Apply(job models.Job, cancelled boshtask.CancelSignal) error
 	Configure(job models.Job, jobIndex int) error
 	KeepOnly(jobs []models.Job) error
+
+	// IsInstalled and PlanKeepOnly describe what Apply and KeepOnly would do
+	IsInstalled(job models.Job) (bool, error)
+	PlanKeepOnly(jobs []models.Job) (removedPaths []string, err error)
 }
@@ -2,14 +2,16 @@ package fakes
 
 import (
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeApplier struct {
 	PreparedJobs []models.Job
 	PrepareError error
 
-	AppliedJobs []models.Job
-	ApplyError  error
+	AppliedJobs       []models.Job
+	ApplyCancelSignal boshtask.CancelSignal
+	ApplyError        error
 
 	ConfiguredJobs       []models.Job
 	ConfiguredJobIndices []int
@@ -17,6 +19,13 @@ type FakeApplier struct {
 
 	KeepOnlyJobs []models.Job
 	KeepOnlyErr  error
+
+	InstalledJobs  []models.Job
+	IsInstalledErr error
+
+	PlanKeepOnlyJobs  []models.Job
+	PlanKeepOnlyPaths []string
+	PlanKeepOnlyErr   error
 }
 
 func NewFakeApplier() *FakeApplier {
@@ -30,8 +39,9 @@ func (s *FakeApplier) Prepare(job models.Job) error {
 	return s.PrepareError
 }
 
-func (s *FakeApplier) Apply(job models.Job) error {
+func (s *FakeApplier) Apply(job models.Job, cancelled boshtask.CancelSignal) error {
 	s.AppliedJobs = append(s.AppliedJobs, job)
+	s.ApplyCancelSignal = cancelled
 	return s.ApplyError
 }
 
@@ -45,3 +55,18 @@ func (s *FakeApplier) KeepOnly(jobs []models.Job) error {
 	s.KeepOnlyJobs = jobs
 	return s.KeepOnlyErr
 }
+
+func (s *FakeApplier) IsInstalled(job models.Job) (bool, error) {
+	for _, installedJob := range s.InstalledJobs {
+		if installedJob.Name == job.Name && installedJob.Version == job.Version {
+			return true, s.IsInstalledErr
+		}
+	}
+
+	return false, s.IsInstalledErr
+}
+
+func (s *FakeApplier) PlanKeepOnly(jobs []models.Job) ([]string, error) {
+	s.PlanKeepOnlyJobs = jobs
+	return s.PlanKeepOnlyPaths, s.PlanKeepOnlyErr
+}
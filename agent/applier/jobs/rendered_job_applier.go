@@ -9,6 +9,8 @@ import (
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -50,6 +52,10 @@ func NewRenderedJobApplier(
 }
 
 func (s renderedJobApplier) Prepare(job models.Job) error {
+	return s.prepare(job, nil)
+}
+
+func (s renderedJobApplier) prepare(job models.Job, cancelled boshtask.CancelSignal) error {
 	s.logger.Debug(logTag, "Preparing job %v", job)
 
 	jobBundle, err := s.jobsBc.Get(job)
@@ -63,7 +69,7 @@ func (s renderedJobApplier) Prepare(job models.Job) error {
 	}
 
 	if !jobInstalled {
-		err := s.downloadAndInstall(job, jobBundle)
+		err := s.downloadAndInstall(job, jobBundle, cancelled)
 		if err != nil {
 			return err
 		}
@@ -72,10 +78,10 @@ func (s renderedJobApplier) Prepare(job models.Job) error {
 	return nil
 }
 
-func (s *renderedJobApplier) Apply(job models.Job) error {
+func (s *renderedJobApplier) Apply(job models.Job, cancelled boshtask.CancelSignal) error {
 	s.logger.Debug(logTag, "Applying job %v", job)
 
-	err := s.Prepare(job)
+	err := s.prepare(job, cancelled)
 	if err != nil {
 		return bosherr.WrapError(err, "Preparing job")
 	}
@@ -90,10 +96,10 @@ func (s *renderedJobApplier) Apply(job models.Job) error {
 		return bosherr.WrapError(err, "Enabling job")
 	}
 
-	return s.applyPackages(job)
+	return s.applyPackages(job, cancelled)
 }
 
-func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc.Bundle) error {
+func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc.Bundle, cancelled boshtask.CancelSignal) error {
 	tmpDir, err := s.fs.TempDir("bosh-agent-applier-jobs-RenderedJobApplier-Apply")
 	if err != nil {
 		return bosherr.WrapError(err, "Getting temp dir")
@@ -105,7 +111,7 @@ func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc
 		}
 	}()
 
-	file, err := s.blobstore.Get(job.Source.BlobstoreID, job.Source.Sha1)
+	file, err := boshagentblob.GetUnlessCancelled(s.blobstore, job.Source.BlobstoreID, job.Source.Sha1, cancelled)
 	if err != nil {
 		return bosherr.WrapError(err, "Getting job source from blobstore")
 	}
@@ -145,11 +151,11 @@ func (s *renderedJobApplier) downloadAndInstall(job models.Job, jobBundle boshbc
 
 // applyPackages keeps job specific packages directory up-to-date with installed packages.
 // (e.g. /var/vcap/jobs/job-a/packages/pkg-a has symlinks to /var/vcap/packages/pkg-a)
-func (s *renderedJobApplier) applyPackages(job models.Job) error {
+func (s *renderedJobApplier) applyPackages(job models.Job, cancelled boshtask.CancelSignal) error {
 	packageApplier := s.packageApplierProvider.JobSpecific(job.Name)
 
 	for _, pkg := range job.Packages {
-		err := packageApplier.Apply(pkg)
+		err := packageApplier.Apply(pkg, cancelled)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Applying package %s for job %s", pkg.Name, job.Name)
 		}
@@ -210,18 +216,78 @@ func (s *renderedJobApplier) Configure(job models.Job, jobIndex int) (err error)
 func (s *renderedJobApplier) KeepOnly(jobs []models.Job) error {
 	s.logger.Debug(logTag, "Keeping only jobs %v", jobs)
 
+	removedBundles, err := s.bundlesNotKept(jobs)
+	if err != nil {
+		return err
+	}
+
+	for _, installedBundle := range removedBundles {
+		err = installedBundle.Disable()
+		if err != nil {
+			return bosherr.WrapError(err, "Disabling job bundle")
+		}
+
+		// If we uninstall the bundle first, and the disable failed (leaving the symlink),
+		// then the next time bundle collection will not include bundle in its list
+		// which means that symlink will never be deleted.
+		err = installedBundle.Uninstall()
+		if err != nil {
+			return bosherr.WrapError(err, "Uninstalling job bundle")
+		}
+	}
+
+	return nil
+}
+
+func (s *renderedJobApplier) IsInstalled(job models.Job) (bool, error) {
+	jobBundle, err := s.jobsBc.Get(job)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting job bundle")
+	}
+
+	jobInstalled, err := jobBundle.IsInstalled()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Checking if job is installed")
+	}
+
+	return jobInstalled, nil
+}
+
+func (s *renderedJobApplier) PlanKeepOnly(jobs []models.Job) ([]string, error) {
+	removedBundles, err := s.bundlesNotKept(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	removedPaths := []string{}
+
+	for _, installedBundle := range removedBundles {
+		_, installPath, err := installedBundle.GetInstallPath()
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Getting job bundle install path")
+		}
+
+		removedPaths = append(removedPaths, installPath)
+	}
+
+	return removedPaths, nil
+}
+
+func (s *renderedJobApplier) bundlesNotKept(jobs []models.Job) ([]boshbc.Bundle, error) {
 	installedBundles, err := s.jobsBc.List()
 	if err != nil {
-		return bosherr.WrapError(err, "Retrieving installed bundles")
+		return nil, bosherr.WrapError(err, "Retrieving installed bundles")
 	}
 
+	var removedBundles []boshbc.Bundle
+
 	for _, installedBundle := range installedBundles {
 		var shouldKeep bool
 
 		for _, job := range jobs {
 			jobBundle, err := s.jobsBc.Get(job)
 			if err != nil {
-				return bosherr.WrapError(err, "Getting job bundle")
+				return nil, bosherr.WrapError(err, "Getting job bundle")
 			}
 
 			if jobBundle == installedBundle {
@@ -231,20 +297,9 @@ func (s *renderedJobApplier) KeepOnly(jobs []models.Job) error {
 		}
 
 		if !shouldKeep {
-			err = installedBundle.Disable()
-			if err != nil {
-				return bosherr.WrapError(err, "Disabling job bundle")
-			}
-
-			// If we uninstall the bundle first, and the disable failed (leaving the symlink),
-			// then the next time bundle collection will not include bundle in its list
-			// which means that symlink will never be deleted.
-			err = installedBundle.Uninstall()
-			if err != nil {
-				return bosherr.WrapError(err, "Uninstalling job bundle")
-			}
+			removedBundles = append(removedBundles, installedBundle)
 		}
 	}
 
-	return nil
+	return removedBundles, nil
 }
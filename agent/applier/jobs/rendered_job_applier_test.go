@@ -11,6 +11,7 @@ import (
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/jobs"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakejobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor/fakes"
 	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
@@ -403,7 +404,7 @@ func init() {
 
 			Describe("Apply", func() {
 				act := func() error {
-					return applier.Apply(job)
+					return applier.Apply(job, nil)
 				}
 
 				It("return an error if getting file bundle fails", func() {
@@ -461,6 +462,28 @@ func init() {
 						Expect(bundle.ActionsCalled).To(Equal([]string{"Install", "Enable"}))
 					})
 
+					It("does not download or install job when cancelled", func() {
+						canceller := boshtask.NewCanceller()
+						canceller.Cancel()
+
+						err := applier.Apply(job, canceller.Signal())
+						Expect(err).To(HaveOccurred())
+						Expect(boshtask.IsCancelled(err)).To(BeTrue())
+						Expect(blobstore.GetCallCount()).To(Equal(0))
+						Expect(bundle.ActionsCalled).To(Equal([]string{}))
+					})
+
+					It("passes cancel signal when applying packages", func() {
+						packageApplier := fakepackages.NewFakeApplier()
+						packageApplierProvider.JobSpecificAppliers[job.Name] = packageApplier
+
+						canceller := boshtask.NewCanceller()
+
+						err := applier.Apply(job, canceller.Signal())
+						Expect(err).ToNot(HaveOccurred())
+						Expect(packageApplier.ApplyCancelSignal).To(Equal(canceller.Signal()))
+					})
+
 					It("returns error when job enable fails", func() {
 						bundle.EnableError = errors.New("fake-enable-error")
 
@@ -576,5 +599,52 @@ func init() {
 				Expect(err.Error()).To(ContainSubstring("fake-bc-uninstall-error"))
 			})
 		})
+
+		Describe("PlanKeepOnly", func() {
+			It("returns install paths of jobs that are not in keeponly list without removing them", func() {
+				_, bundle1 := buildJob(jobsBc)
+				job2, bundle2 := buildJob(jobsBc)
+
+				bundle1.GetDirPath = "/fake-jobs/job1"
+				bundle2.GetDirPath = "/fake-jobs/job2"
+
+				jobsBc.ListBundles = []boshbc.Bundle{bundle1, bundle2}
+
+				removedPaths, err := applier.PlanKeepOnly([]models.Job{job2})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(removedPaths).To(Equal([]string{"/fake-jobs/job1"}))
+
+				Expect(bundle1.ActionsCalled).To(Equal([]string{}))
+				Expect(bundle2.ActionsCalled).To(Equal([]string{}))
+			})
+
+			It("returns error when bundle collection fails to return list of installed bundles", func() {
+				jobsBc.ListErr = errors.New("fake-bc-list-error")
+
+				_, err := applier.PlanKeepOnly([]models.Job{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-bc-list-error"))
+			})
+		})
+
+		Describe("IsInstalled", func() {
+			It("returns whether job bundle is installed", func() {
+				job, bundle := buildJob(jobsBc)
+				bundle.Installed = true
+
+				installed, err := applier.IsInstalled(job)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(installed).To(BeTrue())
+			})
+
+			It("returns error when checking bundle fails", func() {
+				job, bundle := buildJob(jobsBc)
+				bundle.IsInstalledErr = errors.New("fake-is-installed-error")
+
+				_, err := applier.IsInstalled(job)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-is-installed-error"))
+			})
+		})
 	})
 }
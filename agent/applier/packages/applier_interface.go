@@ -2,10 +2,15 @@ package packages
 
 import (
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type Applier interface {
 	Prepare(pkg models.Package) error
-	Apply(pkg models.Package) error
+	Apply(pkg models.Package, cancelled boshtask.CancelSignal) error
 	KeepOnly(pkgs []models.Package) error
+
+	// IsInstalled and PlanKeepOnly describe what Apply and KeepOnly would do
+	IsInstalled(pkg models.Package) (bool, error)
+	PlanKeepOnly(pkgs []models.Package) (removedPaths []string, err error)
 }
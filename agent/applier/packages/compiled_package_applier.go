@@ -3,6 +3,8 @@ package packages
 import (
 	bc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
@@ -43,6 +45,10 @@ func NewCompiledPackageApplier(
 }
 
 func (s compiledPackageApplier) Prepare(pkg models.Package) error {
+	return s.prepare(pkg, nil)
+}
+
+func (s compiledPackageApplier) prepare(pkg models.Package, cancelled boshtask.CancelSignal) error {
 	s.logger.Debug(logTag, "Preparing package %v", pkg)
 
 	pkgBundle, err := s.packagesBc.Get(pkg)
@@ -56,7 +62,7 @@ func (s compiledPackageApplier) Prepare(pkg models.Package) error {
 	}
 
 	if !pkgInstalled {
-		err := s.downloadAndInstall(pkg, pkgBundle)
+		err := s.downloadAndInstall(pkg, pkgBundle, cancelled)
 		if err != nil {
 			return err
 		}
@@ -65,10 +71,10 @@ func (s compiledPackageApplier) Prepare(pkg models.Package) error {
 	return nil
 }
 
-func (s compiledPackageApplier) Apply(pkg models.Package) error {
+func (s compiledPackageApplier) Apply(pkg models.Package, cancelled boshtask.CancelSignal) error {
 	s.logger.Debug(logTag, "Applying package %v", pkg)
 
-	err := s.Prepare(pkg)
+	err := s.prepare(pkg, cancelled)
 	if err != nil {
 		return err
 	}
@@ -86,7 +92,7 @@ func (s compiledPackageApplier) Apply(pkg models.Package) error {
 	return nil
 }
 
-func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundle bc.Bundle) error {
+func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundle bc.Bundle, cancelled boshtask.CancelSignal) error {
 	tmpDir, err := s.fs.TempDir("bosh-agent-applier-packages-CompiledPackageApplier-Apply")
 	if err != nil {
 		return bosherr.WrapError(err, "Getting temp dir")
@@ -98,7 +104,7 @@ func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundl
 		}
 	}()
 
-	file, err := s.blobstore.Get(pkg.Source.BlobstoreID, pkg.Source.Sha1)
+	file, err := boshagentblob.GetUnlessCancelled(s.blobstore, pkg.Source.BlobstoreID, pkg.Source.Sha1, cancelled)
 	if err != nil {
 		return bosherr.WrapError(err, "Fetching package blob")
 	}
@@ -125,18 +131,81 @@ func (s *compiledPackageApplier) downloadAndInstall(pkg models.Package, pkgBundl
 func (s *compiledPackageApplier) KeepOnly(pkgs []models.Package) error {
 	s.logger.Debug(logTag, "Keeping only packages %v", pkgs)
 
+	removedBundles, err := s.bundlesNotKept(pkgs)
+	if err != nil {
+		return err
+	}
+
+	for _, installedBundle := range removedBundles {
+		err = installedBundle.Disable()
+		if err != nil {
+			return bosherr.WrapError(err, "Disabling package bundle")
+		}
+
+		if s.packagesBcOwner {
+			// If we uninstall the bundle first, and the disable failed (leaving the symlink),
+			// then the next time bundle collection will not include bundle in its list
+			// which means that symlink will never be deleted.
+			err = installedBundle.Uninstall()
+			if err != nil {
+				return bosherr.WrapError(err, "Uninstalling package bundle")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *compiledPackageApplier) IsInstalled(pkg models.Package) (bool, error) {
+	pkgBundle, err := s.packagesBc.Get(pkg)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting package bundle")
+	}
+
+	pkgInstalled, err := pkgBundle.IsInstalled()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Checking if package is installed")
+	}
+
+	return pkgInstalled, nil
+}
+
+// PlanKeepOnly includes packages that are only disabled when not operating as owner
+func (s *compiledPackageApplier) PlanKeepOnly(pkgs []models.Package) ([]string, error) {
+	removedBundles, err := s.bundlesNotKept(pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	removedPaths := []string{}
+
+	for _, installedBundle := range removedBundles {
+		_, installPath, err := installedBundle.GetInstallPath()
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Getting package bundle install path")
+		}
+
+		removedPaths = append(removedPaths, installPath)
+	}
+
+	return removedPaths, nil
+}
+
+func (s *compiledPackageApplier) bundlesNotKept(pkgs []models.Package) ([]bc.Bundle, error) {
 	installedBundles, err := s.packagesBc.List()
 	if err != nil {
-		return bosherr.WrapError(err, "Retrieving installed bundles")
+		return nil, bosherr.WrapError(err, "Retrieving installed bundles")
 	}
 
+	var removedBundles []bc.Bundle
+
 	for _, installedBundle := range installedBundles {
 		var shouldKeep bool
 
 		for _, pkg := range pkgs {
 			pkgBundle, err := s.packagesBc.Get(pkg)
 			if err != nil {
-				return bosherr.WrapError(err, "Getting package bundle")
+				return nil, bosherr.WrapError(err, "Getting package bundle")
 			}
 
 			if pkgBundle == installedBundle {
@@ -146,22 +215,9 @@ func (s *compiledPackageApplier) KeepOnly(pkgs []models.Package) error {
 		}
 
 		if !shouldKeep {
-			err = installedBundle.Disable()
-			if err != nil {
-				return bosherr.WrapError(err, "Disabling package bundle")
-			}
-
-			if s.packagesBcOwner {
-				// If we uninstall the bundle first, and the disable failed (leaving the symlink),
-				// then the next time bundle collection will not include bundle in its list
-				// which means that symlink will never be deleted.
-				err = installedBundle.Uninstall()
-				if err != nil {
-					return bosherr.WrapError(err, "Uninstalling package bundle")
-				}
-			}
+			removedBundles = append(removedBundles, installedBundle)
 		}
 	}
 
-	return nil
+	return removedBundles, nil
 }
@@ -10,6 +10,7 @@ import (
 	fakebc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection/fakes"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	. "github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
@@ -240,7 +241,7 @@ func init() {
 			})
 
 			Describe("Apply", func() {
-				act := func() error { return applier.Apply(pkg) }
+				act := func() error { return applier.Apply(pkg, nil) }
 
 				It("return an error if getting file bundle fails", func() {
 					packagesBc.GetErr = errors.New("fake-get-bundle-error")
@@ -295,6 +296,17 @@ func init() {
 						Expect(bundle.ActionsCalled).To(Equal([]string{"Install", "Enable"}))
 					})
 
+					It("does not download or install package when cancelled", func() {
+						canceller := boshtask.NewCanceller()
+						canceller.Cancel()
+
+						err := applier.Apply(pkg, canceller.Signal())
+						Expect(err).To(HaveOccurred())
+						Expect(boshtask.IsCancelled(err)).To(BeTrue())
+						Expect(blobstore.GetCallCount()).To(Equal(0))
+						Expect(bundle.ActionsCalled).To(Equal([]string{}))
+					})
+
 					It("returns error when package enable fails", func() {
 						bundle.EnableError = errors.New("fake-enable-error")
 
@@ -403,5 +415,43 @@ func init() {
 			})
 
 		})
+
+		Describe("PlanKeepOnly", func() {
+			It("returns install paths of packages that are not in keeponly list without removing them", func() {
+				_, bundle1 := buildPkg(packagesBc)
+				pkg2, bundle2 := buildPkg(packagesBc)
+
+				bundle1.GetDirPath = "/fake-packages/pkg1"
+				bundle2.GetDirPath = "/fake-packages/pkg2"
+
+				packagesBc.ListBundles = []boshbc.Bundle{bundle1, bundle2}
+
+				removedPaths, err := applier.PlanKeepOnly([]models.Package{pkg2})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(removedPaths).To(Equal([]string{"/fake-packages/pkg1"}))
+
+				Expect(bundle1.ActionsCalled).To(Equal([]string{}))
+				Expect(bundle2.ActionsCalled).To(Equal([]string{}))
+			})
+
+			It("returns error when bundle collection fails to return list of installed bundles", func() {
+				packagesBc.ListErr = errors.New("fake-bc-list-error")
+
+				_, err := applier.PlanKeepOnly([]models.Package{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-bc-list-error"))
+			})
+		})
+
+		Describe("IsInstalled", func() {
+			It("returns whether package bundle is installed", func() {
+				pkg, bundle := buildPkg(packagesBc)
+				bundle.Installed = true
+
+				installed, err := applier.IsInstalled(pkg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(installed).To(BeTrue())
+			})
+		})
 	})
 }
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	models "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 )
 
 type FakeApplier struct {
@@ -10,11 +11,19 @@ type FakeApplier struct {
 	PreparedPackages []models.Package
 	PrepareError     error
 
-	AppliedPackages []models.Package
-	ApplyError      error
+	AppliedPackages   []models.Package
+	ApplyCancelSignal boshtask.CancelSignal
+	ApplyError        error
 
 	KeptOnlyPackages []models.Package
 	KeepOnlyErr      error
+
+	InstalledPackages []models.Package
+	IsInstalledErr    error
+
+	PlanKeepOnlyPackages []models.Package
+	PlanKeepOnlyPaths    []string
+	PlanKeepOnlyErr      error
 }
 
 func NewFakeApplier() *FakeApplier {
@@ -29,9 +38,10 @@ func (s *FakeApplier) Prepare(pkg models.Package) error {
 	return s.PrepareError
 }
 
-func (s *FakeApplier) Apply(pkg models.Package) error {
+func (s *FakeApplier) Apply(pkg models.Package, cancelled boshtask.CancelSignal) error {
 	s.ActionsCalled = append(s.ActionsCalled, "Apply")
 	s.AppliedPackages = append(s.AppliedPackages, pkg)
+	s.ApplyCancelSignal = cancelled
 	return s.ApplyError
 }
 
@@ -40,3 +50,18 @@ func (s *FakeApplier) KeepOnly(pkgs []models.Package) error {
 	s.KeptOnlyPackages = pkgs
 	return s.KeepOnlyErr
 }
+
+func (s *FakeApplier) IsInstalled(pkg models.Package) (bool, error) {
+	for _, installedPkg := range s.InstalledPackages {
+		if installedPkg.Name == pkg.Name && installedPkg.Version == pkg.Version {
+			return true, s.IsInstalledErr
+		}
+	}
+
+	return false, s.IsInstalledErr
+}
+
+func (s *FakeApplier) PlanKeepOnly(pkgs []models.Package) ([]string, error) {
+	s.PlanKeepOnlyPackages = pkgs
+	return s.PlanKeepOnlyPaths, s.PlanKeepOnlyErr
+}
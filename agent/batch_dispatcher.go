@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+)
+
+// BatchMethod is dispatched without an action so that
+// each of its entries is authorized and run like a separate request.
+const BatchMethod = "batch"
+
+const (
+	// maxBatchEntries keeps a single request from queueing unbounded work
+	maxBatchEntries = 100
+
+	// batchParallelism is number of entries of a parallel batch dispatched at a time
+	batchParallelism = 8
+)
+
+type batchArguments struct {
+	// Parallel runs entries concurrently instead of one after another
+	Parallel bool `json:"parallel"`
+
+	// Each entry is a request with method and arguments
+	Entries []json.RawMessage `json:"entries"`
+}
+
+type batchEntry struct {
+	Method string `json:"method"`
+}
+
+// batchResponse keeps one response per entry in the order of entries
+type batchResponse struct {
+	Value []boshhandler.Response `json:"value"`
+}
+
+func (r batchResponse) Shorten() boshhandler.Response {
+	shortened := make([]boshhandler.Response, len(r.Value))
+
+	for i, resp := range r.Value {
+		shortened[i] = resp.Shorten()
+	}
+
+	return batchResponse{Value: shortened}
+}
+
+// dispatchBatch responds with exception only if batch itself is invalid;
+// failures of entries are included in their responses.
+func (dispatcher concreteActionDispatcher) dispatchBatch(req boshhandler.Request) boshhandler.Response {
+	var payload struct {
+		Arguments []batchArguments `json:"arguments"`
+	}
+
+	err := json.Unmarshal(req.GetPayload(), &payload)
+	if err != nil || len(payload.Arguments) != 1 {
+		return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(
+			boshhandler.ErrorCodeInvalidArguments, "Batch expects a single argument with entries"))
+	}
+
+	args := payload.Arguments[0]
+
+	if len(args.Entries) > maxBatchEntries {
+		return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(
+			boshhandler.ErrorCodeInvalidArguments, "Batch has %d entries, at most %d are allowed", len(args.Entries), maxBatchEntries))
+	}
+
+	entryReqs := make([]boshhandler.Request, len(args.Entries))
+
+	for i, entryJSON := range args.Entries {
+		var entry batchEntry
+
+		err = json.Unmarshal(entryJSON, &entry)
+		if err != nil {
+			return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(
+				boshhandler.ErrorCodeInvalidArguments, "Unmarshalling batch entry %d: %s", i, err.Error()))
+		}
+
+		if entry.Method == BatchMethod {
+			return boshhandler.NewExceptionResponse(boshhandler.NewErrorf(
+				boshhandler.ErrorCodeInvalidArguments, "Batch entry %d cannot be a batch", i))
+		}
+
+		entryReq := boshhandler.NewRequest(req.ReplyTo, entry.Method, entryJSON, req.ProtocolVersion)
+		entryReq.DryRun = req.DryRun
+		entryReq.Caller = req.Caller
+
+		// Entries of a retried batch find their own cached responses
+		if req.RequestID != "" {
+			entryReq.RequestID = fmt.Sprintf("%s.%d", req.RequestID, i)
+		}
+
+		entryReqs[i] = entryReq
+	}
+
+	dispatcher.logger.Info(actionDispatcherLogTag, "Running batch of %d entries (parallel: %t)", len(entryReqs), args.Parallel)
+
+	resps := make([]boshhandler.Response, len(entryReqs))
+
+	if !args.Parallel {
+		for i, entryReq := range entryReqs {
+			resps[i] = dispatcher.Dispatch(entryReq)
+		}
+
+		return batchResponse{Value: resps}
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < batchParallelism && w < len(entryReqs); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				resps[i] = dispatcher.Dispatch(entryReqs[i])
+			}
+		}()
+	}
+
+	for i := range entryReqs {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return batchResponse{Value: resps}
+}
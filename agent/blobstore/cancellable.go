@@ -0,0 +1,90 @@
+package blobstore
+
+import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshUtilsBlobStore "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type getResult struct {
+	fileName string
+	err      error
+}
+
+// GetUnlessCancelled stops waiting for download once cancel signal is closed.
+// Blobstores cannot interrupt downloads so abandoned blobs are cleaned up once downloaded.
+func GetUnlessCancelled(
+	blobstore boshUtilsBlobStore.DigestBlobstore,
+	blobID string,
+	digest boshcrypto.Digest,
+	cancelled boshtask.CancelSignal,
+) (string, error) {
+	err := cancelled.Check()
+	if err != nil {
+		return "", err
+	}
+
+	resultCh := make(chan getResult, 1)
+
+	go func() {
+		fileName, err := blobstore.Get(blobID, digest)
+		resultCh <- getResult{fileName: fileName, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.fileName, result.err
+
+	case <-cancelled:
+		go func() {
+			result := <-resultCh
+			if result.err == nil {
+				_ = blobstore.CleanUp(result.fileName)
+			}
+		}()
+
+		return "", bosherr.WrapErrorf(boshtask.ErrCancelled, "Downloading blob %s", blobID)
+	}
+}
+
+type createResult struct {
+	blobID string
+	digest boshcrypto.MultipleDigest
+	err    error
+}
+
+// CreateUnlessCancelled stops waiting for upload once cancel signal is closed.
+// Abandoned blobs are deleted once uploaded since nobody will refer to them.
+func CreateUnlessCancelled(
+	blobstore boshUtilsBlobStore.DigestBlobstore,
+	fileName string,
+	cancelled boshtask.CancelSignal,
+) (string, boshcrypto.MultipleDigest, error) {
+	err := cancelled.Check()
+	if err != nil {
+		return "", boshcrypto.MultipleDigest{}, err
+	}
+
+	resultCh := make(chan createResult, 1)
+
+	go func() {
+		blobID, digest, err := blobstore.Create(fileName)
+		resultCh <- createResult{blobID: blobID, digest: digest, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.blobID, result.digest, result.err
+
+	case <-cancelled:
+		go func() {
+			result := <-resultCh
+			if result.err == nil {
+				_ = blobstore.Delete(result.blobID)
+			}
+		}()
+
+		return "", boshcrypto.MultipleDigest{}, bosherr.WrapErrorf(boshtask.ErrCancelled, "Uploading %s", fileName)
+	}
+}
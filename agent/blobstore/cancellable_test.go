@@ -0,0 +1,109 @@
+package blobstore_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("Cancellable blobstore operations", func() {
+	var (
+		innerBlobstore *fakeblob.FakeDigestBlobstore
+		canceller      *boshtask.Canceller
+		digest         boshcrypto.Digest
+	)
+
+	BeforeEach(func() {
+		innerBlobstore = &fakeblob.FakeDigestBlobstore{}
+		canceller = boshtask.NewCanceller()
+		digest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-checksum")
+	})
+
+	Describe("GetUnlessCancelled", func() {
+		It("returns downloaded blob when not cancelled", func() {
+			innerBlobstore.GetReturns("/fake-blob-path", nil)
+
+			fileName, err := blobstore.GetUnlessCancelled(innerBlobstore, "fake-blob-id", digest, canceller.Signal())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-path"))
+
+			receivedBlobID, receivedDigest := innerBlobstore.GetArgsForCall(0)
+			Expect(receivedBlobID).To(Equal("fake-blob-id"))
+			Expect(receivedDigest).To(Equal(digest))
+		})
+
+		It("returns download error", func() {
+			innerBlobstore.GetReturns("", errors.New("fake-get-err"))
+
+			_, err := blobstore.GetUnlessCancelled(innerBlobstore, "fake-blob-id", digest, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("fake-get-err"))
+		})
+
+		It("does not start download when already cancelled", func() {
+			canceller.Cancel()
+
+			_, err := blobstore.GetUnlessCancelled(innerBlobstore, "fake-blob-id", digest, canceller.Signal())
+			Expect(boshtask.IsCancelled(err)).To(BeTrue())
+			Expect(innerBlobstore.GetCallCount()).To(Equal(0))
+		})
+
+		It("returns cancelled error during download and cleans up abandoned blob", func() {
+			release := make(chan struct{})
+
+			innerBlobstore.GetStub = func(string, boshcrypto.Digest) (string, error) {
+				canceller.Cancel()
+				<-release
+				return "/fake-blob-path", nil
+			}
+
+			_, err := blobstore.GetUnlessCancelled(innerBlobstore, "fake-blob-id", digest, canceller.Signal())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Downloading blob fake-blob-id: Task was cancelled"))
+			Expect(boshtask.IsCancelled(err)).To(BeTrue())
+
+			close(release)
+
+			Eventually(innerBlobstore.CleanUpCallCount).Should(Equal(1))
+			Expect(innerBlobstore.CleanUpArgsForCall(0)).To(Equal("/fake-blob-path"))
+		})
+	})
+
+	Describe("CreateUnlessCancelled", func() {
+		It("returns uploaded blob when not cancelled", func() {
+			multiDigest := boshcrypto.MustNewMultipleDigest(digest)
+			innerBlobstore.CreateReturns("fake-blob-id", multiDigest, nil)
+
+			blobID, receivedDigest, err := blobstore.CreateUnlessCancelled(innerBlobstore, "/fake-file", canceller.Signal())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(receivedDigest).To(Equal(multiDigest))
+			Expect(innerBlobstore.CreateArgsForCall(0)).To(Equal("/fake-file"))
+		})
+
+		It("returns cancelled error during upload and deletes abandoned blob", func() {
+			release := make(chan struct{})
+
+			innerBlobstore.CreateStub = func(string) (string, boshcrypto.MultipleDigest, error) {
+				canceller.Cancel()
+				<-release
+				return "fake-blob-id", boshcrypto.MultipleDigest{}, nil
+			}
+
+			_, _, err := blobstore.CreateUnlessCancelled(innerBlobstore, "/fake-file", canceller.Signal())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Uploading /fake-file: Task was cancelled"))
+
+			close(release)
+
+			Eventually(innerBlobstore.DeleteCallCount).Should(Equal(1))
+			Expect(innerBlobstore.DeleteArgsForCall(0)).To(Equal("fake-blob-id"))
+		})
+	})
+})
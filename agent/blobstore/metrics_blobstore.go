@@ -0,0 +1,65 @@
+package blobstore
+
+import (
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshUtilsBlobStore "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type metricsBlobstore struct {
+	innerBlobstore boshUtilsBlobStore.DigestBlobstore
+	fs             boshsys.FileSystem
+
+	downloads      boshmetrics.Counter
+	downloadBytes  boshmetrics.Counter
+	downloadErrors boshmetrics.Counter
+}
+
+// NewMetricsBlobstore counts blobs and bytes downloaded by inner blobstore
+func NewMetricsBlobstore(
+	innerBlobstore boshUtilsBlobStore.DigestBlobstore,
+	fs boshsys.FileSystem,
+	metrics *boshmetrics.Registry) boshUtilsBlobStore.DigestBlobstore {
+	return metricsBlobstore{
+		innerBlobstore: innerBlobstore,
+		fs:             fs,
+
+		downloads:      metrics.Counter("bosh_agent_blob_downloads_total", "Number of blobs downloaded from blobstore."),
+		downloadBytes:  metrics.Counter("bosh_agent_blob_download_bytes_total", "Number of bytes downloaded from blobstore."),
+		downloadErrors: metrics.Counter("bosh_agent_blob_download_errors_total", "Number of blob downloads that failed."),
+	}
+}
+
+func (b metricsBlobstore) Get(blobID string, digest boshcrypto.Digest) (string, error) {
+	fileName, err := b.innerBlobstore.Get(blobID, digest)
+	if err != nil {
+		b.downloadErrors.Inc()
+		return "", err
+	}
+
+	b.downloads.Inc()
+
+	// Size is only informational so blob is returned even if it cannot be determined
+	if info, statErr := b.fs.Stat(fileName); statErr == nil {
+		b.downloadBytes.Add(float64(info.Size()))
+	}
+
+	return fileName, nil
+}
+
+func (b metricsBlobstore) CleanUp(fileName string) error {
+	return b.innerBlobstore.CleanUp(fileName)
+}
+
+func (b metricsBlobstore) Create(fileName string) (string, boshcrypto.MultipleDigest, error) {
+	return b.innerBlobstore.Create(fileName)
+}
+
+func (b metricsBlobstore) Validate() error {
+	return b.innerBlobstore.Validate()
+}
+
+func (b metricsBlobstore) Delete(blobID string) error {
+	return b.innerBlobstore.Delete(blobID)
+}
@@ -0,0 +1,77 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("metricsBlobstore", func() {
+	var (
+		innerBlobstore   *fakeblob.FakeDigestBlobstore
+		fs               *fakesys.FakeFileSystem
+		metrics          *boshmetrics.Registry
+		metricsBlobstore boshblob.DigestBlobstore
+		digest           boshcrypto.Digest
+	)
+
+	BeforeEach(func() {
+		innerBlobstore = &fakeblob.FakeDigestBlobstore{}
+		fs = fakesys.NewFakeFileSystem()
+		metrics = boshmetrics.NewRegistry()
+		digest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-checksum")
+
+		metricsBlobstore = blobstore.NewMetricsBlobstore(innerBlobstore, fs, metrics)
+	})
+
+	metricsText := func() string {
+		buf := bytes.NewBufferString("")
+		Expect(metrics.WriteText(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	Describe("Get", func() {
+		It("returns downloaded blob and counts its bytes", func() {
+			fs.WriteFileString("/fake-blob-path", "fake-contents")
+			innerBlobstore.GetReturns("/fake-blob-path", nil)
+
+			fileName, err := metricsBlobstore.Get("fake-blob-id", digest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fileName).To(Equal("/fake-blob-path"))
+
+			receivedBlobID, receivedDigest := innerBlobstore.GetArgsForCall(0)
+			Expect(receivedBlobID).To(Equal("fake-blob-id"))
+			Expect(receivedDigest).To(Equal(digest))
+
+			Expect(metricsText()).To(ContainSubstring("bosh_agent_blob_downloads_total 1\n"))
+			Expect(metricsText()).To(ContainSubstring("bosh_agent_blob_download_bytes_total 13\n"))
+		})
+
+		It("counts failed downloads", func() {
+			innerBlobstore.GetReturns("", errors.New("fake-get-err"))
+
+			_, err := metricsBlobstore.Get("fake-blob-id", digest)
+			Expect(err).To(MatchError("fake-get-err"))
+
+			Expect(metricsText()).To(ContainSubstring("bosh_agent_blob_download_errors_total 1\n"))
+			Expect(metricsText()).ToNot(ContainSubstring("bosh_agent_blob_downloads_total 1"))
+		})
+	})
+
+	It("delegates other calls to inner blobstore", func() {
+		innerBlobstore.DeleteReturns(errors.New("fake-delete-err"))
+
+		err := metricsBlobstore.Delete("fake-blob-id")
+		Expect(err).To(MatchError("fake-delete-err"))
+		Expect(innerBlobstore.DeleteArgsForCall(0)).To(Equal("fake-blob-id"))
+	})
+})
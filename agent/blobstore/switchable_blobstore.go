@@ -0,0 +1,53 @@
+package blobstore
+
+import (
+	"sync"
+
+	boshUtilsBlobStore "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+// SwitchableBlobstore lets blobstore be replaced when blobstore settings change
+// without rebuilding everything that refers to it. Operations already started
+// finish with the blobstore they were started with.
+type SwitchableBlobstore struct {
+	lock           sync.RWMutex
+	innerBlobstore boshUtilsBlobStore.DigestBlobstore
+}
+
+func NewSwitchableBlobstore(innerBlobstore boshUtilsBlobStore.DigestBlobstore) *SwitchableBlobstore {
+	return &SwitchableBlobstore{innerBlobstore: innerBlobstore}
+}
+
+func (b *SwitchableBlobstore) Switch(innerBlobstore boshUtilsBlobStore.DigestBlobstore) {
+	b.lock.Lock()
+	b.innerBlobstore = innerBlobstore
+	b.lock.Unlock()
+}
+
+func (b *SwitchableBlobstore) current() boshUtilsBlobStore.DigestBlobstore {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.innerBlobstore
+}
+
+func (b *SwitchableBlobstore) Get(blobID string, digest boshcrypto.Digest) (string, error) {
+	return b.current().Get(blobID, digest)
+}
+
+func (b *SwitchableBlobstore) CleanUp(fileName string) error {
+	return b.current().CleanUp(fileName)
+}
+
+func (b *SwitchableBlobstore) Create(fileName string) (string, boshcrypto.MultipleDigest, error) {
+	return b.current().Create(fileName)
+}
+
+func (b *SwitchableBlobstore) Validate() error {
+	return b.current().Validate()
+}
+
+func (b *SwitchableBlobstore) Delete(blobID string) error {
+	return b.current().Delete(blobID)
+}
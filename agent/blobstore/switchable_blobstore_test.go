@@ -0,0 +1,60 @@
+package blobstore_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-agent/agent/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("SwitchableBlobstore", func() {
+	var (
+		initialBlobstore    *fakeblob.FakeDigestBlobstore
+		switchedBlobstore   *fakeblob.FakeDigestBlobstore
+		switchableBlobstore *blobstore.SwitchableBlobstore
+		digest              boshcrypto.Digest
+	)
+
+	BeforeEach(func() {
+		initialBlobstore = &fakeblob.FakeDigestBlobstore{}
+		switchedBlobstore = &fakeblob.FakeDigestBlobstore{}
+		switchableBlobstore = blobstore.NewSwitchableBlobstore(initialBlobstore)
+		digest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-checksum")
+	})
+
+	It("delegates to initial blobstore", func() {
+		initialBlobstore.GetReturns("/fake-initial-path", nil)
+
+		fileName, err := switchableBlobstore.Get("fake-blob-id", digest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal("/fake-initial-path"))
+	})
+
+	It("delegates to blobstore it was switched to", func() {
+		switchedBlobstore.GetReturns("/fake-switched-path", nil)
+		switchedBlobstore.CreateReturns("fake-blob-id", boshcrypto.MultipleDigest{}, nil)
+
+		switchableBlobstore.Switch(switchedBlobstore)
+
+		fileName, err := switchableBlobstore.Get("fake-blob-id", digest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal("/fake-switched-path"))
+
+		blobID, _, err := switchableBlobstore.Create("/fake-file")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blobID).To(Equal("fake-blob-id"))
+
+		Expect(switchableBlobstore.Delete("fake-blob-id")).To(Succeed())
+		Expect(switchableBlobstore.CleanUp("/fake-switched-path")).To(Succeed())
+		Expect(switchableBlobstore.Validate()).To(Succeed())
+
+		Expect(switchedBlobstore.DeleteCallCount()).To(Equal(1))
+		Expect(switchedBlobstore.CleanUpCallCount()).To(Equal(1))
+		Expect(switchedBlobstore.ValidateCallCount()).To(Equal(1))
+
+		Expect(initialBlobstore.GetCallCount()).To(Equal(0))
+		Expect(initialBlobstore.CreateCallCount()).To(Equal(0))
+	})
+})
@@ -24,6 +24,7 @@ type bootstrap struct {
 	platform        boshplatform.Platform
 	dirProvider     boshdir.Provider
 	settingsService boshsettings.Service
+	mbusSchemes     []string
 	logger          boshlog.Logger
 }
 
@@ -31,6 +32,7 @@ func NewBootstrap(
 	platform boshplatform.Platform,
 	dirProvider boshdir.Provider,
 	settingsService boshsettings.Service,
+	mbusSchemes []string,
 	logger boshlog.Logger,
 ) Bootstrap {
 	return bootstrap{
@@ -38,6 +40,7 @@ func NewBootstrap(
 		platform:        platform,
 		dirProvider:     dirProvider,
 		settingsService: settingsService,
+		mbusSchemes:     mbusSchemes,
 		logger:          logger,
 	}
 }
@@ -63,6 +66,13 @@ func (boot bootstrap) Run() (err error) {
 	}
 
 	settings := boot.settingsService.GetSettings()
+
+	if err = settings.Validate(boot.mbusSchemes); err != nil {
+		return bosherr.WrapError(err, "Validating settings")
+	}
+
+	boot.subscribeToSettingsChanges(iaasPublicKey)
+
 	envPublicKeys := settings.Env.GetAuthorizedKeys()
 
 	if len(envPublicKeys) > 0 {
@@ -190,6 +200,29 @@ func (boot bootstrap) Run() (err error) {
 	return nil
 }
 
+// subscribeToSettingsChanges keeps parts of the system set up from settings
+// up to date when settings are refreshed after bootstrap
+func (boot bootstrap) subscribeToSettingsChanges(iaasPublicKey string) {
+	boot.settingsService.Subscribe(func(settings boshsettings.Settings) error {
+		publicKeys := append([]string{}, settings.Env.GetAuthorizedKeys()...)
+
+		if len(iaasPublicKey) > 0 {
+			publicKeys = append(publicKeys, iaasPublicKey)
+		}
+
+		return boot.platform.SetupSSH(publicKeys, boshsettings.VCAPUsername)
+	}, boshsettings.FieldAuthorizedKeys)
+
+	boot.settingsService.Subscribe(func(settings boshsettings.Settings) error {
+		return boot.platform.SetTimeWithNtpServers(settings.Ntp)
+	}, boshsettings.FieldNtp)
+
+	// Network configuration is only rewritten when resulting files change
+	boot.settingsService.Subscribe(func(settings boshsettings.Settings) error {
+		return boot.platform.SetupNetworking(settings.Networks)
+	}, boshsettings.FieldDNS)
+}
+
 func (boot bootstrap) comparePersistentDisk() error {
 	settings := boot.settingsService.GetSettings()
 	updateSettingsPath := filepath.Join(boot.platform.GetDirProvider().BoshDir(), "update_settings.json")
@@ -18,6 +18,7 @@ import (
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
 	fakedisk "github.com/cloudfoundry/bosh-agent/platform/disk/fakes"
@@ -64,7 +65,7 @@ func init() {
 
 			bootstrap := func() error {
 				logger := boshlog.NewLogger(boshlog.LevelNone)
-				return NewBootstrap(platform, dirProvider, settingsService, logger).Run()
+				return NewBootstrap(platform, dirProvider, settingsService, []string{"nats", "https"}, logger).Run()
 			}
 
 			It("sets up runtime configuration", func() {
@@ -188,6 +189,68 @@ func init() {
 				})
 			})
 
+			Context("when settings are invalid", func() {
+				BeforeEach(func() {
+					settingsService.Settings.AgentID = "fake-agent-id"
+					settingsService.Settings.Mbus = "fake-scheme://fake-host"
+					settingsService.Settings.Networks = boshsettings.Networks{
+						"bosh": boshsettings.Network{IP: "fake-ip"},
+					}
+				})
+
+				It("returns all problems before acting on settings", func() {
+					err := bootstrap()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Validating settings: Invalid settings: " +
+						"networks.bosh.ip: must be an IP address, got 'fake-ip'; " +
+						"mbus: must have one of schemes nats, https, got 'fake-scheme'"))
+
+					validationErr, ok := err.(bosherr.ComplexError).Cause.(boshsettings.ValidationError)
+					Expect(ok).To(BeTrue())
+					Expect(validationErr.Problems).To(HaveLen(2))
+
+					Expect(platform.SetupHostnameHostname).To(BeEmpty())
+					Expect(platform.SetupNetworkingNetworks).To(BeNil())
+				})
+			})
+
+			Context("when settings are refreshed after bootstrap", func() {
+				BeforeEach(func() {
+					settingsService.PublicKey = "fake-iaas-public-key"
+				})
+
+				JustBeforeEach(func() {
+					err := bootstrap()
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("sets up ssh with changed authorized keys", func() {
+					settingsService.Settings.Env.Bosh.AuthorizedKeys = []string{"fake-env-public-key"}
+
+					err := settingsService.Notify(boshsettings.FieldAuthorizedKeys)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(platform.SetupSSHPublicKey).To(Equal([]string{"fake-env-public-key", "fake-iaas-public-key"}))
+				})
+
+				It("sets time with changed NTP servers", func() {
+					settingsService.Settings.Ntp = []string{"fake-new-ntp"}
+
+					err := settingsService.Notify(boshsettings.FieldNtp)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(platform.SetTimeWithNtpServersServers).To(Equal([]string{"fake-new-ntp"}))
+				})
+
+				It("sets up networking with changed DNS servers", func() {
+					settingsService.Settings.Networks = boshsettings.Networks{
+						"bosh": boshsettings.Network{DNS: []string{"10.0.0.2"}},
+					}
+
+					err := settingsService.Notify(boshsettings.FieldDNS)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(platform.SetupNetworkingNetworks).To(Equal(settingsService.Settings.Networks))
+				})
+			})
+
 			It("sets up networking", func() {
 				networks := boshsettings.Networks{
 					"bosh": boshsettings.Network{},
@@ -204,7 +267,7 @@ func init() {
 				swapSize = 2048
 				settingsService.Settings.Env.Bosh.SwapSizeInMB = &swapSize
 				settingsService.Settings.Disks = boshsettings.Disks{
-					Ephemeral: "fake-ephemeral-disk-setting",
+					Ephemeral: boshsettings.DiskSettings{Path: "fake-ephemeral-disk-setting", VolumeID: "fake-ephemeral-disk-setting"},
 				}
 
 				platform.GetEphemeralDiskPathRealPath = "/dev/sda"
@@ -423,8 +486,8 @@ func init() {
 					Context("there is a single managed persistent disk attached", func() {
 						BeforeEach(func() {
 							settingsService.Settings.Disks = boshsettings.Disks{
-								Persistent: map[string]interface{}{
-									"vol-123": "/dev/sdb",
+								Persistent: map[string]boshsettings.DiskSettings{
+									"vol-123": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
 								},
 							}
 						})
@@ -438,9 +501,9 @@ func init() {
 					Context("there are multiple managed persistent disk attached", func() {
 						BeforeEach(func() {
 							settingsService.Settings.Disks = boshsettings.Disks{
-								Persistent: map[string]interface{}{
-									"vol-123": "/dev/sdb",
-									"vol-456": "/dev/sdc",
+								Persistent: map[string]boshsettings.DiskSettings{
+									"vol-123": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+									"vol-456": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
 								},
 							}
 						})
@@ -462,8 +525,8 @@ func init() {
 							platform.Fs.WriteFile(managedDiskSettingsPath, []byte(diskCid))
 
 							settingsService.Settings.Disks = boshsettings.Disks{
-								Persistent: map[string]interface{}{
-									"i-am-a-disk-cid": "/dev/sdb",
+								Persistent: map[string]boshsettings.DiskSettings{
+									"i-am-a-disk-cid": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
 								},
 							}
 						})
@@ -531,9 +594,9 @@ func init() {
 						platform.Fs.WriteFile(updateSettingsPath, updateSettingsBytes)
 
 						settingsService.Settings.Disks = boshsettings.Disks{
-							Persistent: map[string]interface{}{
-								"vol-123": "/dev/sdb",
-								"vol-456": "/dev/sdc",
+							Persistent: map[string]boshsettings.DiskSettings{
+								"vol-123": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+								"vol-456": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
 							},
 						}
 					})
@@ -558,10 +621,10 @@ func init() {
 					Context("A disk is attached that shouldn't be", func() {
 						BeforeEach(func() {
 							settingsService.Settings.Disks = boshsettings.Disks{
-								Persistent: map[string]interface{}{
-									"vol-123": "/dev/sdb",
-									"vol-456": "/dev/sdc",
-									"vol-789": "/dev/sdd",
+								Persistent: map[string]boshsettings.DiskSettings{
+									"vol-123": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+									"vol-456": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
+									"vol-789": {Path: "/dev/sdd", VolumeID: "/dev/sdd"},
 								},
 							}
 						})
@@ -578,9 +641,9 @@ func init() {
 					Context("there are multiple disks in the registry for this instance", func() {
 						BeforeEach(func() {
 							settingsService.Settings.Disks = boshsettings.Disks{
-								Persistent: map[string]interface{}{
-									"vol-123": "/dev/sdb",
-									"vol-456": "/dev/sdc",
+								Persistent: map[string]boshsettings.DiskSettings{
+									"vol-123": {Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+									"vol-456": {Path: "/dev/sdc", VolumeID: "/dev/sdc"},
 								},
 							}
 						})
@@ -633,7 +696,7 @@ func init() {
 				Context("when there is no persistent disk", func() {
 					It("does not try to mount ", func() {
 						settingsService.Settings.Disks = boshsettings.Disks{
-							Persistent: map[string]interface{}{},
+							Persistent: map[string]boshsettings.DiskSettings{},
 						}
 
 						err := bootstrap()
@@ -646,8 +709,8 @@ func init() {
 				Context("when there is no drive specified by settings", func() {
 					It("returns error", func() {
 						settingsService.Settings.Disks = boshsettings.Disks{
-							Persistent: map[string]interface{}{
-								"vol-123": "/dev/not-exists",
+							Persistent: map[string]boshsettings.DiskSettings{
+								"vol-123": {Path: "/dev/not-exists", VolumeID: "/dev/not-exists"},
 							},
 						}
 						platform.SetIsPersistentDiskMountable(false, errors.New("Drive not exist!"))
@@ -671,8 +734,8 @@ func init() {
 
 					It("does not try to mount ", func() {
 						settingsService.Settings.Disks = boshsettings.Disks{
-							Persistent: map[string]interface{}{
-								"vol-123": "/dev/valid",
+							Persistent: map[string]boshsettings.DiskSettings{
+								"vol-123": {Path: "/dev/valid", VolumeID: "/dev/valid"},
 							},
 						}
 						platform.SetIsPersistentDiskMountable(false, nil)
@@ -694,10 +757,10 @@ func init() {
 						platform.Fs.WriteFile(updateSettingsPath, updateSettingsBytes)
 
 						settingsService.Settings.Disks = boshsettings.Disks{
-							Persistent: map[string]interface{}{
-								"vol-123": map[string]interface{}{
-									"volume_id": "2",
-									"path":      "/dev/sdb",
+							Persistent: map[string]boshsettings.DiskSettings{
+								"vol-123": {
+									VolumeID: "2",
+									Path:     "/dev/sdb",
 								},
 							},
 						}
@@ -879,6 +942,8 @@ func init() {
 				settingsService := boshsettings.NewService(
 					platform.GetFs(),
 					settingsPath,
+					filepath.Join(dirProvider.BoshDir(), "update_settings.json"),
+					boshsettings.NewKeyfileCacheCipher(platform.GetFs(), filepath.Join("bosh", "settings.key")),
 					settingsSource,
 					platform,
 					logger,
@@ -888,6 +953,7 @@ func init() {
 					platform,
 					dirProvider,
 					settingsService,
+					[]string{"nats", "https"},
 					logger,
 				)
 			})
@@ -1,6 +1,7 @@
 package cmdrunner
 
 import (
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -17,4 +18,8 @@ type CmdResult struct {
 
 type CmdRunner interface {
 	RunCommand(jobName, taskName string, cmd boshsys.Command) (*CmdResult, error)
+
+	// RunCancellableCommand terminates process group of the command
+	// once cancel signal is closed and returns boshtask.ErrCancelled.
+	RunCancellableCommand(jobName, taskName string, cmd boshsys.Command, cancelled boshtask.CancelSignal) (*CmdResult, error)
 }
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	boshcmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -11,6 +12,9 @@ type FakeFileLoggingCmdRunner struct {
 	RunCommandTaskName string
 	RunCommandResult   *boshcmdrunner.CmdResult
 	RunCommandErr      error
+
+	// RunCommandCancelSignal is nil for commands run with RunCommand
+	RunCommandCancelSignal boshtask.CancelSignal
 }
 
 func NewFakeFileLoggingCmdRunner() *FakeFileLoggingCmdRunner {
@@ -18,6 +22,11 @@ func NewFakeFileLoggingCmdRunner() *FakeFileLoggingCmdRunner {
 }
 
 func (f *FakeFileLoggingCmdRunner) RunCommand(jobName, taskName string, cmd boshsys.Command) (*boshcmdrunner.CmdResult, error) {
+	return f.RunCancellableCommand(jobName, taskName, cmd, nil)
+}
+
+func (f *FakeFileLoggingCmdRunner) RunCancellableCommand(jobName, taskName string, cmd boshsys.Command, cancelled boshtask.CancelSignal) (*boshcmdrunner.CmdResult, error) {
+	f.RunCommandCancelSignal = cancelled
 	f.RunCommandJobName = jobName
 	f.RunCommandTaskName = taskName
 	f.RunCommands = append(f.RunCommands, cmd)
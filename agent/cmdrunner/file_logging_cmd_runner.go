@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 	"unicode/utf8"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -14,6 +16,9 @@ import (
 const (
 	fileOpenFlag int         = os.O_RDWR | os.O_CREATE | os.O_TRUNC
 	fileOpenPerm os.FileMode = os.FileMode(0640)
+
+	// killGracePeriod is how long cancelled commands are given to exit after SIGTERM
+	killGracePeriod = 10 * time.Second
 )
 
 type FileLoggingCmdRunner struct {
@@ -62,6 +67,10 @@ func NewFileLoggingCmdRunner(
 }
 
 func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd boshsys.Command) (*CmdResult, error) {
+	return f.RunCancellableCommand(jobName, taskName, cmd, nil)
+}
+
+func (f FileLoggingCmdRunner) RunCancellableCommand(jobName string, taskName string, cmd boshsys.Command, cancelled boshtask.CancelSignal) (*CmdResult, error) {
 	logsDir := path.Join(f.baseDir, jobName)
 
 	err := f.fs.RemoveAll(logsDir)
@@ -97,8 +106,20 @@ func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd bo
 
 	cmd.Stderr = stderrFile
 
+	var exitStatus int
+	var runErr error
+
 	// Stdout/stderr are redirected to the files
-	_, _, exitStatus, runErr := f.cmdRunner.RunComplexCommand(cmd)
+	if cancelled == nil {
+		_, _, exitStatus, runErr = f.cmdRunner.RunComplexCommand(cmd)
+	} else {
+		var wasCancelled bool
+
+		exitStatus, wasCancelled, runErr = f.runUntilCancelled(cmd, cancelled)
+		if wasCancelled {
+			return nil, bosherr.WrapErrorf(boshtask.ErrCancelled, "Running command for task %s", taskName)
+		}
+	}
 
 	stdout, isStdoutTruncated, err := f.getTruncatedOutput(stdoutFile, f.truncateLength)
 	if err != nil {
@@ -127,6 +148,35 @@ func (f FileLoggingCmdRunner) RunCommand(jobName string, taskName string, cmd bo
 	return result, nil
 }
 
+// runUntilCancelled terminates process group of the command at most once
+// since the signal stays closed after cancellation.
+func (f FileLoggingCmdRunner) runUntilCancelled(cmd boshsys.Command, cancelled boshtask.CancelSignal) (int, bool, error) {
+	process, err := f.cmdRunner.RunComplexCommandAsync(cmd)
+	if err != nil {
+		return -1, false, err
+	}
+
+	var result boshsys.Result
+	var wasCancelled bool
+
+	for processExitedCh := process.Wait(); processExitedCh != nil; {
+		select {
+		case result = <-processExitedCh:
+			processExitedCh = nil
+		case <-cancelled:
+			cancelled = nil
+			wasCancelled = true
+
+			err = process.TerminateNicely(killGracePeriod)
+			if err != nil {
+				return -1, true, bosherr.WrapError(err, "Terminating cancelled command")
+			}
+		}
+	}
+
+	return result.ExitStatus, wasCancelled, result.Error
+}
+
 func (f FileLoggingCmdRunner) getTruncatedOutput(file boshsys.File, truncateLength int64) ([]byte, bool, error) {
 	isTruncated := false
 
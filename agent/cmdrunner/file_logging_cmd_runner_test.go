@@ -3,11 +3,13 @@ package cmdrunner_test
 import (
 	"errors"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -293,4 +295,53 @@ var _ = Describe("FileLoggingCmdRunner", func() {
 			})
 		})
 	})
+
+	Describe("RunCancellableCommand", func() {
+		var (
+			canceller *boshtask.Canceller
+		)
+
+		BeforeEach(func() {
+			canceller = boshtask.NewCanceller()
+		})
+
+		It("returns result of command that finishes without being cancelled", func() {
+			cmdRunner.AddProcess("fake-cmd fake-args", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{ExitStatus: 0},
+			})
+
+			result, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, canceller.Signal())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.ExitStatus).To(Equal(0))
+		})
+
+		It("returns an error when command fails", func() {
+			cmdRunner.AddProcess("fake-cmd fake-args", &fakesys.FakeProcess{
+				WaitResult: boshsys.Result{ExitStatus: 1, Error: errors.New("fake-run-error")},
+			})
+
+			_, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, canceller.Signal())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Command exited with 1"))
+		})
+
+		It("terminates process group of cancelled command and returns cancelled error", func() {
+			process := &fakesys.FakeProcess{
+				TerminatedNicelyCallBack: func(p *fakesys.FakeProcess) {
+					p.WaitCh <- boshsys.Result{ExitStatus: 143, Error: errors.New("fake-terminated")}
+				},
+			}
+			cmdRunner.AddProcess("fake-cmd fake-args", process)
+
+			canceller.Cancel()
+
+			result, err := runner.RunCancellableCommand("fake-log-dir-name", "fake-log-file-name", cmd, canceller.Signal())
+			Expect(err).To(HaveOccurred())
+			Expect(boshtask.IsCancelled(err)).To(BeTrue())
+			Expect(result).To(BeNil())
+
+			Expect(process.TerminatedNicely).To(BeTrue())
+			Expect(process.TerminateNicelyKillGracePeriod).To(Equal(10 * time.Second))
+		})
+	})
 })
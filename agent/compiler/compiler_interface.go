@@ -2,11 +2,13 @@ package compiler
 
 import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 )
 
 type Compiler interface {
-	Compile(pkg Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error)
+	// Compile removes compiled package bundle and compile directory if it is cancelled
+	Compile(pkg Package, deps []boshmodels.Package, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (blobID string, digest boshcrypto.Digest, err error)
 }
 
 type Package struct {
@@ -3,11 +3,12 @@ package compiler
 import (
 	"fmt"
 
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-func (c concreteCompiler) runPackagingCommand(compilePath, enablePath string, pkg Package) error {
+func (c concreteCompiler) runPackagingCommand(compilePath, enablePath string, pkg Package, cancelled boshtask.CancelSignal) error {
 	command := boshsys.Command{
 		Name: "powershell",
 		Args: []string{"-command", fmt.Sprintf(`"iex (get-content -raw %s)"`, PackagingScriptName)},
@@ -20,7 +21,7 @@ func (c concreteCompiler) runPackagingCommand(compilePath, enablePath string, pk
 		WorkingDir: compilePath,
 	}
 
-	_, err := c.runner.RunCommand("compilation", PackagingScriptName, command)
+	_, err := c.runner.RunCancellableCommand("compilation", PackagingScriptName, command, cancelled)
 	if err != nil {
 		return bosherr.WrapError(err, "Running packaging script")
 	}
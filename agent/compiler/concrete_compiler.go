@@ -8,7 +8,9 @@ import (
 	boshbc "github.com/cloudfoundry/bosh-agent/agent/applier/bundlecollection"
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	"github.com/cloudfoundry/bosh-agent/agent/applier/packages"
+	boshagentblob "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshcmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -52,14 +54,25 @@ func NewConcreteCompiler(
 	}
 }
 
-func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error) {
+func (c concreteCompiler) Compile(
+	pkg Package,
+	deps []boshmodels.Package,
+	progress boshtask.ProgressReporter,
+	cancelled boshtask.CancelSignal,
+) (blobID string, digest boshcrypto.Digest, err error) {
+	progress.Report(boshtask.Progress{
+		Percent: 0,
+		Phase:   "installing_dependencies",
+		Message: fmt.Sprintf("Installing %d dependencies", len(deps)),
+	})
+
 	err = c.packageApplier.KeepOnly([]boshmodels.Package{})
 	if err != nil {
 		return "", nil, bosherr.WrapError(err, "Removing packages")
 	}
 
 	for _, dep := range deps {
-		err := c.packageApplier.Apply(dep)
+		err := c.packageApplier.Apply(dep, cancelled)
 		if err != nil {
 			return "", nil, bosherr.WrapErrorf(err, "Installing dependent package: '%s'", dep.Name)
 		}
@@ -67,7 +80,9 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 
 	compilePath := path.Join(c.compileDirProvider.CompileDir(), pkg.Name)
 
-	err = c.fetchAndUncompress(pkg, compilePath)
+	progress.Report(boshtask.Progress{Percent: 10, Phase: "downloading", Message: pkg.Name})
+
+	err = c.fetchAndUncompress(pkg, compilePath, progress, cancelled)
 	if err != nil {
 		return "", nil, bosherr.WrapErrorf(err, "Fetching package %s", pkg.Name)
 	}
@@ -101,14 +116,31 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 		return "", nil, bosherr.WrapError(err, "Enabling new package bundle")
 	}
 
+	// Compiled package bundle is only useful once uploaded
+	defer func() {
+		if boshtask.IsCancelled(err) {
+			_ = compiledPkgBundle.Disable()
+			_ = compiledPkgBundle.Uninstall()
+		}
+	}()
+
 	scriptPath := path.Join(compilePath, PackagingScriptName)
 
+	progress.Report(boshtask.Progress{Percent: 30, Phase: "compiling", Message: pkg.Name})
+
 	if c.fs.FileExists(scriptPath) {
-		if err := c.runPackagingCommand(compilePath, enablePath, pkg); err != nil {
+		if err := c.runPackagingCommand(compilePath, enablePath, pkg, cancelled); err != nil {
 			return "", nil, bosherr.WrapError(err, "Running packaging script")
 		}
 	}
 
+	err = cancelled.Check()
+	if err != nil {
+		return "", nil, err
+	}
+
+	progress.Report(boshtask.Progress{Percent: 80, Phase: "compressing", Message: pkg.Name})
+
 	tmpPackageTar, err := c.compressor.CompressFilesInDir(installPath)
 	if err != nil {
 		return "", nil, bosherr.WrapError(err, "Compressing compiled package")
@@ -129,7 +161,9 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 		return "", nil, bosherr.WrapError(err, "Calculating compiled package digest")
 	}
 
-	uploadedBlobID, _, err := c.blobstore.Create(tmpPackageTar)
+	progress.Report(boshtask.Progress{Percent: 90, Phase: "uploading", Message: pkg.Name})
+
+	uploadedBlobID, _, err := boshagentblob.CreateUnlessCancelled(c.blobstore, tmpPackageTar, cancelled)
 	if err != nil {
 		return "", nil, bosherr.WrapError(err, "Uploading compiled package")
 	}
@@ -152,16 +186,18 @@ func (c concreteCompiler) Compile(pkg Package, deps []boshmodels.Package) (blobI
 	return uploadedBlobID, digest, nil
 }
 
-func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string) error {
+func (c concreteCompiler) fetchAndUncompress(pkg Package, targetDir string, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) error {
 	if pkg.BlobstoreID == "" {
 		return bosherr.Error(fmt.Sprintf("Blobstore ID for package '%s' is empty", pkg.Name))
 	}
 
-	depFilePath, err := c.blobstore.Get(pkg.BlobstoreID, pkg.Sha1)
+	depFilePath, err := boshagentblob.GetUnlessCancelled(c.blobstore, pkg.BlobstoreID, pkg.Sha1, cancelled)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Fetching package blob %s", pkg.BlobstoreID)
 	}
 
+	progress.Report(boshtask.Progress{Percent: 20, Phase: "extracting", Message: pkg.Name})
+
 	err = c.atomicDecompress(depFilePath, targetDir)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Uncompressing package %s", pkg.Name)
@@ -14,6 +14,8 @@ import (
 	fakepackages "github.com/cloudfoundry/bosh-agent/agent/applier/packages/fakes"
 	fakecmdrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner/fakes"
 	. "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	faketask "github.com/cloudfoundry/bosh-agent/agent/task/fakes"
 	fakeblobstore "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
@@ -93,9 +95,10 @@ func init() {
 
 		Describe("Compile", func() {
 			var (
-				bundle  *fakebc.FakeBundle
-				pkg     Package
-				pkgDeps []boshmodels.Package
+				bundle   *fakebc.FakeBundle
+				pkg      Package
+				pkgDeps  []boshmodels.Package
+				progress *faketask.FakeProgressReporter
 			)
 
 			BeforeEach(func() {
@@ -110,12 +113,13 @@ func init() {
 				compressor.CompressFilesInDirTarballPath = "/tmp/compressed-compiled-package"
 
 				pkg, pkgDeps = getCompileArgs()
+				progress = faketask.NewFakeProgressReporter()
 			})
 
 			It("returns blob id and sha1 of created compiled package", func() {
 				blobstore.CreateReturns("fake-blob-id", boshcrypto.MultipleDigest{}, nil)
 
-				blobID, digest, err := compiler.Compile(pkg, pkgDeps)
+				blobID, digest, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(blobID).To(Equal("fake-blob-id"))
@@ -128,7 +132,7 @@ func init() {
 				// Currently algo of source package is used for compilation pkg algo
 				pkg.Sha1 = boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, "fakesha"))
 
-				_, digest, err := compiler.Compile(pkg, pkgDeps)
+				_, digest, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				// echo -n fake-contents|shasum -a 256
 				Expect(digest.String()).To(Equal("sha256:d12d3a3ee8dcdc9e7ea3416fd618298ea50abde2cf434313c6c3edb213f441cd"))
@@ -138,8 +142,24 @@ func init() {
 				Expect(fingerprint).To(Equal(pkg.Sha1))
 			})
 
+			It("reports progress through each compilation phase", func() {
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(progress.Phases()).To(Equal([]string{
+					"installing_dependencies",
+					"downloading",
+					"extracting",
+					"compiling",
+					"compressing",
+					"uploading",
+				}))
+				Expect(progress.Reported[0].Message).To(Equal("Installing 2 dependencies"))
+				Expect(progress.Reported[5].Percent).To(Equal(90))
+			})
+
 			It("cleans up all packages before and after applying dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.ActionsCalled).To(Equal([]string{"KeepOnly", "Apply", "Apply", "KeepOnly"}))
 				Expect(packageApplier.KeptOnlyPackages).To(BeEmpty())
@@ -148,7 +168,7 @@ func init() {
 			It("returns an error if cleaning up packages fails", func() {
 				packageApplier.KeepOnlyErr = errors.New("fake-keep-only-error")
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-keep-only-error"))
 			})
@@ -161,7 +181,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -174,7 +194,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
@@ -187,7 +207,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -195,7 +215,7 @@ func init() {
 			It("returns an error if creating temporary compile target directory during uncompression fails", func() {
 				fs.RegisterMkdirAllError("/fake-compile-dir/pkg_name-bosh-agent-unpack", errors.New("fake-mkdir-error"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-mkdir-error"))
 			})
@@ -203,25 +223,25 @@ func init() {
 			It("returns an error if target directory is empty during uncompression", func() {
 				pkg.BlobstoreID = ""
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Blobstore ID for package '%s' is empty", pkg.Name))
 			})
 
 			It("installs dependent packages", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packageApplier.AppliedPackages).To(Equal(pkgDeps))
 			})
 
 			It("cleans up the compile directory", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
 			})
 
 			It("installs, enables and later cleans up bundle", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(bundle.ActionsCalled).To(Equal([]string{
 					"InstallWithoutContents",
@@ -243,7 +263,7 @@ func init() {
 					return nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-remove-error"))
 			})
@@ -258,7 +278,7 @@ func init() {
 				})
 
 				It("runs packaging script ", func() {
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 					Expect(err).ToNot(HaveOccurred())
 
 					expectedCmd := boshsys.Command{
@@ -286,23 +306,53 @@ func init() {
 					Expect(runner.RunCommandTaskName).To(Equal(PackagingScriptName))
 				})
 
+				It("passes cancel signal to packaging script and dependencies", func() {
+					canceller := boshtask.NewCanceller()
+
+					_, _, err := compiler.Compile(pkg, pkgDeps, progress, canceller.Signal())
+					Expect(err).ToNot(HaveOccurred())
+					Expect(runner.RunCommandCancelSignal).To(Equal(canceller.Signal()))
+					Expect(packageApplier.ApplyCancelSignal).To(Equal(canceller.Signal()))
+				})
+
+				It("uninstalls compiled package and removes compile dir when packaging script is cancelled", func() {
+					runner.RunCommandErr = boshtask.ErrCancelled
+
+					_, _, err := compiler.Compile(pkg, pkgDeps, progress, boshtask.NewCanceller().Signal())
+					Expect(err).To(HaveOccurred())
+					Expect(boshtask.IsCancelled(err)).To(BeTrue())
+
+					Expect(bundle.ActionsCalled).To(Equal([]string{"InstallWithoutContents", "Enable", "Disable", "Uninstall"}))
+					Expect(fs.FileExists("/fake-compile-dir/pkg_name")).To(BeFalse())
+					Expect(blobstore.CreateCallCount()).To(Equal(0))
+				})
+
 				It("propagates the error from packaging script", func() {
 					runner.RunCommandErr = errors.New("fake-packaging-error")
 
-					_, _, err := compiler.Compile(pkg, pkgDeps)
+					_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-packaging-error"))
 				})
 			})
 
+			It("does not download package when already cancelled", func() {
+				canceller := boshtask.NewCanceller()
+				canceller.Cancel()
+
+				_, _, err := compiler.Compile(pkg, []boshmodels.Package{}, progress, canceller.Signal())
+				Expect(boshtask.IsCancelled(err)).To(BeTrue())
+				Expect(blobstore.GetCallCount()).To(Equal(0))
+			})
+
 			It("does not run packaging script when script does not exist", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(runner.RunCommands).To(BeEmpty())
 			})
 
 			It("compresses compiled package", func() {
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				// archive was downloaded from the blobstore and decompress to this temp dir
@@ -321,7 +371,7 @@ func init() {
 			It("uploads compressed package to blobstore", func() {
 				compressor.CompressFilesInDirTarballPath = "/tmp/compressed-compiled-package"
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(blobstore.CreateArgsForCall(0)).To(Equal("/tmp/compressed-compiled-package"))
 			})
@@ -329,7 +379,7 @@ func init() {
 			It("returs error if uploading compressed package fails", func() {
 				blobstore.CreateReturns("", boshcrypto.MultipleDigest{}, errors.New("fake-create-err"))
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
 			})
@@ -342,7 +392,7 @@ func init() {
 					return "my-blob-id", boshcrypto.MultipleDigest{}, nil
 				}
 
-				_, _, err := compiler.Compile(pkg, pkgDeps)
+				_, _, err := compiler.Compile(pkg, pkgDeps, progress, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				// Compressed package is not cleaned up before blobstore upload
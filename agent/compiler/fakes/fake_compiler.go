@@ -3,15 +3,18 @@ package fakes
 import (
 	boshmodels "github.com/cloudfoundry/bosh-agent/agent/applier/models"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 )
 
 type FakeCompiler struct {
-	CompilePkg    boshcomp.Package
-	CompileDeps   []boshmodels.Package
-	CompileBlobID string
-	CompileDigest boshcrypto.Digest
-	CompileErr    error
+	CompilePkg      boshcomp.Package
+	CompileDeps     []boshmodels.Package
+	CompileProgress boshtask.ProgressReporter
+	CompileSignal   boshtask.CancelSignal
+	CompileBlobID   string
+	CompileDigest   boshcrypto.Digest
+	CompileErr      error
 }
 
 func NewFakeCompiler() (c *FakeCompiler) {
@@ -19,9 +22,11 @@ func NewFakeCompiler() (c *FakeCompiler) {
 	return
 }
 
-func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package) (blobID string, digest boshcrypto.Digest, err error) {
+func (c *FakeCompiler) Compile(pkg boshcomp.Package, deps []boshmodels.Package, progress boshtask.ProgressReporter, cancelled boshtask.CancelSignal) (blobID string, digest boshcrypto.Digest, err error) {
 	c.CompilePkg = pkg
 	c.CompileDeps = deps
+	c.CompileProgress = progress
+	c.CompileSignal = cancelled
 	blobID = c.CompileBlobID
 	digest = c.CompileDigest
 	err = c.CompileErr
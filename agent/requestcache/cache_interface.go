@@ -0,0 +1,20 @@
+package requestcache
+
+import (
+	"encoding/json"
+)
+
+// Entry records the outcome of a request that carried a request ID.
+// Synchronous actions record their marshalled response;
+// asynchronous actions record the ID of the task that was started.
+type Entry struct {
+	RequestID string          `json:"request_id"`
+	Method    string          `json:"method"`
+	TaskID    string          `json:"task_id,omitempty"`
+	Response  json.RawMessage `json:"response,omitempty"`
+}
+
+type Cache interface {
+	Get(requestID string) (Entry, bool, error)
+	Add(entry Entry) error
+}
@@ -0,0 +1,32 @@
+package fakes
+
+import (
+	boshreqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache"
+)
+
+type FakeCache struct {
+	Entries map[string]boshreqcache.Entry
+
+	GetErr error
+	AddErr error
+}
+
+func NewFakeCache() *FakeCache {
+	return &FakeCache{Entries: make(map[string]boshreqcache.Entry)}
+}
+
+func (c *FakeCache) Get(requestID string) (boshreqcache.Entry, bool, error) {
+	if c.GetErr != nil {
+		return boshreqcache.Entry{}, false, c.GetErr
+	}
+	entry, found := c.Entries[requestID]
+	return entry, found, nil
+}
+
+func (c *FakeCache) Add(entry boshreqcache.Entry) error {
+	if c.AddErr != nil {
+		return c.AddErr
+	}
+	c.Entries[entry.RequestID] = entry
+	return nil
+}
@@ -0,0 +1,123 @@
+package requestcache
+
+import (
+	"encoding/json"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const fileCacheLogTag = "Request Cache"
+
+// fileCache keeps the most recent maxEntries entries
+// in insertion order and evicts the oldest ones first.
+type fileCache struct {
+	fs         boshsys.FileSystem
+	path       string
+	maxEntries int
+	logger     boshlog.Logger
+
+	// Access to entries must be synchronized via entriesLock
+	entries     []Entry
+	loaded      bool
+	entriesLock sync.Mutex
+}
+
+func NewFileCache(fs boshsys.FileSystem, path string, maxEntries int, logger boshlog.Logger) Cache {
+	return &fileCache{
+		fs:         fs,
+		path:       path,
+		maxEntries: maxEntries,
+		logger:     logger,
+	}
+}
+
+func (c *fileCache) Get(requestID string) (Entry, bool, error) {
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	err := c.load()
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		if c.entries[i].RequestID == requestID {
+			return c.entries[i], true, nil
+		}
+	}
+
+	return Entry{}, false, nil
+}
+
+func (c *fileCache) Add(entry Entry) error {
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	err := c.load()
+	if err != nil {
+		return err
+	}
+
+	entries := []Entry{}
+	for _, existingEntry := range c.entries {
+		if existingEntry.RequestID != entry.RequestID {
+			entries = append(entries, existingEntry)
+		}
+	}
+
+	entries = append(entries, entry)
+
+	if len(entries) > c.maxEntries {
+		entries = entries[len(entries)-c.maxEntries:]
+	}
+
+	err = c.write(entries)
+	if err != nil {
+		return err
+	}
+
+	c.entries = entries
+
+	return nil
+}
+
+func (c *fileCache) load() error {
+	if c.loaded {
+		return nil
+	}
+
+	if c.fs.FileExists(c.path) {
+		bytes, err := c.fs.ReadFile(c.path)
+		if err != nil {
+			return bosherr.WrapError(err, "Reading request cache")
+		}
+
+		err = json.Unmarshal(bytes, &c.entries)
+		if err != nil {
+			// A corrupted cache only means that retried requests will run again
+			c.logger.Error(fileCacheLogTag, "Discarding unreadable request cache: %s", err.Error())
+			c.entries = nil
+		}
+	}
+
+	c.loaded = true
+
+	return nil
+}
+
+func (c *fileCache) write(entries []Entry) error {
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling request cache")
+	}
+
+	err = c.fs.WriteFile(c.path, bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing request cache")
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package requestcache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshreqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("fileCache", func() {
+	var (
+		logger boshlog.Logger
+		fs     *fakesys.FakeFileSystem
+		cache  boshreqcache.Cache
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
+		cache = boshreqcache.NewFileCache(fs, "/dir/request_cache.json", 2, logger)
+	})
+
+	Describe("Get", func() {
+		It("returns not found when there is no cache file", func() {
+			_, found, err := cache.Get("fake-request-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns entries added by another cache using the same file", func() {
+			entry := boshreqcache.Entry{
+				RequestID: "fake-request-id",
+				Method:    "fake-method",
+				Response:  json.RawMessage(`{"value":"fake-value"}`),
+			}
+
+			otherCache := boshreqcache.NewFileCache(fs, "/dir/request_cache.json", 2, logger)
+			err := otherCache.Add(entry)
+			Expect(err).ToNot(HaveOccurred())
+
+			foundEntry, found, err := cache.Get("fake-request-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(foundEntry).To(Equal(entry))
+		})
+
+		It("ignores a cache file that cannot be unmarshalled", func() {
+			err := fs.WriteFileString("/dir/request_cache.json", "bad-json")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := cache.Get("fake-request-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns an error when the cache file cannot be read", func() {
+			err := fs.WriteFileString("/dir/request_cache.json", "[]")
+			Expect(err).ToNot(HaveOccurred())
+			fs.ReadFileError = errors.New("fake-read-error")
+
+			_, _, err = cache.Get("fake-request-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-error"))
+		})
+	})
+
+	Describe("Add", func() {
+		It("evicts the oldest entries when the cache is full", func() {
+			for i := 1; i <= 3; i++ {
+				err := cache.Add(boshreqcache.Entry{RequestID: fmt.Sprintf("fake-request-id-%d", i)})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, found, err := cache.Get("fake-request-id-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			_, found, err = cache.Get("fake-request-id-3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+
+		It("replaces an existing entry with the same request id", func() {
+			err := cache.Add(boshreqcache.Entry{RequestID: "fake-request-id", TaskID: "fake-task-id-1"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = cache.Add(boshreqcache.Entry{RequestID: "fake-request-id", TaskID: "fake-task-id-2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			var entries []boshreqcache.Entry
+			contents, err := fs.ReadFile("/dir/request_cache.json")
+			Expect(err).ToNot(HaveOccurred())
+			err = json.Unmarshal(contents, &entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(Equal([]boshreqcache.Entry{
+				{RequestID: "fake-request-id", TaskID: "fake-task-id-2"},
+			}))
+		})
+
+		It("returns an error when writing the cache file fails", func() {
+			fs.WriteFileError = errors.New("fake-write-error")
+
+			err := cache.Add(boshreqcache.Entry{RequestID: "fake-request-id"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+
+			_, found, err := cache.Get("fake-request-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package requestcache_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRequestcache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Request Cache Suite")
+}
@@ -1,38 +1,67 @@
 package task
 
 import (
+	"github.com/pivotal-golang/clock"
+
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
-// Access to the currentTasks map should always be performed in the semaphore
+const asyncTaskServiceLogTag = "Task Service"
+
+// Access to currentTasks, queuedTasks and running counts
+// should always be performed in the semaphore
 // Use the taskSem channel for that
 
 type asyncTaskService struct {
-	uuidGen boshuuid.Generator
-	logger  boshlog.Logger
+	uuidGen     boshuuid.Generator
+	journal     Journal
+	policy      ConcurrencyPolicy
+	timeService clock.Clock
+	logger      boshlog.Logger
 
 	currentTasks map[string]Task
-	taskChan     chan Task
+	queuedTasks  []Task
+	runningLanes map[string]int
+	runningTotal int
 	taskSem      chan func()
+
+	tasks         boshmetrics.Gauge
+	finishedTasks boshmetrics.Counter
 }
 
-func NewAsyncTaskService(uuidGen boshuuid.Generator, logger boshlog.Logger) (service Service) {
-	s := asyncTaskService{
+func NewAsyncTaskService(
+	uuidGen boshuuid.Generator,
+	journal Journal,
+	policy ConcurrencyPolicy,
+	timeService clock.Clock,
+	logger boshlog.Logger,
+	metrics *boshmetrics.Registry,
+) (service Service) {
+	s := &asyncTaskService{
 		uuidGen:      uuidGen,
+		journal:      journal,
+		policy:       policy,
+		timeService:  timeService,
 		logger:       logger,
 		currentTasks: make(map[string]Task),
-		taskChan:     make(chan Task),
+		runningLanes: make(map[string]int),
 		taskSem:      make(chan func()),
+
+		tasks:         metrics.Gauge("bosh_agent_tasks", "Number of known tasks by state.", "state"),
+		finishedTasks: metrics.Counter("bosh_agent_tasks_finished_total", "Number of tasks finished by action and state.", "action", "state"),
 	}
 
-	go s.processTasks()
 	go s.processSemFuncs()
 
+	metrics.OnCollect(s.collectMetrics)
+
 	return s
 }
 
-func (service asyncTaskService) CreateTask(
+func (service *asyncTaskService) CreateTask(
 	taskFunc Func,
 	cancelFunc CancelFunc,
 	endFunc EndFunc,
@@ -45,7 +74,7 @@ func (service asyncTaskService) CreateTask(
 	return service.CreateTaskWithID(uuid, taskFunc, cancelFunc, endFunc), nil
 }
 
-func (service asyncTaskService) CreateTaskWithID(
+func (service *asyncTaskService) CreateTaskWithID(
 	id string,
 	taskFunc Func,
 	cancelFunc CancelFunc,
@@ -60,19 +89,26 @@ func (service asyncTaskService) CreateTaskWithID(
 	}
 }
 
-func (service asyncTaskService) StartTask(task Task) {
-	taskChan := make(chan Task)
+func (service *asyncTaskService) StartTask(task Task) {
+	task.State = StateQueued
+
+	// Record before task could possibly finish
+	// so that its outcome is not overwritten
+	service.recordTask(task)
+
+	doneChan := make(chan struct{})
 
 	service.taskSem <- func() {
 		service.currentTasks[task.ID] = task
-		taskChan <- task
+		service.queuedTasks = append(service.queuedTasks, task)
+		service.startQueuedTasks()
+		close(doneChan)
 	}
 
-	recordedTask := <-taskChan
-	service.taskChan <- recordedTask
+	<-doneChan
 }
 
-func (service asyncTaskService) FindTaskWithID(id string) (Task, bool) {
+func (service *asyncTaskService) FindTaskWithID(id string) (Task, bool) {
 	taskChan := make(chan Task)
 	foundChan := make(chan bool)
 
@@ -85,7 +121,105 @@ func (service asyncTaskService) FindTaskWithID(id string) (Task, bool) {
 	return <-taskChan, <-foundChan
 }
 
-func (service asyncTaskService) processSemFuncs() {
+func (service *asyncTaskService) RestoreTasks() error {
+	records, err := service.journal.GetRecords()
+	if err != nil {
+		return bosherr.WrapError(err, "Getting task records")
+	}
+
+	for _, record := range records {
+		task := Task{
+			ID:     record.TaskID,
+			Method: record.Method,
+			State:  record.State,
+			Value:  record.Value,
+		}
+
+		if record.Error != "" {
+			task.Error = bosherr.Error(record.Error)
+		}
+
+		// Tasks that were running when agent stopped will never finish
+		// unless they are resumed, in which case they will be started again.
+		if !task.State.IsFinished() {
+			task.State = StateFailed
+			task.Error = bosherr.Error("Task was interrupted by agent restart")
+			service.recordTask(task)
+		}
+
+		service.taskSem <- func() {
+			service.currentTasks[task.ID] = task
+		}
+	}
+
+	return nil
+}
+
+func (service *asyncTaskService) recordTask(task Task) {
+	record := Record{
+		TaskID:    task.ID,
+		Method:    task.Method,
+		State:     task.State,
+		Value:     task.Value,
+		UpdatedAt: service.timeService.Now(),
+	}
+
+	if task.Error != nil {
+		record.Error = task.Error.Error()
+	}
+
+	err := service.journal.AddRecord(record)
+	if err != nil {
+		// Task will be reported as unknown if agent restarts
+		service.logger.Error(asyncTaskServiceLogTag, "Failed recording task #%s: %s", task.ID, err.Error())
+	}
+}
+
+func (service *asyncTaskService) NewProgressReporter(id string) ProgressReporter {
+	return taskProgressReporter{taskID: id, service: service}
+}
+
+func (service *asyncTaskService) Stats() Stats {
+	statsChan := make(chan Stats)
+
+	service.taskSem <- func() {
+		stats := Stats{
+			Running:        service.runningTotal,
+			Queued:         len(service.queuedTasks),
+			QueuedByAction: map[string]int{},
+		}
+
+		for _, task := range service.queuedTasks {
+			stats.QueuedByAction[task.Method]++
+		}
+
+		statsChan <- stats
+	}
+
+	return <-statsChan
+}
+
+func (service *asyncTaskService) collectMetrics() {
+	doneChan := make(chan struct{})
+
+	service.taskSem <- func() {
+		byState := map[State]int{}
+
+		for _, task := range service.currentTasks {
+			byState[task.State]++
+		}
+
+		for _, state := range []State{StateQueued, StateRunning, StateDone, StateFailed, StateCancelled} {
+			service.tasks.Set(float64(byState[state]), string(state))
+		}
+
+		close(doneChan)
+	}
+
+	<-doneChan
+}
+
+func (service *asyncTaskService) processSemFuncs() {
 	defer service.logger.HandlePanic("Task Service Process Sem Funcs")
 
 	for {
@@ -94,33 +228,92 @@ func (service asyncTaskService) processSemFuncs() {
 	}
 }
 
-func (service asyncTaskService) processTasks() {
-	defer service.logger.HandlePanic("Task Service Process Tasks")
-
+// startQueuedTasks must be called in the semaphore
+func (service *asyncTaskService) startQueuedTasks() {
 	for {
-		task := <-service.taskChan
+		next := -1
 
-		value, err := task.Func()
-		if err != nil {
-			task.Error = err
-			task.State = StateFailed
-			service.logger.Error("Task Service", "Failed processing task #%s got: %s", task.ID, err.Error())
-		} else {
-			task.Value = value
-			task.State = StateDone
+		for i, task := range service.queuedTasks {
+			if !service.canStart(task) {
+				continue
+			}
+
+			if next == -1 || service.priority(task) > service.priority(service.queuedTasks[next]) {
+				next = i
+			}
 		}
 
-		if task.EndFunc != nil {
-			task.EndFunc(task)
+		if next == -1 {
+			return
 		}
 
-		// Nil to prevent to memory leaks in case these are closures.
-		task.Func = nil
-		task.CancelFunc = nil
-		task.EndFunc = nil
+		task := service.queuedTasks[next]
+		service.queuedTasks = append(service.queuedTasks[:next], service.queuedTasks[next+1:]...)
 
-		service.taskSem <- func() {
-			service.currentTasks[task.ID] = task
-		}
+		task.State = StateRunning
+		service.currentTasks[task.ID] = task
+		service.runningLanes[service.policy.laneForAction(task.Method)]++
+		service.runningTotal++
+
+		go service.runTask(task)
+	}
+}
+
+func (service *asyncTaskService) canStart(task Task) bool {
+	if !service.policy.allowsMore(service.runningTotal, service.policy.MaxConcurrent) {
+		return false
+	}
+
+	actionPolicy := service.policy.ForAction(task.Method)
+
+	return service.policy.allowsMore(service.runningLanes[service.policy.laneForAction(task.Method)], actionPolicy.MaxConcurrent)
+}
+
+func (service *asyncTaskService) priority(task Task) int {
+	return service.policy.ForAction(task.Method).Priority
+}
+
+func (service *asyncTaskService) runTask(task Task) {
+	defer service.logger.HandlePanic("Task Service Run Task")
+
+	service.recordTask(task)
+
+	value, err := task.Func()
+
+	if IsCancelled(err) {
+		task.Error = err
+		task.State = StateCancelled
+
+		service.logger.Info(asyncTaskServiceLogTag, "Cancelled processing task #%s: %s", task.ID, err.Error())
+	} else if err != nil {
+		task.Error = err
+		task.State = StateFailed
+
+		service.logger.Error(asyncTaskServiceLogTag, "Failed processing task #%s got: %s", task.ID, err.Error())
+	} else {
+		task.Value = value
+		task.State = StateDone
+	}
+
+	service.recordTask(task)
+
+	service.finishedTasks.Inc(task.Method, string(task.State))
+
+	if task.EndFunc != nil {
+		task.EndFunc(task)
+	}
+
+	// Nil to prevent to memory leaks in case these are closures.
+	task.Func = nil
+	task.CancelFunc = nil
+	task.EndFunc = nil
+
+	service.taskSem <- func() {
+		// Keep latest progress reported while task was running
+		task.Progress = service.currentTasks[task.ID].Progress
+		service.currentTasks[task.ID] = task
+		service.runningLanes[service.policy.laneForAction(task.Method)]--
+		service.runningTotal--
+		service.startQueuedTasks()
 	}
 }
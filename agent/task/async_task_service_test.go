@@ -1,34 +1,49 @@
 package task_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
 
 func init() {
 	Describe("asyncTaskService", func() {
 		var (
-			uuidGen *fakeuuid.FakeGenerator
-			service Service
+			uuidGen     *fakeuuid.FakeGenerator
+			fs          *fakesys.FakeFileSystem
+			timeService *fakeclock.FakeClock
+			logger      boshlog.Logger
+			journal     Journal
+			service     Service
+			metrics     *boshmetrics.Registry
 		)
 
 		BeforeEach(func() {
 			uuidGen = &fakeuuid.FakeGenerator{}
-			service = NewAsyncTaskService(uuidGen, boshlog.NewLogger(boshlog.LevelNone))
+			fs = fakesys.NewFakeFileSystem()
+			timeService = fakeclock.NewFakeClock(time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC))
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			journal = NewJournal(logger, fs, "/dir/task_journal.json", time.Hour, timeService)
+			metrics = boshmetrics.NewRegistry()
+			service = NewAsyncTaskService(uuidGen, journal, DefaultConcurrencyPolicy(), timeService, logger, metrics)
 		})
 
 		Describe("StartTask", func() {
 			startAndWaitForTaskCompletion := func(task Task) Task {
 				service.StartTask(task)
-				for task.State == StateRunning {
+				for !task.State.IsFinished() {
 					time.Sleep(time.Nanosecond)
 					task, _ = service.FindTaskWithID(task.ID)
 				}
@@ -149,6 +164,57 @@ func init() {
 				})
 			})
 
+			It("records the outcome of a finished task in the journal", func() {
+				runFunc := func() (interface{}, error) { return nil, errors.New("fake-error") }
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				startAndWaitForTaskCompletion(task)
+
+				Eventually(func() []Record {
+					records, err := journal.GetRecords()
+					Expect(err).ToNot(HaveOccurred())
+					return records
+				}).Should(ConsistOf(Record{
+					TaskID:    "fake-task-id",
+					State:     StateFailed,
+					Error:     "fake-error",
+					UpdatedAt: timeService.Now(),
+				}))
+			})
+
+			It("records tasks that stopped because they were cancelled as cancelled", func() {
+				runFunc := func() (interface{}, error) { return nil, bosherr.WrapError(ErrCancelled, "fake-wrap") }
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				startAndWaitForTaskCompletion(task)
+
+				Eventually(func() []Record {
+					records, err := journal.GetRecords()
+					Expect(err).ToNot(HaveOccurred())
+					return records
+				}).Should(ConsistOf(Record{
+					TaskID:    "fake-task-id",
+					State:     StateCancelled,
+					Error:     "fake-wrap: Task was cancelled",
+					UpdatedAt: timeService.Now(),
+				}))
+			})
+
+			It("reports tasks by state and finished tasks by action in metrics", func() {
+				runFunc := func() (interface{}, error) { return nil, errors.New("fake-error") }
+				task := service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+				task.Method = "fake-method"
+				startAndWaitForTaskCompletion(task)
+
+				metricsText := func() string {
+					buf := &bytes.Buffer{}
+					Expect(metrics.WriteText(buf)).To(Succeed())
+					return buf.String()
+				}
+
+				Eventually(metricsText).Should(ContainSubstring(`bosh_agent_tasks_finished_total{action="fake-method",state="failed"} 1`))
+				Expect(metricsText()).To(ContainSubstring(`bosh_agent_tasks{state="failed"} 1`))
+				Expect(metricsText()).To(ContainSubstring(`bosh_agent_tasks{state="running"} 0`))
+			})
+
 			It("can process many tasks simultaneously", func() {
 				taskFunc := func() (interface{}, error) {
 					time.Sleep(10 * time.Millisecond)
@@ -184,6 +250,65 @@ func init() {
 			})
 		})
 
+		Describe("NewProgressReporter", func() {
+			var (
+				release chan struct{}
+				task    Task
+			)
+
+			BeforeEach(func() {
+				testRelease := make(chan struct{})
+				release = testRelease
+
+				runFunc := func() (interface{}, error) {
+					progress := service.NewProgressReporter("fake-task-id")
+					progress.Report(Progress{Percent: 150, Phase: "fake-phase", Message: "fake-message"})
+					<-testRelease
+					return nil, nil
+				}
+
+				task = service.CreateTaskWithID("fake-task-id", runFunc, nil, nil)
+			})
+
+			It("makes latest progress of a running task available", func() {
+				service.StartTask(task)
+
+				Eventually(func() *Progress {
+					task, _ := service.FindTaskWithID("fake-task-id")
+					return task.Progress
+				}).Should(Equal(&Progress{Percent: 100, Phase: "fake-phase", Message: "fake-message"}))
+
+				close(release)
+
+				Eventually(func() State {
+					task, _ := service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+			})
+
+			It("ignores progress reported for a finished task", func() {
+				close(release)
+				service.StartTask(task)
+
+				Eventually(func() State {
+					task, _ := service.FindTaskWithID("fake-task-id")
+					return task.State
+				}).Should(Equal(StateDone))
+
+				service.NewProgressReporter("fake-task-id").Report(Progress{Percent: 5, Phase: "late-phase"})
+
+				task, _ := service.FindTaskWithID("fake-task-id")
+				Expect(task.Progress.Phase).To(Equal("fake-phase"))
+			})
+
+			It("ignores progress reported for an unknown task", func() {
+				service.NewProgressReporter("unknown-task-id").Report(Progress{Percent: 5})
+
+				_, found := service.FindTaskWithID("unknown-task-id")
+				Expect(found).To(BeFalse())
+			})
+		})
+
 		Describe("CreateTask", func() {
 			It("creates a task with auto-assigned id", func() {
 				uuidGen.GeneratedUUID = "fake-uuid"
@@ -221,6 +346,236 @@ func init() {
 			})
 		})
 
+		Describe("concurrency policy", func() {
+			var (
+				policy       ConcurrencyPolicy
+				release      chan struct{}
+				blockingFunc Func
+			)
+
+			BeforeEach(func() {
+				policy = ConcurrencyPolicy{
+					MaxConcurrent: 3,
+					Default:       ActionPolicy{MaxConcurrent: 1},
+					Actions: map[string]ActionPolicy{
+						"fake-unlimited-action": ActionPolicy{MaxConcurrent: UnlimitedConcurrency},
+						"fake-priority-action":  ActionPolicy{MaxConcurrent: 1, Priority: 10},
+					},
+				}
+				release = make(chan struct{})
+				service = NewAsyncTaskService(uuidGen, journal, policy, timeService, logger, metrics)
+
+				testRelease := release
+				blockingFunc = func() (interface{}, error) {
+					<-testRelease
+					return nil, nil
+				}
+			})
+
+			AfterEach(func() {
+				close(release)
+			})
+
+			startTask := func(id, method string, taskFunc Func) {
+				task := service.CreateTaskWithID(id, taskFunc, nil, nil)
+				task.Method = method
+				service.StartTask(task)
+			}
+
+			taskState := func(id string) func() State {
+				return func() State {
+					task, _ := service.FindTaskWithID(id)
+					return task.State
+				}
+			}
+
+			It("queues tasks over the action limit until running tasks finish", func() {
+				finish := make(chan struct{})
+				startTask("fake-task-id-1", "fake-action", func() (interface{}, error) {
+					<-finish
+					return nil, nil
+				})
+				startTask("fake-task-id-2", "fake-action", blockingFunc)
+
+				Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+				Expect(taskState("fake-task-id-2")()).To(Equal(StateQueued))
+				Expect(service.Stats()).To(Equal(Stats{
+					Running:        1,
+					Queued:         1,
+					QueuedByAction: map[string]int{"fake-action": 1},
+				}))
+
+				close(finish)
+
+				Eventually(taskState("fake-task-id-1")).Should(Equal(StateDone))
+				Eventually(taskState("fake-task-id-2")).Should(Equal(StateRunning))
+			})
+
+			It("runs tasks of different actions side by side", func() {
+				startTask("fake-task-id-1", "fake-action-1", blockingFunc)
+				startTask("fake-task-id-2", "fake-action-2", blockingFunc)
+
+				Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+				Expect(taskState("fake-task-id-2")()).To(Equal(StateRunning))
+			})
+
+			It("counts tasks of actions in the same lane together", func() {
+				policy.Actions["fake-lane-action-1"] = ActionPolicy{MaxConcurrent: 1, Lane: "fake-lane"}
+				policy.Actions["fake-lane-action-2"] = ActionPolicy{MaxConcurrent: 1, Lane: "fake-lane"}
+				service = NewAsyncTaskService(uuidGen, journal, policy, timeService, logger, metrics)
+
+				startTask("fake-task-id-1", "fake-lane-action-1", blockingFunc)
+				startTask("fake-task-id-2", "fake-lane-action-2", blockingFunc)
+
+				Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+				Expect(taskState("fake-task-id-2")()).To(Equal(StateQueued))
+			})
+
+			It("does not limit actions with unlimited concurrency other than by overall limit", func() {
+				for i := 1; i <= 4; i++ {
+					startTask(fmt.Sprintf("fake-task-id-%d", i), "fake-unlimited-action", blockingFunc)
+				}
+
+				Expect(service.Stats().Running).To(Equal(3))
+				Expect(taskState("fake-task-id-4")()).To(Equal(StateQueued))
+			})
+
+			It("starts queued tasks with higher priority first", func() {
+				finish := make(chan struct{})
+				startTask("fake-task-id-1", "fake-unlimited-action", func() (interface{}, error) {
+					<-finish
+					return nil, nil
+				})
+				startTask("fake-task-id-2", "fake-unlimited-action", blockingFunc)
+				startTask("fake-task-id-3", "fake-unlimited-action", blockingFunc)
+				startTask("fake-task-id-4", "fake-action", blockingFunc)
+				startTask("fake-task-id-5", "fake-priority-action", blockingFunc)
+
+				close(finish)
+
+				Eventually(taskState("fake-task-id-5")).Should(Equal(StateRunning))
+				Expect(taskState("fake-task-id-4")()).To(Equal(StateQueued))
+			})
+
+			Context("with default policy", func() {
+				BeforeEach(func() {
+					service = NewAsyncTaskService(uuidGen, journal, DefaultConcurrencyPolicy(), timeService, logger, metrics)
+				})
+
+				It("does not run tasks of actions that change state side by side", func() {
+					finish := make(chan struct{})
+					startTask("fake-task-id-1", "apply", func() (interface{}, error) {
+						<-finish
+						return nil, nil
+					})
+					startTask("fake-task-id-2", "drain", blockingFunc)
+					startTask("fake-task-id-3", "run_script", blockingFunc)
+
+					Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+					Expect(taskState("fake-task-id-2")()).To(Equal(StateQueued))
+					Expect(taskState("fake-task-id-3")()).To(Equal(StateQueued))
+
+					close(finish)
+
+					Eventually(taskState("fake-task-id-2")).Should(Equal(StateRunning))
+					Expect(taskState("fake-task-id-3")()).To(Equal(StateQueued))
+				})
+
+				It("runs tasks of listed actions next to tasks that change state", func() {
+					startTask("fake-task-id-1", "apply", blockingFunc)
+					startTask("fake-task-id-2", "fetch_logs", blockingFunc)
+
+					Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+					Expect(taskState("fake-task-id-2")()).To(Equal(StateRunning))
+				})
+
+				It("runs one compile_package task at a time", func() {
+					startTask("fake-task-id-1", "compile_package", blockingFunc)
+					startTask("fake-task-id-2", "compile_package", blockingFunc)
+
+					Expect(taskState("fake-task-id-1")()).To(Equal(StateRunning))
+					Expect(taskState("fake-task-id-2")()).To(Equal(StateQueued))
+				})
+			})
+		})
+
+		Describe("RestoreTasks", func() {
+			It("makes tasks finished before agent restart available", func() {
+				err := journal.AddRecord(Record{
+					TaskID:    "fake-task-id-1",
+					State:     StateDone,
+					Value:     "fake-value",
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = journal.AddRecord(Record{
+					TaskID:    "fake-task-id-2",
+					State:     StateFailed,
+					Error:     "fake-error",
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				restartedService := NewAsyncTaskService(
+					uuidGen,
+					NewJournal(logger, fs, "/dir/task_journal.json", time.Hour, timeService),
+					DefaultConcurrencyPolicy(),
+					timeService,
+					logger,
+					boshmetrics.NewRegistry(),
+				)
+
+				_, found := restartedService.FindTaskWithID("fake-task-id-1")
+				Expect(found).To(BeFalse())
+
+				err = restartedService.RestoreTasks()
+				Expect(err).ToNot(HaveOccurred())
+
+				task, found := restartedService.FindTaskWithID("fake-task-id-1")
+				Expect(found).To(BeTrue())
+				Expect(task.State).To(Equal(StateDone))
+				Expect(task.Value).To(Equal("fake-value"))
+				Expect(task.Error).To(BeNil())
+
+				task, found = restartedService.FindTaskWithID("fake-task-id-2")
+				Expect(found).To(BeTrue())
+				Expect(task.State).To(Equal(StateFailed))
+				Expect(task.Error.Error()).To(Equal("fake-error"))
+			})
+
+			It("marks tasks that were running before agent restart as failed", func() {
+				err := journal.AddRecord(Record{
+					TaskID:    "fake-task-id",
+					State:     StateRunning,
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = service.RestoreTasks()
+				Expect(err).ToNot(HaveOccurred())
+
+				task, found := service.FindTaskWithID("fake-task-id")
+				Expect(found).To(BeTrue())
+				Expect(task.State).To(Equal(StateFailed))
+				Expect(task.Error.Error()).To(ContainSubstring("interrupted by agent restart"))
+
+				records, err := journal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records[0].State).To(Equal(StateFailed))
+			})
+
+			It("returns an error when reading the journal fails", func() {
+				err := fs.WriteFileString("/dir/task_journal.json", "{}")
+				Expect(err).ToNot(HaveOccurred())
+				fs.ReadFileError = errors.New("fake-read-error")
+
+				err = service.RestoreTasks()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Getting task records"))
+			})
+		})
+
 		Describe("CreateTaskWithID", func() {
 			It("creates a task with given id", func() {
 				runFuncCalled := false
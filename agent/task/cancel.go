@@ -0,0 +1,61 @@
+package task
+
+import (
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ErrCancelled is returned by tasks that stopped early because they were cancelled
+var ErrCancelled = bosherr.Error("Task was cancelled")
+
+// CancelSignal is closed once cancellation of a task is requested.
+// Nil signal is never closed, e.g. for synchronous actions nobody could cancel.
+type CancelSignal <-chan struct{}
+
+// Check returns ErrCancelled once signal is closed
+// so that tasks can stop between steps of their work.
+func (s CancelSignal) Check() error {
+	select {
+	case <-s:
+		return ErrCancelled
+	default:
+		return nil
+	}
+}
+
+// IsCancelled returns true if ErrCancelled is found in the chain of wrapped errors
+func IsCancelled(err error) bool {
+	for err != nil {
+		if err == ErrCancelled {
+			return true
+		}
+
+		complexErr, ok := err.(bosherr.ComplexError)
+		if !ok {
+			return false
+		}
+
+		err = complexErr.Cause
+	}
+
+	return false
+}
+
+// Canceller closes its signal the first time Cancel is called
+type Canceller struct {
+	signal chan struct{}
+	once   sync.Once
+}
+
+func NewCanceller() *Canceller {
+	return &Canceller{signal: make(chan struct{})}
+}
+
+func (c *Canceller) Cancel() {
+	c.once.Do(func() { close(c.signal) })
+}
+
+func (c *Canceller) Signal() CancelSignal {
+	return c.signal
+}
@@ -0,0 +1,175 @@
+package task
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const journalLogTag = "Task Journal"
+
+type concreteJournal struct {
+	logger      boshlog.Logger
+	fs          boshsys.FileSystem
+	fsSem       chan func()
+	journalPath string
+	ttl         time.Duration
+	timeService clock.Clock
+
+	// Access to records must be synchronized via fsSem
+	records map[string]Record
+	loaded  bool
+}
+
+func NewJournal(
+	logger boshlog.Logger,
+	fs boshsys.FileSystem,
+	journalPath string,
+	ttl time.Duration,
+	timeService clock.Clock,
+) Journal {
+	j := &concreteJournal{
+		logger:      logger,
+		fs:          fs,
+		fsSem:       make(chan func()),
+		journalPath: journalPath,
+		ttl:         ttl,
+		timeService: timeService,
+		records:     make(map[string]Record),
+	}
+	go j.processFsFuncs()
+	return j
+}
+
+func (j *concreteJournal) GetRecords() ([]Record, error) {
+	recordsChan := make(chan []Record)
+	errCh := make(chan error)
+
+	j.fsSem <- func() {
+		var records []Record
+
+		err := j.load()
+		if err == nil {
+			for _, record := range j.records {
+				records = append(records, record)
+			}
+		}
+
+		recordsChan <- records
+		errCh <- err
+	}
+
+	return <-recordsChan, <-errCh
+}
+
+func (j *concreteJournal) AddRecord(record Record) error {
+	// Make sure that a value that cannot be serialized
+	// does not prevent other records from being saved
+	_, err := json.Marshal(record)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling task record %s", record.TaskID)
+	}
+
+	errCh := make(chan error)
+
+	j.fsSem <- func() {
+		err := j.load()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		j.records[record.TaskID] = record
+		errCh <- j.writeRecords()
+	}
+
+	return <-errCh
+}
+
+func (j *concreteJournal) processFsFuncs() {
+	defer j.logger.HandlePanic("Task Journal Process Fs Funcs")
+
+	for {
+		do := <-j.fsSem
+		do()
+	}
+}
+
+func (j *concreteJournal) load() error {
+	if !j.loaded {
+		records, err := j.readRecords()
+		if err != nil {
+			return err
+		}
+
+		j.records = records
+		j.loaded = true
+	}
+
+	j.removeExpiredRecords()
+
+	return nil
+}
+
+func (j *concreteJournal) removeExpiredRecords() {
+	now := j.timeService.Now()
+
+	for taskID, record := range j.records {
+		if record.State.IsFinished() && now.Sub(record.UpdatedAt) > j.ttl {
+			delete(j.records, taskID)
+		}
+	}
+}
+
+func (j *concreteJournal) readRecords() (map[string]Record, error) {
+	records := make(map[string]Record)
+
+	if !j.fs.FileExists(j.journalPath) {
+		return records, nil
+	}
+
+	journalJSON, err := j.fs.ReadFile(j.journalPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading task journal")
+	}
+
+	err = json.Unmarshal(journalJSON, &records)
+	if err != nil {
+		// Unreadable journal only means that finished tasks are no longer known;
+		// keeping it would prevent tasks from being recorded until it is removed
+		j.logger.Error(journalLogTag, "Discarding unreadable task journal: %s", err.Error())
+		return make(map[string]Record), nil
+	}
+
+	return records, nil
+}
+
+func (j *concreteJournal) writeRecords() error {
+	journalJSON, err := json.Marshal(j.records)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling task journal")
+	}
+
+	// Journal is renamed into place so that it is not left
+	// half written when agent stops while writing it
+	tmpPath := j.journalPath + ".tmp"
+
+	err = j.fs.WriteFile(tmpPath, journalJSON)
+	if err != nil {
+		_ = j.fs.RemoveAll(tmpPath)
+		return bosherr.WrapError(err, "Writing task journal")
+	}
+
+	err = j.fs.Rename(tmpPath, j.journalPath)
+	if err != nil {
+		_ = j.fs.RemoveAll(tmpPath)
+		return bosherr.WrapError(err, "Moving task journal into place")
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package task_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+func init() {
+	Describe("concreteJournal", func() {
+		var (
+			logger      boshlog.Logger
+			fs          *fakesys.FakeFileSystem
+			timeService *fakeclock.FakeClock
+			journal     boshtask.Journal
+		)
+
+		BeforeEach(func() {
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fs = fakesys.NewFakeFileSystem()
+			timeService = fakeclock.NewFakeClock(time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC))
+			journal = boshtask.NewJournal(logger, fs, "/dir/task_journal.json", time.Hour, timeService)
+		})
+
+		Describe("GetRecords", func() {
+			It("succeeds when there is no journal file", func() {
+				records, err := journal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(BeEmpty())
+			})
+
+			It("loads records saved by another journal", func() {
+				record := boshtask.Record{
+					TaskID:    "fake-task-id",
+					State:     boshtask.StateDone,
+					Value:     map[string]interface{}{"fake-key": "fake-value"},
+					UpdatedAt: timeService.Now(),
+				}
+
+				err := journal.AddRecord(record)
+				Expect(err).ToNot(HaveOccurred())
+
+				otherJournal := boshtask.NewJournal(logger, fs, "/dir/task_journal.json", time.Hour, timeService)
+				records, err := otherJournal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(Equal([]boshtask.Record{record}))
+			})
+
+			It("does not return finished records older than ttl", func() {
+				err := journal.AddRecord(boshtask.Record{
+					TaskID:    "fake-finished-task-id",
+					State:     boshtask.StateDone,
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = journal.AddRecord(boshtask.Record{
+					TaskID:    "fake-running-task-id",
+					State:     boshtask.StateRunning,
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				timeService.Increment(time.Hour + time.Second)
+
+				records, err := journal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(HaveLen(1))
+				Expect(records[0].TaskID).To(Equal("fake-running-task-id"))
+			})
+
+			It("returns an error when journal file cannot be read", func() {
+				err := fs.WriteFileString("/dir/task_journal.json", "{}")
+				Expect(err).ToNot(HaveOccurred())
+				fs.ReadFileError = errors.New("fake-read-error")
+
+				_, err = journal.GetRecords()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-read-error"))
+			})
+
+			It("discards unreadable journal file so that records can be added again", func() {
+				err := fs.WriteFileString("/dir/task_journal.json", `{"fake-task-id":`)
+				Expect(err).ToNot(HaveOccurred())
+
+				records, err := journal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(BeEmpty())
+
+				record := boshtask.Record{TaskID: "fake-task-id", State: boshtask.StateRunning}
+				Expect(journal.AddRecord(record)).To(Succeed())
+
+				otherJournal := boshtask.NewJournal(logger, fs, "/dir/task_journal.json", time.Hour, timeService)
+				records, err = otherJournal.GetRecords()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(records).To(HaveLen(1))
+				Expect(records[0].TaskID).To(Equal("fake-task-id"))
+			})
+		})
+
+		Describe("AddRecord", func() {
+			It("writes journal to temporary file and renames it into place", func() {
+				err := journal.AddRecord(boshtask.Record{TaskID: "fake-task-id"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.RenameOldPaths).To(Equal([]string{"/dir/task_journal.json.tmp"}))
+				Expect(fs.RenameNewPaths).To(Equal([]string{"/dir/task_journal.json"}))
+				Expect(fs.FileExists("/dir/task_journal.json.tmp")).To(BeFalse())
+			})
+
+			It("returns an error and removes temporary file when renaming journal fails", func() {
+				fs.RenameError = errors.New("fake-rename-error")
+
+				err := journal.AddRecord(boshtask.Record{TaskID: "fake-task-id"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-rename-error"))
+				Expect(fs.FileExists("/dir/task_journal.json.tmp")).To(BeFalse())
+			})
+
+			It("replaces the record of the same task", func() {
+				err := journal.AddRecord(boshtask.Record{TaskID: "fake-task-id", State: boshtask.StateRunning})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = journal.AddRecord(boshtask.Record{
+					TaskID:    "fake-task-id",
+					State:     boshtask.StateFailed,
+					Error:     "fake-error",
+					UpdatedAt: timeService.Now(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				content, err := fs.ReadFile("/dir/task_journal.json")
+				Expect(err).ToNot(HaveOccurred())
+
+				var decodedMap map[string]boshtask.Record
+				err = json.Unmarshal(content, &decodedMap)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decodedMap).To(Equal(map[string]boshtask.Record{
+					"fake-task-id": boshtask.Record{
+						TaskID:    "fake-task-id",
+						State:     boshtask.StateFailed,
+						Error:     "fake-error",
+						UpdatedAt: timeService.Now(),
+					},
+				}))
+			})
+
+			It("returns an error when value cannot be serialized", func() {
+				err := journal.AddRecord(boshtask.Record{TaskID: "fake-task-id", Value: func() {}})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Marshalling task record fake-task-id"))
+			})
+
+			It("returns an error when writing journal fails", func() {
+				fs.WriteFileError = errors.New("fake-write-error")
+
+				err := journal.AddRecord(boshtask.Record{TaskID: "fake-task-id"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+			})
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package task
+
+// UnlimitedConcurrency allows any number of tasks to run at once
+const UnlimitedConcurrency = 0
+
+type ActionPolicy struct {
+	// Number of tasks of the action that may run at once
+	MaxConcurrent int
+
+	// Queued tasks with higher priority are started first
+	Priority int
+
+	// Tasks of all actions in the same lane count against MaxConcurrent together;
+	// without a lane only tasks of the action itself are counted
+	Lane string
+}
+
+type ConcurrencyPolicy struct {
+	// Number of tasks of all actions that may run at once
+	MaxConcurrent int
+
+	// Used for actions that are not listed in Actions
+	Default ActionPolicy
+
+	Actions map[string]ActionPolicy
+}
+
+// SerialLane is shared by actions that change state of the VM
+// (e.g. apply, drain, mount_disk) so that they run one at a time
+const SerialLane = "serial"
+
+// DefaultConcurrencyPolicy runs tasks of actions that change state of the VM
+// one at a time as they ran before tasks could be queued;
+// only listed actions that do not interfere with them run side by side.
+func DefaultConcurrencyPolicy() ConcurrencyPolicy {
+	return ConcurrencyPolicy{
+		MaxConcurrent: UnlimitedConcurrency,
+		Default:       ActionPolicy{MaxConcurrent: 1, Lane: SerialLane},
+		Actions: map[string]ActionPolicy{
+			"compile_package": ActionPolicy{MaxConcurrent: 1},
+			"fetch_logs":      ActionPolicy{MaxConcurrent: 2},
+			"upload_blob":     ActionPolicy{MaxConcurrent: 2},
+			"run_script":      ActionPolicy{MaxConcurrent: 1, Lane: SerialLane},
+		},
+	}
+}
+
+func (p ConcurrencyPolicy) ForAction(method string) ActionPolicy {
+	if actionPolicy, found := p.Actions[method]; found {
+		return actionPolicy
+	}
+
+	return p.Default
+}
+
+func (p ConcurrencyPolicy) laneForAction(method string) string {
+	if lane := p.ForAction(method).Lane; lane != "" {
+		return lane
+	}
+
+	return method
+}
+
+func (p ConcurrencyPolicy) allowsMore(running int, max int) bool {
+	return max == UnlimitedConcurrency || running < max
+}
@@ -8,11 +8,19 @@ type FakeService struct {
 	StartedTasks        map[string]boshtask.Task
 	CreateTaskErr       error
 	CreateTaskWithIDErr error
+
+	RestoreTasksCalled bool
+	RestoreTasksErr    error
+
+	StatsResult boshtask.Stats
+
+	ProgressReporters map[string]*FakeProgressReporter
 }
 
 func NewFakeService() *FakeService {
 	return &FakeService{
-		StartedTasks: make(map[string]boshtask.Task),
+		StartedTasks:      make(map[string]boshtask.Task),
+		ProgressReporters: make(map[string]*FakeProgressReporter),
 	}
 }
 
@@ -50,3 +58,19 @@ func (s *FakeService) FindTaskWithID(id string) (boshtask.Task, bool) {
 	task, found := s.StartedTasks[id]
 	return task, found
 }
+
+func (s *FakeService) RestoreTasks() error {
+	s.RestoreTasksCalled = true
+	return s.RestoreTasksErr
+}
+
+func (s *FakeService) Stats() boshtask.Stats {
+	return s.StatsResult
+}
+
+func (s *FakeService) NewProgressReporter(id string) boshtask.ProgressReporter {
+	if s.ProgressReporters[id] == nil {
+		s.ProgressReporters[id] = NewFakeProgressReporter()
+	}
+	return s.ProgressReporters[id]
+}
@@ -0,0 +1,22 @@
+package task
+
+import (
+	"time"
+)
+
+// Record is the durable form of a task's outcome
+// so that it could be reported after agent is restarted.
+type Record struct {
+	TaskID    string      `json:"task_id"`
+	Method    string      `json:"method,omitempty"`
+	State     State       `json:"state"`
+	Value     interface{} `json:"value,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type Journal interface {
+	// GetRecords returns all records that have not yet expired
+	GetRecords() ([]Record, error)
+	AddRecord(record Record) error
+}
@@ -0,0 +1,48 @@
+package task
+
+// Progress describes how far along a running task is.
+// Phases are action specific, e.g. downloading, compiling, uploading.
+type Progress struct {
+	Percent int    `json:"percent"`
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+}
+
+type ProgressReporter interface {
+	Report(progress Progress)
+}
+
+type nopProgressReporter struct{}
+
+// NewNopProgressReporter returns reporter that discards progress
+// e.g. for synchronous actions that nobody could poll.
+func NewNopProgressReporter() ProgressReporter {
+	return nopProgressReporter{}
+}
+
+func (r nopProgressReporter) Report(_ Progress) {}
+
+type taskProgressReporter struct {
+	taskID  string
+	service *asyncTaskService
+}
+
+func (r taskProgressReporter) Report(progress Progress) {
+	if progress.Percent < 0 {
+		progress.Percent = 0
+	}
+
+	if progress.Percent > 100 {
+		progress.Percent = 100
+	}
+
+	r.service.taskSem <- func() {
+		task, found := r.service.currentTasks[r.taskID]
+		if !found || task.State.IsFinished() {
+			return
+		}
+
+		task.Progress = &progress
+		r.service.currentTasks[r.taskID] = task
+	}
+}
@@ -6,6 +6,16 @@ type Service interface {
 	CreateTaskWithID(string, Func, CancelFunc, EndFunc) Task
 
 	// Records that task to run later
+	// Task is queued if concurrency policy does not allow it to run right away
 	StartTask(Task)
 	FindTaskWithID(string) (Task, bool)
+
+	// Makes tasks recorded before agent restart available again
+	RestoreTasks() error
+
+	// Returns reporter that updates progress of the task with given id
+	NewProgressReporter(string) ProgressReporter
+
+	// Reports number of running and queued tasks
+	Stats() Stats
 }
@@ -9,16 +9,29 @@ type EndFunc func(task Task)
 type State string
 
 const (
+	StateQueued  State = "queued"
 	StateRunning State = "running"
 	StateDone    State = "done"
 	StateFailed  State = "failed"
+
+	// StateCancelled is the final state of tasks that stopped early because they were cancelled
+	StateCancelled State = "cancelled"
 )
 
+// IsFinished returns false for tasks that are waiting to run or running.
+func (s State) IsFinished() bool {
+	return s != StateQueued && s != StateRunning
+}
+
 type Task struct {
-	ID    string
-	State State
-	Value interface{}
-	Error error
+	ID     string
+	Method string
+	State  State
+	Value  interface{}
+	Error  error
+
+	// Latest progress reported by a running task
+	Progress *Progress
 
 	Func       Func
 	CancelFunc CancelFunc
@@ -33,6 +46,13 @@ func (t Task) Cancel() error {
 }
 
 type StateValue struct {
-	AgentTaskID string `json:"agent_task_id"`
-	State       State  `json:"state"`
+	AgentTaskID string    `json:"agent_task_id"`
+	State       State     `json:"state"`
+	Progress    *Progress `json:"progress,omitempty"`
+}
+
+type Stats struct {
+	Running        int            `json:"running"`
+	Queued         int            `json:"queued"`
+	QueuedByAction map[string]int `json:"queued_by_action"`
 }
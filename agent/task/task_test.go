@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/agent/task"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 var _ = Describe("Task", func() {
@@ -42,3 +43,32 @@ var _ = Describe("Task", func() {
 		})
 	})
 })
+
+var _ = Describe("Canceller", func() {
+	It("closes signal once cancelled", func() {
+		canceller := NewCanceller()
+		Expect(canceller.Signal().Check()).To(Succeed())
+
+		canceller.Cancel()
+		canceller.Cancel()
+
+		Expect(canceller.Signal().Check()).To(Equal(ErrCancelled))
+		Expect(canceller.Signal()).To(BeClosed())
+	})
+
+	It("never cancels nil signal", func() {
+		Expect(CancelSignal(nil).Check()).To(Succeed())
+	})
+})
+
+var _ = Describe("IsCancelled", func() {
+	It("finds cancelled error in wrapped causes", func() {
+		err := bosherr.WrapError(bosherr.WrapError(ErrCancelled, "Fetching"), "Compiling")
+		Expect(IsCancelled(err)).To(BeTrue())
+	})
+
+	It("returns false for other errors", func() {
+		Expect(IsCancelled(bosherr.WrapError(errors.New("fake-err"), "Fetching"))).To(BeFalse())
+		Expect(IsCancelled(nil)).To(BeFalse())
+	})
+})
@@ -253,7 +253,7 @@ func (c *agentClient) sendAsyncTaskMessage(method string, arguments []interface{
 			return false, bosherr.WrapError(err, "Getting task state")
 		}
 
-		if taskState != "running" {
+		if taskState != "running" && taskState != "queued" {
 			var ok bool
 			value, ok = response.Value.(map[string]interface{})
 			if !ok {
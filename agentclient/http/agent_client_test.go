@@ -279,6 +279,20 @@ var _ = Describe("AgentClient", func() {
 			})
 		})
 
+		Context("when agent reports the task as queued", func() {
+			BeforeEach(func() {
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":{"agent_task_id":"fake-agent-task-id","state":"queued"}}`, 200, nil)
+				fakeHTTPClient.SetPostBehavior(`{"value":"stopped"}`, 200, nil)
+			})
+
+			It("waits for the task to be finished", func() {
+				err := agentClient.Apply(spec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeHTTPClient.PostInputs).To(HaveLen(3))
+			})
+		})
+
 		Context("when agent does not respond with 200", func() {
 			BeforeEach(func() {
 				fakeHTTPClient.SetPostBehavior("", http.StatusInternalServerError, nil)
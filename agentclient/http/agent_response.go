@@ -7,6 +7,7 @@ import (
 
 	"github.com/cloudfoundry/bosh-agent/agent/action"
 	"github.com/cloudfoundry/bosh-agent/agentclient"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
@@ -16,7 +17,27 @@ type Response interface {
 }
 
 type exception struct {
-	Message string
+	Message   string
+	Code      boshhandler.ErrorCode
+	Category  boshhandler.ErrorCategory
+	Retryable bool
+}
+
+// ServerError returns boshhandler.Error when agent included
+// an error code so that callers can inspect it via boshhandler.FindError.
+func (e *exception) ServerError() error {
+	err := bosherr.Errorf("Agent responded with error: %s", e.Message)
+
+	if e.Code == "" {
+		return err
+	}
+
+	return boshhandler.Error{
+		Code:      e.Code,
+		Category:  e.Category,
+		Retryable: e.Retryable,
+		Err:       err,
+	}
 }
 
 type SimpleTaskResponse struct {
@@ -26,7 +47,7 @@ type SimpleTaskResponse struct {
 
 func (r *SimpleTaskResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -47,7 +68,7 @@ type SSHResponse struct {
 
 func (r *SSHResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -58,7 +79,7 @@ func (r *SSHResponse) Unmarshal(message []byte) error {
 
 func (r *SyncDNSResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -74,7 +95,7 @@ type ListResponse struct {
 
 func (r *ListResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -97,7 +118,7 @@ type BlobResponse struct {
 
 func (r *BlobResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -113,7 +134,7 @@ type StateResponse struct {
 
 func (r *StateResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -134,7 +155,7 @@ type TaskResponse struct {
 
 func (r *TaskResponse) ServerError() error {
 	if r.Exception != nil {
-		return bosherr.Errorf("Agent responded with error: %s", r.Exception.Message)
+		return r.Exception.ServerError()
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package http_test
 
 import (
 	. "github.com/cloudfoundry/bosh-agent/agentclient/http"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -24,6 +25,26 @@ var _ = Describe("AgentResponse", func() {
 			})
 		})
 
+		Describe("ServerError with error code", func() {
+			BeforeEach(func() {
+				agentResponseJSON := `{"exception":{"message":"fake-exception-message","code":"job_supervisor_unavailable","category":"unavailable","retryable":true}}`
+				err := agentTaskResponse.Unmarshal([]byte(agentResponseJSON))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns coded error", func() {
+				err := agentTaskResponse.ServerError()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Agent responded with error: fake-exception-message"))
+
+				codedErr, found := boshhandler.FindError(err)
+				Expect(found).To(BeTrue())
+				Expect(codedErr.Code).To(Equal(boshhandler.ErrorCodeJobSupervisorUnavailable))
+				Expect(codedErr.Category).To(Equal(boshhandler.ErrorCategoryUnavailable))
+				Expect(codedErr.Retryable).To(BeTrue())
+			})
+		})
+
 		Describe("TaskID", func() {
 			BeforeEach(func() {
 				agentResponseJSON := `{"value":{"agent_task_id":"fake-agent-task-id","state":"running"}}`
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pivotal-golang/clock"
 
+	boshadmin "github.com/cloudfoundry/bosh-agent/admin"
 	boshagent "github.com/cloudfoundry/bosh-agent/agent"
 	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshapplier "github.com/cloudfoundry/bosh-agent/agent/applier"
@@ -18,14 +19,17 @@ import (
 	boshagentblobstore "github.com/cloudfoundry/bosh-agent/agent/blobstore"
 	boshrunner "github.com/cloudfoundry/bosh-agent/agent/cmdrunner"
 	boshcomp "github.com/cloudfoundry/bosh-agent/agent/compiler"
+	boshreqcache "github.com/cloudfoundry/bosh-agent/agent/requestcache"
 	boshscript "github.com/cloudfoundry/bosh-agent/agent/script"
 	boshtask "github.com/cloudfoundry/bosh-agent/agent/task"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
 	boshjobsuper "github.com/cloudfoundry/bosh-agent/jobsupervisor"
 	boshmonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
 	boshmbus "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshnotif "github.com/cloudfoundry/bosh-agent/notification"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
 	boshsigar "github.com/cloudfoundry/bosh-agent/sigar"
@@ -38,6 +42,13 @@ import (
 	sigar "github.com/cloudfoundry/gosigar"
 )
 
+// Number of recently dispatched requests remembered
+// so that retried requests are not run again
+const requestCacheMaxEntries = 500
+
+// How long results of finished tasks are kept across agent restarts
+const taskJournalTTL = 24 * time.Hour
+
 type App interface {
 	Setup(opts Options) error
 	Run() error
@@ -51,6 +62,12 @@ type app struct {
 	fs          boshsys.FileSystem
 	logTag      string
 	dirProvider boshdirs.Provider
+
+	// metricsServer is only set when enabled in config
+	metricsServer *boshmetrics.Server
+
+	adminServer      *boshadmin.Server
+	actionDispatcher boshagent.ActionDispatcher
 }
 
 func New(logger boshlog.Logger, fs boshsys.FileSystem) App {
@@ -94,9 +111,18 @@ func (app *app) Setup(opts Options) error {
 		return bosherr.WrapError(err, "Getting Settings Source")
 	}
 
+	// Default key is kept next to settings it encrypts and only protects
+	// copies of settings.json made without the key (see CacheEncryptionOptions)
+	settingsKeyPath := config.SettingsEncryption.KeyPath
+	if settingsKeyPath == "" {
+		settingsKeyPath = filepath.Join(app.dirProvider.BoshDir(), "settings.key")
+	}
+
 	settingsService := boshsettings.NewService(
 		app.platform.GetFs(),
 		filepath.Join(app.dirProvider.BoshDir(), "settings.json"),
+		filepath.Join(app.dirProvider.BoshDir(), "update_settings.json"),
+		boshsettings.NewKeyfileCacheCipher(app.platform.GetFs(), settingsKeyPath),
 		settingsSource,
 		app.platform,
 		app.logger,
@@ -106,6 +132,7 @@ func (app *app) Setup(opts Options) error {
 		app.platform,
 		app.dirProvider,
 		settingsService,
+		boshmbus.NewDefaultTransportRegistry().Schemes(),
 		app.logger,
 	)
 
@@ -113,7 +140,16 @@ func (app *app) Setup(opts Options) error {
 		return bosherr.WrapError(err, "Running bootstrap")
 	}
 
-	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, app.logger, auditLogger)
+	uuidGen := boshuuid.NewGenerator()
+
+	metrics := boshmetrics.NewRegistry()
+	boshvitals.RegisterMetrics(app.platform.GetVitalsService(), metrics, app.logger)
+
+	if config.Metrics.IsEnabled() {
+		app.metricsServer = boshmetrics.NewServer(config.Metrics, metrics, app.logger)
+	}
+
+	mbusHandlerProvider := boshmbus.NewHandlerProvider(settingsService, app.logger, auditLogger, timeService, uuidGen, config.HTTPSTLS, config.HTTPSLimits, metrics)
 
 	mbusHandler, err := mbusHandlerProvider.Get(app.platform, app.dirProvider)
 	if err != nil {
@@ -121,12 +157,25 @@ func (app *app) Setup(opts Options) error {
 	}
 
 	blobManager := boshblob.NewBlobManager(app.platform.GetFs(), app.dirProvider.BlobsDir())
-	blobstore, err := app.setupBlobstore(settingsService.GetSettings().Blobstore, blobManager)
+	initialBlobstore, err := app.setupBlobstore(settingsService.GetSettings().Blobstore, blobManager, metrics)
 
 	if err != nil {
 		return bosherr.WrapError(err, "Getting blobstore")
 	}
 
+	blobstore := boshagentblobstore.NewSwitchableBlobstore(initialBlobstore)
+
+	settingsService.Subscribe(func(settings boshsettings.Settings) error {
+		changedBlobstore, err := app.setupBlobstore(settings.Blobstore, blobManager, metrics)
+		if err != nil {
+			return err
+		}
+
+		blobstore.Switch(changedBlobstore)
+
+		return nil
+	}, boshsettings.FieldBlobstore)
+
 	monitClientProvider := boshmonit.NewProvider(app.platform, app.logger)
 
 	monitClient, err := monitClientProvider.Get()
@@ -134,6 +183,8 @@ func (app *app) Setup(opts Options) error {
 		return bosherr.WrapError(err, "Getting monit client")
 	}
 
+	monitClient = boshmonit.NewMetricsClient(monitClient, metrics)
+
 	jobSupervisorProvider := boshjobsuper.NewProvider(
 		app.platform,
 		monitClient,
@@ -151,9 +202,22 @@ func (app *app) Setup(opts Options) error {
 
 	applier, compiler := app.buildApplierAndCompiler(app.dirProvider, blobstore, jobSupervisor)
 
-	uuidGen := boshuuid.NewGenerator()
+	taskJournal := boshtask.NewJournal(
+		app.logger,
+		app.platform.GetFs(),
+		filepath.Join(app.dirProvider.BoshDir(), "task_journal.json"),
+		taskJournalTTL,
+		timeService,
+	)
 
-	taskService := boshtask.NewAsyncTaskService(uuidGen, app.logger)
+	taskService := boshtask.NewAsyncTaskService(
+		uuidGen,
+		taskJournal,
+		boshtask.DefaultConcurrencyPolicy(),
+		timeService,
+		app.logger,
+		metrics,
+	)
 
 	taskManager := boshtask.NewManagerProvider().NewManager(
 		app.logger,
@@ -175,6 +239,8 @@ func (app *app) Setup(opts Options) error {
 		app.logger,
 	)
 
+	app.adminServer = boshadmin.NewServer(app.dirProvider.AdminSocketPath(), boshadmin.DefaultAllowedActions, app.platform.GetFs(), app.logger)
+
 	actionFactory := boshaction.NewFactory(
 		settingsService,
 		app.platform,
@@ -187,19 +253,38 @@ func (app *app) Setup(opts Options) error {
 		jobSupervisor,
 		specService,
 		jobScriptProvider,
+		app.adminServer,
 		app.logger,
 	)
 
+	err = config.Authorization.Validate(actionFactory)
+	if err != nil {
+		return bosherr.WrapError(err, "Validating authorization policy")
+	}
+
 	actionRunner := boshaction.NewRunner()
 
+	requestCache := boshreqcache.NewFileCache(
+		app.platform.GetFs(),
+		filepath.Join(app.dirProvider.BoshDir(), "request_cache.json"),
+		requestCacheMaxEntries,
+		app.logger,
+	)
+
 	actionDispatcher := boshagent.NewActionDispatcher(
 		app.logger,
 		taskService,
 		taskManager,
 		actionFactory,
 		actionRunner,
+		requestCache,
+		config.Authorization,
+		auditLogger,
+		metrics,
 	)
 
+	app.actionDispatcher = actionDispatcher
+
 	syslogServer := boshsyslog.NewServer(33331, net.Listen, app.logger)
 
 	app.agent = boshagent.New(
@@ -214,13 +299,27 @@ func (app *app) Setup(opts Options) error {
 		settingsService,
 		uuidGen,
 		timeService,
+		config.SettingsRefresh.Interval(),
 	)
 
 	return nil
 }
 
 func (app *app) Run() error {
-	err := app.agent.Run()
+	if app.metricsServer != nil {
+		err := app.metricsServer.Start()
+		if err != nil {
+			return bosherr.WrapError(err, "Starting metrics server")
+		}
+	}
+
+	// Agent is still usable over mbus without admin socket
+	err := app.adminServer.Start(app.actionDispatcher.Dispatch)
+	if err != nil {
+		app.logger.Warn(app.logTag, "Failed to start admin server: %s", err.Error())
+	}
+
+	err = app.agent.Run()
 	if err != nil {
 		return bosherr.WrapError(err, "Running agent")
 	}
@@ -317,7 +416,7 @@ func (app *app) fileContents(path string) string {
 	return contents
 }
 
-func (app *app) setupBlobstore(blobstoreSettings boshsettings.Blobstore, blobManager boshblob.BlobManagerInterface) (boshblob.DigestBlobstore, error) {
+func (app *app) setupBlobstore(blobstoreSettings boshsettings.Blobstore, blobManager boshblob.BlobManagerInterface, metrics *boshmetrics.Registry) (boshblob.DigestBlobstore, error) {
 	blobstoreProvider := boshblob.NewProvider(
 		app.platform.GetFs(),
 		app.platform.GetRunner(),
@@ -330,5 +429,8 @@ func (app *app) setupBlobstore(blobstoreSettings boshsettings.Blobstore, blobMan
 		return nil, bosherr.WrapError(err, "Getting blobstore")
 	}
 
+	// Only blobs fetched from blobstore count as downloads, not ones uploaded directly to agent
+	blobstore = boshagentblobstore.NewMetricsBlobstore(blobstore, app.platform.GetFs(), metrics)
+
 	return boshagentblobstore.NewCascadingBlobstore(blobstore, blobManager, app.logger), nil
 }
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -71,15 +72,15 @@ func init() {
 				"networks": {
 					"netA": {
 						"default": ["dns", "gateway"],
-						"ip": "ww.ww.ww.ww",
+						"ip": "10.0.0.5",
 						"dns": [
-							"xx.xx.xx.xx",
-							"yy.yy.yy.yy"
+							"8.8.8.8",
+							"8.8.4.4"
 						]
 					},
 					"netB": {
 						"dns": [
-							"zz.zz.zz.zz"
+							"10.0.0.2"
 						]
 					}
 				},
@@ -120,6 +121,22 @@ func init() {
 			Expect(app.GetPlatform().GetDevicePathResolver()).To(Equal(devicepathresolver.NewIdentityDevicePathResolver()))
 		})
 
+		It("encrypts settings cached before settings were encrypted", func() {
+			err := app.Setup(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			settingsData, err := ioutil.ReadFile(filepath.Join(baseDir, "bosh", "settings.json"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(settingsData)).ToNot(ContainSubstring("my-agent-id"))
+
+			keyInfo, err := os.Stat(filepath.Join(baseDir, "bosh", "settings.key"))
+			Expect(err).ToNot(HaveOccurred())
+
+			if runtime.GOOS != "windows" {
+				Expect(keyInfo.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			}
+		})
+
 		Context("when DevicePathResolutionType is 'virtio'", func() {
 			BeforeEach(func() {
 				agentConfJSON = `{
@@ -3,8 +3,12 @@ package app
 import (
 	"encoding/json"
 
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
@@ -12,6 +16,24 @@ import (
 type Config struct {
 	Platform       boshplatform.Options
 	Infrastructure boshinf.Options
+
+	// HTTPSTLS provides defaults for https mbus TLS settings
+	HTTPSTLS boshsettings.HTTPSTLS
+
+	// HTTPSLimits restricts size and rate of requests to https mbus
+	HTTPSLimits boshdispatcher.Limits
+
+	// Metrics enables local listener serving agent metrics
+	Metrics boshmetrics.Options
+
+	// SettingsEncryption configures key for settings cached in settings.json
+	SettingsEncryption boshsettings.CacheEncryptionOptions
+
+	// SettingsRefresh configures periodic refresh of settings from settings source
+	SettingsRefresh boshsettings.RefreshOptions
+
+	// Authorization restricts actions callers may run; all actions are allowed without rules
+	Authorization boshaction.Policy
 }
 
 func LoadConfigFromPath(fs boshsys.FileSystem, path string) (Config, error) {
@@ -4,8 +4,11 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshaction "github.com/cloudfoundry/bosh-agent/agent/action"
 	boshinf "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
@@ -58,11 +61,46 @@ var _ = Describe("LoadConfigFromPath", func() {
 						"URI": "/fake-uri",
 						"Headers": {"fake": "headers"},
 						"SettingsPath": "/fake-settings-path"
+					  },
+					  {
+						"Type": "NoCloud",
+						"Directory": "/fake-seed-dir",
+						"DiskPaths": ["/fake-disk-path"],
+						"MetaDataPath": "meta-data",
+						"UserDataPath": "user-data",
+						"NetworkConfigPath": "network-config"
+					  },
+					  {
+						"Type": "OpenStack",
+						"DiskPaths": ["/fake-disk-path"],
+						"MetaDataPath": "openstack/latest/meta_data.json",
+						"UserDataPath": "openstack/latest/user_data",
+						"NetworkDataPath": "openstack/latest/network_data.json"
 					  }
 				  ],
 				  "UseServerName": true,
 				  "UseRegistry": true
 				}
+			},
+			"Metrics": {
+				"ListenAddress": "127.0.0.1:9100"
+			},
+			"SettingsEncryption": {
+				"KeyPath": "/fake-key-path"
+			},
+			"SettingsRefresh": {
+				"IntervalSeconds": 300
+			},
+			"Authorization": {
+				"Rules": [
+					{
+						"HTTPSUser": "fake-user",
+						"Actions": [
+							{"Name": "fetch_logs", "Arguments": [["job"]]},
+							{"Name": "get_state"}
+						]
+					}
+				]
 			}
 		}`)
 
@@ -103,11 +141,44 @@ var _ = Describe("LoadConfigFromPath", func() {
 							Headers:      map[string]string{"fake": "headers"},
 							SettingsPath: "/fake-settings-path",
 						},
+						boshinf.NoCloudSourceOptions{
+							Directory:         "/fake-seed-dir",
+							DiskPaths:         []string{"/fake-disk-path"},
+							MetaDataPath:      "meta-data",
+							UserDataPath:      "user-data",
+							NetworkConfigPath: "network-config",
+						},
+						boshinf.OpenStackSourceOptions{
+							DiskPaths:       []string{"/fake-disk-path"},
+							MetaDataPath:    "openstack/latest/meta_data.json",
+							UserDataPath:    "openstack/latest/user_data",
+							NetworkDataPath: "openstack/latest/network_data.json",
+						},
 					},
 					UseServerName: true,
 					UseRegistry:   true,
 				},
 			},
+			Metrics: boshmetrics.Options{
+				ListenAddress: "127.0.0.1:9100",
+			},
+			SettingsEncryption: boshsettings.CacheEncryptionOptions{
+				KeyPath: "/fake-key-path",
+			},
+			SettingsRefresh: boshsettings.RefreshOptions{
+				IntervalSeconds: 300,
+			},
+			Authorization: boshaction.Policy{
+				Rules: []boshaction.PolicyRule{
+					{
+						HTTPSUser: "fake-user",
+						Actions: []boshaction.PolicyAction{
+							{Name: "fetch_logs", Arguments: [][]string{{"job"}}},
+							{Name: "get_state"},
+						},
+					},
+				},
+			},
 		}))
 	})
 
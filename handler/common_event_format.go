@@ -19,6 +19,7 @@ const (
 type CommonEventFormat interface {
 	ProduceHTTPRequestEventLog(*http.Request, int, string) (string, error)
 	ProduceNATSRequestEventLog(string, string, string, string, int, string, string) (string, error)
+	ProduceActionDeniedEventLog(Caller, string, string) (string, error)
 }
 
 func NewCommonEventFormat() CommonEventFormat {
@@ -79,3 +80,18 @@ func (cef concreteCommonEventFormat) ProduceNATSRequestEventLog(addr string, por
 
 	return fmt.Sprintf("CEF:%v|%s|%s|%s|%s|%s|%v|%s", cefVersion, deviceVendor, deviceProduct, deviceVersion, signatureID, msgMethod, severity, extension), nil
 }
+
+// ProduceActionDeniedEventLog records callers denied by authorization policy
+// regardless of the transport their request was received on.
+func (cef concreteCommonEventFormat) ProduceActionDeniedEventLog(caller Caller, msgMethod string, reason string) (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	extension := fmt.Sprintf(
+		`duser=%s shost=%s cs1=%s cs1Label=statusReason cs2=%s cs2Label=callerKind`,
+		caller.Name, hostname, reason, caller.Kind)
+
+	return fmt.Sprintf("CEF:%v|%s|%s|%s|%s|%s|%v|%s", cefVersion, deviceVendor, deviceProduct, deviceVersion, signatureID, msgMethod, 7, extension), nil
+}
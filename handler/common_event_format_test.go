@@ -72,4 +72,17 @@ var _ = Describe("CommonEventFormat", func() {
 			})
 		})
 	})
+
+	Context("when action is denied by authorization policy", func() {
+		It("should produce CEF string with severity=7, caller and statusReason", func() {
+			caller := handler.Caller{Kind: handler.CallerHTTPSUser, Name: "fake-user"}
+
+			cefLog, err := cef.ProduceActionDeniedEventLog(caller, "fetch_logs", "fake-reason")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cefLog).To(ContainSubstring("CEF:0|CloudFoundry|BOSH|1|agent_api|fetch_logs|7|duser=fake-user"))
+			Expect(cefLog).To(ContainSubstring("shost="))
+			Expect(cefLog).To(ContainSubstring("cs1=fake-reason cs1Label=statusReason cs2=https_user cs2Label=callerKind"))
+		})
+	})
 })
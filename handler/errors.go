@@ -0,0 +1,144 @@
+package handler
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type ErrorCategory string
+
+const (
+	// ErrorCategoryClient indicates that request itself is invalid
+	// and will fail again unless it is changed.
+	ErrorCategoryClient ErrorCategory = "client"
+
+	// ErrorCategoryNotFound indicates that request refers
+	// to a resource (task, disk, etc.) agent does not know about.
+	ErrorCategoryNotFound ErrorCategory = "not_found"
+
+	// ErrorCategoryUnauthorized indicates that request was rejected
+	// because its origin could not be verified or is not allowed to make it.
+	ErrorCategoryUnauthorized ErrorCategory = "unauthorized"
+
+	// ErrorCategoryUnavailable indicates that a dependency
+	// (monit, blobstore, etc.) could not be reached.
+	ErrorCategoryUnavailable ErrorCategory = "unavailable"
+
+	// ErrorCategoryInternal indicates that agent failed
+	// for a reason not attributable to the request.
+	ErrorCategoryInternal ErrorCategory = "internal"
+)
+
+// ErrorCode is only given to failures that callers are expected to handle differently.
+// Other failures (e.g. of apply, compile_package or mounting disks) are reported
+// without code, category and retryable flag; callers should treat them as internal
+// and not retryable.
+type ErrorCode string
+
+const (
+	ErrorCodeUnknownAction            ErrorCode = "unknown_action"
+	ErrorCodeInvalidArguments         ErrorCode = "invalid_arguments"
+	ErrorCodeRequestIDConflict        ErrorCode = "request_id_conflict"
+	ErrorCodeTaskNotFound             ErrorCode = "task_not_found"
+	ErrorCodeDiskNotFound             ErrorCode = "disk_not_found"
+	ErrorCodeChecksumMismatch         ErrorCode = "checksum_mismatch"
+	ErrorCodeJobSupervisorUnavailable ErrorCode = "job_supervisor_unavailable"
+	ErrorCodeBlobstoreUnavailable     ErrorCode = "blobstore_unavailable"
+	ErrorCodeInvalidSignature         ErrorCode = "invalid_signature"
+	ErrorCodeActionNotAllowed         ErrorCode = "action_not_allowed"
+	ErrorCodeInternal                 ErrorCode = "internal"
+)
+
+type errorCodeInfo struct {
+	category  ErrorCategory
+	retryable bool
+}
+
+var errorCodeInfos = map[ErrorCode]errorCodeInfo{
+	ErrorCodeUnknownAction:            {ErrorCategoryClient, false},
+	ErrorCodeInvalidArguments:         {ErrorCategoryClient, false},
+	ErrorCodeRequestIDConflict:        {ErrorCategoryClient, false},
+	ErrorCodeTaskNotFound:             {ErrorCategoryNotFound, false},
+	ErrorCodeDiskNotFound:             {ErrorCategoryNotFound, false},
+	ErrorCodeChecksumMismatch:         {ErrorCategoryClient, false},
+	ErrorCodeJobSupervisorUnavailable: {ErrorCategoryUnavailable, true},
+	ErrorCodeBlobstoreUnavailable:     {ErrorCategoryUnavailable, true},
+	ErrorCodeInvalidSignature:         {ErrorCategoryUnauthorized, false},
+	ErrorCodeActionNotAllowed:         {ErrorCategoryUnauthorized, false},
+	ErrorCodeInternal:                 {ErrorCategoryInternal, false},
+}
+
+// Error annotates an error with a code so that API consumers
+// do not need to match on error messages.
+type Error struct {
+	Code      ErrorCode
+	Category  ErrorCategory
+	Retryable bool
+
+	Err error
+}
+
+// NewError returns error with category and retryability
+// determined by the given code. Unknown codes are considered internal.
+func NewError(code ErrorCode, err error) Error {
+	info, found := errorCodeInfos[code]
+	if !found {
+		info = errorCodeInfos[ErrorCodeInternal]
+	}
+
+	return Error{
+		Code:      code,
+		Category:  info.category,
+		Retryable: info.retryable,
+		Err:       err,
+	}
+}
+
+func NewErrorf(code ErrorCode, msg string, args ...interface{}) Error {
+	return NewError(code, bosherr.Errorf(msg, args...))
+}
+
+func (e Error) Error() string {
+	if e.Err == nil {
+		return string(e.Code)
+	}
+
+	return e.Err.Error()
+}
+
+func (e Error) ShortError() string {
+	if shortenableErr, ok := e.Err.(bosherr.ShortenableError); ok {
+		return shortenableErr.ShortError()
+	}
+
+	return e.Error()
+}
+
+// FindError returns the outermost coded error
+// found in the chain of wrapped errors.
+func FindError(err error) (Error, bool) {
+	switch typedErr := err.(type) {
+	case Error:
+		return typedErr, true
+
+	case *Error:
+		if typedErr != nil {
+			return *typedErr, true
+		}
+
+	case bosherr.ComplexError:
+		if codedErr, found := FindError(typedErr.Err); found {
+			return codedErr, true
+		}
+
+		return FindError(typedErr.Cause)
+	}
+
+	return Error{}, false
+}
+
+// IsRetryable returns true if error chain contains
+// a coded error that indicates request may succeed if retried.
+func IsRetryable(err error) bool {
+	codedErr, found := FindError(err)
+	return found && codedErr.Retryable
+}
@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var _ = Describe("Error", func() {
+	Describe("NewError", func() {
+		It("determines category and retryability from code", func() {
+			err := NewError(ErrorCodeBlobstoreUnavailable, errors.New("fake-err"))
+			Expect(err.Code).To(Equal(ErrorCodeBlobstoreUnavailable))
+			Expect(err.Category).To(Equal(ErrorCategoryUnavailable))
+			Expect(err.Retryable).To(BeTrue())
+			Expect(err.Error()).To(Equal("fake-err"))
+		})
+
+		It("considers unknown codes internal", func() {
+			err := NewError(ErrorCode("fake-code"), errors.New("fake-err"))
+			Expect(err.Code).To(Equal(ErrorCode("fake-code")))
+			Expect(err.Category).To(Equal(ErrorCategoryInternal))
+			Expect(err.Retryable).To(BeFalse())
+		})
+	})
+
+	Describe("FindError", func() {
+		It("finds coded error wrapped by other errors", func() {
+			codedErr := NewErrorf(ErrorCodeTaskNotFound, "fake-err")
+			err := bosherr.WrapError(bosherr.WrapError(codedErr, "fake-wrap1"), "fake-wrap2")
+
+			foundErr, found := FindError(err)
+			Expect(found).To(BeTrue())
+			Expect(foundErr).To(Equal(codedErr))
+		})
+
+		It("finds outermost coded error", func() {
+			innerErr := NewErrorf(ErrorCodeDiskNotFound, "fake-err")
+			outerErr := NewError(ErrorCodeInvalidArguments, bosherr.WrapError(innerErr, "fake-wrap"))
+
+			foundErr, found := FindError(outerErr)
+			Expect(found).To(BeTrue())
+			Expect(foundErr.Code).To(Equal(ErrorCodeInvalidArguments))
+		})
+
+		It("returns false when error is not coded", func() {
+			_, found := FindError(bosherr.WrapError(errors.New("fake-err"), "fake-wrap"))
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("IsRetryable", func() {
+		It("returns true for retryable coded errors", func() {
+			err := bosherr.WrapError(NewErrorf(ErrorCodeJobSupervisorUnavailable, "fake-err"), "fake-wrap")
+			Expect(IsRetryable(err)).To(BeTrue())
+		})
+
+		It("returns false for non-retryable coded errors", func() {
+			Expect(IsRetryable(NewErrorf(ErrorCodeDiskNotFound, "fake-err"))).To(BeFalse())
+		})
+
+		It("returns false for checksum mismatch since retrying the same request fails again", func() {
+			Expect(IsRetryable(NewErrorf(ErrorCodeChecksumMismatch, "fake-err"))).To(BeFalse())
+		})
+
+		It("returns false for errors without code", func() {
+			Expect(IsRetryable(errors.New("fake-err"))).To(BeFalse())
+		})
+	})
+})
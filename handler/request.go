@@ -2,6 +2,27 @@ package handler
 
 type ProtocolVersion int
 
+type CallerKind string
+
+const (
+	CallerNATS        CallerKind = "nats"
+	CallerDialOut     CallerKind = "dial_out"
+	CallerHTTPSUser   CallerKind = "https_user"
+	CallerMTLS        CallerKind = "mtls"
+	CallerAdminSocket CallerKind = "admin_socket"
+	CallerSigningKey  CallerKind = "signing_key"
+)
+
+// Caller identifies sender of a request as established by the handler
+// that received it: ID of the key a verified request signature was made with,
+// basic auth user for HTTPS or common name of verified client certificate.
+// Reply to subjects of NATS and dial out callers are chosen by the sender
+// and are only advisory.
+type Caller struct {
+	Kind CallerKind
+	Name string
+}
+
 func NewRequest(replyTo, method string, payload []byte, protocolVersion ProtocolVersion) Request {
 	return Request{
 		ReplyTo:         replyTo,
@@ -16,8 +37,38 @@ type Request struct {
 	Method          string
 	Payload         []byte
 	ProtocolVersion ProtocolVersion `json:"protocol"`
+
+	// RequestID is optionally set by API consumers so that
+	// retried requests are not run more than once.
+	RequestID string `json:"request_id"`
+
+	// DryRun asks actions to describe changes they would make
+	// instead of making them. Actions without dry run support fail.
+	DryRun bool `json:"dry_run"`
+
+	// Caller is never read from request JSON
+	Caller Caller `json:"-"`
 }
 
 func (r Request) GetPayload() []byte {
 	return r.Payload
 }
+
+// SignedCaller identifies callers of requests with verified signature
+// by signing key ID since unlike reply to subjects it cannot be forged.
+func SignedCaller(keyID string, caller Caller) Caller {
+	if keyID != "" {
+		return Caller{Kind: CallerSigningKey, Name: keyID}
+	}
+
+	return caller
+}
+
+// WithCaller returns handler func that sets caller returned by callerFunc
+// on every request before passing it to the handler func.
+func WithCaller(handlerFunc Func, callerFunc func(Request) Caller) Func {
+	return func(req Request) Response {
+		req.Caller = callerFunc(req)
+		return handlerFunc(req)
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// SignedRequest wraps request JSON so that signature
+// covers exact bytes produced by the client.
+type SignedRequest struct {
+	Signature *RequestSignature `json:"signature"`
+
+	// SignedRequest is base64 encoded request JSON
+	SignedRequest []byte `json:"signed_request"`
+}
+
+type RequestSignature struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+
+	// Timestamp is a number of seconds since Unix epoch
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+
+	// Value is base64 encoded signature of SignedContent
+	Value []byte `json:"value"`
+}
+
+// SignedContent returns "<agent id>\n<timestamp>\n<nonce>\n<request JSON>"
+// so that requests signed for one agent cannot be replayed to other agents.
+func (s RequestSignature) SignedContent(agentID string, requestJSON []byte) []byte {
+	return append([]byte(fmt.Sprintf("%s\n%d\n%s\n", agentID, s.Timestamp, s.Nonce)), requestJSON...)
+}
+
+type RequestVerifier interface {
+	// Verify returns request JSON after checking signature and replay window
+	// together with ID of the key request was signed with, if its signature was verified.
+	// Request JSON is also returned together with an error when request
+	// is rejected so that rejected requests can be audited and replied to.
+	Verify(rawJSON []byte) ([]byte, string, error)
+}
+
+type concreteRequestVerifier struct {
+	settingsService boshsettings.Service
+	timeService     clock.Clock
+
+	// usedNonces keeps nonce expiration times;
+	// nonce does not need to be kept after its timestamp is outside of allowed window
+	usedNonces     map[string]time.Time
+	usedNoncesLock sync.Mutex
+
+	// startedAt is the earliest accepted timestamp since
+	// nonces used before agent restart are not known
+	startedAt time.Time
+}
+
+func NewRequestVerifier(settingsService boshsettings.Service, timeService clock.Clock) RequestVerifier {
+	return &concreteRequestVerifier{
+		settingsService: settingsService,
+		timeService:     timeService,
+		usedNonces:      map[string]time.Time{},
+		startedAt:       timeService.Now().Truncate(time.Second),
+	}
+}
+
+func (v *concreteRequestVerifier) Verify(rawJSON []byte) ([]byte, string, error) {
+	settings := v.settingsService.GetSettings()
+	signingSettings := settings.RequestSigning
+
+	var signedRequest SignedRequest
+
+	err := json.Unmarshal(rawJSON, &signedRequest)
+	if err != nil {
+		if !signingSettings.IsEnabled() {
+			// Let request handling report invalid JSON as before
+			return rawJSON, "", nil
+		}
+		return rawJSON, "", NewError(ErrorCodeInvalidSignature, bosherr.WrapError(err, "Unmarshalling signed request"))
+	}
+
+	if signedRequest.Signature == nil {
+		if signingSettings.IsEnabled() {
+			return rawJSON, "", NewErrorf(ErrorCodeInvalidSignature, "Request is not signed")
+		}
+		return rawJSON, "", nil
+	}
+
+	requestJSON := signedRequest.SignedRequest
+
+	// Unsigned requests are accepted when signing is not configured
+	// so signed requests do not need to be verified either
+	if !signingSettings.IsEnabled() {
+		return requestJSON, "", nil
+	}
+
+	err = v.verifySignature(signingSettings, settings.AgentID, *signedRequest.Signature, requestJSON)
+	if err != nil {
+		return requestJSON, "", NewError(ErrorCodeInvalidSignature, err)
+	}
+
+	return requestJSON, signedRequest.Signature.KeyID, nil
+}
+
+func (v *concreteRequestVerifier) verifySignature(
+	signingSettings boshsettings.RequestSigning,
+	agentID string,
+	signature RequestSignature,
+	requestJSON []byte,
+) error {
+	key, found := signingSettings.FindKey(signature.KeyID)
+	if !found {
+		return bosherr.Errorf("Unknown signing key '%s'", signature.KeyID)
+	}
+
+	if signature.Algorithm != key.Algorithm {
+		return bosherr.Errorf("Signing key '%s' does not use algorithm '%s'", key.ID, signature.Algorithm)
+	}
+
+	if signature.Nonce == "" {
+		return bosherr.Error("Request nonce is missing")
+	}
+
+	now := v.timeService.Now()
+	timestamp := time.Unix(signature.Timestamp, 0)
+	maxClockSkew := signingSettings.MaxClockSkewDuration()
+
+	if timestamp.Before(now.Add(-maxClockSkew)) || timestamp.After(now.Add(maxClockSkew)) {
+		return bosherr.Errorf("Request timestamp %d is outside of allowed window of %s", signature.Timestamp, maxClockSkew)
+	}
+
+	if timestamp.Before(v.startedAt) {
+		return bosherr.Errorf("Request timestamp %d is before agent started", signature.Timestamp)
+	}
+
+	content := signature.SignedContent(agentID, requestJSON)
+
+	switch key.Algorithm {
+	case boshsettings.RequestSigningAlgorithmHMACSHA256:
+		mac := hmac.New(sha256.New, []byte(key.Secret))
+		_, _ = mac.Write(content)
+
+		if !hmac.Equal(mac.Sum(nil), signature.Value) {
+			return bosherr.Error("Request signature does not match")
+		}
+
+	case boshsettings.RequestSigningAlgorithmEd25519:
+		publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Decoding public key '%s'", key.ID)
+		}
+
+		if len(publicKey) != ed25519.PublicKeySize {
+			return bosherr.Errorf("Public key '%s' is not a valid ed25519 key", key.ID)
+		}
+
+		if !ed25519.Verify(ed25519.PublicKey(publicKey), content, signature.Value) {
+			return bosherr.Error("Request signature does not match")
+		}
+
+	default:
+		return bosherr.Errorf("Unsupported signature algorithm '%s'", key.Algorithm)
+	}
+
+	// Nonce is only recorded after signature is verified
+	// so that forged requests cannot use up nonces
+	return v.useNonce(key.ID+":"+signature.Nonce, timestamp.Add(maxClockSkew), now)
+}
+
+func (v *concreteRequestVerifier) useNonce(nonce string, expiresAt, now time.Time) error {
+	v.usedNoncesLock.Lock()
+	defer v.usedNoncesLock.Unlock()
+
+	for usedNonce, usedExpiresAt := range v.usedNonces {
+		if usedExpiresAt.Before(now) {
+			delete(v.usedNonces, usedNonce)
+		}
+	}
+
+	if _, found := v.usedNonces[nonce]; found {
+		return bosherr.Error("Request nonce has already been used")
+	}
+
+	v.usedNonces[nonce] = expiresAt
+
+	return nil
+}
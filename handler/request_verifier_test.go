@@ -0,0 +1,247 @@
+package handler_test
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/cloudfoundry/bosh-agent/handler"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+)
+
+func signRequestWithHMAC(secret string, signature RequestSignature, requestJSON string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(signature.SignedContent("fake-agent-id", []byte(requestJSON)))
+	signature.Value = mac.Sum(nil)
+
+	return buildSignedRequest(signature, requestJSON)
+}
+
+func buildSignedRequest(signature RequestSignature, requestJSON string) []byte {
+	signedRequestJSON, err := json.Marshal(SignedRequest{
+		Signature:     &signature,
+		SignedRequest: []byte(requestJSON),
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	return signedRequestJSON
+}
+
+var _ = Describe("RequestVerifier", func() {
+	var (
+		settingsService *fakesettings.FakeSettingsService
+		timeService     *fakeclock.FakeClock
+		verifier        RequestVerifier
+
+		requestJSON string
+		signature   RequestSignature
+	)
+
+	BeforeEach(func() {
+		settingsService = &fakesettings.FakeSettingsService{}
+		settingsService.Settings.AgentID = "fake-agent-id"
+		timeService = fakeclock.NewFakeClock(time.Unix(1500000000, 0))
+		verifier = NewRequestVerifier(settingsService, timeService)
+
+		requestJSON = `{"method":"ping","arguments":[],"reply_to":"fake-reply-to"}`
+		signature = RequestSignature{
+			KeyID:     "fake-key-id",
+			Algorithm: "hmac-sha256",
+			Timestamp: 1500000000,
+			Nonce:     "fake-nonce",
+		}
+	})
+
+	expectRejected := func(rawJSON []byte, msg string) {
+		_, _, err := verifier.Verify(rawJSON)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(msg))
+
+		codedErr, found := FindError(err)
+		Expect(found).To(BeTrue())
+		Expect(codedErr.Code).To(Equal(ErrorCodeInvalidSignature))
+		Expect(codedErr.Category).To(Equal(ErrorCategoryUnauthorized))
+	}
+
+	Context("when request signing is not configured", func() {
+		It("returns unsigned request as is", func() {
+			verifiedJSON, _, err := verifier.Verify([]byte(requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifiedJSON).To(Equal([]byte(requestJSON)))
+		})
+
+		It("returns invalid JSON as is so that it is reported when handling request", func() {
+			verifiedJSON, _, err := verifier.Verify([]byte("bad json"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifiedJSON).To(Equal([]byte("bad json")))
+		})
+
+		It("unwraps signed request", func() {
+			verifiedJSON, _, err := verifier.Verify(buildSignedRequest(signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifiedJSON).To(Equal([]byte(requestJSON)))
+		})
+	})
+
+	Context("when request signing is configured with hmac-sha256 key", func() {
+		BeforeEach(func() {
+			settingsService.Settings.RequestSigning = boshsettings.RequestSigning{
+				Keys: []boshsettings.RequestSigningKey{
+					{ID: "fake-other-key-id", Algorithm: "hmac-sha256", Secret: "fake-other-secret"},
+					{ID: "fake-key-id", Algorithm: "hmac-sha256", Secret: "fake-secret"},
+				},
+			}
+		})
+
+		It("returns request signed with configured key", func() {
+			verifiedJSON, _, err := verifier.Verify(signRequestWithHMAC("fake-secret", signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifiedJSON).To(Equal([]byte(requestJSON)))
+		})
+
+		It("rejects unsigned request", func() {
+			expectRejected([]byte(requestJSON), "Request is not signed")
+		})
+
+		It("rejects invalid JSON", func() {
+			expectRejected([]byte("bad json"), "Unmarshalling signed request")
+		})
+
+		It("rejects request signed with different secret and returns request JSON", func() {
+			verifiedJSON, _, err := verifier.Verify(signRequestWithHMAC("fake-wrong-secret", signature, requestJSON))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Request signature does not match"))
+			Expect(verifiedJSON).To(Equal([]byte(requestJSON)))
+		})
+
+		It("rejects request which was modified after signing", func() {
+			signedRequestJSON := signRequestWithHMAC("fake-secret", signature, requestJSON)
+
+			var signedRequest SignedRequest
+			Expect(json.Unmarshal(signedRequestJSON, &signedRequest)).To(Succeed())
+
+			expectRejected(buildSignedRequest(*signedRequest.Signature, `{"method":"ssh"}`), "Request signature does not match")
+		})
+
+		It("rejects request signed with unknown key", func() {
+			signature.KeyID = "fake-unknown-key-id"
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "Unknown signing key 'fake-unknown-key-id'")
+		})
+
+		It("rejects request using algorithm different from key's algorithm", func() {
+			signature.Algorithm = "ed25519"
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "does not use algorithm 'ed25519'")
+		})
+
+		It("rejects request without nonce", func() {
+			signature.Nonce = ""
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "Request nonce is missing")
+		})
+
+		It("rejects request with timestamp before allowed window", func() {
+			signature.Timestamp = 1500000000 - 301
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "outside of allowed window of 5m0s")
+		})
+
+		It("rejects request with timestamp before agent started since nonces used earlier are not known", func() {
+			signature.Timestamp = 1500000000 - 1
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "Request timestamp 1499999999 is before agent started")
+
+			timeService.Increment(time.Minute)
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "is before agent started")
+		})
+
+		It("rejects request signed for another agent", func() {
+			settingsService.Settings.AgentID = "fake-other-agent-id"
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "Request signature does not match")
+		})
+
+		It("rejects request with timestamp after allowed window", func() {
+			signature.Timestamp = 1500000000 + 301
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "outside of allowed window")
+		})
+
+		It("uses configured clock skew", func() {
+			settingsService.Settings.RequestSigning.MaxClockSkew = 10
+			signature.Timestamp = 1500000000 - 11
+			expectRejected(signRequestWithHMAC("fake-secret", signature, requestJSON), "outside of allowed window of 10s")
+		})
+
+		It("rejects replayed request", func() {
+			signedRequestJSON := signRequestWithHMAC("fake-secret", signature, requestJSON)
+
+			_, _, err := verifier.Verify(signedRequestJSON)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectRejected(signedRequestJSON, "Request nonce has already been used")
+		})
+
+		It("does not record nonce of rejected request", func() {
+			expectRejected(signRequestWithHMAC("fake-wrong-secret", signature, requestJSON), "Request signature does not match")
+
+			_, _, err := verifier.Verify(signRequestWithHMAC("fake-secret", signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts same nonce signed with different key", func() {
+			_, _, err := verifier.Verify(signRequestWithHMAC("fake-secret", signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+
+			signature.KeyID = "fake-other-key-id"
+			_, _, err = verifier.Verify(signRequestWithHMAC("fake-other-secret", signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when request signing is configured with ed25519 key", func() {
+		var privateKey ed25519.PrivateKey
+
+		BeforeEach(func() {
+			publicKey, generatedPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			privateKey = generatedPrivateKey
+
+			settingsService.Settings.RequestSigning = boshsettings.RequestSigning{
+				Keys: []boshsettings.RequestSigningKey{
+					{ID: "fake-key-id", Algorithm: "ed25519", PublicKey: base64.StdEncoding.EncodeToString(publicKey)},
+				},
+			}
+
+			signature.Algorithm = "ed25519"
+		})
+
+		It("returns request signed with private key", func() {
+			signature.Value = ed25519.Sign(privateKey, signature.SignedContent("fake-agent-id", []byte(requestJSON)))
+
+			verifiedJSON, _, err := verifier.Verify(buildSignedRequest(signature, requestJSON))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(verifiedJSON).To(Equal([]byte(requestJSON)))
+		})
+
+		It("rejects request signed with different private key", func() {
+			_, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+
+			signature.Value = ed25519.Sign(otherPrivateKey, signature.SignedContent("fake-agent-id", []byte(requestJSON)))
+
+			expectRejected(buildSignedRequest(signature, requestJSON), "Request signature does not match")
+		})
+
+		It("rejects request when public key is invalid", func() {
+			settingsService.Settings.RequestSigning.Keys[0].PublicKey = base64.StdEncoding.EncodeToString([]byte("fake-short-key"))
+			signature.Value = ed25519.Sign(privateKey, signature.SignedContent("fake-agent-id", []byte(requestJSON)))
+
+			expectRejected(buildSignedRequest(signature, requestJSON), "Public key 'fake-key-id' is not a valid ed25519 key")
+		})
+	})
+})
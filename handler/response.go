@@ -23,23 +23,39 @@ func (r valueResponse) Shorten() Response {
 }
 
 type exceptionResponse struct {
-	Exception struct {
-		Message string `json:"message,omitempty"`
-	} `json:"exception"`
+	Exception exception `json:"exception"`
 
 	err error
 }
 
+type exception struct {
+	Message string `json:"message,omitempty"`
+
+	// Only included for errors that were given a code;
+	// exceptions without code are internal and not retryable
+	Code      ErrorCode     `json:"code,omitempty"`
+	Category  ErrorCategory `json:"category,omitempty"`
+	Retryable bool          `json:"retryable,omitempty"`
+}
+
 func NewExceptionResponse(err error) (resp Response) {
 	r := exceptionResponse{}
 	r.Exception.Message = err.Error()
 	r.err = err
+
+	if codedErr, found := FindError(err); found {
+		r.Exception.Code = codedErr.Code
+		r.Exception.Category = codedErr.Category
+		r.Exception.Retryable = codedErr.Retryable
+	}
+
 	return r
 }
 
 func (r exceptionResponse) Shorten() Response {
 	if typedErr, ok := r.err.(bosherr.ShortenableError); ok {
 		sr := exceptionResponse{}
+		sr.Exception = r.Exception
 		sr.Exception.Message = typedErr.ShortError()
 		sr.err = typedErr
 		return sr
@@ -47,3 +63,10 @@ func (r exceptionResponse) Shorten() Response {
 
 	return r
 }
+
+// IsActionNotAllowed returns true if response rejects request
+// because its caller is not allowed to run requested action.
+func IsActionNotAllowed(resp Response) bool {
+	exceptionResp, ok := resp.(exceptionResponse)
+	return ok && exceptionResp.Exception.Code == ErrorCodeActionNotAllowed
+}
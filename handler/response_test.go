@@ -4,9 +4,11 @@ import (
 	"errors"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 
 	. "github.com/cloudfoundry/bosh-agent/handler"
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type testShortError struct {
@@ -91,4 +93,42 @@ var _ = Describe("NewExceptionResponse", func() {
 			)
 		})
 	})
+
+	Context("with coded error", func() {
+		var err error
+
+		BeforeEach(func() {
+			err = bosherr.WrapError(NewErrorf(ErrorCodeDiskNotFound, "fake-msg"), "fake-wrap")
+		})
+
+		It("includes code, category and retryability in JSON", func() {
+			resp := NewExceptionResponse(err)
+			boshassert.MatchesJSONString(GinkgoT(), resp,
+				`{"exception":{"message":"fake-wrap: fake-msg","code":"disk_not_found","category":"not_found"}}`)
+		})
+
+		It("includes retryable flag for retryable errors", func() {
+			resp := NewExceptionResponse(NewErrorf(ErrorCodeJobSupervisorUnavailable, "fake-msg"))
+			boshassert.MatchesJSONString(GinkgoT(), resp,
+				`{"exception":{"message":"fake-msg","code":"job_supervisor_unavailable","category":"unavailable","retryable":true}}`)
+		})
+
+		It("keeps code when shortened", func() {
+			resp := NewExceptionResponse(err)
+			boshassert.MatchesJSONString(GinkgoT(), resp.Shorten(),
+				`{"exception":{"message":"fake-wrap: fake-msg","code":"disk_not_found","category":"not_found"}}`)
+		})
+	})
+
+	Describe("IsActionNotAllowed", func() {
+		It("returns true for exceptions with action not allowed code", func() {
+			resp := NewExceptionResponse(bosherr.WrapError(NewErrorf(ErrorCodeActionNotAllowed, "fake-msg"), "fake-wrap"))
+			Expect(IsActionNotAllowed(resp)).To(BeTrue())
+		})
+
+		It("returns false for other responses", func() {
+			Expect(IsActionNotAllowed(NewExceptionResponse(NewErrorf(ErrorCodeDiskNotFound, "fake-msg")))).To(BeFalse())
+			Expect(IsActionNotAllowed(NewValueResponse("fake-value"))).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,28 @@
+package httpsdispatcher
+
+import (
+	"time"
+)
+
+// Exports rate limiter for tests in httpsdispatcher_test package
+
+const MaxTrackedClients = maxTrackedClients
+
+type RateLimiter struct {
+	limiter *rateLimiter
+}
+
+func NewRateLimiter(rate float64, burst int) RateLimiter {
+	return RateLimiter{limiter: newRateLimiter(rate, burst)}
+}
+
+func (l RateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	return l.limiter.Allow(client, now)
+}
+
+func (l RateLimiter) TrackedClients() int {
+	l.limiter.lock.Lock()
+	defer l.limiter.lock.Unlock()
+
+	return len(l.limiter.buckets)
+}
@@ -5,16 +5,35 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 const httpsDispatcherLogTag = "HTTPS Dispatcher"
 
+// cipherSuites only apply to TLS 1.2 and below.
+// Both 3DES & RC4 ciphers can be exploited
+// Using Mozilla's "Modern" recommended settings (where they overlap with golang support)
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+}
+
 type HTTPSDispatcher struct {
 	httpServer                  *http.Server
 	mux                         *http.ServeMux
@@ -22,31 +41,33 @@ type HTTPSDispatcher struct {
 	logger                      boshlog.Logger
 	baseURL                     *url.URL
 	expectedAuthorizationHeader string
+
+	tlsSettingsFunc func() boshsettings.HTTPSTLS
+	tlsFiles        *tlsFilesLoader
+
+	limits       Limits
+	rateLimiter  *rateLimiter
+	requestSlots chan struct{}
+	streamSlots  chan struct{}
+	rejectedFunc RequestRejectedFunc
 }
 
 type HTTPHandlerFunc func(writer http.ResponseWriter, request *http.Request)
 
-func NewHTTPSDispatcher(baseURL *url.URL, logger boshlog.Logger) *HTTPSDispatcher {
-	tlsConfig := &tls.Config{
-		// SSLv3 is insecure due to BEAST and POODLE attacks
-		MinVersion: tls.VersionTLS10,
-		// Both 3DES & RC4 ciphers can be exploited
-		// Using Mozilla's "Modern" recommended settings (where they overlap with golang support)
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-		PreferServerCipherSuites: true,
-	}
-	httpServer := &http.Server{
-		TLSConfig: tlsConfig,
-	}
+// RequestRejectedFunc is called when request exceeds limits before reaching route handler
+type RequestRejectedFunc func(request *http.Request, statusCode int, reason string)
+
+// NewHTTPSDispatcher retrieves TLS settings on every TLS handshake
+// so that changed settings and certificate files apply to new connections.
+func NewHTTPSDispatcher(
+	baseURL *url.URL,
+	logger boshlog.Logger,
+	fs boshsys.FileSystem,
+	tlsSettingsFunc func() boshsettings.HTTPSTLS,
+	limits Limits,
+	rejectedFunc RequestRejectedFunc,
+) *HTTPSDispatcher {
+	httpServer := &http.Server{}
 	mux := http.NewServeMux()
 	httpServer.Handler = mux
 
@@ -56,35 +77,95 @@ func NewHTTPSDispatcher(baseURL *url.URL, logger boshlog.Logger) *HTTPSDispatche
 	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
 	expectedAuthorizationHeader := fmt.Sprintf("Basic %s", encodedAuth)
 
-	return &HTTPSDispatcher{
+	dispatcher := &HTTPSDispatcher{
 		httpServer:                  httpServer,
 		mux:                         mux,
 		logger:                      logger,
 		baseURL:                     baseURL,
 		expectedAuthorizationHeader: expectedAuthorizationHeader,
+
+		tlsSettingsFunc: tlsSettingsFunc,
+		tlsFiles:        newTLSFilesLoader(fs, logger),
+
+		limits:       limits,
+		rejectedFunc: rejectedFunc,
 	}
+
+	if limits.RequestsPerSecond > 0 {
+		dispatcher.rateLimiter = newRateLimiter(limits.RequestsPerSecond, limits.Burst)
+	}
+
+	if limits.MaxConcurrentRequests > 0 {
+		dispatcher.requestSlots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+
+	if limits.MaxConcurrentStreams > 0 {
+		dispatcher.streamSlots = make(chan struct{}, limits.MaxConcurrentStreams)
+	}
+
+	return dispatcher
 }
 
 func (h *HTTPSDispatcher) Start() error {
+	// Fail early instead of on first connection when TLS is misconfigured
+	_, err := h.tlsConfig()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading agent SSL cert")
+	}
+
 	tcpListener, err := net.Listen("tcp", h.baseURL.Host)
 	if err != nil {
 		return bosherr.WrapError(err, "Starting HTTP listener")
 	}
 	h.listener = tcpListener
 
-	cert, err := tls.LoadX509KeyPair("agent.cert", "agent.key")
+	h.httpServer.TLSConfig = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := h.tlsConfig()
+			if err != nil {
+				h.logger.Error(httpsDispatcherLogTag, "Building TLS config: %s", err.Error())
+			}
+			return config, err
+		},
+	}
+
+	tlsListener := tls.NewListener(tcpListener, h.httpServer.TLSConfig)
+
+	return h.httpServer.Serve(tlsListener)
+}
+
+func (h *HTTPSDispatcher) tlsConfig() (*tls.Config, error) {
+	tlsSettings := h.tlsSettingsFunc()
+
+	minVersion, err := tlsSettings.TLSMinVersion()
 	if err != nil {
-		return bosherr.WrapError(err, "Loading agent SSL cert")
+		return nil, err
 	}
 
-	// update the server config with the cert
-	config := h.httpServer.TLSConfig
-	config.NextProtos = []string{"http/1.1"}
-	config.Certificates = []tls.Certificate{cert}
+	cert, err := h.tlsFiles.Certificate(tlsSettings.CertificatePathOrDefault(), tlsSettings.PrivateKeyPathOrDefault())
+	if err != nil {
+		return nil, err
+	}
 
-	tlsListener := tls.NewListener(tcpListener, config)
+	config := &tls.Config{
+		MinVersion:               minVersion,
+		CipherSuites:             cipherSuites,
+		PreferServerCipherSuites: true,
+		NextProtos:               []string{"http/1.1"},
+		Certificates:             []tls.Certificate{*cert},
+	}
 
-	return h.httpServer.Serve(tlsListener)
+	if tlsSettings.IsClientCertificateRequired() {
+		clientCAs, err := h.tlsFiles.ClientCAs(tlsSettings.ClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
 }
 
 func (h *HTTPSDispatcher) Stop() {
@@ -102,18 +183,103 @@ func (h *HTTPSDispatcher) constantTimeEquals(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) != 1
 }
 
+// authenticate requires clients to present verified certificate when client CA is configured
+// instead of checking basic auth credentials from the base URL.
+// Returned principal identifies client for rate limiting.
+func (h *HTTPSDispatcher) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.tlsSettingsFunc().IsClientCertificateRequired() {
+		// Connection may have been established before client CA was configured
+		commonName, verified := ClientCommonName(r)
+		if !verified {
+			w.WriteHeader(401)
+			return "", false
+		}
+
+		return "mtls:" + commonName, true
+	}
+
+	if h.requestNotAuthorized(r) {
+		w.Header().Add("WWW-Authenticate", `Basic realm=""`)
+		w.WriteHeader(401)
+		return "", false
+	}
+
+	return "user:" + h.baseURL.User.Username(), true
+}
+
+// AddRoute authenticates clients before checking their rate and concurrency limits
+// so that limits of one client cannot be used up by others.
 func (h *HTTPSDispatcher) AddRoute(route string, handler HTTPHandlerFunc) {
+	h.addRoute(route, handler, h.requestSlots)
+}
+
+// AddStreamingRoute is like AddRoute for routes whose requests stay open
+// (e.g. server-sent events) so that they do not take up request slots.
+func (h *HTTPSDispatcher) AddStreamingRoute(route string, handler HTTPHandlerFunc) {
+	h.addRoute(route, handler, h.streamSlots)
+}
+
+func (h *HTTPSDispatcher) addRoute(route string, handler HTTPHandlerFunc, slots chan struct{}) {
+	maxBodySize := h.limits.MaxBodySizes[route]
+
 	authWrapper := func(w http.ResponseWriter, r *http.Request) {
 		h.logger.Info(httpsDispatcherLogTag, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
 
-		if h.requestNotAuthorized(r) {
-			w.Header().Add("WWW-Authenticate", `Basic realm=""`)
-			w.WriteHeader(401)
+		principal, authenticated := h.authenticate(w, r)
+		if !authenticated {
 			return
 		}
 
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				h.reject(w, r, 429, "Too many concurrent requests")
+				return
+			}
+		}
+
+		if h.rateLimiter != nil {
+			allowed, wait := h.rateLimiter.Allow(principal, time.Now())
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				h.reject(w, r, 429, "Too many requests")
+				return
+			}
+		}
+
+		if maxBodySize > 0 {
+			if r.ContentLength > maxBodySize {
+				h.reject(w, r, 413, ErrRequestBodyTooLarge.Error())
+				return
+			}
+
+			// Chunked bodies are only checked while being read by the handler
+			r.Body = &limitedBody{ReadCloser: r.Body, remaining: maxBodySize}
+		}
+
 		handler(w, r)
 	}
 
 	h.mux.HandleFunc(route, authWrapper)
 }
+
+func (h *HTTPSDispatcher) reject(w http.ResponseWriter, r *http.Request, statusCode int, reason string) {
+	h.logger.Warn(httpsDispatcherLogTag, "Rejecting %s %s from %s: %s", r.Method, r.URL.Path, clientAddress(r), reason)
+
+	w.WriteHeader(statusCode)
+
+	if h.rejectedFunc != nil {
+		h.rejectedFunc(r, statusCode, reason)
+	}
+}
+
+// ClientCommonName returns subject common name of verified client certificate.
+func ClientCommonName(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
@@ -0,0 +1,198 @@
+package httpsdispatcher
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// maxTrackedClients bounds memory used for rate limiting;
+// once it is reached clients with full buckets are forgotten,
+// or the least recently seen client when no bucket is full.
+const maxTrackedClients = 1024
+
+var ErrRequestBodyTooLarge = errors.New("Request body too large")
+
+// Limits protects agent from clients sending too many or too large requests.
+// Zero values disable corresponding limit.
+type Limits struct {
+	// MaxBodySizes maps route (e.g. /agent) to maximum request body size in bytes
+	MaxBodySizes map[string]int64 `json:"max_body_sizes"`
+
+	// RequestsPerSecond and Burst configure token bucket of each authenticated client
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+
+	// MaxConcurrentStreams limits streaming routes separately since
+	// their requests stay open as long as clients are subscribed
+	MaxConcurrentStreams int `json:"max_concurrent_streams"`
+}
+
+// Merge fills routes missing max body size with defaults
+func (l Limits) Merge(defaults Limits) Limits {
+	maxBodySizes := map[string]int64{}
+
+	for route, size := range defaults.MaxBodySizes {
+		maxBodySizes[route] = size
+	}
+
+	for route, size := range l.MaxBodySizes {
+		maxBodySizes[route] = size
+	}
+
+	l.MaxBodySizes = maxBodySizes
+
+	return l
+}
+
+// IsRequestBodyTooLarge checks whether reading request body failed
+// because it exceeded maximum body size of its route.
+func IsRequestBodyTooLarge(err error) bool {
+	for err != nil {
+		if err == ErrRequestBodyTooLarge {
+			return true
+		}
+
+		complexErr, ok := err.(bosherr.ComplexError)
+		if !ok {
+			return false
+		}
+
+		err = complexErr.Cause
+	}
+
+	return false
+}
+
+// limitedBody fails reads once more than remaining bytes are read
+// unlike io.LimitReader which silently truncates.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, ErrRequestBodyTooLarge
+	}
+
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	seenAt    time.Time
+}
+
+// rateLimiter keeps a token bucket per client
+type rateLimiter struct {
+	lock sync.Mutex
+
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// Allow takes a token from client bucket or returns how long client should wait
+func (l *rateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket, found := l.buckets[client]
+	if !found {
+		if len(l.buckets) >= maxTrackedClients {
+			l.forgetClients(now)
+		}
+
+		bucket = &tokenBucket{tokens: l.burst, updatedAt: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.seenAt = now
+
+	l.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - bucket.tokens) / l.rate * float64(time.Second)))
+		return false, wait
+	}
+
+	bucket.tokens--
+
+	return true, 0
+}
+
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+		bucket.updatedAt = now
+	}
+}
+
+func (l *rateLimiter) forgetClients(now time.Time) {
+	var leastRecentClient string
+	var leastRecentBucket *tokenBucket
+
+	for client, bucket := range l.buckets {
+		l.refill(bucket, now)
+
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, client)
+			continue
+		}
+
+		if leastRecentBucket == nil || bucket.seenAt.Before(leastRecentBucket.seenAt) {
+			leastRecentClient = client
+			leastRecentBucket = bucket
+		}
+	}
+
+	if len(l.buckets) >= maxTrackedClients {
+		delete(l.buckets, leastRecentClient)
+	}
+}
+
+// clientAddress is used in logs of rejected requests
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,370 @@
+package httpsdispatcher_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
+	fakelogger "github.com/cloudfoundry/bosh-agent/logger/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type rejectedRequest struct {
+	Path       string
+	StatusCode int
+	Reason     string
+}
+
+var _ = Describe("HTTPSDispatcher limits", func() {
+	var (
+		dispatcher *boshdispatcher.HTTPSDispatcher
+		limits     boshdispatcher.Limits
+
+		rejected     []rejectedRequest
+		rejectedLock sync.Mutex
+	)
+
+	rejectedRequests := func() []rejectedRequest {
+		rejectedLock.Lock()
+		defer rejectedLock.Unlock()
+		return append([]rejectedRequest{}, rejected...)
+	}
+
+	BeforeEach(func() {
+		limits = boshdispatcher.Limits{}
+		rejected = nil
+	})
+
+	JustBeforeEach(func() {
+		serverURL, err := url.Parse(targetURL)
+		Expect(err).ToNot(HaveOccurred())
+
+		dispatcher = boshdispatcher.NewHTTPSDispatcher(
+			serverURL,
+			&fakelogger.FakeLogger{},
+			boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone)),
+			func() boshsettings.HTTPSTLS { return boshsettings.HTTPSTLS{} },
+			limits,
+			func(r *http.Request, statusCode int, reason string) {
+				rejectedLock.Lock()
+				defer rejectedLock.Unlock()
+				rejected = append(rejected, rejectedRequest{Path: r.URL.Path, StatusCode: statusCode, Reason: reason})
+			},
+		)
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- dispatcher.Start()
+		}()
+
+		select {
+		case err := <-errChan:
+			Expect(err).ToNot(HaveOccurred())
+		case <-time.After(1 * time.Second):
+			// server should now be running, continue
+		}
+	})
+
+	AfterEach(func() {
+		dispatcher.Stop()
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	Context("when route has maximum body size", func() {
+		var readErrs chan error
+
+		BeforeEach(func() {
+			limits.MaxBodySizes = map[string]int64{"/example": 5}
+		})
+
+		JustBeforeEach(func() {
+			errs := make(chan error, 1)
+			readErrs = errs
+
+			dispatcher.AddRoute("/example", func(w http.ResponseWriter, r *http.Request) {
+				_, err := ioutil.ReadAll(r.Body)
+				errs <- err
+				w.WriteHeader(201)
+			})
+
+			dispatcher.AddRoute("/other", func(w http.ResponseWriter, r *http.Request) {
+				_, err := ioutil.ReadAll(r.Body)
+				errs <- err
+				w.WriteHeader(201)
+			})
+		})
+
+		It("accepts bodies within the limit", func() {
+			client := getHTTPClient()
+			response, err := client.Post(targetURL+"/example", "text/plain", strings.NewReader("12345"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(201))
+
+			Expect(<-readErrs).ToNot(HaveOccurred())
+		})
+
+		It("rejects bodies with larger content length with a 413 without calling handler", func() {
+			client := getHTTPClient()
+			response, err := client.Post(targetURL+"/example", "text/plain", strings.NewReader("123456"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(413))
+
+			Expect(readErrs).ToNot(Receive())
+			Expect(rejectedRequests()).To(Equal([]rejectedRequest{
+				{Path: "/example", StatusCode: 413, Reason: "Request body too large"},
+			}))
+		})
+
+		It("fails reading chunked bodies once they exceed the limit", func() {
+			body := io.MultiReader(strings.NewReader("123"), strings.NewReader("456"))
+
+			client := getHTTPClient()
+			_, err := client.Post(targetURL+"/example", "text/plain", body)
+			Expect(err).ToNot(HaveOccurred())
+
+			readErr := <-readErrs
+			Expect(readErr).To(HaveOccurred())
+			Expect(boshdispatcher.IsRequestBodyTooLarge(readErr)).To(BeTrue())
+		})
+
+		It("does not limit other routes", func() {
+			client := getHTTPClient()
+			response, err := client.Post(targetURL+"/other", "text/plain", strings.NewReader("123456"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(201))
+
+			Expect(<-readErrs).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when requests per second are limited", func() {
+		BeforeEach(func() {
+			limits.RequestsPerSecond = 0.1
+			limits.Burst = 2
+		})
+
+		JustBeforeEach(func() {
+			dispatcher.AddRoute("/example", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+		})
+
+		It("rejects requests exceeding burst with a 429", func() {
+			client := getHTTPClient()
+
+			for i := 0; i < 2; i++ {
+				response, err := client.Get(targetURL + "/example")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(200))
+			}
+
+			response, err := client.Get(targetURL + "/example")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(429))
+			Expect(response.Header.Get("Retry-After")).To(Equal("10"))
+
+			Expect(rejectedRequests()).To(Equal([]rejectedRequest{
+				{Path: "/example", StatusCode: 429, Reason: "Too many requests"},
+			}))
+		})
+
+		It("checks credentials before limiting so that unauthenticated requests do not use up limits of clients", func() {
+			client := getHTTPClient()
+			wrongURL := strings.Replace(targetURL, "pass", "wrong", -1)
+
+			for i := 0; i < 3; i++ {
+				response, err := client.Get(wrongURL + "/example")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(401))
+			}
+
+			response, err := client.Get(targetURL + "/example")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(200))
+			Expect(rejectedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when concurrent requests are limited", func() {
+		var (
+			started chan struct{}
+			release chan struct{}
+		)
+
+		BeforeEach(func() {
+			limits.MaxConcurrentRequests = 1
+		})
+
+		JustBeforeEach(func() {
+			startedRequests, releaseRequests := make(chan struct{}, 1), make(chan struct{})
+			started, release = startedRequests, releaseRequests
+
+			dispatcher.AddRoute("/example", func(w http.ResponseWriter, r *http.Request) {
+				startedRequests <- struct{}{}
+				<-releaseRequests
+				w.WriteHeader(200)
+			})
+		})
+
+		It("rejects requests exceeding the limit with a 429 until running request finishes", func() {
+			firstStatus := make(chan int, 1)
+
+			go func() {
+				defer GinkgoRecover()
+
+				client := getHTTPClient()
+				response, err := client.Get(targetURL + "/example")
+				Expect(err).ToNot(HaveOccurred())
+				firstStatus <- response.StatusCode
+			}()
+
+			Eventually(started).Should(Receive())
+
+			client := getHTTPClient()
+			response, err := client.Get(targetURL + "/example")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(429))
+
+			close(release)
+			Eventually(firstStatus).Should(Receive(Equal(200)))
+
+			response, err = client.Get(targetURL + "/example")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(200))
+
+			Expect(rejectedRequests()).To(Equal([]rejectedRequest{
+				{Path: "/example", StatusCode: 429, Reason: "Too many concurrent requests"},
+			}))
+		})
+
+		Context("when route is streaming", func() {
+			BeforeEach(func() {
+				limits.MaxConcurrentStreams = 1
+			})
+
+			JustBeforeEach(func() {
+				dispatcher.AddStreamingRoute("/stream", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(200)
+					w.(http.Flusher).Flush()
+					<-r.Context().Done()
+				})
+			})
+
+			It("limits open streams separately from other requests", func() {
+				client := getHTTPClient()
+
+				stream, err := client.Get(targetURL + "/stream")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stream.StatusCode).To(Equal(200))
+				defer stream.Body.Close()
+
+				response, err := client.Get(targetURL + "/stream")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(429))
+
+				go func() {
+					defer GinkgoRecover()
+
+					client := getHTTPClient()
+					response, err := client.Get(targetURL + "/example")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(response.StatusCode).To(Equal(200))
+				}()
+
+				Eventually(started).Should(Receive())
+				close(release)
+			})
+		})
+	})
+})
+
+var _ = Describe("Limits", func() {
+	Describe("Merge", func() {
+		It("uses default max body sizes for routes without configured size", func() {
+			limits := boshdispatcher.Limits{
+				MaxBodySizes:      map[string]int64{"/agent": 10},
+				RequestsPerSecond: 5,
+			}
+
+			merged := limits.Merge(boshdispatcher.Limits{
+				MaxBodySizes: map[string]int64{"/agent": 20, "/blobs/": 30},
+			})
+
+			Expect(merged).To(Equal(boshdispatcher.Limits{
+				MaxBodySizes:      map[string]int64{"/agent": 10, "/blobs/": 30},
+				RequestsPerSecond: 5,
+			}))
+		})
+	})
+})
+
+var _ = Describe("RateLimiter", func() {
+	var (
+		limiter boshdispatcher.RateLimiter
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	fillTrackedClients := func() {
+		for i := 0; i < boshdispatcher.MaxTrackedClients; i++ {
+			allowed, _ := limiter.Allow(fmt.Sprintf("client-%d", i), now.Add(time.Duration(i)*time.Millisecond))
+			Expect(allowed).To(BeTrue())
+		}
+
+		Expect(limiter.TrackedClients()).To(Equal(boshdispatcher.MaxTrackedClients))
+	}
+
+	It("forgets clients with full buckets once too many clients are tracked", func() {
+		limiter = boshdispatcher.NewRateLimiter(1, 1)
+		fillTrackedClients()
+
+		allowed, _ := limiter.Allow("new-client", now.Add(time.Hour))
+		Expect(allowed).To(BeTrue())
+
+		Expect(limiter.TrackedClients()).To(Equal(1))
+	})
+
+	It("forgets least recently seen client when no bucket is full", func() {
+		limiter = boshdispatcher.NewRateLimiter(0.0001, 1)
+		fillTrackedClients()
+
+		later := now.Add(time.Second)
+
+		allowed, _ := limiter.Allow("new-client", later)
+		Expect(allowed).To(BeTrue())
+
+		Expect(limiter.TrackedClients()).To(Equal(boshdispatcher.MaxTrackedClients))
+
+		allowed, _ = limiter.Allow(fmt.Sprintf("client-%d", boshdispatcher.MaxTrackedClients-1), later)
+		Expect(allowed).To(BeFalse())
+
+		allowed, _ = limiter.Allow("client-0", later)
+		Expect(allowed).To(BeTrue())
+	})
+})
+
+var _ = Describe("IsRequestBodyTooLarge", func() {
+	It("finds error in wrapped causes", func() {
+		err := bosherr.WrapError(bosherr.WrapError(boshdispatcher.ErrRequestBodyTooLarge, "Reading"), "Writing blob")
+		Expect(boshdispatcher.IsRequestBodyTooLarge(err)).To(BeTrue())
+	})
+
+	It("returns false for other errors", func() {
+		Expect(boshdispatcher.IsRequestBodyTooLarge(bosherr.WrapError(errors.New("fake-err"), "Reading"))).To(BeFalse())
+		Expect(boshdispatcher.IsRequestBodyTooLarge(nil)).To(BeFalse())
+	})
+})
@@ -0,0 +1,112 @@
+package httpsdispatcher
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// fileContents remembers which file parsed contents came from
+type fileContents struct {
+	path     string
+	contents []byte
+}
+
+func (c fileContents) matches(path string, contents []byte) bool {
+	return c.path == path && bytes.Equal(c.contents, contents)
+}
+
+// tlsFilesLoader keeps parsed certificate and client CA in memory
+// and only parses their files again after they change.
+type tlsFilesLoader struct {
+	fs   boshsys.FileSystem
+	lock sync.Mutex
+
+	certFile fileContents
+	keyFile  fileContents
+	cert     *tls.Certificate
+
+	caFile fileContents
+	caPool *x509.CertPool
+
+	logger boshlog.Logger
+}
+
+func newTLSFilesLoader(fs boshsys.FileSystem, logger boshlog.Logger) *tlsFilesLoader {
+	return &tlsFilesLoader{fs: fs, logger: logger}
+}
+
+func (l *tlsFilesLoader) Certificate(certPath, keyPath string) (*tls.Certificate, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	certPEM, err := l.fs.ReadFile(certPath)
+	if err != nil {
+		return l.cachedCertificate(certPath, keyPath, bosherr.WrapErrorf(err, "Reading certificate %s", certPath))
+	}
+
+	keyPEM, err := l.fs.ReadFile(keyPath)
+	if err != nil {
+		return l.cachedCertificate(certPath, keyPath, bosherr.WrapErrorf(err, "Reading private key %s", keyPath))
+	}
+
+	if l.cert != nil && l.certFile.matches(certPath, certPEM) && l.keyFile.matches(keyPath, keyPEM) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return l.cachedCertificate(certPath, keyPath, bosherr.WrapErrorf(err, "Parsing certificate %s", certPath))
+	}
+
+	l.logger.Debug(httpsDispatcherLogTag, "Loaded certificate %s", certPath)
+
+	l.cert = &cert
+	l.certFile = fileContents{path: certPath, contents: certPEM}
+	l.keyFile = fileContents{path: keyPath, contents: keyPEM}
+
+	return l.cert, nil
+}
+
+// cachedCertificate keeps serving previously loaded certificate
+// while its files are being replaced (e.g. key written before certificate)
+func (l *tlsFilesLoader) cachedCertificate(certPath, keyPath string, loadErr error) (*tls.Certificate, error) {
+	if l.cert == nil || l.certFile.path != certPath || l.keyFile.path != keyPath {
+		return nil, loadErr
+	}
+
+	l.logger.Warn(httpsDispatcherLogTag, "Using previously loaded certificate: %s", loadErr.Error())
+
+	return l.cert, nil
+}
+
+func (l *tlsFilesLoader) ClientCAs(caPath string) (*x509.CertPool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	caPEM, err := l.fs.ReadFile(caPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading client CA %s", caPath)
+	}
+
+	if l.caPool != nil && l.caFile.matches(caPath, caPEM) {
+		return l.caPool, nil
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return nil, bosherr.Errorf("Parsing client CA %s", caPath)
+	}
+
+	l.logger.Debug(httpsDispatcherLogTag, "Loaded client CA %s", caPath)
+
+	l.caPool = caPool
+	l.caFile = fileContents{path: caPath, contents: caPEM}
+
+	return l.caPool, nil
+}
@@ -247,8 +247,8 @@ func describeHTTPRegistry() {
 								},
 							},
 							Disks: boshsettings.Disks{
-								Ephemeral:  "/dev/sdb",
-								Persistent: map[string]interface{}{"vol-xxxxxx": "/dev/sdf"},
+								Ephemeral:  boshsettings.DiskSettings{Path: "/dev/sdb", VolumeID: "/dev/sdb"},
+								Persistent: map[string]boshsettings.DiskSettings{"vol-xxxxxx": {Path: "/dev/sdf", VolumeID: "/dev/sdf"}},
 								System:     "/dev/sda1",
 							},
 							Env: boshsettings.Env{
@@ -0,0 +1,295 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// cloudInitNetworkConfig is a cloud-init network-config of version 1 or 2.
+// Both versions may also be nested under 'network' key.
+type cloudInitNetworkConfig struct {
+	Network *cloudInitNetworkConfig `yaml:"network"`
+	Version int                     `yaml:"version"`
+
+	// Version 1
+	Config []cloudInitV1Config `yaml:"config"`
+
+	// Version 2
+	Ethernets map[string]cloudInitV2Interface `yaml:"ethernets"`
+	Bonds     map[string]cloudInitV2Interface `yaml:"bonds"`
+	Bridges   map[string]cloudInitV2Interface `yaml:"bridges"`
+	Vlans     map[string]cloudInitV2Interface `yaml:"vlans"`
+}
+
+type cloudInitV1Config struct {
+	Type       string              `yaml:"type"`
+	Name       string              `yaml:"name"`
+	MacAddress string              `yaml:"mac_address"`
+	Subnets    []cloudInitV1Subnet `yaml:"subnets"`
+
+	// Address lists DNS servers of nameserver config
+	Address yamlStrings `yaml:"address"`
+}
+
+type cloudInitV1Subnet struct {
+	Type           string   `yaml:"type"`
+	Address        string   `yaml:"address"`
+	Netmask        string   `yaml:"netmask"`
+	Gateway        string   `yaml:"gateway"`
+	DNSNameservers []string `yaml:"dns_nameservers"`
+}
+
+type cloudInitV2Interface struct {
+	Match struct {
+		MacAddress string `yaml:"macaddress"`
+	} `yaml:"match"`
+
+	SetName string `yaml:"set-name"`
+
+	DHCP4 bool `yaml:"dhcp4"`
+	DHCP6 bool `yaml:"dhcp6"`
+
+	Addresses []string `yaml:"addresses"`
+	Gateway4  string   `yaml:"gateway4"`
+	Gateway6  string   `yaml:"gateway6"`
+
+	Nameservers struct {
+		Addresses []string `yaml:"addresses"`
+	} `yaml:"nameservers"`
+
+	Routes []struct {
+		To  string `yaml:"to"`
+		Via string `yaml:"via"`
+	} `yaml:"routes"`
+}
+
+// yamlStrings accepts a single string as well as a list of strings
+type yamlStrings []string
+
+func (s *yamlStrings) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*s = yamlStrings{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*s = list
+	return nil
+}
+
+// ParseCloudInitNetworkConfig converts cloud-init network-config of version 1 or 2
+// into networks. Networks are named after interfaces; additional addresses
+// of an interface are suffixed with their index, e.g. eth0_1.
+// First network with a gateway is made default for DNS and gateway.
+func ParseCloudInitNetworkConfig(contents []byte) (boshsettings.Networks, error) {
+	var config cloudInitNetworkConfig
+
+	err := yaml.Unmarshal(contents, &config)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling network config")
+	}
+
+	if config.Network != nil {
+		config = *config.Network
+	}
+
+	switch config.Version {
+	case 1:
+		return parseCloudInitV1NetworkConfig(config)
+	case 2:
+		return parseCloudInitV2NetworkConfig(config)
+	default:
+		return nil, bosherr.Errorf("Unsupported network config version '%d'", config.Version)
+	}
+}
+
+func parseCloudInitV1NetworkConfig(config cloudInitNetworkConfig) (boshsettings.Networks, error) {
+	builder := &networksBuilder{}
+	globalDNS := []string{}
+
+	for _, entry := range config.Config {
+		if entry.Type == "nameserver" {
+			globalDNS = append(globalDNS, entry.Address...)
+			continue
+		}
+
+		for i, subnet := range entry.Subnets {
+			network := boshsettings.Network{Mac: entry.MacAddress, DNS: subnet.DNSNameservers}
+
+			switch subnet.Type {
+			case "static", "static6":
+				ip, netmask, err := splitCIDR(subnet.Address, subnet.Netmask)
+				if err != nil {
+					return nil, bosherr.WrapErrorf(err, "Parsing address of interface '%s'", entry.Name)
+				}
+
+				network.Type = boshsettings.NetworkTypeManual
+				network.IP = ip
+				network.Netmask = netmask
+				network.Gateway = subnet.Gateway
+
+			case "dhcp", "dhcp4", "dhcp6", "ipv6_slaac", "ipv6_dhcpv6-stateful", "ipv6_dhcpv6-stateless":
+				network.Type = boshsettings.NetworkTypeDynamic
+
+			case "manual":
+				// Interface is brought up without an address
+				continue
+
+			default:
+				return nil, bosherr.Errorf("Unsupported subnet type '%s' of interface '%s'", subnet.Type, entry.Name)
+			}
+
+			builder.add(indexedName(entry.Name, i), network)
+		}
+	}
+
+	return builder.build(globalDNS), nil
+}
+
+func parseCloudInitV2NetworkConfig(config cloudInitNetworkConfig) (boshsettings.Networks, error) {
+	builder := &networksBuilder{}
+
+	for _, interfaces := range []map[string]cloudInitV2Interface{config.Ethernets, config.Bonds, config.Bridges, config.Vlans} {
+		ids := []string{}
+		for id := range interfaces {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			iface := interfaces[id]
+
+			name := id
+			if iface.SetName != "" {
+				name = iface.SetName
+			}
+
+			gateway4, gateway6 := iface.Gateway4, iface.Gateway6
+			for _, route := range iface.Routes {
+				switch route.To {
+				case "default", "0.0.0.0/0":
+					if gateway4 == "" {
+						gateway4 = route.Via
+					}
+				case "::/0":
+					if gateway6 == "" {
+						gateway6 = route.Via
+					}
+				}
+			}
+
+			for i, address := range iface.Addresses {
+				ip, netmask, err := splitCIDR(address, "")
+				if err != nil {
+					return nil, bosherr.WrapErrorf(err, "Parsing address of interface '%s'", id)
+				}
+
+				gateway := gateway4
+				if !isIPv4Address(ip) {
+					gateway = gateway6
+				}
+
+				builder.add(indexedName(name, i), boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      ip,
+					Netmask: netmask,
+					Gateway: gateway,
+					DNS:     iface.Nameservers.Addresses,
+					Mac:     iface.Match.MacAddress,
+				})
+			}
+
+			if len(iface.Addresses) == 0 && (iface.DHCP4 || iface.DHCP6) {
+				builder.add(name, boshsettings.Network{
+					Type: boshsettings.NetworkTypeDynamic,
+					DNS:  iface.Nameservers.Addresses,
+					Mac:  iface.Match.MacAddress,
+				})
+			}
+		}
+	}
+
+	return builder.build(nil), nil
+}
+
+// networksBuilder keeps networks in the order they were declared
+// so that default network is picked predictably.
+type networksBuilder struct {
+	names    []string
+	networks []boshsettings.Network
+}
+
+func (b *networksBuilder) add(name string, network boshsettings.Network) {
+	b.names = append(b.names, name)
+	b.networks = append(b.networks, network)
+}
+
+// build gives globalDNS to networks without DNS servers of their own
+func (b *networksBuilder) build(globalDNS []string) boshsettings.Networks {
+	networks := boshsettings.Networks{}
+
+	defaultIndex := -1
+	for i, network := range b.networks {
+		if network.Gateway != "" {
+			defaultIndex = i
+			break
+		}
+	}
+
+	for i, network := range b.networks {
+		if len(network.DNS) == 0 && len(globalDNS) > 0 {
+			network.DNS = globalDNS
+		}
+
+		if i == defaultIndex {
+			network.Default = []string{"dns", "gateway"}
+		}
+
+		networks[b.names[i]] = network
+	}
+
+	return networks
+}
+
+// splitCIDR accepts address with a prefix length, e.g. 10.0.0.5/24,
+// or address with a separate netmask.
+func splitCIDR(address, netmask string) (string, string, error) {
+	if !strings.Contains(address, "/") {
+		if net.ParseIP(address) == nil {
+			return "", "", bosherr.Errorf("Expected IP address, got '%s'", address)
+		}
+		return address, netmask, nil
+	}
+
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Parsing CIDR '%s'", address)
+	}
+
+	return ip.String(), net.IP(ipNet.Mask).String(), nil
+}
+
+func indexedName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("%s_%d", name, i)
+}
+
+func isIPv4Address(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() != nil
+}
@@ -0,0 +1,174 @@
+package infrastructure_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+var _ = Describe("ParseCloudInitNetworkConfig", func() {
+	Context("version 1", func() {
+		It("converts static and dhcp subnets into networks", func() {
+			networks, err := ParseCloudInitNetworkConfig([]byte(`
+network:
+  version: 1
+  config:
+    - type: physical
+      name: eth0
+      mac_address: "52:54:00:12:34:00"
+      subnets:
+        - type: static
+          address: 10.0.0.5/24
+          gateway: 10.0.0.1
+        - type: static6
+          address: fd00::5
+          netmask: "ffff:ffff:ffff:ffff::"
+          dns_nameservers: ["fd00::2"]
+    - type: physical
+      name: eth1
+      mac_address: "52:54:00:12:34:01"
+      subnets:
+        - type: dhcp
+    - type: nameserver
+      address: [10.0.0.2, 10.0.0.3]
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks).To(Equal(boshsettings.Networks{
+				"eth0": boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      "10.0.0.5",
+					Netmask: "255.255.255.0",
+					Gateway: "10.0.0.1",
+					Default: []string{"dns", "gateway"},
+					DNS:     []string{"10.0.0.2", "10.0.0.3"},
+					Mac:     "52:54:00:12:34:00",
+				},
+				"eth0_1": boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      "fd00::5",
+					Netmask: "ffff:ffff:ffff:ffff::",
+					DNS:     []string{"fd00::2"},
+					Mac:     "52:54:00:12:34:00",
+				},
+				"eth1": boshsettings.Network{
+					Type: boshsettings.NetworkTypeDynamic,
+					DNS:  []string{"10.0.0.2", "10.0.0.3"},
+					Mac:  "52:54:00:12:34:01",
+				},
+			}))
+		})
+
+		It("accepts nameserver address given as a single string", func() {
+			networks, err := ParseCloudInitNetworkConfig([]byte(`
+version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets: [{type: dhcp4}]
+  - type: nameserver
+    address: 10.0.0.2
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks["eth0"].DNS).To(Equal([]string{"10.0.0.2"}))
+		})
+
+		It("returns error for unsupported subnet types", func() {
+			_, err := ParseCloudInitNetworkConfig([]byte(`
+version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets: [{type: fake-type}]
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unsupported subnet type 'fake-type' of interface 'eth0'"))
+		})
+
+		It("returns error for invalid addresses", func() {
+			_, err := ParseCloudInitNetworkConfig([]byte(`
+version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets: [{type: static, address: fake-address}]
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected IP address, got 'fake-address'"))
+		})
+	})
+
+	Context("version 2", func() {
+		It("converts addresses and dhcp of interfaces into networks", func() {
+			networks, err := ParseCloudInitNetworkConfig([]byte(`
+version: 2
+ethernets:
+  id0:
+    match:
+      macaddress: "52:54:00:12:34:00"
+    set-name: eth0
+    addresses: [10.0.0.5/24, "fd00::5/64"]
+    gateway6: fd00::1
+    routes:
+      - to: 0.0.0.0/0
+        via: 10.0.0.1
+    nameservers:
+      addresses: [10.0.0.2]
+  id1:
+    match:
+      macaddress: "52:54:00:12:34:01"
+    dhcp4: true
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks).To(Equal(boshsettings.Networks{
+				"eth0": boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      "10.0.0.5",
+					Netmask: "255.255.255.0",
+					Gateway: "10.0.0.1",
+					Default: []string{"dns", "gateway"},
+					DNS:     []string{"10.0.0.2"},
+					Mac:     "52:54:00:12:34:00",
+				},
+				"eth0_1": boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      "fd00::5",
+					Netmask: "ffff:ffff:ffff:ffff::",
+					Gateway: "fd00::1",
+					DNS:     []string{"10.0.0.2"},
+					Mac:     "52:54:00:12:34:00",
+				},
+				"id1": boshsettings.Network{
+					Type: boshsettings.NetworkTypeDynamic,
+					Mac:  "52:54:00:12:34:01",
+				},
+			}))
+		})
+
+		It("prefers gateway4 over default route", func() {
+			networks, err := ParseCloudInitNetworkConfig([]byte(`
+version: 2
+ethernets:
+  eth0:
+    addresses: [10.0.0.5/24]
+    gateway4: 10.0.0.1
+    routes: [{to: default, via: 10.0.0.254}]
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(networks["eth0"].Gateway).To(Equal("10.0.0.1"))
+		})
+	})
+
+	It("returns error for unsupported versions", func() {
+		_, err := ParseCloudInitNetworkConfig([]byte(`version: 3`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Unsupported network config version '3'"))
+	})
+
+	It("returns error when network config cannot be parsed", func() {
+		_, err := ParseCloudInitNetworkConfig([]byte(`version: [`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling network config"))
+	})
+})
@@ -0,0 +1,141 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// NoCloudMetaData is a cloud-init NoCloud meta-data file.
+// Public keys may be given as a single key, a list or a hash of keys.
+type NoCloudMetaData struct {
+	InstanceID    string      `yaml:"instance-id"`
+	LocalHostname string      `yaml:"local-hostname"`
+	PublicKeys    interface{} `yaml:"public-keys"`
+}
+
+// NoCloudSettingsSource reads a cloud-init NoCloud seed from a directory
+// or from an ISO. User data contains agent settings; networks are taken
+// from network config when user data does not include any.
+type NoCloudSettingsSource struct {
+	seed seedReader
+
+	metaDataPath      string
+	userDataPath      string
+	networkConfigPath string
+}
+
+func NewNoCloudSettingsSource(
+	directory string,
+	diskPaths []string,
+	metaDataPath string,
+	userDataPath string,
+	networkConfigPath string,
+	fs boshsys.FileSystem,
+	platform boshplatform.Platform,
+	logger boshlog.Logger,
+) *NoCloudSettingsSource {
+	return &NoCloudSettingsSource{
+		seed: seedReader{
+			directory: directory,
+			diskPaths: diskPaths,
+
+			fs:       fs,
+			platform: platform,
+
+			logTag: "NoCloudSettingsSource",
+			logger: logger,
+		},
+
+		metaDataPath:      metaDataPath,
+		userDataPath:      userDataPath,
+		networkConfigPath: networkConfigPath,
+	}
+}
+
+func (s *NoCloudSettingsSource) PublicSSHKeyForUsername(string) (string, error) {
+	contents, err := s.seed.ReadFile(s.metaDataPath)
+	if err != nil {
+		return "", err
+	}
+
+	var metaData NoCloudMetaData
+
+	err = yaml.Unmarshal(contents, &metaData)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing NoCloud meta data from '%s'", s.metaDataPath)
+	}
+
+	return firstPublicKey(metaData.PublicKeys), nil
+}
+
+func (s *NoCloudSettingsSource) Settings() (boshsettings.Settings, error) {
+	var settings boshsettings.Settings
+
+	contents, err := s.seed.ReadFile(s.userDataPath)
+	if err != nil {
+		return settings, err
+	}
+
+	err = json.Unmarshal(contents, &settings)
+	if err != nil {
+		return boshsettings.Settings{}, bosherr.WrapErrorf(
+			err, "Parsing NoCloud user data from '%s'", s.userDataPath)
+	}
+
+	if len(settings.Networks) > 0 {
+		return settings, nil
+	}
+
+	contents, found, err := s.seed.ReadOptionalFile(s.networkConfigPath)
+	if err != nil {
+		return boshsettings.Settings{}, err
+	} else if !found {
+		return settings, nil
+	}
+
+	settings.Networks, err = ParseCloudInitNetworkConfig(contents)
+	if err != nil {
+		return boshsettings.Settings{}, bosherr.WrapErrorf(
+			err, "Parsing NoCloud network config from '%s'", s.networkConfigPath)
+	}
+
+	return settings, nil
+}
+
+// firstPublicKey picks key listed first or, for a hash, key with the first name
+func firstPublicKey(publicKeys interface{}) string {
+	switch keys := publicKeys.(type) {
+	case string:
+		return keys
+
+	case []interface{}:
+		if len(keys) > 0 {
+			key, _ := keys[0].(string)
+			return key
+		}
+
+	case map[interface{}]interface{}:
+		names := []string{}
+		for name := range keys {
+			if nameStr, ok := name.(string); ok {
+				names = append(names, nameStr)
+			}
+		}
+		sort.Strings(names)
+
+		if len(names) > 0 {
+			key, _ := keys[names[0]].(string)
+			return key
+		}
+	}
+
+	return ""
+}
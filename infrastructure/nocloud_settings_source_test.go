@@ -0,0 +1,160 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("NoCloudSettingsSource", func() {
+	var (
+		platform *fakeplatform.FakePlatform
+		logger   boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		platform = fakeplatform.NewFakePlatform()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	Context("when seed directory exists", func() {
+		var source *NoCloudSettingsSource
+
+		BeforeEach(func() {
+			seedDir, err := filepath.Abs("testdata/nocloud")
+			Expect(err).ToNot(HaveOccurred())
+
+			source = NewNoCloudSettingsSource(
+				seedDir,
+				[]string{"/fake-disk-path"},
+				"meta-data",
+				"user-data",
+				"network-config",
+				boshsys.NewOsFileSystem(logger),
+				platform,
+				logger,
+			)
+		})
+
+		It("returns first public key from meta data", func() {
+			publicKey, err := source.PublicSSHKeyForUsername("fake-username")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa fake-public-key"))
+		})
+
+		It("returns settings from user data with networks from network config", func() {
+			settings, err := source.Settings()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(settings.AgentID).To(Equal("fake-agent-id"))
+			Expect(settings.VM.Name).To(Equal("fake-vm-name"))
+			Expect(settings.EphemeralDiskSettings()).To(Equal(boshsettings.DiskSettings{Path: "/dev/sdb", VolumeID: "/dev/sdb"}))
+			Expect(settings.Networks).To(Equal(boshsettings.Networks{
+				"eth0": boshsettings.Network{
+					Type:    boshsettings.NetworkTypeManual,
+					IP:      "10.0.0.5",
+					Netmask: "255.255.255.0",
+					Gateway: "10.0.0.1",
+					Default: []string{"dns", "gateway"},
+					DNS:     []string{"10.0.0.2"},
+					Mac:     "52:54:00:12:34:00",
+				},
+				"eth1": boshsettings.Network{
+					Type: boshsettings.NetworkTypeDynamic,
+					Mac:  "52:54:00:12:34:01",
+				},
+			}))
+
+			Expect(platform.GetFileContentsFromDiskCalledTimes).To(Equal(0))
+		})
+	})
+
+	Context("when seed directory does not exist", func() {
+		var (
+			fs     *fakesys.FakeFileSystem
+			source *NoCloudSettingsSource
+		)
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+
+			source = NewNoCloudSettingsSource(
+				"/fake-seed-dir",
+				[]string{"/fake-disk-path-1", "/fake-disk-path-2"},
+				"meta-data",
+				"user-data",
+				"network-config",
+				fs,
+				platform,
+				logger,
+			)
+
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-1/user-data", nil, errors.New("fake-read-disk-error"))
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-2/user-data", []byte(`{"agent_id": "fake-agent-id"}`), nil)
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-1/network-config", nil, errors.New("fake-read-disk-error"))
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-2/network-config", nil, errors.New("fake-read-disk-error"))
+		})
+
+		It("reads settings from the first disk they can be read from", func() {
+			settings, err := source.Settings()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings).To(Equal(boshsettings.Settings{AgentID: "fake-agent-id"}))
+		})
+
+		It("does not use network config when user data includes networks", func() {
+			platform.SetGetFilesContentsFromDisk(
+				"/fake-disk-path-2/user-data",
+				[]byte(`{"networks": {"default": {"type": "dynamic"}}}`),
+				nil,
+			)
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-1/network-config", []byte(`version: 3`), nil)
+
+			settings, err := source.Settings()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings.Networks).To(Equal(boshsettings.Networks{
+				"default": boshsettings.Network{Type: boshsettings.NetworkTypeDynamic},
+			}))
+		})
+
+		It("returns error when network config cannot be parsed", func() {
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-1/network-config", []byte(`version: 3`), nil)
+
+			_, err := source.Settings()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing NoCloud network config from 'network-config'"))
+		})
+
+		It("returns error when user data cannot be read from any disk", func() {
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-2/user-data", nil, errors.New("fake-read-disk-error"))
+
+			_, err := source.Settings()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Loading file 'user-data' from disk"))
+		})
+
+		It("returns error when user data is not valid settings", func() {
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-2/user-data", []byte(`#cloud-config`), nil)
+
+			_, err := source.Settings()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing NoCloud user data from 'user-data'"))
+		})
+
+		It("returns public key given as a single key", func() {
+			platform.SetGetFilesContentsFromDisk("/fake-disk-path-1/meta-data", []byte("public-keys: ssh-rsa fake-public-key\n"), nil)
+
+			publicKey, err := source.PublicSSHKeyForUsername("fake-username")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(publicKey).To(Equal("ssh-rsa fake-public-key"))
+		})
+	})
+})
@@ -0,0 +1,234 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// OpenStackMetaData is an OpenStack meta_data.json file
+type OpenStackMetaData struct {
+	UUID       string            `json:"uuid"`
+	Hostname   string            `json:"hostname"`
+	PublicKeys map[string]string `json:"public_keys"`
+
+	Keys []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Data string `json:"data"`
+	} `json:"keys"`
+}
+
+// OpenStackNetworkData is an OpenStack network_data.json file
+type OpenStackNetworkData struct {
+	Links []struct {
+		ID                 string `json:"id"`
+		EthernetMacAddress string `json:"ethernet_mac_address"`
+	} `json:"links"`
+
+	Networks []struct {
+		ID        string `json:"id"`
+		Type      string `json:"type"`
+		Link      string `json:"link"`
+		IPAddress string `json:"ip_address"`
+		Netmask   string `json:"netmask"`
+
+		Routes []struct {
+			Network string `json:"network"`
+			Netmask string `json:"netmask"`
+			Gateway string `json:"gateway"`
+		} `json:"routes"`
+
+		Services []OpenStackNetworkService `json:"services"`
+	} `json:"networks"`
+
+	Services []OpenStackNetworkService `json:"services"`
+}
+
+type OpenStackNetworkService struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// OpenStackSettingsSource reads OpenStack config drive layout from a directory
+// or from a config drive. User data contains agent settings; networks are taken
+// from network data when user data does not include any.
+type OpenStackSettingsSource struct {
+	seed seedReader
+
+	metaDataPath    string
+	userDataPath    string
+	networkDataPath string
+}
+
+func NewOpenStackSettingsSource(
+	directory string,
+	diskPaths []string,
+	metaDataPath string,
+	userDataPath string,
+	networkDataPath string,
+	fs boshsys.FileSystem,
+	platform boshplatform.Platform,
+	logger boshlog.Logger,
+) *OpenStackSettingsSource {
+	return &OpenStackSettingsSource{
+		seed: seedReader{
+			directory: directory,
+			diskPaths: diskPaths,
+
+			fs:       fs,
+			platform: platform,
+
+			logTag: "OpenStackSettingsSource",
+			logger: logger,
+		},
+
+		metaDataPath:    metaDataPath,
+		userDataPath:    userDataPath,
+		networkDataPath: networkDataPath,
+	}
+}
+
+func (s *OpenStackSettingsSource) PublicSSHKeyForUsername(string) (string, error) {
+	contents, err := s.seed.ReadFile(s.metaDataPath)
+	if err != nil {
+		return "", err
+	}
+
+	var metaData OpenStackMetaData
+
+	err = json.Unmarshal(contents, &metaData)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Parsing OpenStack meta data from '%s'", s.metaDataPath)
+	}
+
+	for _, key := range metaData.Keys {
+		if key.Type == "ssh" {
+			return key.Data, nil
+		}
+	}
+
+	names := []string{}
+	for name := range metaData.PublicKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		return metaData.PublicKeys[names[0]], nil
+	}
+
+	return "", nil
+}
+
+func (s *OpenStackSettingsSource) Settings() (boshsettings.Settings, error) {
+	var settings boshsettings.Settings
+
+	contents, err := s.seed.ReadFile(s.userDataPath)
+	if err != nil {
+		return settings, err
+	}
+
+	err = json.Unmarshal(contents, &settings)
+	if err != nil {
+		return boshsettings.Settings{}, bosherr.WrapErrorf(
+			err, "Parsing OpenStack user data from '%s'", s.userDataPath)
+	}
+
+	if len(settings.Networks) > 0 {
+		return settings, nil
+	}
+
+	contents, found, err := s.seed.ReadOptionalFile(s.networkDataPath)
+	if err != nil {
+		return boshsettings.Settings{}, err
+	} else if !found {
+		return settings, nil
+	}
+
+	settings.Networks, err = ParseOpenStackNetworkData(contents)
+	if err != nil {
+		return boshsettings.Settings{}, bosherr.WrapErrorf(
+			err, "Parsing OpenStack network data from '%s'", s.networkDataPath)
+	}
+
+	return settings, nil
+}
+
+// ParseOpenStackNetworkData converts network_data.json into networks named
+// after network ids. DNS services of the whole file are given to networks
+// without DNS services of their own.
+func ParseOpenStackNetworkData(contents []byte) (boshsettings.Networks, error) {
+	var networkData OpenStackNetworkData
+
+	err := json.Unmarshal(contents, &networkData)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling network data")
+	}
+
+	macs := map[string]string{}
+	for _, link := range networkData.Links {
+		macs[link.ID] = link.EthernetMacAddress
+	}
+
+	builder := &networksBuilder{}
+
+	for i, openStackNetwork := range networkData.Networks {
+		name := openStackNetwork.ID
+		if name == "" {
+			name = fmt.Sprintf("network%d", i)
+		}
+
+		network := boshsettings.Network{
+			Mac: macs[openStackNetwork.Link],
+			DNS: dnsAddresses(openStackNetwork.Services),
+		}
+
+		switch openStackNetwork.Type {
+		case "ipv4", "ipv6":
+			ip, netmask, err := splitCIDR(openStackNetwork.IPAddress, openStackNetwork.Netmask)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Parsing address of network '%s'", name)
+			}
+
+			network.Type = boshsettings.NetworkTypeManual
+			network.IP = ip
+			network.Netmask = netmask
+
+			for _, route := range openStackNetwork.Routes {
+				if route.Network == "0.0.0.0" || route.Network == "::" {
+					network.Gateway = route.Gateway
+					break
+				}
+			}
+
+		case "ipv4_dhcp", "ipv6_dhcp", "ipv6_slaac", "ipv6_dhcpv6-stateful", "ipv6_dhcpv6-stateless":
+			network.Type = boshsettings.NetworkTypeDynamic
+
+		default:
+			return nil, bosherr.Errorf("Unsupported type '%s' of network '%s'", openStackNetwork.Type, name)
+		}
+
+		builder.add(name, network)
+	}
+
+	return builder.build(dnsAddresses(networkData.Services)), nil
+}
+
+func dnsAddresses(services []OpenStackNetworkService) []string {
+	var addresses []string
+
+	for _, service := range services {
+		if service.Type == "dns" {
+			addresses = append(addresses, service.Address)
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,108 @@
+package infrastructure_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/infrastructure"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("OpenStackSettingsSource", func() {
+	var (
+		platform *fakeplatform.FakePlatform
+		source   *OpenStackSettingsSource
+	)
+
+	BeforeEach(func() {
+		platform = fakeplatform.NewFakePlatform()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		configDriveDir, err := filepath.Abs("testdata/openstack")
+		Expect(err).ToNot(HaveOccurred())
+
+		source = NewOpenStackSettingsSource(
+			configDriveDir,
+			[]string{"/fake-disk-path"},
+			"openstack/latest/meta_data.json",
+			"openstack/latest/user_data",
+			"openstack/latest/network_data.json",
+			boshsys.NewOsFileSystem(logger),
+			platform,
+			logger,
+		)
+	})
+
+	It("returns ssh key from meta data", func() {
+		publicKey, err := source.PublicSSHKeyForUsername("fake-username")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(publicKey).To(Equal("ssh-rsa fake-public-key"))
+	})
+
+	It("returns settings from user data with networks from network data", func() {
+		settings, err := source.Settings()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(settings.AgentID).To(Equal("fake-agent-id"))
+		Expect(settings.Networks).To(Equal(boshsettings.Networks{
+			"network0": boshsettings.Network{
+				Type:    boshsettings.NetworkTypeManual,
+				IP:      "10.0.0.5",
+				Netmask: "255.255.255.0",
+				Gateway: "10.0.0.1",
+				Default: []string{"dns", "gateway"},
+				DNS:     []string{"10.0.0.2"},
+				Mac:     "fa:16:3e:00:00:01",
+			},
+			"network1": boshsettings.Network{
+				Type: boshsettings.NetworkTypeDynamic,
+				DNS:  []string{"10.0.0.2"},
+				Mac:  "fa:16:3e:00:00:02",
+			},
+		}))
+
+		Expect(platform.GetFileContentsFromDiskCalledTimes).To(Equal(0))
+	})
+})
+
+var _ = Describe("ParseOpenStackNetworkData", func() {
+	It("reads IPv6 addresses with prefix length and DNS services of networks", func() {
+		networks, err := ParseOpenStackNetworkData([]byte(`{
+			"networks": [{
+				"type": "ipv6",
+				"ip_address": "fd00::5/64",
+				"routes": [{"network": "::", "netmask": "::", "gateway": "fd00::1"}],
+				"services": [{"type": "dns", "address": "fd00::2"}]
+			}],
+			"services": [{"type": "dns", "address": "10.0.0.2"}]
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(networks).To(Equal(boshsettings.Networks{
+			"network0": boshsettings.Network{
+				Type:    boshsettings.NetworkTypeManual,
+				IP:      "fd00::5",
+				Netmask: "ffff:ffff:ffff:ffff::",
+				Gateway: "fd00::1",
+				Default: []string{"dns", "gateway"},
+				DNS:     []string{"fd00::2"},
+			},
+		}))
+	})
+
+	It("returns error for unsupported network types", func() {
+		_, err := ParseOpenStackNetworkData([]byte(`{"networks": [{"id": "network0", "type": "fake-type"}]}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Unsupported type 'fake-type' of network 'network0'"))
+	})
+
+	It("returns error when network data cannot be parsed", func() {
+		_, err := ParseOpenStackNetworkData([]byte(`fake-json`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling network data"))
+	})
+})
@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"path/filepath"
+
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// seedReader reads files from a directory when it exists,
+// otherwise from the first disk the file could be loaded from.
+type seedReader struct {
+	directory string
+	diskPaths []string
+
+	fs       boshsys.FileSystem
+	platform boshplatform.Platform
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func (r seedReader) ReadFile(filePath string) ([]byte, error) {
+	if r.useDirectory() {
+		fullPath := filepath.Join(r.directory, filePath)
+
+		contents, err := r.fs.ReadFile(fullPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading file '%s'", fullPath)
+		}
+
+		return contents, nil
+	}
+
+	err := bosherr.Error("No disk paths are configured")
+
+	for _, diskPath := range r.diskPaths {
+		var contents [][]byte
+
+		contents, err = r.platform.GetFilesContentsFromDisk(diskPath, []string{filePath})
+		if err == nil {
+			r.logger.Debug(r.logTag, "Successfully loaded file '%s' from disk '%s'", filePath, diskPath)
+			return contents[0], nil
+		}
+
+		r.logger.Warn(r.logTag, "Failed to load file '%s' from disk '%s': %s", filePath, diskPath, err.Error())
+	}
+
+	return nil, bosherr.WrapErrorf(err, "Loading file '%s' from disk", filePath)
+}
+
+// ReadOptionalFile returns false when file is not configured or cannot be found.
+// Missing files cannot be told apart from other errors when reading
+// from disks, so errors are only returned when reading from a directory.
+func (r seedReader) ReadOptionalFile(filePath string) ([]byte, bool, error) {
+	if filePath == "" {
+		return nil, false, nil
+	}
+
+	if r.useDirectory() && !r.fs.FileExists(filepath.Join(r.directory, filePath)) {
+		return nil, false, nil
+	}
+
+	contents, err := r.ReadFile(filePath)
+	if err != nil {
+		if r.useDirectory() {
+			return nil, false, err
+		}
+
+		r.logger.Info(r.logTag, "Skipping optional file '%s': %s", filePath, err.Error())
+		return nil, false, nil
+	}
+
+	return contents, true, nil
+}
+
+func (r seedReader) useDirectory() bool {
+	return r.directory != "" && r.fs.FileExists(r.directory)
+}
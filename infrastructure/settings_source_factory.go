@@ -72,6 +72,33 @@ type InstanceMetadataSourceOptions struct {
 
 func (o InstanceMetadataSourceOptions) sourceOptionsInterface() {}
 
+// NoCloudSourceOptions configure cloud-init NoCloud seed. Directory,
+// e.g. /var/lib/cloud/seed/nocloud, is used instead of disks when it exists.
+// Network config is optional.
+type NoCloudSourceOptions struct {
+	Directory string
+	DiskPaths []string
+
+	MetaDataPath      string
+	UserDataPath      string
+	NetworkConfigPath string
+}
+
+func (o NoCloudSourceOptions) sourceOptionsInterface() {}
+
+// OpenStackSourceOptions configure OpenStack config drive. Directory
+// is used instead of disks when it exists. Network data is optional.
+type OpenStackSourceOptions struct {
+	Directory string
+	DiskPaths []string
+
+	MetaDataPath    string
+	UserDataPath    string
+	NetworkDataPath string
+}
+
+func (o OpenStackSourceOptions) sourceOptionsInterface() {}
+
 type SettingsSourceFactory struct {
 	options  SettingsOptions
 	platform boshplat.Platform
@@ -144,6 +171,12 @@ func (f SettingsSourceFactory) buildWithRegistry() (boshsettings.Source, error)
 
 		case InstanceMetadataSourceOptions:
 			return nil, bosherr.Error("Instance Metadata source is not supported when registry is used")
+
+		case NoCloudSourceOptions:
+			return nil, bosherr.Error("NoCloud source is not supported when registry is used")
+
+		case OpenStackSourceOptions:
+			return nil, bosherr.Error("OpenStack source is not supported when registry is used")
 		}
 		metadataServices = append(metadataServices, metadataService)
 	}
@@ -196,6 +229,30 @@ func (f SettingsSourceFactory) buildWithoutRegistry() (boshsettings.Source, erro
 				f.platform,
 				f.logger,
 			)
+
+		case NoCloudSourceOptions:
+			settingsSource = NewNoCloudSettingsSource(
+				typedOpts.Directory,
+				typedOpts.DiskPaths,
+				typedOpts.MetaDataPath,
+				typedOpts.UserDataPath,
+				typedOpts.NetworkConfigPath,
+				f.platform.GetFs(),
+				f.platform,
+				f.logger,
+			)
+
+		case OpenStackSourceOptions:
+			settingsSource = NewOpenStackSettingsSource(
+				typedOpts.Directory,
+				typedOpts.DiskPaths,
+				typedOpts.MetaDataPath,
+				typedOpts.UserDataPath,
+				typedOpts.NetworkDataPath,
+				f.platform.GetFs(),
+				f.platform,
+				f.logger,
+			)
 		}
 
 		settingsSources = append(settingsSources, settingsSource)
@@ -238,6 +295,14 @@ func (s *SourceOptionsSlice) UnmarshalJSON(data []byte) error {
 				var o CDROMSourceOptions
 				err, opts = mapstruc.Decode(m, &o), o
 
+			case optType == "NoCloud":
+				var o NoCloudSourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
+			case optType == "OpenStack":
+				var o OpenStackSourceOptions
+				err, opts = mapstruc.Decode(m, &o), o
+
 			default:
 				err = bosherr.Errorf("Unknown source type '%s'", optType)
 			}
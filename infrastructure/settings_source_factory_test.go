@@ -132,6 +132,22 @@ var _ = Describe("SettingsSourceFactory", func() {
 						Expect(err.Error()).To(ContainSubstring("CDROM source is not supported when registry is used"))
 					})
 				})
+
+				Context("when using NoCloud source", func() {
+					BeforeEach(func() {
+						options.Sources = []SourceOptions{
+							NoCloudSourceOptions{
+								DiskPaths: []string{"/fake-disk-path"},
+							},
+						}
+					})
+
+					It("returns error because it is not supported", func() {
+						_, err := factory.New()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("NoCloud source is not supported when registry is used"))
+					})
+				})
 			}
 
 			Context("when UseServerName is set to true", func() {
@@ -249,6 +265,77 @@ var _ = Describe("SettingsSourceFactory", func() {
 					Expect(settingsSource).To(Equal(multiSettingsSource))
 				})
 			})
+
+			Context("when using NoCloud source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							NoCloudSourceOptions{
+								Directory:         "/fake-seed-dir",
+								DiskPaths:         []string{"/fake-disk-path"},
+								MetaDataPath:      "meta-data",
+								UserDataPath:      "user-data",
+								NetworkConfigPath: "network-config",
+							},
+						},
+					}
+				})
+
+				It("returns a settings source that uses the NoCloud seed to fetch settings", func() {
+					noCloudSettingsSource := NewNoCloudSettingsSource(
+						"/fake-seed-dir",
+						[]string{"/fake-disk-path"},
+						"meta-data",
+						"user-data",
+						"network-config",
+						platform.GetFs(),
+						platform,
+						logger,
+					)
+
+					multiSettingsSource, err := NewMultiSettingsSource(noCloudSettingsSource)
+					Expect(err).ToNot(HaveOccurred())
+
+					settingsSource, err := factory.New()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(settingsSource).To(Equal(multiSettingsSource))
+				})
+			})
+
+			Context("when using OpenStack source", func() {
+				BeforeEach(func() {
+					options = SettingsOptions{
+						Sources: []SourceOptions{
+							OpenStackSourceOptions{
+								DiskPaths:       []string{"/fake-disk-path"},
+								MetaDataPath:    "openstack/latest/meta_data.json",
+								UserDataPath:    "openstack/latest/user_data",
+								NetworkDataPath: "openstack/latest/network_data.json",
+							},
+						},
+					}
+				})
+
+				It("returns a settings source that uses the OpenStack config drive to fetch settings", func() {
+					openStackSettingsSource := NewOpenStackSettingsSource(
+						"",
+						[]string{"/fake-disk-path"},
+						"openstack/latest/meta_data.json",
+						"openstack/latest/user_data",
+						"openstack/latest/network_data.json",
+						platform.GetFs(),
+						platform,
+						logger,
+					)
+
+					multiSettingsSource, err := NewMultiSettingsSource(openStackSettingsSource)
+					Expect(err).ToNot(HaveOccurred())
+
+					settingsSource, err := factory.New()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(settingsSource).To(Equal(multiSettingsSource))
+				})
+			})
 		})
 	})
 })
@@ -45,7 +45,7 @@ var _ = Describe("compile_package", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 		}
 
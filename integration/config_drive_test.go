@@ -34,9 +34,9 @@ var _ = Describe("ConfigDrive", func() {
 		})
 
 		It("using config drive to get registry URL", func() {
-			settingsJSON, err := testEnvironment.GetFileContents("/var/vcap/bosh/settings.json")
+			settings, err := testEnvironment.GetCachedSettings()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(settingsJSON).To(ContainSubstring("fake-agent-id"))
+			Expect(settings.AgentID).To(Equal("fake-agent-id"))
 		})
 
 		It("config drive is being unmounted", func() {
@@ -90,7 +90,7 @@ var _ = Describe("DeleteARPEntries", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 		}
 
@@ -60,7 +60,7 @@ var _ = Describe("EphemeralDisk", func() {
 		Context("when ephemeral disk is provided in settings", func() {
 			BeforeEach(func() {
 				registrySettings.Disks = boshsettings.Disks{
-					Ephemeral: "/dev/sdh",
+					Ephemeral: boshsettings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 				}
 			})
 
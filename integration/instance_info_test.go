@@ -46,7 +46,7 @@ var _ = Describe("Instance Info", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 		}
 
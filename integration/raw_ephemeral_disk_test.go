@@ -58,7 +58,7 @@ var _ = Describe("RawEphemeralDisk", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			registrySettings.Disks = boshsettings.Disks{
-				Ephemeral:    "/dev/sdh",
+				Ephemeral:    boshsettings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 				RawEphemeral: []boshsettings.DiskSettings{{ID: "1", Path: "/dev/xvdb"}, {ID: "2", Path: "/dev/xvdc"}},
 			}
 
@@ -47,7 +47,7 @@ var _ = Describe("sync_dns", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 		}
 
@@ -63,7 +63,7 @@ var _ = Describe("SystemMounts", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				registrySettings.Disks = boshsettings.Disks{
-					Ephemeral: "/dev/sdh",
+					Ephemeral: boshsettings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 				}
 			})
 
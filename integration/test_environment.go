@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -469,6 +470,55 @@ func (t *TestEnvironment) GetFileContents(filePath string) (string, error) {
 	)
 }
 
+// GetCachedSettings decrypts settings.json with key generated by the agent
+func (t *TestEnvironment) GetCachedSettings() (boshsettings.Settings, error) {
+	var settings boshsettings.Settings
+
+	encryptedSettings, err := t.getFileContentsAsRoot("/var/vcap/bosh/settings.json")
+	if err != nil {
+		return settings, err
+	}
+
+	secret, err := t.getFileContentsAsRoot("/var/vcap/bosh/settings.key")
+	if err != nil {
+		return settings, err
+	}
+
+	fs := boshsys.NewOsFileSystem(t.logger)
+
+	keyFile, err := fs.TempFile("bosh-agent-settings-key")
+	if err != nil {
+		return settings, err
+	}
+
+	defer fs.RemoveAll(keyFile.Name())
+
+	_, err = keyFile.Write(secret)
+	keyFile.Close()
+	if err != nil {
+		return settings, err
+	}
+
+	settingsJSON, _, err := boshsettings.NewKeyfileCacheCipher(fs, keyFile.Name()).Decrypt(encryptedSettings)
+	if err != nil {
+		return settings, err
+	}
+
+	err = json.Unmarshal(settingsJSON, &settings)
+
+	return settings, err
+}
+
+// getFileContentsAsRoot transfers contents as base64 since files may be binary
+func (t *TestEnvironment) getFileContentsAsRoot(filePath string) ([]byte, error) {
+	encodedContents, err := t.RunCommand(fmt.Sprintf("sudo base64 -w 0 %s", filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(encodedContents))
+}
+
 func (t *TestEnvironment) RunCommand(command string) (string, error) {
 	stdout, _, _, err := t.RunCommand3(command)
 	return stdout, err
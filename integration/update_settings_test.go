@@ -44,7 +44,7 @@ var _ = Describe("CertManager", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 		}
 
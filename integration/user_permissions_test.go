@@ -53,7 +53,7 @@ var _ = Describe("Instance Info", func() {
 			},
 
 			Disks: settings.Disks{
-				Ephemeral: "/dev/sdh",
+				Ephemeral: settings.DiskSettings{Path: "/dev/sdh", VolumeID: "/dev/sdh"},
 			},
 			Networks: networks,
 		}
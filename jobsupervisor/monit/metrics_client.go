@@ -0,0 +1,60 @@
+package monit
+
+import (
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+)
+
+type metricsClient struct {
+	client Client
+
+	calls    boshmetrics.Counter
+	failures boshmetrics.Counter
+}
+
+// NewMetricsClient counts calls made to monit and how many of them failed
+func NewMetricsClient(client Client, metrics *boshmetrics.Registry) Client {
+	return metricsClient{
+		client: client,
+
+		calls:    metrics.Counter("bosh_agent_monit_calls_total", "Number of calls made to monit.", "call"),
+		failures: metrics.Counter("bosh_agent_monit_call_failures_total", "Number of calls to monit that failed.", "call"),
+	}
+}
+
+func (c metricsClient) ServicesInGroup(name string) ([]string, error) {
+	services, err := c.client.ServicesInGroup(name)
+	c.record("services_in_group", err)
+	return services, err
+}
+
+func (c metricsClient) StartService(name string) error {
+	err := c.client.StartService(name)
+	c.record("start_service", err)
+	return err
+}
+
+func (c metricsClient) StopService(name string) error {
+	err := c.client.StopService(name)
+	c.record("stop_service", err)
+	return err
+}
+
+func (c metricsClient) UnmonitorService(name string) error {
+	err := c.client.UnmonitorService(name)
+	c.record("unmonitor_service", err)
+	return err
+}
+
+func (c metricsClient) Status() (Status, error) {
+	status, err := c.client.Status()
+	c.record("status", err)
+	return status, err
+}
+
+func (c metricsClient) record(call string, err error) {
+	c.calls.Inc(call)
+
+	if err != nil {
+		c.failures.Inc(call)
+	}
+}
@@ -0,0 +1,64 @@
+package monit_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit"
+	fakemonit "github.com/cloudfoundry/bosh-agent/jobsupervisor/monit/fakes"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+)
+
+var _ = Describe("metricsClient", func() {
+	var (
+		innerClient *fakemonit.FakeMonitClient
+		metrics     *boshmetrics.Registry
+		client      Client
+	)
+
+	BeforeEach(func() {
+		innerClient = fakemonit.NewFakeMonitClient()
+		metrics = boshmetrics.NewRegistry()
+		client = NewMetricsClient(innerClient, metrics)
+	})
+
+	metricsText := func() string {
+		buf := bytes.NewBufferString("")
+		Expect(metrics.WriteText(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	It("delegates calls to inner client", func() {
+		innerClient.ServicesInGroupServices = []string{"fake-service"}
+
+		services, err := client.ServicesInGroup("fake-group")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(services).To(Equal([]string{"fake-service"}))
+		Expect(innerClient.ServicesInGroupName).To(Equal("fake-group"))
+
+		err = client.StartService("fake-service")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(innerClient.StartServiceNames).To(Equal([]string{"fake-service"}))
+	})
+
+	It("counts calls", func() {
+		client.StartService("fake-service")
+		client.StartService("fake-service")
+		client.Status()
+
+		Expect(metricsText()).To(ContainSubstring(`bosh_agent_monit_calls_total{call="start_service"} 2` + "\n"))
+		Expect(metricsText()).To(ContainSubstring(`bosh_agent_monit_calls_total{call="status"} 1` + "\n"))
+	})
+
+	It("counts failed calls and returns their errors", func() {
+		innerClient.StopServiceErr = errors.New("fake-stop-err")
+
+		err := client.StopService("fake-service")
+		Expect(err).To(Equal(innerClient.StopServiceErr))
+
+		Expect(metricsText()).To(ContainSubstring(`bosh_agent_monit_call_failures_total{call="stop_service"} 1` + "\n"))
+	})
+})
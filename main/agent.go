@@ -70,6 +70,10 @@ func startAgent(logger logger.Logger) error {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	asyncLog := boshlog.NewAsyncWriterLogger(boshlog.LevelDebug, os.Stdout, os.Stderr)
 	logger := newSignalableLogger(asyncLog)
 
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	boshadmin "github.com/cloudfoundry/bosh-agent/admin"
+	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
+)
+
+const ctlUsage = "Usage: bosh-agent ctl [-b base-dir] [-t timeout] <action> [arguments...]"
+
+type ctlResponse struct {
+	Exception *json.RawMessage `json:"exception"`
+}
+
+// runCtl sends action to running agent over admin socket.
+// Arguments are passed as JSON values and fall back to strings,
+// e.g. 'bosh-agent ctl get_state full' or 'bosh-agent ctl get_task <id>'.
+func runCtl(args []string, stdout, stderr io.Writer) int {
+	flagSet := flag.NewFlagSet("bosh-agent-ctl", flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+
+	baseDir := flagSet.String("b", "/var/vcap", "Set Base Directory")
+	timeout := flagSet.Duration("t", time.Minute, "Response timeout")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flagSet.NArg() == 0 {
+		fmt.Fprintln(stderr, ctlUsage)
+		return 2
+	}
+
+	arguments := []interface{}{}
+
+	for _, arg := range flagSet.Args()[1:] {
+		var value interface{}
+
+		if json.Unmarshal([]byte(arg), &value) != nil {
+			value = arg
+		}
+
+		arguments = append(arguments, value)
+	}
+
+	socketPath := boshdirs.NewProvider(*baseDir).AdminSocketPath()
+
+	respBytes, err := boshadmin.NewClient(socketPath, *timeout).Call(flagSet.Arg(0), arguments)
+	if err != nil {
+		fmt.Fprintf(stderr, "Calling agent: %s\n", err.Error())
+		return 1
+	}
+
+	var response ctlResponse
+
+	err = json.Unmarshal(respBytes, &response)
+	if err != nil {
+		fmt.Fprintf(stderr, "Parsing response '%s': %s\n", respBytes, err.Error())
+		return 1
+	}
+
+	var indented bytes.Buffer
+
+	err = json.Indent(&indented, respBytes, "", "  ")
+	if err != nil {
+		indented.Reset()
+		indented.Write(respBytes)
+	}
+
+	if response.Exception != nil {
+		fmt.Fprintln(stderr, indented.String())
+		return 1
+	}
+
+	fmt.Fprintln(stdout, indented.String())
+
+	return 0
+}
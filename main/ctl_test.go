@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshadmin "github.com/cloudfoundry/bosh-agent/admin"
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdirs "github.com/cloudfoundry/bosh-agent/settings/directories"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("runCtl", func() {
+	var (
+		baseDir string
+		server  *boshadmin.Server
+		stdout  *bytes.Buffer
+		stderr  *bytes.Buffer
+
+		receivedRequest boshhandler.Request
+		response        boshhandler.Response
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		baseDir, err = ioutil.TempDir("", "bosh-agent-ctl")
+		Expect(err).ToNot(HaveOccurred())
+
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+
+		receivedRequest = boshhandler.Request{}
+		response = boshhandler.NewValueResponse("fake-value")
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		socketPath := boshdirs.NewProvider(baseDir).AdminSocketPath()
+		server = boshadmin.NewServer(socketPath, []string{"get_task", "ping"}, boshsys.NewOsFileSystem(logger), logger)
+
+		err = server.Start(func(req boshhandler.Request) boshhandler.Response {
+			receivedRequest = req
+			return response
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(server.Stop()).To(Succeed())
+		Expect(os.RemoveAll(baseDir)).To(Succeed())
+	})
+
+	It("sends action with arguments parsed as JSON and falling back to strings", func() {
+		status := runCtl([]string{"-b", baseDir, "get_task", "fake-task-id", "5", `{"key":"value"}`}, stdout, stderr)
+		Expect(status).To(Equal(0))
+
+		Expect(receivedRequest.Method).To(Equal("get_task"))
+
+		var arguments []interface{}
+		Expect(json.Unmarshal(receivedRequest.GetPayload(), &struct {
+			Arguments *[]interface{} `json:"arguments"`
+		}{&arguments})).To(Succeed())
+
+		Expect(arguments).To(Equal([]interface{}{
+			"fake-task-id",
+			float64(5),
+			map[string]interface{}{"key": "value"},
+		}))
+	})
+
+	It("prints indented response to stdout", func() {
+		status := runCtl([]string{"-b", baseDir, "ping"}, stdout, stderr)
+		Expect(status).To(Equal(0))
+
+		Expect(stdout.String()).To(Equal("{\n  \"value\": \"fake-value\"\n}\n"))
+		Expect(stderr.String()).To(BeEmpty())
+	})
+
+	It("prints exception to stderr and fails", func() {
+		response = boshhandler.NewExceptionResponse(errors.New("fake-error"))
+
+		status := runCtl([]string{"-b", baseDir, "ping"}, stdout, stderr)
+		Expect(status).To(Equal(1))
+
+		Expect(stdout.String()).To(BeEmpty())
+		Expect(stderr.String()).To(ContainSubstring(`"message": "fake-error"`))
+	})
+
+	It("prints usage when action is missing", func() {
+		status := runCtl([]string{"-b", baseDir}, stdout, stderr)
+		Expect(status).To(Equal(2))
+
+		Expect(stderr.String()).To(ContainSubstring(ctlUsage))
+		Expect(receivedRequest.Method).To(BeEmpty())
+	})
+
+	It("fails when flags cannot be parsed", func() {
+		status := runCtl([]string{"-t", "not-a-duration", "ping"}, stdout, stderr)
+		Expect(status).To(Equal(2))
+	})
+
+	It("fails when agent cannot be reached", func() {
+		status := runCtl([]string{"-b", baseDir + "/missing", "ping"}, stdout, stderr)
+		Expect(status).To(Equal(1))
+
+		Expect(stderr.String()).To(ContainSubstring("Calling agent"))
+	})
+})
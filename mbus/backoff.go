@@ -0,0 +1,38 @@
+package mbus
+
+import (
+	"math"
+	"time"
+)
+
+// Backoff calculates exponentially increasing delays between retries.
+// Jitter spreads out retries of many agents that lost connection
+// at the same time (e.g. during NATS restart).
+type Backoff struct {
+	Initial time.Duration
+
+	// Max limits delay before jitter is applied
+	Max time.Duration
+
+	Multiplier float64
+
+	// Jitter is a fraction of delay that is randomized,
+	// e.g. 0.2 results in delay within +/-20%
+	Jitter float64
+}
+
+// Delay returns delay before given retry attempt (starting at 0).
+// random is expected to be within [0, 1).
+func (b Backoff) Delay(attempt int, random float64) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	delay += delay * b.Jitter * (2*random - 1)
+	if delay < 0 {
+		delay = 0
+	}
+
+	return time.Duration(delay)
+}
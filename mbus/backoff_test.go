@@ -0,0 +1,48 @@
+package mbus_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+)
+
+var _ = Describe("Backoff", func() {
+	var backoff Backoff
+
+	BeforeEach(func() {
+		backoff = Backoff{
+			Initial:    time.Second,
+			Max:        10 * time.Second,
+			Multiplier: 2,
+			Jitter:     0.5,
+		}
+	})
+
+	Describe("Delay", func() {
+		It("increases delay exponentially", func() {
+			Expect(backoff.Delay(0, 0.5)).To(Equal(1 * time.Second))
+			Expect(backoff.Delay(1, 0.5)).To(Equal(2 * time.Second))
+			Expect(backoff.Delay(2, 0.5)).To(Equal(4 * time.Second))
+		})
+
+		It("does not exceed max delay", func() {
+			Expect(backoff.Delay(4, 0.5)).To(Equal(10 * time.Second))
+			Expect(backoff.Delay(100, 0.5)).To(Equal(10 * time.Second))
+		})
+
+		It("randomizes delay by jitter fraction", func() {
+			Expect(backoff.Delay(1, 0)).To(Equal(1 * time.Second))
+			Expect(backoff.Delay(1, 0.75)).To(Equal(2500 * time.Millisecond))
+			Expect(backoff.Delay(4, 0)).To(Equal(5 * time.Second))
+		})
+
+		It("does not randomize delay without jitter", func() {
+			backoff.Jitter = 0
+			Expect(backoff.Delay(1, 0)).To(Equal(2 * time.Second))
+			Expect(backoff.Delay(1, 0.99)).To(Equal(2 * time.Second))
+		})
+	})
+})
@@ -0,0 +1,434 @@
+package mbus
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// dialOutMaxReceiveSize limits size of data received at once
+// (a WebSocket message or a long poll response)
+const dialOutMaxReceiveSize = 64 * 1024 * 1024
+
+// transportMessage is sent by the agent over transports that
+// do not have a notion of subjects (responses, heartbeats and alerts).
+// Subjects are the same as ones used with NATS.
+type transportMessage struct {
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// dialOutConn is a connection that agent established to the director.
+type dialOutConn interface {
+	// Receive blocks until requests are received or transport specific timeout passes
+	Receive() ([][]byte, error)
+	Send(transportMessage) error
+	Close() error
+}
+
+type dialOutDialFunc func() (dialOutConn, error)
+
+// dialOutHandler implements handler for transports where agent connects to
+// the director instead of accepting connections, so that inbound
+// connections to the agent do not need to be allowed by firewalls.
+type dialOutHandler struct {
+	dial      dialOutDialFunc
+	serverURL *url.URL
+
+	settingsService boshsettings.Service
+	requestVerifier boshhandler.RequestVerifier
+	timeService     clock.Clock
+
+	handlerFuncs     []boshhandler.Func
+	handlerFuncsLock sync.Mutex
+
+	// connLock protects current connection and outbound buffer
+	connLock       sync.Mutex
+	conn           dialOutConn
+	outboundBuffer *messageBuffer
+	stopCh         chan struct{}
+
+	logger      boshlog.Logger
+	auditLogger boshplatform.AuditLogger
+	logTag      string
+}
+
+func newDialOutHandler(
+	dial dialOutDialFunc,
+	serverURL *url.URL,
+	deps TransportDeps,
+	logTag string,
+) *dialOutHandler {
+	return &dialOutHandler{
+		dial:      dial,
+		serverURL: serverURL,
+
+		settingsService: deps.SettingsService,
+		requestVerifier: deps.RequestVerifier,
+		timeService:     deps.TimeService,
+
+		outboundBuffer: newMessageBuffer(outboundBufferSize),
+
+		logger:      deps.Logger,
+		auditLogger: deps.AuditLogger,
+		logTag:      logTag,
+	}
+}
+
+func (h *dialOutHandler) Run(handlerFunc boshhandler.Func) error {
+	err := h.Start(handlerFunc)
+	defer h.Stop()
+
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Starting %s handler", h.logTag)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+
+	return nil
+}
+
+func (h *dialOutHandler) Start(handlerFunc boshhandler.Func) error {
+	h.RegisterAdditionalFunc(handlerFunc)
+
+	conn, err := h.dial()
+	if err != nil {
+		return bosherr.WrapError(err, "Connecting")
+	}
+
+	stopCh := make(chan struct{})
+
+	h.connLock.Lock()
+	h.stopCh = stopCh
+	h.connLock.Unlock()
+
+	h.handleConnected(conn, stopCh)
+
+	go h.serve(conn, stopCh)
+
+	return nil
+}
+
+func (h *dialOutHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
+	h.handlerFuncsLock.Lock()
+	h.handlerFuncs = append(h.handlerFuncs, handlerFunc)
+	h.handlerFuncsLock.Unlock()
+}
+
+func (h *dialOutHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling message (target=%s, topic=%s): %#v", target, topic, message)
+	}
+
+	h.logger.Info(h.logTag, "Sending %s message '%s'", target, topic)
+	h.logger.DebugWithDetails(h.logTag, "Message Payload", string(bytes))
+
+	settings := h.settingsService.GetSettings()
+
+	msg := transportMessage{
+		Subject: fmt.Sprintf("%s.agent.%s.%s", target, topic, settings.AgentID),
+		Payload: bytes,
+	}
+
+	bufferable := topic == boshhandler.Heartbeat || topic == boshhandler.Alert
+
+	h.connLock.Lock()
+	conn := h.conn
+	if conn == nil && bufferable {
+		h.outboundBuffer.Push(msg)
+	}
+	h.connLock.Unlock()
+
+	if conn == nil {
+		if bufferable {
+			return nil
+		}
+		return bosherr.Errorf("Sending message to %s: not connected", msg.Subject)
+	}
+
+	err = conn.Send(msg)
+	if err != nil {
+		if bufferable {
+			h.logger.Warn(h.logTag, "Buffering message to %s after failing to send it: %s", msg.Subject, err.Error())
+
+			h.connLock.Lock()
+			h.outboundBuffer.Push(msg)
+			h.connLock.Unlock()
+
+			return nil
+		}
+
+		return bosherr.WrapErrorf(err, "Sending message to %s", msg.Subject)
+	}
+
+	return nil
+}
+
+func (h *dialOutHandler) Stop() {
+	h.connLock.Lock()
+	conn := h.conn
+	h.conn = nil
+
+	if h.stopCh != nil {
+		close(h.stopCh)
+		h.stopCh = nil
+	}
+	h.connLock.Unlock()
+
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+func (h *dialOutHandler) serve(conn dialOutConn, stopCh chan struct{}) {
+	failures := 0
+
+	for {
+		if conn == nil {
+			if !h.waitBeforeReconnecting(failures, stopCh) {
+				return
+			}
+
+			var err error
+
+			conn, err = h.dial()
+			if err != nil {
+				failures++
+				h.logger.Warn(h.logTag, "Reconnecting to %s: %s", h.serverURL.Host, err.Error())
+				continue
+			}
+
+			h.logger.Info(h.logTag, "Reconnected to %s", h.serverURL.Host)
+
+			if !h.handleConnected(conn, stopCh) {
+				_ = conn.Close()
+				return
+			}
+		}
+
+		requests, err := conn.Receive()
+		if err != nil {
+			if h.isStopped(stopCh) {
+				return
+			}
+
+			failures++
+			h.logger.Warn(h.logTag, "Lost connection to %s: %s", h.serverURL.Host, err.Error())
+
+			h.connLock.Lock()
+			h.conn = nil
+			h.connLock.Unlock()
+
+			_ = conn.Close()
+			conn = nil
+
+			continue
+		}
+
+		failures = 0
+
+		// Sending may fail even though receiving works (e.g. long poll)
+		h.sendBuffered(conn)
+
+		// Long running requests must not hold up other requests
+		for _, request := range requests {
+			go h.handleRequest(conn, request)
+		}
+	}
+}
+
+// waitBeforeReconnecting returns false if handler was stopped while waiting
+func (h *dialOutHandler) waitBeforeReconnecting(failures int, stopCh chan struct{}) bool {
+	if failures == 0 {
+		return !h.isStopped(stopCh)
+	}
+
+	delay := reconnectBackoff.Delay(failures-1, rand.Float64())
+
+	h.logger.Info(h.logTag, "Waiting %s before reconnecting to %s", delay, h.serverURL.Host)
+
+	timer := h.timeService.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-stopCh:
+		return false
+	case <-timer.C():
+		return true
+	}
+}
+
+func (h *dialOutHandler) isStopped(stopCh chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleConnected sends buffered messages; returns false if handler was stopped
+func (h *dialOutHandler) handleConnected(conn dialOutConn, stopCh chan struct{}) bool {
+	h.connLock.Lock()
+
+	if h.stopCh != stopCh {
+		h.connLock.Unlock()
+		return false
+	}
+
+	h.conn = conn
+
+	h.connLock.Unlock()
+
+	h.sendBuffered(conn)
+
+	return true
+}
+
+// sendBuffered keeps messages that could not be sent in the buffer
+// so that they are retried once connection works again
+func (h *dialOutHandler) sendBuffered(conn dialOutConn) {
+	h.connLock.Lock()
+	messages, dropped := h.outboundBuffer.Drain()
+	h.connLock.Unlock()
+
+	if dropped > 0 {
+		h.logger.Warn(h.logTag, "Dropped %d messages that did not fit into outbound buffer", dropped)
+	}
+
+	for i, msg := range messages {
+		err := conn.Send(msg)
+		if err != nil {
+			h.logger.Warn(h.logTag, "Sending buffered message to %s: %s", msg.Subject, err.Error())
+
+			h.connLock.Lock()
+			for _, remainingMsg := range messages[i:] {
+				h.outboundBuffer.Push(remainingMsg)
+			}
+			h.connLock.Unlock()
+
+			return
+		}
+	}
+}
+
+func (h *dialOutHandler) handleRequest(conn dialOutConn, rawJSON []byte) {
+	requestJSON, keyID, err := h.requestVerifier.Verify(rawJSON)
+	if err != nil {
+		h.logger.Error(h.logTag, "Rejecting request: %s", err.Error())
+
+		rejectErr := err
+
+		h.performAndReply(conn, requestJSON, "", func(boshhandler.Request) boshhandler.Response {
+			return boshhandler.NewExceptionResponse(rejectErr)
+		}, rejectErr.Error())
+
+		return
+	}
+
+	// Do not lock handler funcs around possible network calls!
+	h.handlerFuncsLock.Lock()
+	handlerFuncs := h.handlerFuncs
+	h.handlerFuncsLock.Unlock()
+
+	for _, handlerFunc := range handlerFuncs {
+		h.performAndReply(conn, requestJSON, keyID, handlerFunc, "")
+	}
+}
+
+// dialOutCaller identifies callers by subject they expect replies on.
+// Requests can only be sent by the server that the agent connected to,
+// but server may relay requests of other clients so caller is advisory.
+func dialOutCaller(req boshhandler.Request) boshhandler.Caller {
+	return boshhandler.Caller{Kind: boshhandler.CallerDialOut, Name: req.ReplyTo}
+}
+
+// performAndReply records request as failed when rejectReason is given
+func (h *dialOutHandler) performAndReply(conn dialOutConn, requestJSON []byte, keyID string, handlerFunc boshhandler.Func, rejectReason string) {
+	respBytes, req, err := boshhandler.PerformHandlerWithJSON(
+		requestJSON,
+		boshhandler.WithCaller(handlerFunc, func(req boshhandler.Request) boshhandler.Caller {
+			return boshhandler.SignedCaller(keyID, dialOutCaller(req))
+		}),
+		responseMaxLength,
+		h.logger,
+	)
+	if err != nil {
+		h.logger.Error(h.logTag, "Running handler: %s", err)
+		h.generateCEFLog(req, 7, err.Error())
+		return
+	}
+
+	if len(respBytes) > 0 && req.ReplyTo != "" {
+		err = conn.Send(transportMessage{Subject: req.ReplyTo, Payload: respBytes})
+		if err != nil {
+			h.logger.Error(h.logTag, "Replying to %s: %s", req.ReplyTo, err.Error())
+			h.generateCEFLog(req, 7, err.Error())
+			return
+		}
+	}
+
+	if rejectReason != "" {
+		h.generateCEFLog(req, 7, rejectReason)
+		return
+	}
+
+	h.generateCEFLog(req, 1, "")
+}
+
+func (h *dialOutHandler) generateCEFLog(req boshhandler.Request, severity int, statusReason string) {
+	cef := boshhandler.NewCommonEventFormat()
+
+	host, port, err := net.SplitHostPort(h.serverURL.Host)
+	if err != nil {
+		host, port = h.serverURL.Host, ""
+	}
+
+	subject := fmt.Sprintf("agent.%s", h.settingsService.GetSettings().AgentID)
+
+	cefString, err := cef.ProduceNATSRequestEventLog(host, port, req.ReplyTo, req.Method, severity, subject, statusReason)
+	if err != nil {
+		h.logger.Error(h.logTag, err.Error())
+		return
+	}
+
+	if severity == 7 {
+		h.auditLogger.Err(cefString)
+		return
+	}
+
+	h.auditLogger.Debug(cefString)
+}
+
+// dialOutTLSConfig only trusts mbus CA and presents client certificate
+// when mbus TLS is configured; system CAs are trusted otherwise
+func dialOutTLSConfig(settingsService boshsettings.Service, serverURL *url.URL) (*tls.Config, error) {
+	tlsSettings := settingsService.GetSettings().MbusTLS
+
+	if !tlsSettings.IsEnabled() {
+		return &tls.Config{
+			ServerName: serverURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}, nil
+	}
+
+	return NewNatsTLSConfig(tlsSettings, serverURL.Hostname())
+}
@@ -3,21 +3,29 @@ package mbus
 import (
 	"net/url"
 
-	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
-	boshmicro "github.com/cloudfoundry/bosh-agent/micro"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
 type HandlerProvider struct {
 	settingsService boshsettings.Service
 	logger          boshlog.Logger
 	auditLogger     boshplatform.AuditLogger
+	timeService     clock.Clock
+	uuidGenerator   boshuuid.Generator
+	defaultHTTPSTLS boshsettings.HTTPSTLS
+	httpsLimits     boshdispatcher.Limits
+	metrics         *boshmetrics.Registry
+	registry        TransportRegistry
 	handler         boshhandler.Handler
 }
 
@@ -25,13 +33,29 @@ func NewHandlerProvider(
 	settingsService boshsettings.Service,
 	logger boshlog.Logger,
 	auditLogger boshplatform.AuditLogger,
+	timeService clock.Clock,
+	uuidGenerator boshuuid.Generator,
+	defaultHTTPSTLS boshsettings.HTTPSTLS,
+	httpsLimits boshdispatcher.Limits,
+	metrics *boshmetrics.Registry,
 ) (p HandlerProvider) {
 	p.settingsService = settingsService
 	p.logger = logger
 	p.auditLogger = auditLogger
+	p.timeService = timeService
+	p.uuidGenerator = uuidGenerator
+	p.defaultHTTPSTLS = defaultHTTPSTLS
+	p.httpsLimits = httpsLimits
+	p.metrics = metrics
+	p.registry = NewDefaultTransportRegistry()
 	return
 }
 
+// RegisterTransport makes handler provider support mbus URLs with given scheme.
+func (p HandlerProvider) RegisterTransport(scheme string, factory TransportFactory) {
+	p.registry.Register(scheme, factory)
+}
+
 func (p HandlerProvider) Get(
 	platform boshplatform.Platform,
 	dirProvider boshdir.Provider,
@@ -47,13 +71,21 @@ func (p HandlerProvider) Get(
 		return
 	}
 
-	switch mbusURL.Scheme {
-	case "nats":
-		handler = NewNatsHandler(p.settingsService, yagnats.NewClient(), p.logger, platform)
-	case "https":
-		handler = boshmicro.NewHTTPSHandler(mbusURL, p.logger, platform.GetFs(), dirProvider, p.auditLogger)
-	default:
-		err = bosherr.Errorf("Message Bus Handler with scheme %s could not be found", mbusURL.Scheme)
+	handler, err = p.registry.Build(mbusURL, TransportDeps{
+		SettingsService: p.settingsService,
+		Platform:        platform,
+		DirProvider:     dirProvider,
+		RequestVerifier: boshhandler.NewRequestVerifier(p.settingsService, p.timeService),
+		TimeService:     p.timeService,
+		UUIDGenerator:   p.uuidGenerator,
+		Logger:          p.logger,
+		AuditLogger:     p.auditLogger,
+		DefaultHTTPSTLS: p.defaultHTTPSTLS,
+		HTTPSLimits:     p.httpsLimits,
+		Metrics:         p.metrics,
+	})
+	if err != nil {
+		return
 	}
 
 	p.handler = handler
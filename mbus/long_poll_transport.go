@@ -0,0 +1,175 @@
+package mbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	longPollHandlerLogTag = "Long Poll Handler"
+
+	// longPollWait is how long director is asked to hold poll request
+	// when there are no requests for the agent
+	longPollWait = 30 * time.Second
+
+	longPollClientTimeout = longPollWait + 30*time.Second
+
+	longPollSchemePrefix = "longpoll+"
+)
+
+// NewLongPollTransport polls longpoll+http(s)://<mbus host>/<mbus path>/agents/<agent id>/requests
+// and posts transport messages to .../agents/<agent id>/messages.
+// Poll responds with a JSON array of requests or with 204 when no requests arrived in time.
+func NewLongPollTransport(mbusURL *url.URL, deps TransportDeps) (boshhandler.Handler, error) {
+	if !strings.HasPrefix(mbusURL.Scheme, longPollSchemePrefix) {
+		return nil, bosherr.Errorf("Long poll URL scheme must start with '%s'", longPollSchemePrefix)
+	}
+
+	httpURL := *mbusURL
+	httpURL.Scheme = strings.TrimPrefix(mbusURL.Scheme, longPollSchemePrefix)
+	httpURL.User = nil
+
+	dial := func() (dialOutConn, error) {
+		tlsConfig, err := dialOutTLSConfig(deps.SettingsService, mbusURL)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Building TLS config")
+		}
+
+		agentURL := agentEndpointURL(&httpURL, deps.SettingsService.GetSettings().AgentID)
+
+		transport := &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+
+		return newLongPollConn(agentURL, mbusURL.User, transport), nil
+	}
+
+	return newDialOutHandler(dial, mbusURL, deps, longPollHandlerLogTag), nil
+}
+
+type longPollConn struct {
+	client      *http.Client
+	transport   *http.Transport
+	requestsURL string
+	messagesURL string
+	user        *url.Userinfo
+
+	// ctx is cancelled on close so that outstanding poll returns
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newLongPollConn(agentURL *url.URL, user *url.Userinfo, transport *http.Transport) *longPollConn {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &longPollConn{
+		client:      &http.Client{Transport: transport, Timeout: longPollClientTimeout},
+		transport:   transport,
+		requestsURL: fmt.Sprintf("%s/requests?wait=%d", agentURL.String(), int(longPollWait.Seconds())),
+		messagesURL: agentURL.String() + "/messages",
+		user:        user,
+
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (c *longPollConn) Receive() ([][]byte, error) {
+	resp, err := c.do("GET", c.requestsURL, nil)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Polling for requests")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, bosherr.Errorf("Polling for requests: unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, dialOutMaxReceiveSize+1))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading requests")
+	}
+
+	if len(body) > dialOutMaxReceiveSize {
+		return nil, bosherr.Errorf("Polling for requests: response exceeds maximum size of %d bytes", dialOutMaxReceiveSize)
+	}
+
+	var rawRequests []json.RawMessage
+
+	err = json.Unmarshal(body, &rawRequests)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling requests")
+	}
+
+	requests := make([][]byte, len(rawRequests))
+	for i, rawRequest := range rawRequests {
+		requests[i] = rawRequest
+	}
+
+	return requests, nil
+}
+
+func (c *longPollConn) Send(msg transportMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling transport message")
+	}
+
+	resp, err := c.do("POST", c.messagesURL, body)
+	if err != nil {
+		return err
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return bosherr.Errorf("Posting message: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *longPollConn) Close() error {
+	c.cancel()
+	c.transport.CloseIdleConnections()
+	return nil
+}
+
+func (c *longPollConn) do(method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building request")
+	}
+
+	req = req.WithContext(c.ctx)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.user != nil {
+		password, _ := c.user.Password()
+		req.SetBasicAuth(c.user.Username(), password)
+	}
+
+	return c.client.Do(req)
+}
@@ -0,0 +1,221 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	gourl "net/url"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func init() {
+	Describe("long poll transport", func() {
+		var (
+			server         *httptest.Server
+			pendingRequest chan string
+			messages       chan receivedTransportMessage
+			pollURLs       chan string
+			authHeaders    chan string
+			failing        int32
+			failedPolls    int32
+
+			settingsService *fakesettings.FakeSettingsService
+			timeService     *fakeclock.FakeClock
+			mbusURL         *gourl.URL
+			handler         boshhandler.Handler
+		)
+
+		BeforeEach(func() {
+			// Handlers of previous test may still be running
+			requests, postedMessages := make(chan string, 10), make(chan receivedTransportMessage, 10)
+			requestURLs, requestAuthHeaders := make(chan string, 1000), make(chan string, 1000)
+			pendingRequest, messages, pollURLs, authHeaders = requests, postedMessages, requestURLs, requestAuthHeaders
+
+			atomic.StoreInt32(&failing, 0)
+			atomic.StoreInt32(&failedPolls, 0)
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&failing) == 1 {
+					if r.Method == "GET" {
+						atomic.AddInt32(&failedPolls, 1)
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/director/agents/my-agent-id/requests":
+					requestURLs <- r.URL.RequestURI()
+					requestAuthHeaders <- r.Header.Get("Authorization")
+
+					select {
+					case request := <-requests:
+						w.WriteHeader(http.StatusOK)
+						_, _ = w.Write([]byte("[" + request + "]"))
+					case <-time.After(20 * time.Millisecond):
+						w.WriteHeader(http.StatusNoContent)
+					}
+
+				case r.Method == "POST" && r.URL.Path == "/director/agents/my-agent-id/messages":
+					var msg receivedTransportMessage
+					if json.NewDecoder(r.Body).Decode(&msg) != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					postedMessages <- msg
+
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			var err error
+
+			mbusURL, err = gourl.Parse("longpoll+http://fake-user:fake-password@" + server.Listener.Addr().String() + "/director")
+			Expect(err).ToNot(HaveOccurred())
+
+			settingsService = &fakesettings.FakeSettingsService{
+				Settings: boshsettings.Settings{AgentID: "my-agent-id"},
+			}
+
+			timeService = fakeclock.NewFakeClock(time.Now())
+
+			handler, err = NewLongPollTransport(mbusURL, TransportDeps{
+				SettingsService: settingsService,
+				RequestVerifier: boshhandler.NewRequestVerifier(settingsService, timeService),
+				TimeService:     timeService,
+				Logger:          boshlog.NewLogger(boshlog.LevelNone),
+				AuditLogger:     fakeplatform.NewFakeAuditLogger(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			handler.Stop()
+			server.Close()
+		})
+
+		It("returns error when scheme is not prefixed with longpoll+", func() {
+			mbusURL.Scheme = "http"
+
+			_, err := NewLongPollTransport(mbusURL, TransportDeps{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("longpoll+"))
+		})
+
+		It("polls agent endpoint using credentials from mbus URL", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(pollURLs).Should(Receive(Equal("/director/agents/my-agent-id/requests?wait=30")))
+			Eventually(authHeaders).Should(Receive(Equal("Basic ZmFrZS11c2VyOmZha2UtcGFzc3dvcmQ=")))
+		})
+
+		It("performs polled requests and posts replies", func() {
+			receivedMethods := make(chan string, 1)
+
+			err := handler.Start(func(req boshhandler.Request) boshhandler.Response {
+				receivedMethods <- req.Method
+				return boshhandler.NewValueResponse("expected value")
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			pendingRequest <- `{"method":"ping","arguments":[],"reply_to":"director.fake-reply"}`
+
+			var msg receivedTransportMessage
+			Eventually(messages).Should(Receive(&msg))
+			Expect(msg.Subject).To(Equal("director.fake-reply"))
+			Expect(string(msg.Payload)).To(Equal(`{"value":"expected value"}`))
+
+			Expect(receivedMethods).To(Receive(Equal("ping")))
+		})
+
+		It("does not hold up requests while performing long running requests", func() {
+			release := make(chan struct{})
+			defer close(release)
+
+			err := handler.Start(func(req boshhandler.Request) boshhandler.Response {
+				if req.Method == "fake-slow-action" {
+					<-release
+				}
+				return boshhandler.NewValueResponse(req.Method)
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			pendingRequest <- `{"method":"fake-slow-action","arguments":[],"reply_to":"director.fake-slow-reply"},` +
+				`{"method":"ping","arguments":[],"reply_to":"director.fake-reply"}`
+
+			var msg receivedTransportMessage
+			Eventually(messages).Should(Receive(&msg))
+			Expect(msg.Subject).To(Equal("director.fake-reply"))
+		})
+
+		It("posts messages with NATS subjects", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Alert, map[string]string{"id": "fake-alert"})
+			Expect(err).ToNot(HaveOccurred())
+
+			var msg receivedTransportMessage
+			Eventually(messages).Should(Receive(&msg))
+			Expect(msg.Subject).To(Equal("hm.agent.alert.my-agent-id"))
+			Expect(string(msg.Payload)).To(Equal(`{"id":"fake-alert"}`))
+		})
+
+		It("returns error when posting message that is not buffered fails", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			atomic.StoreInt32(&failing, 1)
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Topic("fake-topic"), "fake-message")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unexpected status 500"))
+		})
+
+		It("keeps polling after failures and sends buffered heartbeats once director responds again", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			atomic.StoreInt32(&failing, 1)
+
+			Eventually(func() int32 {
+				timeService.Increment(time.Minute)
+				return atomic.LoadInt32(&failedPolls)
+			}).Should(BeNumerically(">", 0))
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"job": "fake-job"})
+			Expect(err).ToNot(HaveOccurred())
+
+			atomic.StoreInt32(&failing, 0)
+
+			var msg receivedTransportMessage
+			Eventually(func() bool {
+				timeService.Increment(time.Minute)
+				select {
+				case msg = <-messages:
+					return true
+				default:
+					return false
+				}
+			}).Should(BeTrue())
+
+			Expect(msg.Subject).To(Equal("hm.agent.heartbeat.my-agent-id"))
+			Expect(string(msg.Payload)).To(Equal(`{"job":"fake-job"}`))
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package mbus
+
+// messageBuffer is a ring that keeps the most recent messages;
+// oldest messages are dropped when it is full.
+type messageBuffer struct {
+	messages []transportMessage
+	start    int
+	count    int
+	dropped  int
+}
+
+func newMessageBuffer(size int) *messageBuffer {
+	return &messageBuffer{messages: make([]transportMessage, size)}
+}
+
+func (b *messageBuffer) Push(msg transportMessage) {
+	if len(b.messages) == 0 {
+		b.dropped++
+		return
+	}
+
+	if b.count == len(b.messages) {
+		b.messages[b.start] = msg
+		b.start = (b.start + 1) % len(b.messages)
+		b.dropped++
+		return
+	}
+
+	b.messages[(b.start+b.count)%len(b.messages)] = msg
+	b.count++
+}
+
+// Drain returns buffered messages from oldest to newest
+// and number of messages dropped since last drain.
+func (b *messageBuffer) Drain() ([]transportMessage, int) {
+	messages := make([]transportMessage, b.count)
+
+	for i := 0; i < b.count; i++ {
+		messages[i] = b.messages[(b.start+i)%len(b.messages)]
+		b.messages[(b.start+i)%len(b.messages)] = transportMessage{}
+	}
+
+	dropped := b.dropped
+
+	b.start = 0
+	b.count = 0
+	b.dropped = 0
+
+	return messages, dropped
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/url"
 	"os"
@@ -11,21 +12,44 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
 
+	boshalert "github.com/cloudfoundry/bosh-agent/agent/alert"
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
 	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 )
 
 const (
 	responseMaxLength = 1024 * 1024
 	natsHandlerLogTag = "NATS Handler"
+
+	// natsPingInterval determines how quickly
+	// lost and restored connections are noticed
+	natsPingInterval = 5 * time.Second
+
+	// outboundBufferSize limits number of heartbeats and alerts
+	// kept while disconnected; with heartbeats sent every 30 seconds
+	// it covers at most 50 minutes of outage, less when alerts are sent
+	outboundBufferSize = 100
 )
 
+// reconnectBackoff is shared by all transports; with NATS it is used in addition
+// to the fixed delay that NATS client waits after each failed connection attempt
+var reconnectBackoff = Backoff{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
 type Handler interface {
 	Run(boshhandler.Func) error
 	Start(boshhandler.Func) error
@@ -42,9 +66,31 @@ type natsHandler struct {
 	handlerFuncs     []boshhandler.Func
 	handlerFuncsLock sync.Mutex
 
+	requestVerifier boshhandler.RequestVerifier
+	timeService     clock.Clock
+	uuidGenerator   boshuuid.Generator
+
+	// connLock protects connection state and outbound buffer
+	connLock          sync.Mutex
+	connected         bool
+	disconnectedAt    time.Time
+	reconnectAttempts int
+	outboundBuffer    *messageBuffer
+	stopCh            chan struct{}
+
+	// conn is the latest connection provided to client;
+	// closing it makes client reconnect with current settings.
+	conn *yagnats.Connection
+
+	settingsSubscription sync.Once
+
 	logger      boshlog.Logger
 	auditLogger boshplatform.AuditLogger
 	logTag      string
+
+	connectedGauge  boshmetrics.Gauge
+	reconnects      boshmetrics.Counter
+	droppedMessages boshmetrics.Counter
 }
 
 func NewNatsHandler(
@@ -52,15 +98,28 @@ func NewNatsHandler(
 	client yagnats.NATSClient,
 	logger boshlog.Logger,
 	platform boshplatform.Platform,
+	requestVerifier boshhandler.RequestVerifier,
+	timeService clock.Clock,
+	uuidGenerator boshuuid.Generator,
+	metrics *boshmetrics.Registry,
 ) Handler {
 	return &natsHandler{
 		settingsService: settingsService,
 		client:          client,
 		platform:        platform,
 
+		requestVerifier: requestVerifier,
+		timeService:     timeService,
+		uuidGenerator:   uuidGenerator,
+		outboundBuffer:  newMessageBuffer(outboundBufferSize),
+
 		logger:      logger,
 		logTag:      natsHandlerLogTag,
 		auditLogger: platform.GetAuditLogger(),
+
+		connectedGauge:  metrics.Gauge("bosh_agent_nats_connected", "Whether agent is connected to NATS."),
+		reconnects:      metrics.Counter("bosh_agent_nats_reconnects_total", "Number of times connection to NATS was restored."),
+		droppedMessages: metrics.Counter("bosh_agent_nats_dropped_messages_total", "Number of messages dropped while NATS was not reachable."),
 	}
 }
 
@@ -80,13 +139,21 @@ func (h *natsHandler) Run(handlerFunc boshhandler.Func) error {
 func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 	h.RegisterAdditionalFunc(handlerFunc)
 
-	connProvider, err := h.getConnectionInfo()
+	_, err := h.getConnectionInfo()
 	if err != nil {
 		return bosherr.WrapError(err, "Getting connection info")
 	}
 
+	// Called before initial connection and before each reconnection attempt
 	h.client.BeforeConnectCallback(func() {
-		hostSplit := strings.Split(connProvider.Addr, ":")
+		h.waitBeforeReconnecting()
+
+		connInfo, err := h.getConnectionInfo()
+		if err != nil {
+			return
+		}
+
+		hostSplit := strings.Split(connInfo.Addr, ":")
 		ip := hostSplit[0]
 
 		if net.ParseIP(ip) == nil {
@@ -99,11 +166,15 @@ func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 		}
 	})
 
-	err = h.client.Connect(connProvider)
+	err = h.client.Connect(NatsConnectionProvider{handler: h})
 	if err != nil {
 		return bosherr.WrapError(err, "Connecting")
 	}
 
+	h.settingsSubscription.Do(func() {
+		h.settingsService.Subscribe(h.reconnectWithChangedTLS, boshsettings.FieldMbusTLS)
+	})
+
 	settings := h.settingsService.GetSettings()
 
 	subject := fmt.Sprintf("agent.%s", settings.AgentID)
@@ -111,19 +182,48 @@ func (h *natsHandler) Start(handlerFunc boshhandler.Func) error {
 	h.logger.Info(h.logTag, "Subscribing to %s", subject)
 
 	_, err = h.client.Subscribe(subject, func(natsMsg *yagnats.Message) {
+		requestJSON, keyID, err := h.requestVerifier.Verify(natsMsg.Payload)
+
+		verifiedMsg := &yagnats.Message{
+			Subject: natsMsg.Subject,
+			ReplyTo: natsMsg.ReplyTo,
+			Payload: requestJSON,
+		}
+
+		if err != nil {
+			h.rejectNatsMsg(verifiedMsg, err)
+			return
+		}
+
+		err = checkReplyTo(verifiedMsg)
+		if err != nil {
+			h.rejectNatsMsg(verifiedMsg, err)
+			return
+		}
+
 		// Do not lock handler funcs around possible network calls!
 		h.handlerFuncsLock.Lock()
 		handlerFuncs := h.handlerFuncs
 		h.handlerFuncsLock.Unlock()
 
 		for _, handlerFunc := range handlerFuncs {
-			h.handleNatsMsg(natsMsg, handlerFunc)
+			h.handleNatsMsg(verifiedMsg, keyID, handlerFunc)
 		}
 	})
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Subscribing to %s", subject)
 	}
 
+	h.handleConnected()
+
+	stopCh := make(chan struct{})
+
+	h.connLock.Lock()
+	h.stopCh = stopCh
+	h.connLock.Unlock()
+
+	go h.monitorConnection(h.timeService.NewTicker(natsPingInterval), stopCh)
+
 	return nil
 }
 
@@ -147,17 +247,187 @@ func (h *natsHandler) Send(target boshhandler.Target, topic boshhandler.Topic, m
 	settings := h.settingsService.GetSettings()
 
 	subject := fmt.Sprintf("%s.agent.%s.%s", target, topic, settings.AgentID)
-	return h.client.Publish(subject, bytes)
+
+	if topic != boshhandler.Heartbeat && topic != boshhandler.Alert {
+		return h.client.Publish(subject, bytes)
+	}
+
+	h.publishOrBuffer(transportMessage{Subject: subject, Payload: bytes})
+
+	return nil
 }
 
 func (h *natsHandler) Stop() {
+	h.connLock.Lock()
+	if h.stopCh != nil {
+		close(h.stopCh)
+		h.stopCh = nil
+	}
+	h.connected = false
+	h.disconnectedAt = time.Time{}
+	h.reconnectAttempts = 0
+	h.connLock.Unlock()
+
 	h.client.Disconnect()
 }
 
-func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshhandler.Func) {
+// publishOrBuffer keeps heartbeats and alerts while NATS is not reachable
+// so that transient outage does not cause them to be lost or the agent to restart
+func (h *natsHandler) publishOrBuffer(msg transportMessage) {
+	h.connLock.Lock()
+	if !h.connected {
+		h.outboundBuffer.Push(msg)
+		h.connLock.Unlock()
+		h.logger.Debug(h.logTag, "Buffering message to %s until reconnected", msg.Subject)
+		return
+	}
+	h.connLock.Unlock()
+
+	err := h.client.Publish(msg.Subject, msg.Payload)
+	if err != nil {
+		h.handleDisconnected(bosherr.WrapErrorf(err, "Publishing to %s", msg.Subject).Error())
+
+		h.connLock.Lock()
+		h.outboundBuffer.Push(msg)
+		h.connLock.Unlock()
+	}
+}
+
+func (h *natsHandler) monitorConnection(ticker clock.Ticker, stopCh chan struct{}) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C():
+			if h.client.Ping() {
+				h.handleConnected()
+			} else {
+				h.handleDisconnected("Ping failed")
+			}
+		}
+	}
+}
+
+// waitBeforeReconnecting does not delay initial connection attempt
+// and first attempt after connection is lost
+func (h *natsHandler) waitBeforeReconnecting() {
+	h.handleDisconnected("Reconnecting")
+
+	h.connLock.Lock()
+	attempt := h.reconnectAttempts
+	h.reconnectAttempts++
+	h.connLock.Unlock()
+
+	if attempt == 0 {
+		return
+	}
+
+	delay := reconnectBackoff.Delay(attempt-1, rand.Float64())
+
+	h.logger.Info(h.logTag, "Waiting %s before reconnecting to NATS (attempt %d)", delay, attempt+1)
+
+	h.timeService.Sleep(delay)
+}
+
+func (h *natsHandler) handleDisconnected(reason string) {
+	h.connLock.Lock()
+	defer h.connLock.Unlock()
+
+	if !h.connected {
+		return
+	}
+
+	h.connected = false
+	h.disconnectedAt = h.timeService.Now()
+	h.connectedGauge.Set(0)
+
+	h.logger.Warn(h.logTag, "Lost connection to NATS: %s", reason)
+}
+
+func (h *natsHandler) handleConnected() {
+	h.connLock.Lock()
+
+	if h.connected {
+		h.connLock.Unlock()
+		return
+	}
+
+	h.connected = true
+	h.reconnectAttempts = 0
+	h.connectedGauge.Set(1)
+
+	disconnectedAt := h.disconnectedAt
+	h.disconnectedAt = time.Time{}
+
+	messages, dropped := h.outboundBuffer.Drain()
+
+	h.connLock.Unlock()
+
+	if !disconnectedAt.IsZero() {
+		h.logger.Info(h.logTag, "Reconnected to NATS after %s", h.timeService.Since(disconnectedAt))
+		h.reconnects.Inc()
+	}
+
+	if dropped > 0 {
+		h.logger.Warn(h.logTag, "Dropped %d messages that did not fit into outbound buffer", dropped)
+		h.droppedMessages.Add(float64(dropped))
+	}
+
+	for i, msg := range messages {
+		err := h.client.Publish(msg.Subject, msg.Payload)
+		if err != nil {
+			h.handleDisconnected(bosherr.WrapErrorf(err, "Publishing buffered message to %s", msg.Subject).Error())
+
+			h.connLock.Lock()
+			for _, remainingMsg := range messages[i:] {
+				h.outboundBuffer.Push(remainingMsg)
+			}
+			h.connLock.Unlock()
+
+			return
+		}
+	}
+
+	if !disconnectedAt.IsZero() {
+		h.sendConnectionRestoredAlert(disconnectedAt, len(messages), dropped)
+	}
+}
+
+func (h *natsHandler) sendConnectionRestoredAlert(disconnectedAt time.Time, sent, dropped int) {
+	uuid, err := h.uuidGenerator.Generate()
+	if err != nil {
+		h.logger.Error(h.logTag, "Generating connection restored alert id: %s", err.Error())
+		return
+	}
+
+	alert := boshalert.Alert{
+		ID:       uuid,
+		Severity: boshalert.SeverityWarning,
+		Title:    "NATS connection restored",
+		Summary: fmt.Sprintf(
+			"Connection to NATS was lost at %s and restored after %s; %d buffered messages were sent, %d were dropped",
+			disconnectedAt.UTC().Format(time.RFC3339),
+			h.timeService.Since(disconnectedAt),
+			sent,
+			dropped,
+		),
+		CreatedAt: h.timeService.Now().Unix(),
+	}
+
+	err = h.Send(boshhandler.HealthMonitor, boshhandler.Alert, alert)
+	if err != nil {
+		h.logger.Error(h.logTag, "Sending connection restored alert: %s", err.Error())
+	}
+}
+
+func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, keyID string, handlerFunc boshhandler.Func) {
 	respBytes, req, err := boshhandler.PerformHandlerWithJSON(
 		natsMsg.Payload,
-		handlerFunc,
+		boshhandler.WithCaller(handlerFunc, func(req boshhandler.Request) boshhandler.Caller {
+			return boshhandler.SignedCaller(keyID, natsCaller(natsMsg, req))
+		}),
 		responseMaxLength,
 		h.logger,
 	)
@@ -169,7 +439,7 @@ func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshha
 	}
 
 	if len(respBytes) > 0 {
-		err = h.client.Publish(req.ReplyTo, respBytes)
+		err = h.client.Publish(replySubject(natsMsg, req), respBytes)
 		if err != nil {
 			h.generateCEFLog(natsMsg, 7, err.Error())
 			h.logger.Error(h.logTag, "Publishing to the client: %s", err.Error())
@@ -180,6 +450,64 @@ func (h *natsHandler) handleNatsMsg(natsMsg *yagnats.Message, handlerFunc boshha
 	h.generateCEFLog(natsMsg, 1, "")
 }
 
+// natsCaller identifies callers by subject they expect replies on:
+// NATS reply subject of the message or reply to in request JSON
+// used by the director. Both are chosen by the sender so caller is advisory.
+func natsCaller(natsMsg *yagnats.Message, req boshhandler.Request) boshhandler.Caller {
+	return boshhandler.Caller{Kind: boshhandler.CallerNATS, Name: replySubject(natsMsg, req)}
+}
+
+// checkReplyTo rejects requests asking for replies on a subject
+// other than NATS reply subject of the message they were sent with.
+func checkReplyTo(natsMsg *yagnats.Message) error {
+	if natsMsg.ReplyTo == "" {
+		return nil
+	}
+
+	var request struct {
+		ReplyTo string `json:"reply_to"`
+	}
+
+	// Invalid JSON is reported when request is handled
+	err := json.Unmarshal(natsMsg.Payload, &request)
+	if err != nil || request.ReplyTo == "" || request.ReplyTo == natsMsg.ReplyTo {
+		return nil
+	}
+
+	return boshhandler.NewErrorf(boshhandler.ErrorCodeActionNotAllowed,
+		"Request reply to '%s' does not match message reply subject '%s'", request.ReplyTo, natsMsg.ReplyTo)
+}
+
+func replySubject(natsMsg *yagnats.Message, req boshhandler.Request) string {
+	if natsMsg.ReplyTo != "" {
+		return natsMsg.ReplyTo
+	}
+
+	return req.ReplyTo
+}
+
+// rejectNatsMsg replies with an exception so that
+// the sender does not have to wait for request to time out
+func (h *natsHandler) rejectNatsMsg(natsMsg *yagnats.Message, rejectErr error) {
+	h.logger.Error(h.logTag, "Rejecting request: %s", rejectErr.Error())
+	h.generateCEFLog(natsMsg, 7, rejectErr.Error())
+
+	respBytes, req, err := boshhandler.PerformHandlerWithJSON(
+		natsMsg.Payload,
+		func(boshhandler.Request) boshhandler.Response { return boshhandler.NewExceptionResponse(rejectErr) },
+		responseMaxLength,
+		h.logger,
+	)
+	if err != nil || replySubject(natsMsg, req) == "" {
+		return
+	}
+
+	err = h.client.Publish(replySubject(natsMsg, req), respBytes)
+	if err != nil {
+		h.logger.Error(h.logTag, "Publishing to the client: %s", err.Error())
+	}
+}
+
 func (h *natsHandler) runUntilInterrupted() {
 	defer h.client.Disconnect()
 
@@ -217,9 +545,66 @@ func (h *natsHandler) getConnectionInfo() (*yagnats.ConnectionInfo, error) {
 		connInfo.Username = user.Username()
 	}
 
+	if h.tlsSettings().IsEnabled() {
+		connInfo.Dial = natsTLSDialer{tlsSettingsFunc: h.tlsSettings}.Dial
+	}
+
 	return connInfo, nil
 }
 
+// NatsConnectionProvider reads mbus settings before every connection attempt
+// so that refreshed mbus credentials are used once connection is re-established
+type NatsConnectionProvider struct {
+	handler *natsHandler
+}
+
+func (p NatsConnectionProvider) ConnectionInfo() (*yagnats.ConnectionInfo, error) {
+	return p.handler.getConnectionInfo()
+}
+
+func (p NatsConnectionProvider) ProvideConnection() (*yagnats.Connection, error) {
+	connInfo, err := p.ConnectionInfo()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting connection info")
+	}
+
+	conn, err := connInfo.ProvideConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	p.handler.connLock.Lock()
+	p.handler.conn = conn
+	p.handler.connLock.Unlock()
+
+	return conn, nil
+}
+
+// tlsSettings include certificates given via update_settings
+// so that they can be rotated without changing infrastructure settings.
+func (h *natsHandler) tlsSettings() boshsettings.MbusTLS {
+	return h.settingsService.GetSettings().MbusTLS
+}
+
+// reconnectWithChangedTLS closes current connection so that client
+// reconnects presenting rotated certificates instead of waiting for connection to fail.
+func (h *natsHandler) reconnectWithChangedTLS(_ boshsettings.Settings) error {
+	h.connLock.Lock()
+	conn := h.conn
+	h.conn = nil
+	h.connLock.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	h.logger.Info(h.logTag, "Reconnecting to NATS since mbus TLS settings changed")
+
+	conn.Disconnect()
+
+	return nil
+}
+
 func (h *natsHandler) generateCEFLog(natsMsg *yagnats.Message, severity int, statusReason string) {
 	cef := boshhandler.NewCommonEventFormat()
 
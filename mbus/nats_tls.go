@@ -0,0 +1,117 @@
+package mbus
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	natsDialTimeout = 5 * time.Second
+
+	// NATS server INFO line is small; limit prevents reading forever
+	// from something that is not a NATS server
+	natsInfoMaxLength = 64 * 1024
+)
+
+// NewNatsTLSConfig returns config that only trusts given CA
+// and presents client certificate if one is configured.
+func NewNatsTLSConfig(tlsSettings boshsettings.MbusTLS, serverName string) (*tls.Config, error) {
+	caPool, err := tlsSettings.CertPool()
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := tlsSettings.ClientCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		RootCAs:      caPool,
+		Certificates: certs,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+type natsTLSDialer struct {
+	tlsSettingsFunc func() boshsettings.MbusTLS
+}
+
+// Dial connects to NATS server and upgrades connection to TLS.
+// NATS server sends INFO in plain text before expecting TLS handshake.
+// Settings are retrieved on every dial so that rotated certificates
+// are used when reconnecting.
+func (d natsTLSDialer) Dial(network, address string) (net.Conn, error) {
+	serverName, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing NATS address %s", address)
+	}
+
+	tlsConfig, err := NewNatsTLSConfig(d.tlsSettingsFunc(), serverName)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Building NATS TLS config")
+	}
+
+	conn, err := net.DialTimeout(network, address, natsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(natsDialTimeout))
+	if err != nil {
+		_ = conn.Close()
+		return nil, bosherr.WrapError(err, "Setting NATS connection deadline")
+	}
+
+	err = d.readInfo(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		_ = conn.Close()
+		return nil, bosherr.WrapError(err, "Performing NATS TLS handshake")
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		_ = tlsConn.Close()
+		return nil, bosherr.WrapError(err, "Clearing NATS connection deadline")
+	}
+
+	return tlsConn, nil
+}
+
+// readInfo reads byte by byte to avoid consuming TLS handshake bytes
+func (d natsTLSDialer) readInfo(conn net.Conn) error {
+	var line []byte
+	buf := make([]byte, 1)
+
+	for len(line) < natsInfoMaxLength {
+		_, err := conn.Read(buf)
+		if err != nil {
+			return bosherr.WrapError(err, "Reading NATS server INFO")
+		}
+
+		if buf[0] == '\n' {
+			if !strings.HasPrefix(string(line), "INFO") {
+				return bosherr.Errorf("Expected NATS server INFO but received '%s'", strings.TrimSpace(string(line)))
+			}
+			return nil
+		}
+
+		line = append(line, buf[0])
+	}
+
+	return bosherr.Error("NATS server INFO is too long")
+}
@@ -0,0 +1,278 @@
+package mbus_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/yagnats/fakeyagnats"
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+)
+
+type testCert struct {
+	CertPEM string
+	KeyPEM  string
+
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func (c testCert) TLSCertificate() tls.Certificate {
+	cert, err := tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+	Expect(err).ToNot(HaveOccurred())
+	return cert
+}
+
+func generateTestCert(commonName string, isCA bool, parent *testCert) testCert {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return testCert{CertPEM: string(certPEM), KeyPEM: string(keyPEM), cert: cert, key: key}
+}
+
+type fakeNatsTLSServer struct {
+	listener    net.Listener
+	info        string
+	clientNames chan string
+
+	// disconnects receives names of clients that sent CONNECT once they disconnect
+	disconnects chan string
+}
+
+func newFakeNatsTLSServer(serverCert tls.Certificate, clientCA testCert, info string) *fakeNatsTLSServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	clientCAPool := x509.NewCertPool()
+	clientCAPool.AddCert(clientCA.cert)
+
+	server := &fakeNatsTLSServer{
+		listener:    listener,
+		info:        info,
+		clientNames: make(chan string, 10),
+		disconnects: make(chan string, 10),
+	}
+
+	go func() {
+		defer GinkgoRecover()
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_, _ = conn.Write([]byte(server.info))
+
+			tlsConn := tls.Server(conn, &tls.Config{
+				Certificates: []tls.Certificate{serverCert},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAPool,
+			})
+
+			err = tlsConn.Handshake()
+			if err != nil {
+				server.clientNames <- ""
+				_ = conn.Close()
+				continue
+			}
+
+			clientName := tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			server.clientNames <- clientName
+
+			line, err := bufio.NewReader(tlsConn).ReadString('\n')
+			if err == nil && strings.HasPrefix(line, "CONNECT") {
+				_, _ = tlsConn.Write([]byte("+OK\r\n"))
+				_, _ = io.Copy(ioutil.Discard, tlsConn)
+				server.disconnects <- clientName
+			}
+
+			_ = tlsConn.Close()
+		}
+	}()
+
+	return server
+}
+
+func (s *fakeNatsTLSServer) Addr() string { return s.listener.Addr().String() }
+
+func (s *fakeNatsTLSServer) Close() { _ = s.listener.Close() }
+
+var _ = Describe("natsHandler with TLS", func() {
+	var (
+		settingsService *fakesettings.FakeSettingsService
+		client          *fakeyagnats.FakeYagnats
+		platform        *fakeplatform.FakePlatform
+		handler         boshhandler.Handler
+
+		ca         testCert
+		clientCert testCert
+		server     *fakeNatsTLSServer
+	)
+
+	BeforeEach(func() {
+		ca = generateTestCert("fake-ca", true, nil)
+		serverCert := generateTestCert("127.0.0.1", false, &ca)
+		clientCert = generateTestCert("fake-agent", false, &ca)
+
+		server = newFakeNatsTLSServer(serverCert.TLSCertificate(), ca, "INFO {\"tls_required\":true}\r\n")
+
+		settingsService = &fakesettings.FakeSettingsService{
+			Settings: boshsettings.Settings{
+				AgentID: "my-agent-id",
+				Mbus:    "nats://fake-username:fake-password@" + server.Addr(),
+				MbusTLS: boshsettings.MbusTLS{
+					CA:          ca.CertPEM,
+					Certificate: clientCert.CertPEM,
+					PrivateKey:  clientCert.KeyPEM,
+				},
+			},
+		}
+
+		logger := boshlog.NewWriterLogger(boshlog.LevelNone, bytes.NewBuffer(nil), bytes.NewBuffer(nil))
+		client = fakeyagnats.New()
+		platform = fakeplatform.NewFakePlatform()
+		handler = NewNatsHandler(settingsService, client, logger, platform, boshhandler.NewRequestVerifier(settingsService, clock.NewClock()), clock.NewClock(), fakeuuid.NewFakeGenerator(), boshmetrics.NewRegistry())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	dial := func() error {
+		err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+		Expect(err).ToNot(HaveOccurred())
+		defer handler.Stop()
+
+		connInfo, err := client.ConnectedConnectionProvider().(NatsConnectionProvider).ConnectionInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo.Dial).ToNot(BeNil())
+
+		conn, err := connInfo.Dial("tcp", connInfo.Addr)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err
+	}
+
+	It("does not use TLS when CA is not configured", func() {
+		settingsService.Settings.MbusTLS = boshsettings.MbusTLS{}
+
+		err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+		Expect(err).ToNot(HaveOccurred())
+		defer handler.Stop()
+
+		connInfo, err := client.ConnectedConnectionProvider().(NatsConnectionProvider).ConnectionInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(connInfo.Dial).To(BeNil())
+	})
+
+	It("connects over TLS presenting client certificate", func() {
+		Expect(dial()).ToNot(HaveOccurred())
+		Eventually(server.clientNames).Should(Receive(Equal("fake-agent")))
+	})
+
+	It("fails to connect when configured CA does not validate server certificate", func() {
+		otherCA := generateTestCert("fake-other-ca", true, nil)
+		settingsService.Settings.MbusTLS.CA = otherCA.CertPEM
+
+		err := dial()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Performing NATS TLS handshake"))
+	})
+
+	It("fails to connect when CA cannot be parsed", func() {
+		settingsService.Settings.MbusTLS.CA = "fake-invalid-ca"
+
+		err := dial()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing mbus CA certificate"))
+	})
+
+	It("fails to connect when server does not send INFO", func() {
+		server.Close()
+		server = newFakeNatsTLSServer(generateTestCert("127.0.0.1", false, &ca).TLSCertificate(), ca, "-ERR 'fake-error'\r\n")
+		settingsService.Settings.Mbus = "nats://" + server.Addr()
+
+		err := dial()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected NATS server INFO"))
+	})
+
+	It("reconnects presenting certificates given via update_settings", func() {
+		err := handler.Start(func(req boshhandler.Request) (res boshhandler.Response) { return })
+		Expect(err).ToNot(HaveOccurred())
+		defer handler.Stop()
+
+		connProvider := client.ConnectedConnectionProvider()
+
+		_, err = connProvider.ProvideConnection()
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(server.clientNames).Should(Receive(Equal("fake-agent")))
+
+		rotatedClientCert := generateTestCert("fake-rotated-agent", false, &ca)
+		settingsService.Settings.MbusTLS = boshsettings.MbusTLS{
+			CA:          ca.CertPEM,
+			Certificate: rotatedClientCert.CertPEM,
+			PrivateKey:  rotatedClientCert.KeyPEM,
+		}
+
+		Expect(settingsService.Notify(boshsettings.FieldMbusTLS)).To(Succeed())
+		Eventually(server.disconnects).Should(Receive(Equal("fake-agent")))
+
+		conn, err := connProvider.ProvideConnection()
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(server.clientNames).Should(Receive(Equal("fake-rotated-agent")))
+
+		conn.Disconnect()
+	})
+})
@@ -0,0 +1,128 @@
+package mbus
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshmicro "github.com/cloudfoundry/bosh-agent/micro"
+	boshplatform "github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+// TransportDeps contains everything transports may need to build a handler.
+type TransportDeps struct {
+	SettingsService boshsettings.Service
+	Platform        boshplatform.Platform
+	DirProvider     boshdir.Provider
+	RequestVerifier boshhandler.RequestVerifier
+	TimeService     clock.Clock
+	UUIDGenerator   boshuuid.Generator
+	Logger          boshlog.Logger
+	AuditLogger     boshplatform.AuditLogger
+
+	// DefaultHTTPSTLS comes from agent config and
+	// is used for fields missing in settings
+	DefaultHTTPSTLS boshsettings.HTTPSTLS
+
+	// HTTPSLimits comes from agent config
+	HTTPSLimits boshdispatcher.Limits
+
+	Metrics *boshmetrics.Registry
+}
+
+// TransportFactory builds handler for mbus URL with a registered scheme.
+type TransportFactory func(mbusURL *url.URL, deps TransportDeps) (boshhandler.Handler, error)
+
+type TransportRegistry struct {
+	factories map[string]TransportFactory
+}
+
+func NewTransportRegistry() TransportRegistry {
+	return TransportRegistry{factories: map[string]TransportFactory{}}
+}
+
+// NewDefaultTransportRegistry includes all transports supported by the agent.
+func NewDefaultTransportRegistry() TransportRegistry {
+	registry := NewTransportRegistry()
+
+	registry.Register("nats", newNatsTransport)
+	registry.Register("https", newHTTPSTransport)
+
+	registry.Register("ws", NewWebsocketTransport)
+	registry.Register("wss", NewWebsocketTransport)
+
+	registry.Register("longpoll+http", NewLongPollTransport)
+	registry.Register("longpoll+https", NewLongPollTransport)
+
+	return registry
+}
+
+// Register replaces transport previously registered with the same scheme.
+func (r TransportRegistry) Register(scheme string, factory TransportFactory) {
+	r.factories[scheme] = factory
+}
+
+func (r TransportRegistry) Schemes() []string {
+	schemes := []string{}
+
+	for scheme := range r.factories {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+func (r TransportRegistry) Build(mbusURL *url.URL, deps TransportDeps) (boshhandler.Handler, error) {
+	factory, found := r.factories[mbusURL.Scheme]
+	if !found {
+		return nil, bosherr.Errorf("Message Bus Handler with scheme %s could not be found", mbusURL.Scheme)
+	}
+
+	return factory(mbusURL, deps)
+}
+
+func newNatsTransport(_ *url.URL, deps TransportDeps) (boshhandler.Handler, error) {
+	return NewNatsHandler(
+		deps.SettingsService,
+		yagnats.NewClient(),
+		deps.Logger,
+		deps.Platform,
+		deps.RequestVerifier,
+		deps.TimeService,
+		deps.UUIDGenerator,
+		deps.Metrics,
+	), nil
+}
+
+func newHTTPSTransport(mbusURL *url.URL, deps TransportDeps) (boshhandler.Handler, error) {
+	return boshmicro.NewHTTPSHandler(
+		mbusURL,
+		deps.Logger,
+		deps.Platform.GetFs(),
+		deps.DirProvider,
+		deps.AuditLogger,
+		deps.RequestVerifier,
+		httpsTLSSettingsFunc(deps),
+		deps.HTTPSLimits,
+	), nil
+}
+
+// httpsTLSSettingsFunc reads settings on every handshake so that certificates
+// given via update_settings are used without restarting the listener.
+func httpsTLSSettingsFunc(deps TransportDeps) func() boshsettings.HTTPSTLS {
+	return func() boshsettings.HTTPSTLS {
+		return deps.SettingsService.GetSettings().HTTPSTLS.Merge(deps.DefaultHTTPSTLS)
+	}
+}
@@ -0,0 +1,72 @@
+package mbus_test
+
+import (
+	gourl "net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	fakembus "github.com/cloudfoundry/bosh-agent/mbus/fakes"
+)
+
+var _ = Describe("TransportRegistry", func() {
+	var (
+		registry TransportRegistry
+	)
+
+	BeforeEach(func() {
+		registry = NewTransportRegistry()
+	})
+
+	Describe("NewDefaultTransportRegistry", func() {
+		It("supports all transports", func() {
+			Expect(NewDefaultTransportRegistry().Schemes()).To(Equal([]string{
+				"https",
+				"longpoll+http",
+				"longpoll+https",
+				"nats",
+				"ws",
+				"wss",
+			}))
+		})
+	})
+
+	Describe("Build", func() {
+		It("builds handler with factory registered for URL scheme", func() {
+			fakeHandler := fakembus.NewFakeHandler()
+
+			registry.Register("fake-scheme", func(mbusURL *gourl.URL, _ TransportDeps) (boshhandler.Handler, error) {
+				Expect(mbusURL.Host).To(Equal("fake-host"))
+				return fakeHandler, nil
+			})
+
+			handler, err := registry.Build(&gourl.URL{Scheme: "fake-scheme", Host: "fake-host"}, TransportDeps{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(handler).To(Equal(fakeHandler))
+		})
+
+		It("uses factory registered last for the same scheme", func() {
+			firstHandler := fakembus.NewFakeHandler()
+			secondHandler := fakembus.NewFakeHandler()
+
+			registry.Register("fake-scheme", func(*gourl.URL, TransportDeps) (boshhandler.Handler, error) {
+				return firstHandler, nil
+			})
+			registry.Register("fake-scheme", func(*gourl.URL, TransportDeps) (boshhandler.Handler, error) {
+				return secondHandler, nil
+			})
+
+			handler, err := registry.Build(&gourl.URL{Scheme: "fake-scheme"}, TransportDeps{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(handler == boshhandler.Handler(secondHandler)).To(BeTrue())
+		})
+
+		It("returns error when scheme is not registered", func() {
+			_, err := registry.Build(&gourl.URL{Scheme: "unknown-scheme"}, TransportDeps{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Message Bus Handler with scheme unknown-scheme could not be found"))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package mbus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pivotal-golang/clock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	websocketHandlerLogTag = "WebSocket Handler"
+
+	// websocketPingInterval keeps connection open through proxies
+	// and lets dead connections be noticed within websocketReadTimeout
+	websocketPingInterval = 30 * time.Second
+	websocketReadTimeout  = 3 * websocketPingInterval
+
+	websocketDialTimeout  = 10 * time.Second
+	websocketWriteTimeout = 10 * time.Second
+)
+
+// NewWebsocketTransport connects to ws(s)://<mbus host>/<mbus path>/agents/<agent id>.
+// Director sends requests as text messages and receives transport messages.
+func NewWebsocketTransport(mbusURL *url.URL, deps TransportDeps) (boshhandler.Handler, error) {
+	dial := func() (dialOutConn, error) {
+		tlsConfig, err := dialOutTLSConfig(deps.SettingsService, mbusURL)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Building TLS config")
+		}
+
+		agentURL := agentEndpointURL(mbusURL, deps.SettingsService.GetSettings().AgentID)
+
+		// Credentials are not allowed in WebSocket URLs
+		header := http.Header{}
+		if agentURL.User != nil {
+			password, _ := agentURL.User.Password()
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(agentURL.User.Username()+":"+password)))
+			agentURL.User = nil
+		}
+
+		dialer := websocket.Dialer{
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: websocketDialTimeout,
+		}
+
+		conn, resp, err := dialer.Dial(agentURL.String(), header)
+		if err != nil {
+			if resp != nil {
+				return nil, bosherr.WrapErrorf(err, "WebSocket handshake failed with status %d", resp.StatusCode)
+			}
+			return nil, bosherr.WrapError(err, "Dialing WebSocket")
+		}
+
+		return newWebsocketConn(conn, deps.TimeService), nil
+	}
+
+	return newDialOutHandler(dial, mbusURL, deps, websocketHandlerLogTag), nil
+}
+
+// agentEndpointURL returns copy of the URL with agent specific path
+func agentEndpointURL(mbusURL *url.URL, agentID string) *url.URL {
+	agentURL := *mbusURL
+	agentURL.Path = path.Join("/", mbusURL.Path, "agents", agentID)
+	return &agentURL
+}
+
+// websocketConn allows one reader and multiple writers;
+// gorilla connections only support one concurrent writer.
+type websocketConn struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebsocketConn(conn *websocket.Conn, timeService clock.Clock) *websocketConn {
+	wsConn := &websocketConn{conn: conn, stopCh: make(chan struct{})}
+
+	conn.SetReadLimit(dialOutMaxReceiveSize)
+
+	// Any frame including pongs shows that connection is alive
+	_ = conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	})
+
+	go wsConn.ping(timeService.NewTicker(websocketPingInterval))
+
+	return wsConn
+}
+
+func (c *websocketConn) Receive() ([][]byte, error) {
+	_, msg, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{msg}, nil
+}
+
+func (c *websocketConn) Send(msg transportMessage) error {
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling transport message")
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	err = c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	return c.conn.WriteMessage(websocket.TextMessage, bytes)
+}
+
+func (c *websocketConn) Close() error {
+	c.closeOnce.Do(func() { close(c.stopCh) })
+
+	// Close message is best effort since connection may already be broken
+	_ = c.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(websocketWriteTimeout),
+	)
+
+	return c.conn.Close()
+}
+
+func (c *websocketConn) ping(ticker clock.Ticker) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteTimeout))
+			if err != nil {
+				// Reading fails as well so connection will be reestablished
+				return
+			}
+		}
+	}
+}
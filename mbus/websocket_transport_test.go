@@ -0,0 +1,203 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	gourl "net/url"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/websocket"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
+	. "github.com/cloudfoundry/bosh-agent/mbus"
+	fakeplatform "github.com/cloudfoundry/bosh-agent/platform/fakes"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesettings "github.com/cloudfoundry/bosh-agent/settings/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type receivedTransportMessage struct {
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func init() {
+	Describe("websocket transport", func() {
+		var (
+			server      *httptest.Server
+			serverConns chan *websocket.Conn
+			paths       chan string
+			authHeaders chan string
+			rejecting   int32
+			rejected    int32
+
+			settingsService *fakesettings.FakeSettingsService
+			timeService     *fakeclock.FakeClock
+			handler         boshhandler.Handler
+		)
+
+		BeforeEach(func() {
+			// Handlers of previous test may still be running
+			conns, requestPaths, requestAuthHeaders := make(chan *websocket.Conn, 10), make(chan string, 10), make(chan string, 10)
+			serverConns, paths, authHeaders = conns, requestPaths, requestAuthHeaders
+
+			atomic.StoreInt32(&rejecting, 0)
+			atomic.StoreInt32(&rejected, 0)
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&rejecting) == 1 {
+					atomic.AddInt32(&rejected, 1)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				requestPaths <- r.URL.Path
+				requestAuthHeaders <- r.Header.Get("Authorization")
+
+				conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+				if err == nil {
+					conns <- conn
+				}
+			}))
+
+			mbusURL, err := gourl.Parse("ws://fake-user:fake-password@" + server.Listener.Addr().String() + "/director")
+			Expect(err).ToNot(HaveOccurred())
+
+			settingsService = &fakesettings.FakeSettingsService{
+				Settings: boshsettings.Settings{AgentID: "my-agent-id"},
+			}
+
+			timeService = fakeclock.NewFakeClock(time.Now())
+
+			handler, err = NewWebsocketTransport(mbusURL, TransportDeps{
+				SettingsService: settingsService,
+				RequestVerifier: boshhandler.NewRequestVerifier(settingsService, timeService),
+				TimeService:     timeService,
+				Logger:          boshlog.NewLogger(boshlog.LevelNone),
+				AuditLogger:     fakeplatform.NewFakeAuditLogger(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			handler.Stop()
+			server.CloseClientConnections()
+			server.Close()
+		})
+
+		readMessage := func(conn *websocket.Conn) receivedTransportMessage {
+			_, bytes, err := conn.ReadMessage()
+			Expect(err).ToNot(HaveOccurred())
+
+			var msg receivedTransportMessage
+			Expect(json.Unmarshal(bytes, &msg)).To(Succeed())
+
+			return msg
+		}
+
+		It("connects to agent endpoint using credentials from mbus URL", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(paths).Should(Receive(Equal("/director/agents/my-agent-id")))
+			Eventually(authHeaders).Should(Receive(Equal("Basic ZmFrZS11c2VyOmZha2UtcGFzc3dvcmQ=")))
+		})
+
+		It("returns error when director cannot be reached", func() {
+			atomic.StoreInt32(&rejecting, 1)
+
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Connecting"))
+		})
+
+		It("performs received requests and replies to reply_to subject", func() {
+			receivedRequests := make(chan boshhandler.Request, 1)
+
+			err := handler.Start(func(req boshhandler.Request) boshhandler.Response {
+				receivedRequests <- req
+				return boshhandler.NewValueResponse("expected value")
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			var conn *websocket.Conn
+			Eventually(serverConns).Should(Receive(&conn))
+
+			request := []byte(`{"method":"ping","arguments":[],"reply_to":"director.fake-reply"}`)
+			Expect(conn.WriteMessage(websocket.TextMessage, request)).To(Succeed())
+
+			msg := readMessage(conn)
+			Expect(msg.Subject).To(Equal("director.fake-reply"))
+			Expect(string(msg.Payload)).To(Equal(`{"value":"expected value"}`))
+
+			var req boshhandler.Request
+			Expect(receivedRequests).To(Receive(&req))
+			Expect(req.Method).To(Equal("ping"))
+			Expect(req.Caller).To(Equal(boshhandler.Caller{Kind: boshhandler.CallerDialOut, Name: "director.fake-reply"}))
+		})
+
+		It("sends messages with NATS subjects", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			var conn *websocket.Conn
+			Eventually(serverConns).Should(Receive(&conn))
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"job": "fake-job"})
+			Expect(err).ToNot(HaveOccurred())
+
+			msg := readMessage(conn)
+			Expect(msg.Subject).To(Equal("hm.agent.heartbeat.my-agent-id"))
+			Expect(string(msg.Payload)).To(Equal(`{"job":"fake-job"}`))
+		})
+
+		It("reconnects after losing connection and sends buffered heartbeats", func() {
+			err := handler.Start(func(boshhandler.Request) boshhandler.Response { return nil })
+			Expect(err).ToNot(HaveOccurred())
+
+			var conn *websocket.Conn
+			Eventually(serverConns).Should(Receive(&conn))
+
+			atomic.StoreInt32(&rejecting, 1)
+			Expect(conn.Close()).To(Succeed())
+
+			// Failing reconnect attempts show that the agent noticed lost connection
+			Eventually(func() int32 {
+				timeService.Increment(time.Minute)
+				return atomic.LoadInt32(&rejected)
+			}).Should(BeNumerically(">", 0))
+
+			err = handler.Send(boshhandler.HealthMonitor, boshhandler.Heartbeat, map[string]string{"job": "fake-job"})
+			Expect(err).ToNot(HaveOccurred())
+
+			atomic.StoreInt32(&rejecting, 0)
+
+			var newConn *websocket.Conn
+			Eventually(func() bool {
+				timeService.Increment(time.Minute)
+				select {
+				case newConn = <-serverConns:
+					return true
+				default:
+					return false
+				}
+			}).Should(BeTrue())
+
+			msg := readMessage(newConn)
+			Expect(msg.Subject).To(Equal("hm.agent.heartbeat.my-agent-id"))
+			Expect(string(msg.Payload)).To(Equal(`{"job":"fake-job"}`))
+		})
+
+		It("returns error when sending message that is not buffered while disconnected", func() {
+			err := handler.Send(boshhandler.HealthMonitor, boshhandler.Topic("fake-topic"), "fake-message")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not connected"))
+		})
+	})
+}
@@ -0,0 +1,302 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// DefaultDurationBuckets (in seconds) cover quick pings up to long compilations
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900}
+
+// CollectFunc updates metrics that are only computed when metrics are read
+type CollectFunc func()
+
+// Registry keeps metrics of the agent and writes them
+// in Prometheus text exposition format.
+type Registry struct {
+	lock       sync.Mutex
+	families   map[string]*family
+	collectors []CollectFunc
+}
+
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues  []string
+	value        float64
+	bucketCounts []uint64
+	count        uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Counter returns counter registered with the name or registers a new one
+// so that components created several times share their metrics.
+func (r *Registry) Counter(name, help string, labelNames ...string) Counter {
+	return Counter{registry: r, family: r.family(name, help, counterType, labelNames, nil)}
+}
+
+func (r *Registry) Gauge(name, help string, labelNames ...string) Gauge {
+	return Gauge{registry: r, family: r.family(name, help, gaugeType, labelNames, nil)}
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	sortedBuckets := append([]float64{}, buckets...)
+	sort.Float64s(sortedBuckets)
+
+	return Histogram{registry: r, family: r.family(name, help, histogramType, labelNames, sortedBuckets)}
+}
+
+// OnCollect registers function called every time metrics are written
+func (r *Registry) OnCollect(collectFunc CollectFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, collectFunc)
+}
+
+func (r *Registry) family(name, help, metricType string, labelNames []string, buckets []float64) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f, found := r.families[name]; found {
+		if f.metricType != metricType || len(f.labelNames) != len(labelNames) {
+			panic(fmt.Sprintf("Metric '%s' is already registered as %s with labels %v", name, f.metricType, f.labelNames))
+		}
+
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+
+	r.families[name] = f
+
+	return f
+}
+
+// seriesFor must be called with registry lock held
+func (f *family) seriesFor(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("Metric '%s' expects labels %v but got values %v", f.name, f.labelNames, labelValues))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	s, found := f.series[key]
+	if !found {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+
+		if f.metricType == histogramType {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+
+		f.series[key] = s
+	}
+
+	return s
+}
+
+type Counter struct {
+	registry *Registry
+	family   *family
+}
+
+func (c Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add ignores negative values since counters only go up
+func (c Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+
+	c.registry.lock.Lock()
+	defer c.registry.lock.Unlock()
+
+	c.family.seriesFor(labelValues).value += value
+}
+
+type Gauge struct {
+	registry *Registry
+	family   *family
+}
+
+func (g Gauge) Set(value float64, labelValues ...string) {
+	g.registry.lock.Lock()
+	defer g.registry.lock.Unlock()
+
+	g.family.seriesFor(labelValues).value = value
+}
+
+func (g Gauge) Add(value float64, labelValues ...string) {
+	g.registry.lock.Lock()
+	defer g.registry.lock.Unlock()
+
+	g.family.seriesFor(labelValues).value += value
+}
+
+// Reset removes all label combinations, e.g. before setting gauges
+// whose label values may disappear from one collection to another.
+func (g Gauge) Reset() {
+	g.registry.lock.Lock()
+	defer g.registry.lock.Unlock()
+
+	g.family.series = map[string]*series{}
+}
+
+type Histogram struct {
+	registry *Registry
+	family   *family
+}
+
+func (h Histogram) Observe(value float64, labelValues ...string) {
+	h.registry.lock.Lock()
+	defer h.registry.lock.Unlock()
+
+	s := h.family.seriesFor(labelValues)
+
+	for i, upperBound := range h.family.buckets {
+		if value <= upperBound {
+			s.bucketCounts[i]++
+		}
+	}
+
+	s.count++
+	s.value += value
+}
+
+// WriteText writes metrics sorted by name and label values
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.Lock()
+	collectors := append([]CollectFunc{}, r.collectors...)
+	r.lock.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, err := io.WriteString(w, r.families[name].text())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *family) text() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(&buf, "# TYPE %s %s\n", f.name, f.metricType)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+
+		if f.metricType != histogramType {
+			fmt.Fprintf(&buf, "%s%s %s\n", f.name, f.labels(s.labelValues, "", 0), formatValue(s.value))
+			continue
+		}
+
+		for i, upperBound := range f.buckets {
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", upperBound), s.bucketCounts[i])
+		}
+
+		fmt.Fprintf(&buf, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", math.Inf(1)), s.count)
+		fmt.Fprintf(&buf, "%s_sum%s %s\n", f.name, f.labels(s.labelValues, "", 0), formatValue(s.value))
+		fmt.Fprintf(&buf, "%s_count%s %d\n", f.name, f.labels(s.labelValues, "", 0), s.count)
+	}
+
+	return buf.String()
+}
+
+// labels includes le label of histogram buckets when leName is given
+func (f *family) labels(labelValues []string, leName string, le float64) string {
+	pairs := []string{}
+
+	for i, labelName := range f.labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labelName, escapeLabelValue(labelValues[i])))
+	}
+
+	if leName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, leName, formatValue(le)))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
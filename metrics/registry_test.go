@@ -0,0 +1,113 @@
+package metrics_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *Registry
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+	})
+
+	text := func() string {
+		buf := bytes.NewBufferString("")
+		Expect(registry.WriteText(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	It("writes counters and gauges sorted by name and label values", func() {
+		gauge := registry.Gauge("fake_gauge", "Fake gauge.")
+		counter := registry.Counter("fake_counter_total", "Fake counter.", "action", "state")
+
+		gauge.Set(3)
+		gauge.Add(-1.5)
+		counter.Inc("ping", "done")
+		counter.Add(2, "apply", "failed")
+		counter.Inc("apply", "failed")
+
+		Expect(text()).To(Equal(`# HELP fake_counter_total Fake counter.
+# TYPE fake_counter_total counter
+fake_counter_total{action="apply",state="failed"} 3
+fake_counter_total{action="ping",state="done"} 1
+# HELP fake_gauge Fake gauge.
+# TYPE fake_gauge gauge
+fake_gauge 1.5
+`))
+	})
+
+	It("ignores negative counter increments", func() {
+		counter := registry.Counter("fake_total", "Fake counter.")
+		counter.Add(2)
+		counter.Add(-1)
+
+		Expect(text()).To(ContainSubstring("fake_total 2\n"))
+	})
+
+	It("writes histogram buckets cumulatively", func() {
+		histogram := registry.Histogram("fake_seconds", "Fake histogram.", []float64{1, 0.1}, "action")
+		histogram.Observe(0.05, "ping")
+		histogram.Observe(0.5, "ping")
+		histogram.Observe(5, "ping")
+
+		Expect(text()).To(Equal(`# HELP fake_seconds Fake histogram.
+# TYPE fake_seconds histogram
+fake_seconds_bucket{action="ping",le="0.1"} 1
+fake_seconds_bucket{action="ping",le="1"} 2
+fake_seconds_bucket{action="ping",le="+Inf"} 3
+fake_seconds_sum{action="ping"} 5.55
+fake_seconds_count{action="ping"} 3
+`))
+	})
+
+	It("escapes help text and label values", func() {
+		gauge := registry.Gauge("fake_gauge", "Fake\\gauge\nhelp.", "name")
+		gauge.Set(1, "a\"b\\c\nd")
+
+		Expect(text()).To(ContainSubstring(`# HELP fake_gauge Fake\\gauge\nhelp.`))
+		Expect(text()).To(ContainSubstring(`fake_gauge{name="a\"b\\c\nd"} 1`))
+	})
+
+	It("returns already registered metric with the same name", func() {
+		registry.Counter("fake_total", "Fake counter.", "action").Inc("ping")
+		registry.Counter("fake_total", "Fake counter.", "action").Inc("ping")
+
+		Expect(text()).To(ContainSubstring(`fake_total{action="ping"} 2`))
+	})
+
+	It("panics when metric is registered again with a different type", func() {
+		registry.Counter("fake_metric", "Fake counter.")
+
+		Expect(func() { registry.Gauge("fake_metric", "Fake gauge.") }).To(Panic())
+	})
+
+	It("panics when label values do not match label names", func() {
+		counter := registry.Counter("fake_total", "Fake counter.", "action")
+
+		Expect(func() { counter.Inc() }).To(Panic())
+	})
+
+	It("runs collect funcs before writing metrics", func() {
+		gauge := registry.Gauge("fake_gauge", "Fake gauge.", "disk")
+		gauge.Set(1, "persistent")
+
+		calls := 0
+		registry.OnCollect(func() {
+			calls++
+			gauge.Reset()
+			gauge.Set(float64(calls), "system")
+		})
+
+		Expect(text()).To(ContainSubstring(`fake_gauge{disk="system"} 1`))
+		Expect(text()).To(ContainSubstring(`fake_gauge{disk="system"} 2`))
+		Expect(text()).ToNot(ContainSubstring(`disk="persistent"`))
+	})
+})
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	serverLogTag = "Metrics Server"
+
+	// Scrapes are small; timeouts keep slow clients from holding connections
+	serverReadTimeout  = 10 * time.Second
+	serverWriteTimeout = 30 * time.Second
+	serverIdleTimeout  = 60 * time.Second
+)
+
+// Options configure optional metrics listener; it is disabled without listen address.
+type Options struct {
+	// e.g. 127.0.0.1:9100
+	ListenAddress string
+
+	// AllowNonLoopback allows listening on addresses reachable from other hosts
+	// since metrics are served without authentication.
+	AllowNonLoopback bool
+}
+
+func (o Options) IsEnabled() bool {
+	return o.ListenAddress != ""
+}
+
+// Validate rejects listen address reachable from other hosts unless it is allowed
+func (o Options) Validate() error {
+	if o.AllowNonLoopback {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(o.ListenAddress)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing listen address %s", o.ListenAddress)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return bosherr.Errorf("Listen address %s is not a loopback address; set AllowNonLoopback to listen on it", o.ListenAddress)
+	}
+
+	return nil
+}
+
+type Server struct {
+	options  Options
+	registry *Registry
+	logger   boshlog.Logger
+
+	httpServer *http.Server
+	listener   net.Listener
+	lock       sync.Mutex
+}
+
+func NewServer(options Options, registry *Registry, logger boshlog.Logger) *Server {
+	return &Server{options: options, registry: registry, logger: logger}
+}
+
+// Start returns once server is listening and serves requests in the background
+func (s *Server) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := s.options.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating metrics options")
+	}
+
+	listener, err := net.Listen("tcp", s.options.ListenAddress)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Listening on %s", s.options.ListenAddress)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.metricsHandler)
+
+	httpServer := &http.Server{
+		Handler:      mux,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
+		IdleTimeout:  serverIdleTimeout,
+	}
+
+	s.httpServer = httpServer
+	s.listener = listener
+
+	go func() {
+		err := httpServer.Serve(listener)
+		if err != nil {
+			s.logger.Debug(serverLogTag, "Stopped serving: %s", err.Error())
+		}
+	}()
+
+	s.logger.Info(serverLogTag, "Serving metrics on %s", listener.Addr().String())
+
+	return nil
+}
+
+func (s *Server) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+func (s *Server) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.httpServer != nil {
+		err := s.httpServer.Close()
+		s.httpServer = nil
+		s.listener = nil
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	err := s.registry.WriteText(w)
+	if err != nil {
+		s.logger.Error(serverLogTag, "Writing metrics: %s", err.Error())
+	}
+}
@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/metrics"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		registry *Registry
+		server   *Server
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+		registry.Counter("fake_total", "Fake counter.").Inc()
+
+		server = NewServer(Options{ListenAddress: "127.0.0.1:0"}, registry, boshlog.NewLogger(boshlog.LevelNone))
+		Expect(server.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(server.Stop()).To(Succeed())
+	})
+
+	metricsURL := func() string {
+		return "http://" + server.Addr().String() + "/metrics"
+	}
+
+	It("serves metrics in text format", func() {
+		response, err := http.Get(metricsURL())
+		Expect(err).ToNot(HaveOccurred())
+
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(200))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/plain; version=0.0.4"))
+
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("fake_total 1\n"))
+	})
+
+	It("only allows GET requests", func() {
+		response, err := http.Post(metricsURL(), "text/plain", strings.NewReader(""))
+		Expect(err).ToNot(HaveOccurred())
+
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(405))
+	})
+
+	It("returns error when address cannot be listened on", func() {
+		otherServer := NewServer(Options{ListenAddress: server.Addr().String()}, registry, boshlog.NewLogger(boshlog.LevelNone))
+
+		err := otherServer.Start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Listening on"))
+	})
+
+	It("returns error when address is not a loopback address", func() {
+		otherServer := NewServer(Options{ListenAddress: "0.0.0.0:0"}, registry, boshlog.NewLogger(boshlog.LevelNone))
+
+		err := otherServer.Start()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Listen address 0.0.0.0:0 is not a loopback address"))
+	})
+
+	It("listens on address that is not a loopback address when allowed", func() {
+		otherServer := NewServer(Options{ListenAddress: "0.0.0.0:0", AllowNonLoopback: true}, registry, boshlog.NewLogger(boshlog.LevelNone))
+
+		Expect(otherServer.Start()).To(Succeed())
+		Expect(otherServer.Stop()).To(Succeed())
+	})
+})
+
+var _ = Describe("Options", func() {
+	It("allows loopback addresses", func() {
+		Expect(Options{ListenAddress: "127.0.0.1:9100"}.Validate()).To(Succeed())
+		Expect(Options{ListenAddress: "[::1]:9100"}.Validate()).To(Succeed())
+		Expect(Options{ListenAddress: "localhost:9100"}.Validate()).To(Succeed())
+	})
+
+	It("rejects addresses reachable from other hosts unless allowed", func() {
+		Expect(Options{ListenAddress: ":9100"}.Validate()).ToNot(Succeed())
+		Expect(Options{ListenAddress: "10.0.0.5:9100"}.Validate()).ToNot(Succeed())
+		Expect(Options{ListenAddress: "fake-host:9100"}.Validate()).ToNot(Succeed())
+
+		Expect(Options{ListenAddress: ":9100", AllowNonLoopback: true}.Validate()).To(Succeed())
+	})
+
+	It("returns error when address cannot be parsed", func() {
+		err := Options{ListenAddress: "fake-address"}.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Parsing listen address fake-address"))
+	})
+})
@@ -0,0 +1,323 @@
+package micro
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// blobUploadIDPattern prevents IDs from escaping uploads and store directories
+var blobUploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+type blobUploadRequestError struct {
+	Message string
+}
+
+func (e blobUploadRequestError) Error() string {
+	return e.Message
+}
+
+type blobUploadOffsetError struct {
+	Offset int64
+}
+
+func (e blobUploadOffsetError) Error() string {
+	return fmt.Sprintf("Upload continues at offset %d", e.Offset)
+}
+
+type blobUploadNotFoundError struct {
+	UploadID string
+}
+
+func (e blobUploadNotFoundError) Error() string {
+	return fmt.Sprintf("Upload '%s' not found", e.UploadID)
+}
+
+type blobChecksumError struct {
+	Err error
+}
+
+func (e blobChecksumError) Error() string {
+	return fmt.Sprintf("Verifying blob checksum: %s", e.Err.Error())
+}
+
+// blobUploads keeps chunks of resumable uploads outside of the store
+// so that blobs only become visible once their checksum is verified.
+type blobUploads struct {
+	fs         boshsys.FileSystem
+	clock      clock.Clock
+	uploadsDir string
+	storeDir   string
+
+	// expiry is time after last received chunk when upload is considered abandoned
+	expiry time.Duration
+
+	// locks serializes requests for the same upload
+	locks     map[string]*sync.Mutex
+	locksLock sync.Mutex
+}
+
+func newBlobUploads(fs boshsys.FileSystem, clock clock.Clock, uploadsDir, storeDir string, expiry time.Duration) *blobUploads {
+	return &blobUploads{
+		fs:         fs,
+		clock:      clock,
+		uploadsDir: uploadsDir,
+		storeDir:   storeDir,
+		expiry:     expiry,
+		locks:      map[string]*sync.Mutex{},
+	}
+}
+
+func newBlobUploadID() (string, error) {
+	bytes := make([]byte, 16)
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Generating upload ID")
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+func validateBlobUploadIDs(blobID, uploadID string) error {
+	if !blobUploadIDPattern.MatchString(blobID) {
+		return blobUploadRequestError{fmt.Sprintf("Invalid blob ID '%s'", blobID)}
+	}
+
+	if !blobUploadIDPattern.MatchString(uploadID) {
+		return blobUploadRequestError{fmt.Sprintf("Invalid upload ID '%s'", uploadID)}
+	}
+
+	return nil
+}
+
+// Offset returns number of bytes received so far
+func (u *blobUploads) Offset(blobID, uploadID string) (int64, error) {
+	err := validateBlobUploadIDs(blobID, uploadID)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock := u.lock(blobID, uploadID)
+	defer unlock()
+
+	return u.offset(blobID, uploadID)
+}
+
+// Append writes chunk at offset which must match number of bytes received so far.
+// Upload is created when offset is 0. Returned offset includes bytes written
+// before an error so that client can continue after interrupted request.
+func (u *blobUploads) Append(blobID, uploadID string, offset int64, chunk io.Reader) (int64, error) {
+	err := validateBlobUploadIDs(blobID, uploadID)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset == 0 {
+		err = u.RemoveExpired()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	unlock := u.lock(blobID, uploadID)
+	defer unlock()
+
+	currentOffset, err := u.offset(blobID, uploadID)
+	if err != nil {
+		if _, notFound := err.(blobUploadNotFoundError); !notFound || offset != 0 {
+			return 0, err
+		}
+	}
+
+	if offset != currentOffset {
+		return currentOffset, blobUploadOffsetError{Offset: currentOffset}
+	}
+
+	err = u.fs.MkdirAll(u.uploadsDir, os.FileMode(0700))
+	if err != nil {
+		return currentOffset, bosherr.WrapError(err, "Creating uploads directory")
+	}
+
+	file, err := u.fs.OpenFile(u.uploadPath(blobID, uploadID), os.O_WRONLY|os.O_CREATE, os.FileMode(0600))
+	if err != nil {
+		return currentOffset, bosherr.WrapError(err, "Opening upload file")
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return currentOffset, bosherr.WrapError(err, "Seeking upload file")
+	}
+
+	written, err := io.Copy(file, chunk)
+	if err != nil {
+		return currentOffset + written, bosherr.WrapError(err, "Writing upload file")
+	}
+
+	return currentOffset + written, nil
+}
+
+// Complete moves upload into the store if its contents match the digest.
+// Upload is discarded when checksum does not match since it cannot be fixed by resuming.
+func (u *blobUploads) Complete(blobID, uploadID string, digest boshcrypto.Digest) error {
+	err := validateBlobUploadIDs(blobID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	unlock := u.lock(blobID, uploadID)
+	defer unlock()
+
+	uploadPath := u.uploadPath(blobID, uploadID)
+
+	if !u.fs.FileExists(uploadPath) {
+		return blobUploadNotFoundError{UploadID: uploadID}
+	}
+
+	err = digest.VerifyFilePath(uploadPath, u.fs)
+	if err != nil {
+		_ = u.fs.RemoveAll(uploadPath)
+		return blobChecksumError{Err: err}
+	}
+
+	err = u.fs.MkdirAll(u.storeDir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating blob store directory")
+	}
+
+	err = u.fs.Rename(uploadPath, filepath.Join(u.storeDir, blobID))
+	if err != nil {
+		return bosherr.WrapError(err, "Moving upload into blob store")
+	}
+
+	u.forgetLock(blobID, uploadID)
+
+	return nil
+}
+
+// Discard removes upload that will not be continued
+func (u *blobUploads) Discard(blobID, uploadID string) error {
+	err := validateBlobUploadIDs(blobID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	unlock := u.lock(blobID, uploadID)
+	defer unlock()
+
+	u.forgetLock(blobID, uploadID)
+
+	return u.fs.RemoveAll(u.uploadPath(blobID, uploadID))
+}
+
+// RemoveExpired removes uploads that did not receive chunks within expiry
+// so that abandoned uploads do not fill up the disk.
+func (u *blobUploads) RemoveExpired() error {
+	uploadPaths, err := u.fs.Glob(filepath.Join(u.uploadsDir, "*~*"))
+	if err != nil {
+		return bosherr.WrapError(err, "Listing uploads")
+	}
+
+	expiredBefore := u.clock.Now().Add(-u.expiry)
+
+	for _, uploadPath := range uploadPaths {
+		err = u.removeIfExpired(uploadPath, expiredBefore)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *blobUploads) removeIfExpired(uploadPath string, expiredBefore time.Time) error {
+	unlock := u.lockPath(uploadPath)
+	defer unlock()
+
+	if !u.fs.FileExists(uploadPath) {
+		return nil
+	}
+
+	info, err := u.fs.Stat(uploadPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Checking upload file")
+	}
+
+	if !info.ModTime().Before(expiredBefore) {
+		return nil
+	}
+
+	err = u.fs.RemoveAll(uploadPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing expired upload '%s'", uploadPath)
+	}
+
+	u.forgetLockPath(uploadPath)
+
+	return nil
+}
+
+func (u *blobUploads) offset(blobID, uploadID string) (int64, error) {
+	uploadPath := u.uploadPath(blobID, uploadID)
+
+	if !u.fs.FileExists(uploadPath) {
+		return 0, blobUploadNotFoundError{UploadID: uploadID}
+	}
+
+	info, err := u.fs.Stat(uploadPath)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Checking upload file")
+	}
+
+	return info.Size(), nil
+}
+
+// uploadPath separates IDs with a character they cannot contain
+func (u *blobUploads) uploadPath(blobID, uploadID string) string {
+	return filepath.Join(u.uploadsDir, blobID+"~"+uploadID)
+}
+
+func (u *blobUploads) lock(blobID, uploadID string) func() {
+	return u.lockPath(u.uploadPath(blobID, uploadID))
+}
+
+func (u *blobUploads) lockPath(key string) func() {
+	u.locksLock.Lock()
+	lock, found := u.locks[key]
+	if !found {
+		lock = &sync.Mutex{}
+		u.locks[key] = lock
+	}
+	u.locksLock.Unlock()
+
+	lock.Lock()
+
+	return lock.Unlock
+}
+
+// forgetLock is called while holding the lock once upload is gone
+func (u *blobUploads) forgetLock(blobID, uploadID string) {
+	u.forgetLockPath(u.uploadPath(blobID, uploadID))
+}
+
+func (u *blobUploads) forgetLockPath(key string) {
+	u.locksLock.Lock()
+	delete(u.locks, key)
+	u.locksLock.Unlock()
+}
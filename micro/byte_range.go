@@ -0,0 +1,90 @@
+package micro
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type byteRangeUnsatisfiableError struct {
+	Header string
+}
+
+func (e byteRangeUnsatisfiableError) Error() string {
+	return fmt.Sprintf("Range '%s' cannot be satisfied", e.Header)
+}
+
+type byteRange struct {
+	Start  int64
+	Length int64
+}
+
+func (r byteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// parseByteRange supports a single range of bytes (e.g. 'bytes=0-99',
+// 'bytes=100-' or 'bytes=-100'); end of range is limited to the size.
+func parseByteRange(header string, size int64) (byteRange, error) {
+	const prefix = "bytes="
+
+	if !strings.HasPrefix(header, prefix) {
+		return byteRange{}, bosherr.Errorf("Unsupported range '%s'", header)
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+
+	if strings.Contains(spec, ",") {
+		return byteRange{}, bosherr.Errorf("Multiple ranges are not supported '%s'", header)
+	}
+
+	pieces := strings.SplitN(spec, "-", 2)
+	if len(pieces) != 2 {
+		return byteRange{}, bosherr.Errorf("Invalid range '%s'", header)
+	}
+
+	startStr, endStr := strings.TrimSpace(pieces[0]), strings.TrimSpace(pieces[1])
+
+	if startStr == "" {
+		suffixLength, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffixLength < 0 {
+			return byteRange{}, bosherr.Errorf("Invalid range '%s'", header)
+		}
+
+		if suffixLength == 0 || size == 0 {
+			return byteRange{}, byteRangeUnsatisfiableError{Header: header}
+		}
+
+		if suffixLength > size {
+			suffixLength = size
+		}
+
+		return byteRange{Start: size - suffixLength, Length: suffixLength}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, bosherr.Errorf("Invalid range '%s'", header)
+	}
+
+	end := size - 1
+
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, bosherr.Errorf("Invalid range '%s'", header)
+		}
+
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return byteRange{}, byteRangeUnsatisfiableError{Header: header}
+	}
+
+	return byteRange{Start: start, Length: end - start + 1}, nil
+}
@@ -0,0 +1,110 @@
+package micro
+
+import (
+	"sync"
+)
+
+// eventSubscriberBufferSize limits events queued for a slow subscriber;
+// events that do not fit are dropped for that subscriber only.
+const eventSubscriberBufferSize = 32
+
+type event struct {
+	ID    int64
+	Topic string
+	Data  []byte
+}
+
+// eventStream fans out published events to subscribers
+// and keeps the most recent events so that subscribers can catch up.
+type eventStream struct {
+	lock sync.Mutex
+
+	lastID int64
+	replay []event
+	start  int
+	count  int
+
+	subscribers map[chan event]struct{}
+	closed      bool
+}
+
+func newEventStream(replaySize int) *eventStream {
+	return &eventStream{
+		replay:      make([]event, replaySize),
+		subscribers: map[chan event]struct{}{},
+	}
+}
+
+func (s *eventStream) Publish(topic string, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastID++
+	evt := event{ID: s.lastID, Topic: topic, Data: data}
+
+	if len(s.replay) > 0 {
+		if s.count == len(s.replay) {
+			s.replay[s.start] = evt
+			s.start = (s.start + 1) % len(s.replay)
+		} else {
+			s.replay[(s.start+s.count)%len(s.replay)] = evt
+			s.count++
+		}
+	}
+
+	for events := range s.subscribers {
+		select {
+		case events <- evt:
+		default:
+		}
+	}
+}
+
+// Subscribe returns buffered events published after lastID and a channel
+// receiving subsequent events. Channel is closed when stream is closed.
+func (s *eventStream) Subscribe(lastID int64) ([]event, chan event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var missed []event
+
+	for i := 0; i < s.count; i++ {
+		evt := s.replay[(s.start+i)%len(s.replay)]
+		if evt.ID > lastID {
+			missed = append(missed, evt)
+		}
+	}
+
+	events := make(chan event, eventSubscriberBufferSize)
+
+	if s.closed {
+		close(events)
+	} else {
+		s.subscribers[events] = struct{}{}
+	}
+
+	return missed, events
+}
+
+func (s *eventStream) Unsubscribe(events chan event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.subscribers[events]; found {
+		delete(s.subscribers, events)
+		close(events)
+	}
+}
+
+// Close ends all subscriptions; events are still kept for replay
+func (s *eventStream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for events := range s.subscribers {
+		close(events)
+	}
+
+	s.subscribers = map[chan event]struct{}{}
+	s.closed = true
+}
@@ -2,23 +2,62 @@ package micro
 
 import (
 	"bufio"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"time"
+
+	"github.com/pivotal-golang/clock"
 
 	boshhandler "github.com/cloudfoundry/bosh-agent/handler"
 	boshdispatcher "github.com/cloudfoundry/bosh-agent/httpsdispatcher"
 	"github.com/cloudfoundry/bosh-agent/platform"
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
 	"github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-const httpsHandlerLogTag = "https_handler"
+const (
+	httpsHandlerLogTag = "https_handler"
+
+	// blobChecksumHeader contains digest (e.g. sha256:<hex>) that uploaded blob must match
+	blobChecksumHeader = "X-Checksum"
+
+	// blobUploadOffsetHeader contains number of bytes of an upload received so far
+	blobUploadOffsetHeader = "Upload-Offset"
+
+	// blobUploadExpiry is time after which uploads without new chunks are removed
+	blobUploadExpiry = 24 * time.Hour
+
+	// eventReplaySize is number of recent events sent to clients when they connect
+	eventReplaySize = 100
+
+	// eventKeepAliveInterval keeps idle event streams from being closed by proxies
+	eventKeepAliveInterval = 15 * time.Second
+)
+
+// defaultLimits apply to routes without configured limits;
+// blobs are not limited since packages may be arbitrarily large.
+var defaultLimits = boshdispatcher.Limits{
+	MaxBodySizes: map[string]int64{
+		"/agent": 10 * 1024 * 1024,
+	},
+}
+
+type blobUploadStatus struct {
+	UploadID string `json:"upload_id,omitempty"`
+	Offset   int64  `json:"offset"`
+	Error    string `json:"error,omitempty"`
+}
 
 type HTTPSHandler struct {
 	parsedURL   *url.URL
@@ -27,6 +66,12 @@ type HTTPSHandler struct {
 	fs          boshsys.FileSystem
 	dirProvider boshdir.Provider
 	auditLogger platform.AuditLogger
+
+	requestVerifier boshhandler.RequestVerifier
+	tlsSettingsFunc func() boshsettings.HTTPSTLS
+
+	blobUploads *blobUploads
+	events      *eventStream
 }
 
 func NewHTTPSHandler(
@@ -35,13 +80,29 @@ func NewHTTPSHandler(
 	fs boshsys.FileSystem,
 	dirProvider boshdir.Provider,
 	auditLogger platform.AuditLogger,
+	requestVerifier boshhandler.RequestVerifier,
+	tlsSettingsFunc func() boshsettings.HTTPSTLS,
+	limits boshdispatcher.Limits,
 ) (handler HTTPSHandler) {
 	handler.parsedURL = parsedURL
 	handler.logger = logger
 	handler.fs = fs
 	handler.dirProvider = dirProvider
-	handler.dispatcher = boshdispatcher.NewHTTPSDispatcher(parsedURL, logger)
+	handler.dispatcher = boshdispatcher.NewHTTPSDispatcher(
+		parsedURL,
+		logger,
+		fs,
+		tlsSettingsFunc,
+		limits.Merge(defaultLimits),
+		func(r *http.Request, statusCode int, reason string) {
+			handler.generateCEFLog(r, statusCode, reason)
+		},
+	)
 	handler.auditLogger = auditLogger
+	handler.requestVerifier = requestVerifier
+	handler.tlsSettingsFunc = tlsSettingsFunc
+	handler.blobUploads = newBlobUploads(fs, clock.NewClock(), dirProvider.MicroUploadsDir(), dirProvider.MicroStore(), blobUploadExpiry)
+	handler.events = newEventStream(eventReplaySize)
 	return
 }
 
@@ -56,10 +117,12 @@ func (h HTTPSHandler) Run(handlerFunc boshhandler.Func) error {
 func (h HTTPSHandler) Start(handlerFunc boshhandler.Func) error {
 	h.dispatcher.AddRoute("/agent", h.agentHandler(handlerFunc))
 	h.dispatcher.AddRoute("/blobs/", h.blobsHandler())
+	h.dispatcher.AddStreamingRoute("/events", h.eventsHandler())
 	return h.dispatcher.Start()
 }
 
 func (h HTTPSHandler) Stop() {
+	h.events.Close()
 	h.dispatcher.Stop()
 }
 
@@ -67,7 +130,22 @@ func (h HTTPSHandler) RegisterAdditionalFunc(handlerFunc boshhandler.Func) {
 	panic("HTTPSHandler does not support registering additional handler funcs")
 }
 
+// Send publishes health monitor messages to /events subscribers;
+// director messages are not sent since director receives responses directly.
 func (h HTTPSHandler) Send(target boshhandler.Target, topic boshhandler.Topic, message interface{}) error {
+	if target != boshhandler.HealthMonitor {
+		return nil
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling message")
+	}
+
+	h.logger.Info(httpsHandlerLogTag, "Publishing %s message '%s'", target, topic)
+
+	h.events.Publish(string(topic), data)
+
 	return nil
 }
 
@@ -84,15 +162,40 @@ func (h HTTPSHandler) agentHandler(handlerFunc boshhandler.Func) func(http.Respo
 		if err != nil {
 			err = bosherr.WrapError(err, "Reading http body")
 			h.logger.Error(httpsHandlerLogTag, err.Error())
-			w.WriteHeader(400)
-			h.generateCEFLog(r, 400, "")
+
+			status := 400
+			if boshdispatcher.IsRequestBodyTooLarge(err) {
+				status = 413
+			}
+
+			w.WriteHeader(status)
+			h.generateCEFLog(r, status, "")
 
 			return
 		}
 
+		// HTTPS callers are identified by the transport even when requests are signed
+		requestJSON, _, err := h.requestVerifier.Verify(rawJSONPayload)
+		if err != nil {
+			h.rejectRequest(w, r, requestJSON, err)
+			return
+		}
+
+		var actionNotAllowed bool
+
 		respBytes, _, err := boshhandler.PerformHandlerWithJSON(
-			rawJSONPayload,
-			handlerFunc,
+			requestJSON,
+			// Actions of callers are authorized by handler func according to authorization policy
+			func(req boshhandler.Request) boshhandler.Response {
+				req.Caller = h.caller(r)
+
+				resp := handlerFunc(req)
+				if boshhandler.IsActionNotAllowed(resp) {
+					actionNotAllowed = true
+				}
+
+				return resp
+			},
 			boshhandler.UnlimitedResponseLength,
 			h.logger,
 		)
@@ -106,12 +209,54 @@ func (h HTTPSHandler) agentHandler(handlerFunc boshhandler.Func) func(http.Respo
 			return
 		}
 
+		status := 200
+		if actionNotAllowed {
+			status = 403
+			w.WriteHeader(status)
+		}
+
 		_, err = w.Write(respBytes)
 		if err != nil {
 			err = bosherr.WrapError(err, "Writing response")
 			h.logger.Error(httpsHandlerLogTag, err.Error())
 		}
-		h.generateCEFLog(r, 200, "")
+		h.generateCEFLog(r, status, "")
+	}
+}
+
+// caller prefers verified client certificate over basic auth user
+func (h HTTPSHandler) caller(r *http.Request) boshhandler.Caller {
+	commonName, verified := boshdispatcher.ClientCommonName(r)
+	if verified {
+		return boshhandler.Caller{Kind: boshhandler.CallerMTLS, Name: commonName}
+	}
+
+	username, _, _ := r.BasicAuth()
+
+	return boshhandler.Caller{Kind: boshhandler.CallerHTTPSUser, Name: username}
+}
+
+func (h HTTPSHandler) rejectRequest(w http.ResponseWriter, r *http.Request, requestJSON []byte, rejectErr error) {
+	h.logger.Error(httpsHandlerLogTag, "Rejecting request: %s", rejectErr.Error())
+
+	respBytes, _, err := boshhandler.PerformHandlerWithJSON(
+		requestJSON,
+		func(boshhandler.Request) boshhandler.Response { return boshhandler.NewExceptionResponse(rejectErr) },
+		boshhandler.UnlimitedResponseLength,
+		h.logger,
+	)
+	if err != nil {
+		respBytes = nil
+	}
+
+	w.WriteHeader(401)
+	h.generateCEFLog(r, 401, rejectErr.Error())
+
+	if len(respBytes) > 0 {
+		_, err = w.Write(respBytes)
+		if err != nil {
+			h.logger.Error(httpsHandlerLogTag, "Failed to write response body: %s", err.Error())
+		}
 	}
 }
 
@@ -120,8 +265,16 @@ func (h HTTPSHandler) blobsHandler() (blobsHandler func(http.ResponseWriter, *ht
 		switch r.Method {
 		case "GET":
 			h.getBlob(w, r)
+		case "HEAD":
+			h.headBlob(w, r)
 		case "PUT":
-			h.putBlob(w, r)
+			if r.URL.Query().Get("upload_id") != "" {
+				h.putBlobChunk(w, r)
+			} else if r.Header.Get(blobChecksumHeader) != "" {
+				h.putVerifiedBlob(w, r)
+			} else {
+				h.putBlob(w, r)
+			}
 		default:
 			w.WriteHeader(404)
 			h.generateCEFLog(r, 404, "")
@@ -137,8 +290,13 @@ func (h HTTPSHandler) putBlob(w http.ResponseWriter, r *http.Request) {
 
 	err := blobManager.Write(blobID, r.Body)
 	if err != nil {
-		w.WriteHeader(500)
-		h.generateCEFLog(r, 500, "")
+		status := 500
+		if boshdispatcher.IsRequestBodyTooLarge(err) {
+			status = 413
+		}
+
+		w.WriteHeader(status)
+		h.generateCEFLog(r, status, "")
 		if _, wErr := w.Write([]byte(err.Error())); wErr != nil {
 			h.logger.Error(httpsHandlerLogTag, "Failed to write response body: %s", wErr.Error())
 		}
@@ -164,6 +322,15 @@ func (h HTTPSHandler) getBlob(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			_ = file.Close()
 		}()
+
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		if r.Header.Get("Range") != "" {
+			statusCode = h.getBlobRange(w, r, file)
+			h.generateCEFLog(r, statusCode, "")
+			return
+		}
+
 		reader := bufio.NewReader(file)
 		if _, wErr := io.Copy(w, reader); wErr != nil {
 			h.logger.Error(httpsHandlerLogTag, "Failed to write response body: %s", wErr.Error())
@@ -173,6 +340,290 @@ func (h HTTPSHandler) getBlob(w http.ResponseWriter, r *http.Request) {
 	h.generateCEFLog(r, statusCode, "")
 }
 
+// getBlobRange serves whole blob when range cannot be parsed
+// or when multiple ranges are requested as allowed by RFC 7233
+func (h HTTPSHandler) getBlobRange(w http.ResponseWriter, r *http.Request, file boshsys.File) int {
+	info, err := file.Stat()
+	if err != nil {
+		h.logger.Error(httpsHandlerLogTag, "Failed to stat blob: %s", err.Error())
+		w.WriteHeader(500)
+		return 500
+	}
+
+	size := info.Size()
+
+	blobRange, err := parseByteRange(r.Header.Get("Range"), size)
+	if err != nil {
+		if _, unsatisfiable := err.(byteRangeUnsatisfiableError); unsatisfiable {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(416)
+			return 416
+		}
+
+		blobRange = byteRange{Start: 0, Length: size}
+	}
+
+	statusCode := 200
+	if blobRange.Length != size {
+		statusCode = 206
+		w.Header().Set("Content-Range", blobRange.ContentRange(size))
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(blobRange.Length, 10))
+	w.WriteHeader(statusCode)
+
+	if _, wErr := io.Copy(w, io.NewSectionReader(file, blobRange.Start, blobRange.Length)); wErr != nil {
+		h.logger.Error(httpsHandlerLogTag, "Failed to write response body: %s", wErr.Error())
+	}
+
+	return statusCode
+}
+
+// headBlob reports size of a blob or offset of an upload when upload_id is given
+func (h HTTPSHandler) headBlob(w http.ResponseWriter, r *http.Request) {
+	_, blobID := path.Split(r.URL.Path)
+
+	if uploadID := r.URL.Query().Get("upload_id"); uploadID != "" {
+		offset, err := h.blobUploads.Offset(blobID, uploadID)
+		if err != nil {
+			statusCode := h.blobUploadErrorStatus(err)
+			w.WriteHeader(statusCode)
+			h.generateCEFLog(r, statusCode, "")
+			return
+		}
+
+		w.Header().Set(blobUploadOffsetHeader, strconv.FormatInt(offset, 10))
+		w.WriteHeader(200)
+		h.generateCEFLog(r, 200, "")
+		return
+	}
+
+	blobManager := blobstore.NewBlobManager(h.fs, h.dirProvider.MicroStore())
+
+	file, err, statusCode := blobManager.Fetch(blobID)
+	if err != nil {
+		w.WriteHeader(statusCode)
+		h.generateCEFLog(r, statusCode, "")
+		return
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		w.WriteHeader(500)
+		h.generateCEFLog(r, 500, "")
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(200)
+	h.generateCEFLog(r, 200, "")
+}
+
+// putBlobChunk appends request body to an upload at offset given in query.
+// Upload is completed when checksum header is given; otherwise 202 is returned
+// so that client sends the next chunk.
+func (h HTTPSHandler) putBlobChunk(w http.ResponseWriter, r *http.Request) {
+	_, blobID := path.Split(r.URL.Path)
+	uploadID := r.URL.Query().Get("upload_id")
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.writeBlobUploadError(w, r, uploadID, 0, blobUploadRequestError{fmt.Sprintf("Invalid offset '%s'", r.URL.Query().Get("offset"))})
+		return
+	}
+
+	var digest boshcrypto.Digest
+
+	if checksum := r.Header.Get(blobChecksumHeader); checksum != "" {
+		digest, err = boshcrypto.ParseMultipleDigest(checksum)
+		if err != nil {
+			h.writeBlobUploadError(w, r, uploadID, offset, blobUploadRequestError{"Parsing checksum: " + err.Error()})
+			return
+		}
+	}
+
+	offset, err = h.blobUploads.Append(blobID, uploadID, offset, r.Body)
+	if err != nil {
+		h.writeBlobUploadError(w, r, uploadID, offset, err)
+		return
+	}
+
+	if digest == nil {
+		h.writeBlobUploadStatus(w, r, 202, uploadID, offset, "")
+		return
+	}
+
+	err = h.blobUploads.Complete(blobID, uploadID, digest)
+	if err != nil {
+		h.writeBlobUploadError(w, r, uploadID, offset, err)
+		return
+	}
+
+	h.writeBlobUploadStatus(w, r, 201, uploadID, offset, "")
+}
+
+// putVerifiedBlob only stores blob when its contents match checksum header
+func (h HTTPSHandler) putVerifiedBlob(w http.ResponseWriter, r *http.Request) {
+	_, blobID := path.Split(r.URL.Path)
+
+	digest, err := boshcrypto.ParseMultipleDigest(r.Header.Get(blobChecksumHeader))
+	if err != nil {
+		h.writeBlobUploadError(w, r, "", 0, blobUploadRequestError{"Parsing checksum: " + err.Error()})
+		return
+	}
+
+	uploadID, err := newBlobUploadID()
+	if err != nil {
+		h.writeBlobUploadError(w, r, "", 0, err)
+		return
+	}
+
+	offset, err := h.blobUploads.Append(blobID, uploadID, 0, r.Body)
+	if err == nil {
+		err = h.blobUploads.Complete(blobID, uploadID, digest)
+	}
+
+	if err != nil {
+		_ = h.blobUploads.Discard(blobID, uploadID)
+		h.writeBlobUploadError(w, r, "", offset, err)
+		return
+	}
+
+	w.WriteHeader(201)
+	h.generateCEFLog(r, 201, "")
+}
+
+func (h HTTPSHandler) writeBlobUploadError(w http.ResponseWriter, r *http.Request, uploadID string, offset int64, err error) {
+	h.logger.Error(httpsHandlerLogTag, "Failed to upload blob: %s", err.Error())
+
+	if offsetErr, ok := err.(blobUploadOffsetError); ok {
+		offset = offsetErr.Offset
+	}
+
+	h.writeBlobUploadStatus(w, r, h.blobUploadErrorStatus(err), uploadID, offset, err.Error())
+}
+
+func (h HTTPSHandler) blobUploadErrorStatus(err error) int {
+	if boshdispatcher.IsRequestBodyTooLarge(err) {
+		return 413
+	}
+
+	switch err.(type) {
+	case blobUploadOffsetError:
+		return 409
+	case blobUploadNotFoundError:
+		return 404
+	case blobChecksumError:
+		return 422
+	case blobUploadRequestError:
+		return 400
+	}
+
+	return 500
+}
+
+func (h HTTPSHandler) writeBlobUploadStatus(w http.ResponseWriter, r *http.Request, statusCode int, uploadID string, offset int64, errMsg string) {
+	respBytes, err := json.Marshal(blobUploadStatus{UploadID: uploadID, Offset: offset, Error: errMsg})
+	if err != nil {
+		respBytes = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(blobUploadOffsetHeader, strconv.FormatInt(offset, 10))
+	w.WriteHeader(statusCode)
+	h.generateCEFLog(r, statusCode, "")
+
+	if _, wErr := w.Write(respBytes); wErr != nil {
+		h.logger.Error(httpsHandlerLogTag, "Failed to write response body: %s", wErr.Error())
+	}
+}
+
+// eventsHandler streams health monitor messages as Server-Sent Events.
+// Events after Last-Event-ID (or all buffered events) are sent first.
+func (h HTTPSHandler) eventsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(404)
+			h.generateCEFLog(r, 404, "")
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			h.logger.Error(httpsHandlerLogTag, "Streaming events is not supported by response writer")
+			w.WriteHeader(500)
+			h.generateCEFLog(r, 500, "")
+			return
+		}
+
+		var lastID int64
+
+		if lastIDStr := r.Header.Get("Last-Event-ID"); lastIDStr != "" {
+			var err error
+
+			lastID, err = strconv.ParseInt(lastIDStr, 10, 64)
+			if err != nil {
+				w.WriteHeader(400)
+				h.generateCEFLog(r, 400, "")
+				return
+			}
+		}
+
+		missed, events := h.events.Subscribe(lastID)
+		defer h.events.Unsubscribe(events)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(200)
+		h.generateCEFLog(r, 200, "")
+
+		for _, evt := range missed {
+			if !h.writeEvent(w, evt) {
+				return
+			}
+		}
+
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case evt, ok := <-events:
+				if !ok || !h.writeEvent(w, evt) {
+					return
+				}
+
+			case <-keepAlive.C:
+				if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+
+			case <-r.Context().Done():
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func (h HTTPSHandler) writeEvent(w io.Writer, evt event) bool {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Topic, evt.Data)
+	if err != nil {
+		h.logger.Debug(httpsHandlerLogTag, "Failed to write event: %s", err.Error())
+		return false
+	}
+
+	return true
+}
+
 func (h HTTPSHandler) generateCEFLog(r *http.Request, respStatusCode int, respJSON string) {
 	cef := boshhandler.NewCommonEventFormat()
 
@@ -1,9 +1,14 @@
 package micro_test
 
 import (
+	"io/ioutil"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
 	"testing"
 )
 
@@ -11,3 +16,16 @@ func TestMicro(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Micro Suite")
 }
+
+// newFakeFileSystemWithCertificate contains default certificate used by https listener
+func newFakeFileSystemWithCertificate() *fakesys.FakeFileSystem {
+	fs := fakesys.NewFakeFileSystem()
+
+	for _, path := range []string{boshsettings.DefaultHTTPSCertificatePath, boshsettings.DefaultHTTPSPrivateKeyPath} {
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.WriteFile(path, contents)).To(Succeed())
+	}
+
+	return fs
+}
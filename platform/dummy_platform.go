@@ -155,6 +155,10 @@ func (p dummyPlatform) SetupNetworking(networks boshsettings.Networks) (err erro
 	return
 }
 
+func (p dummyPlatform) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	return []string{}, nil
+}
+
 func (p dummyPlatform) GetConfiguredNetworkInterfaces() (interfaces []string, err error) {
 	return
 }
@@ -270,6 +274,31 @@ func (p dummyPlatform) MountPersistentDisk(diskSettings boshsettings.DiskSetting
 	return p.fs.WriteFile(p.mountsPath(), mountsJSON)
 }
 
+func (p dummyPlatform) PlanMountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (PersistentDiskMountPlan, error) {
+	plan := PersistentDiskMountPlan{MountPoint: mountPoint}
+
+	_, isMountPoint, err := p.IsMountPoint(mountPoint)
+	if err != nil {
+		return plan, err
+	}
+
+	if isMountPoint {
+		currentManagedDisk, err := p.fs.ReadFileString(filepath.Join(p.dirProvider.BoshDir(), "managed_disk_settings.json"))
+		if err != nil {
+			return plan, err
+		}
+
+		if diskSettings.ID == currentManagedDisk {
+			plan.AlreadyMounted = true
+			return plan, nil
+		}
+
+		plan.MountPoint = p.dirProvider.StoreMigrationDir()
+	}
+
+	return plan, nil
+}
+
 func (p dummyPlatform) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error) {
 	mounts, err := p.existingMounts()
 	if err != nil {
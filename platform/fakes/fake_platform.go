@@ -84,11 +84,20 @@ type FakePlatform struct {
 	SetupNetworkingNetworks boshsettings.Networks
 	SetupNetworkingErr      error
 
+	PlanNetworkingNetworks boshsettings.Networks
+	PlanNetworkingPaths    []string
+	PlanNetworkingErr      error
+
 	MountPersistentDiskCalled     bool
 	MountPersistentDiskSettings   boshsettings.DiskSettings
 	MountPersistentDiskMountPoint string
 	MountPersistentDiskErr        error
 
+	PlanMountPersistentDiskSettings   boshsettings.DiskSettings
+	PlanMountPersistentDiskMountPoint string
+	PlanMountPersistentDiskPlan       platform.PersistentDiskMountPlan
+	PlanMountPersistentDiskErr        error
+
 	UnmountPersistentDiskDidUnmount bool
 	UnmountPersistentDiskSettings   boshsettings.DiskSettings
 
@@ -278,6 +287,11 @@ func (p *FakePlatform) SetupHostname(hostname string) (err error) {
 	return
 }
 
+func (p *FakePlatform) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	p.PlanNetworkingNetworks = networks
+	return p.PlanNetworkingPaths, p.PlanNetworkingErr
+}
+
 func (p *FakePlatform) SetupNetworking(networks boshsettings.Networks) error {
 	p.SetupNetworkingCalled = true
 	p.SetupNetworkingNetworks = networks
@@ -343,6 +357,12 @@ func (p *FakePlatform) SetupLoggingAndAuditing() error {
 	return p.SetupLoggingAndAuditingErr
 }
 
+func (p *FakePlatform) PlanMountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (platform.PersistentDiskMountPlan, error) {
+	p.PlanMountPersistentDiskSettings = diskSettings
+	p.PlanMountPersistentDiskMountPoint = mountPoint
+	return p.PlanMountPersistentDiskPlan, p.PlanMountPersistentDiskErr
+}
+
 func (p *FakePlatform) MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (err error) {
 	p.MountPersistentDiskCalled = true
 	p.MountPersistentDiskSettings = diskSettings
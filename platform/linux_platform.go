@@ -47,6 +47,9 @@ const (
 
 	minRootEphemeralSpaceInBytes = uint64(1024 * 1024 * 1024)
 	maxFdiskPartitionSize        = uint64(2 * 1024 * 1024 * 1024 * 1024)
+
+	fdiskPartitionerName  = "fdisk"
+	partedPartitionerName = "parted"
 )
 
 type LinuxOptions struct {
@@ -216,6 +219,10 @@ func (p linux) SetupNetworking(networks boshsettings.Networks) (err error) {
 	return p.netManager.SetupNetworking(networks, nil)
 }
 
+func (p linux) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	return p.netManager.PlanNetworking(networks)
+}
+
 func (p linux) GetConfiguredNetworkInterfaces() ([]string, error) {
 	return p.netManager.GetConfiguredNetworkInterfaces()
 }
@@ -998,14 +1005,82 @@ func (p linux) changeTmpDirPermissions(path string) error {
 func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountPoint string) error {
 	p.logger.Debug(logTag, "Mounting persistent disk %+v at %s", diskSetting, mountPoint)
 
+	plan, err := p.PlanMountPersistentDisk(diskSetting, mountPoint)
+	if err != nil {
+		return err
+	}
+
+	if plan.AlreadyMounted {
+		p.logger.Info(logTag, "device: %s is already mounted on %s, skipping mounting", plan.PartitionPath, plan.MountPoint)
+		return nil
+	}
+
+	err = p.fs.MkdirAll(plan.MountPoint, persistentDiskPermissions)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory %s", plan.MountPoint)
+	}
+
+	if plan.Partitioner != "" {
+		partitions := []boshdisk.Partition{
+			{Type: boshdisk.PartitionTypeLinux},
+		}
+
+		if plan.Partitioner == partedPartitionerName {
+			err = p.diskManager.GetPartedPartitioner().Partition(plan.DevicePath, partitions)
+		} else {
+			err = p.diskManager.GetPartitioner().Partition(plan.DevicePath, partitions)
+		}
+
+		if err != nil {
+			return bosherr.WrapError(err, "Partitioning disk")
+		}
+
+		err = p.diskManager.GetFormatter().Format(plan.PartitionPath, plan.FileSystemType)
+		if err != nil {
+			return bosherr.WrapError(err, fmt.Sprintf("Formatting partition with %s", diskSetting.FileSystemType))
+		}
+	}
+
+	var mountOptions []string
+	if len(plan.MountOptions) > 0 {
+		mountOptions = []string{"-o", strings.Join(plan.MountOptions, ",")}
+	}
+
+	err = p.diskManager.GetMounter().Mount(plan.MountedPath, plan.MountPoint, mountOptions...)
+
+	if err != nil {
+		return bosherr.WrapError(err, "Mounting partition")
+	}
+
+	managedSettingsPath := filepath.Join(p.dirProvider.BoshDir(), "managed_disk_settings.json")
+
+	err = p.fs.WriteFileString(managedSettingsPath, diskSetting.ID)
+
+	if err != nil {
+		return bosherr.WrapError(err, "Writing managed_disk_settings.json")
+	}
+
+	return nil
+}
+
+func (p linux) PlanMountPersistentDisk(diskSetting boshsettings.DiskSettings, mountPoint string) (PersistentDiskMountPlan, error) {
+	// Disks must not be formatted or mounted without settings CPI asked for
+	if diskSetting.Encryption != nil {
+		return PersistentDiskMountPlan{}, bosherr.Errorf("Disk encryption '%s' is not supported", diskSetting.Encryption.Type)
+	}
+
+	if len(diskSetting.FileSystemOptions) > 0 {
+		return PersistentDiskMountPlan{}, bosherr.Error("Filesystem options are not supported")
+	}
+
 	realPath, _, err := p.devicePathResolver.GetRealDevicePath(diskSetting)
 	if err != nil {
-		return bosherr.WrapError(err, "Getting real device path")
+		return PersistentDiskMountPlan{}, bosherr.WrapError(err, "Getting real device path")
 	}
 
 	devicePath, isMountPoint, err := p.IsMountPoint(mountPoint)
 	if err != nil {
-		return bosherr.WrapError(err, "Checking mount point")
+		return PersistentDiskMountPlan{}, bosherr.WrapError(err, "Checking mount point")
 	}
 	p.logger.Info(logTag, "realPath = %s, devicePath = %s, isMountPoint = %s", realPath, devicePath, isMountPoint)
 
@@ -1014,39 +1089,35 @@ func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountP
 		partitionPath = realPath + "-part1"
 	}
 
+	plan := PersistentDiskMountPlan{
+		DevicePath:   realPath,
+		MountedPath:  realPath,
+		MountPoint:   mountPoint,
+		MountOptions: diskSetting.MountOptions,
+	}
+
 	if isMountPoint {
 		if partitionPath == devicePath {
-			p.logger.Info(logTag, "device: %s is already mounted on %s, skipping mounting", devicePath, mountPoint)
-			return nil
+			plan.PartitionPath = partitionPath
+			plan.MountedPath = partitionPath
+			plan.AlreadyMounted = true
+			return plan, nil
 		}
 
-		mountPoint = p.dirProvider.StoreMigrationDir()
-	}
-
-	err = p.fs.MkdirAll(mountPoint, persistentDiskPermissions)
-	if err != nil {
-		return bosherr.WrapErrorf(err, "Creating directory %s", mountPoint)
+		plan.MountPoint = p.dirProvider.StoreMigrationDir()
 	}
 
 	if !p.options.UsePreformattedPersistentDisk {
-		partitions := []boshdisk.Partition{
-			{Type: boshdisk.PartitionTypeLinux},
-		}
-
 		diskSize, err := p.diskManager.GetDiskUtil(realPath).GetBlockDeviceSize()
 
 		p.logger.Debug(logTag, "Persistent disk size to be partitioned is: %d, and error is: %v", diskSize, err)
 
 		if err != nil || diskSize < maxFdiskPartitionSize {
 			p.logger.Debug(logTag, "fdisk partitioner was chosen")
-			err = p.diskManager.GetPartitioner().Partition(realPath, partitions)
+			plan.Partitioner = fdiskPartitionerName
 		} else {
 			p.logger.Debug(logTag, "parted partitioner was chosen")
-			err = p.diskManager.GetPartedPartitioner().Partition(realPath, partitions)
-		}
-
-		if err != nil {
-			return bosherr.WrapError(err, "Partitioning disk")
+			plan.Partitioner = partedPartitionerName
 		}
 
 		persistentDiskFS := diskSetting.FileSystemType
@@ -1055,32 +1126,15 @@ func (p linux) MountPersistentDisk(diskSetting boshsettings.DiskSettings, mountP
 		case boshdisk.FileSystemDefault:
 			persistentDiskFS = boshdisk.FileSystemExt4
 		default:
-			return bosherr.Error(fmt.Sprintf(`The filesystem type "%s" is not supported`, diskSetting.FileSystemType))
-		}
-
-		err = p.diskManager.GetFormatter().Format(partitionPath, persistentDiskFS)
-		if err != nil {
-			return bosherr.WrapError(err, fmt.Sprintf("Formatting partition with %s", diskSetting.FileSystemType))
+			return PersistentDiskMountPlan{}, bosherr.Error(fmt.Sprintf(`The filesystem type "%s" is not supported`, diskSetting.FileSystemType))
 		}
 
-		realPath = partitionPath
-	}
-
-	err = p.diskManager.GetMounter().Mount(realPath, mountPoint)
-
-	if err != nil {
-		return bosherr.WrapError(err, "Mounting partition")
-	}
-
-	managedSettingsPath := filepath.Join(p.dirProvider.BoshDir(), "managed_disk_settings.json")
-
-	err = p.fs.WriteFileString(managedSettingsPath, diskSetting.ID)
-
-	if err != nil {
-		return bosherr.WrapError(err, "Writing managed_disk_settings.json")
+		plan.PartitionPath = partitionPath
+		plan.FileSystemType = persistentDiskFS
+		plan.MountedPath = partitionPath
 	}
 
-	return nil
+	return plan, nil
 }
 
 func (p linux) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (bool, error) {
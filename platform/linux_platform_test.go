@@ -2102,6 +2102,26 @@ Number  Start   End     Size    File system  Name             Flags
 	})
 
 	Describe("MountPersistentDisk", func() {
+		It("mounts disk with mount options given by CPI", func() {
+			err := platform.MountPersistentDisk(
+				boshsettings.DiskSettings{ID: "fake-unique-id", Path: "fake-volume-id", MountOptions: []string{"noatime", "nodiratime"}},
+				"/mnt/point",
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			mounter := diskManager.FakeMounter
+			Expect(mounter.MountMountOptions).To(Equal([][]string{{"-o", "noatime,nodiratime"}}))
+		})
+
+		It("does not mount disk that is to be encrypted", func() {
+			err := platform.MountPersistentDisk(
+				boshsettings.DiskSettings{ID: "fake-unique-id", Path: "fake-volume-id", Encryption: &boshsettings.DiskEncryption{Type: "luks"}},
+				"/mnt/point",
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(diskManager.FakeMounter.MountCalled).To(BeFalse())
+		})
+
 		act := func() error {
 			return platform.MountPersistentDisk(
 				boshsettings.DiskSettings{ID: "fake-unique-id", Path: "fake-volume-id"},
@@ -2449,6 +2469,120 @@ Number  Start   End     Size    File system  Name             Flags
 		})
 	})
 
+	Describe("PlanMountPersistentDisk", func() {
+		act := func() (PersistentDiskMountPlan, error) {
+			return platform.PlanMountPersistentDisk(
+				boshsettings.DiskSettings{ID: "fake-unique-id", Path: "fake-volume-id"},
+				"/mnt/point",
+			)
+		}
+
+		var mounter *fakedisk.FakeMounter
+
+		BeforeEach(func() {
+			mounter = diskManager.FakeMounter
+			devicePathResolver.RealDevicePath = "fake-real-device-path"
+		})
+
+		It("returns partition, file system and mount without changing the disk", func() {
+			plan, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan).To(Equal(PersistentDiskMountPlan{
+				DevicePath:     "fake-real-device-path",
+				PartitionPath:  "fake-real-device-path1",
+				Partitioner:    "fdisk",
+				FileSystemType: boshdisk.FileSystemExt4,
+				MountedPath:    "fake-real-device-path1",
+				MountPoint:     "/mnt/point",
+			}))
+
+			Expect(fs.FileExists("/mnt/point")).To(BeFalse())
+			Expect(diskManager.PartitionerCalled).To(BeFalse())
+			Expect(diskManager.FakeFormatter.FormatPartitionPaths).To(BeEmpty())
+			Expect(mounter.MountCalled).To(BeFalse())
+		})
+
+		It("plans parted partitioner for disks of at least 2 terabytes", func() {
+			diskManager.FakeDiskUtil.GetBlockDeviceSizeSize = uint64(2199023255552)
+
+			plan, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.Partitioner).To(Equal("parted"))
+		})
+
+		It("plans mounting the store migration directory when a different device is mounted", func() {
+			mounter.IsMountPointResult = true
+			mounter.IsMountPointPartitionPath = "another-device"
+
+			plan, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.MountPoint).To(Equal("/fake-dir/store_migration_target"))
+			Expect(plan.AlreadyMounted).To(BeFalse())
+		})
+
+		It("reports disk as already mounted when its partition is mounted", func() {
+			mounter.IsMountPointResult = true
+			mounter.IsMountPointPartitionPath = "fake-real-device-path1"
+
+			plan, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.AlreadyMounted).To(BeTrue())
+			Expect(plan.Partitioner).To(BeEmpty())
+		})
+
+		Context("when UsePreformattedPersistentDisk set to true", func() {
+			BeforeEach(func() {
+				options.UsePreformattedPersistentDisk = true
+			})
+
+			It("plans mounting the device without partitioning", func() {
+				plan, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(plan).To(Equal(PersistentDiskMountPlan{
+					DevicePath:  "fake-real-device-path",
+					MountedPath: "fake-real-device-path",
+					MountPoint:  "/mnt/point",
+				}))
+			})
+		})
+
+		It("plans mounting with mount options given by CPI", func() {
+			plan, err := platform.PlanMountPersistentDisk(
+				boshsettings.DiskSettings{Path: "fake-volume-id", MountOptions: []string{"noatime", "nodiratime"}},
+				"/mnt/point",
+			)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan.MountOptions).To(Equal([]string{"noatime", "nodiratime"}))
+		})
+
+		It("returns error when disk is to be encrypted", func() {
+			_, err := platform.PlanMountPersistentDisk(
+				boshsettings.DiskSettings{Path: "fake-volume-id", Encryption: &boshsettings.DiskEncryption{Type: "luks"}},
+				"/mnt/point",
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Disk encryption 'luks' is not supported"))
+		})
+
+		It("returns error when disk is to be formatted with filesystem options", func() {
+			_, err := platform.PlanMountPersistentDisk(
+				boshsettings.DiskSettings{Path: "fake-volume-id", FileSystemOptions: []string{"-E", "lazy_itable_init=1"}},
+				"/mnt/point",
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Filesystem options are not supported"))
+		})
+
+		It("returns error when file system type is not supported", func() {
+			_, err := platform.PlanMountPersistentDisk(
+				boshsettings.DiskSettings{Path: "fake-volume-id", FileSystemType: "blahblah"},
+				"/mnt/point",
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`The filesystem type "blahblah" is not supported`))
+		})
+	})
+
 	Describe("UnmountPersistentDisk", func() {
 		act := func() (bool, error) {
 			return platform.UnmountPersistentDisk(boshsettings.DiskSettings{Path: "fake-device-path"})
@@ -50,7 +50,7 @@ func NewCentosNetManager(
 	}
 }
 
-func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+func (net centosNetManager) computeNetworkConfig(networks boshsettings.Networks) ([]StaticInterfaceConfiguration, []DHCPInterfaceConfiguration, []string, error) {
 	nonVipNetworks := boshsettings.Networks{}
 	for networkName, networkSettings := range networks {
 		if networkSettings.IsVIP() {
@@ -61,11 +61,19 @@ func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errC
 
 	staticInterfaceConfigurations, dhcpInterfaceConfigurations, err := net.buildInterfaces(nonVipNetworks)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	dnsNetwork, _ := nonVipNetworks.DefaultNetworkFor("dns")
-	dnsServers := dnsNetwork.DNS
+
+	return staticInterfaceConfigurations, dhcpInterfaceConfigurations, dnsNetwork.DNS, nil
+}
+
+func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errCh chan error) error {
+	staticInterfaceConfigurations, dhcpInterfaceConfigurations, dnsServers, err := net.computeNetworkConfig(networks)
+	if err != nil {
+		return err
+	}
 
 	interfacesChanged, err := net.writeNetworkInterfaces(dhcpInterfaceConfigurations, staticInterfaceConfigurations, dnsServers)
 	if err != nil {
@@ -101,6 +109,29 @@ func (net centosNetManager) SetupNetworking(networks boshsettings.Networks, errC
 	return nil
 }
 
+func (net centosNetManager) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	staticInterfaceConfigurations, dhcpInterfaceConfigurations, dnsServers, err := net.computeNetworkConfig(networks)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := net.renderNetworkInterfaces(dhcpInterfaceConfigurations, staticInterfaceConfigurations, dnsServers)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dhcpInterfaceConfigurations) > 0 {
+		dhcpConfig, err := net.renderDHCPConfiguration(dnsServers)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, renderedFile{Path: centosDHCPConfigPath, Contents: dhcpConfig})
+	}
+
+	return changedFilePaths(net.fs, files), nil
+}
+
 func (net centosNetManager) GetConfiguredNetworkInterfaces() ([]string, error) {
 	interfaces := []string{}
 
@@ -157,25 +188,19 @@ func ifcfgFilePath(name string) string {
 	return path.Join("/etc/sysconfig/network-scripts", "ifcfg-"+name)
 }
 
-func (net centosNetManager) writeIfcfgFile(name string, t *template.Template, config interface{}) (bool, error) {
+func (net centosNetManager) renderIfcfgFile(name string, t *template.Template, config interface{}) (renderedFile, error) {
 	buffer := bytes.NewBuffer([]byte{})
 
 	err := t.Execute(buffer, config)
 	if err != nil {
-		return false, bosherr.WrapErrorf(err, "Generating '%s' config from template", name)
-	}
-
-	filePath := ifcfgFilePath(name)
-	changed, err := net.fs.ConvergeFileContents(filePath, buffer.Bytes())
-	if err != nil {
-		return false, bosherr.WrapErrorf(err, "Writing config to '%s'", filePath)
+		return renderedFile{}, bosherr.WrapErrorf(err, "Generating '%s' config from template", name)
 	}
 
-	return changed, nil
+	return renderedFile{Path: ifcfgFilePath(name), Contents: buffer.Bytes()}, nil
 }
 
-func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations []DHCPInterfaceConfiguration, staticInterfaceConfigurations []StaticInterfaceConfiguration, dnsServers []string) (bool, error) {
-	anyInterfaceChanged := false
+func (net centosNetManager) renderNetworkInterfaces(dhcpInterfaceConfigurations []DHCPInterfaceConfiguration, staticInterfaceConfigurations []StaticInterfaceConfiguration, dnsServers []string) ([]renderedFile, error) {
+	files := []renderedFile{}
 
 	staticConfig := centosStaticIfcfg{}
 	staticConfig.DNSServers = newDNSConfigs(dnsServers)
@@ -184,12 +209,12 @@ func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations [
 	for i := range staticInterfaceConfigurations {
 		staticConfig.StaticInterfaceConfiguration = &staticInterfaceConfigurations[i]
 
-		changed, err := net.writeIfcfgFile(staticConfig.StaticInterfaceConfiguration.Name, staticTemplate, staticConfig)
+		file, err := net.renderIfcfgFile(staticConfig.StaticInterfaceConfiguration.Name, staticTemplate, staticConfig)
 		if err != nil {
-			return false, bosherr.WrapError(err, "Writing static config")
+			return nil, bosherr.WrapError(err, "Writing static config")
 		}
 
-		anyInterfaceChanged = anyInterfaceChanged || changed
+		files = append(files, file)
 	}
 
 	dhcpTemplate := template.Must(template.New("ifcfg").Parse(centosDHCPIfcfgTemplate))
@@ -197,9 +222,29 @@ func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations [
 	for i := range dhcpInterfaceConfigurations {
 		config := &dhcpInterfaceConfigurations[i]
 
-		changed, err := net.writeIfcfgFile(config.Name, dhcpTemplate, config)
+		file, err := net.renderIfcfgFile(config.Name, dhcpTemplate, config)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Writing dhcp config")
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func (net centosNetManager) writeNetworkInterfaces(dhcpInterfaceConfigurations []DHCPInterfaceConfiguration, staticInterfaceConfigurations []StaticInterfaceConfiguration, dnsServers []string) (bool, error) {
+	anyInterfaceChanged := false
+
+	files, err := net.renderNetworkInterfaces(dhcpInterfaceConfigurations, staticInterfaceConfigurations, dnsServers)
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range files {
+		changed, err := net.fs.ConvergeFileContents(file.Path, file.Contents)
 		if err != nil {
-			return false, bosherr.WrapError(err, "Writing dhcp config")
+			return false, bosherr.WrapErrorf(err, "Writing config to '%s'", file.Path)
 		}
 
 		anyInterfaceChanged = anyInterfaceChanged || changed
@@ -256,7 +301,9 @@ request subnet-mask, broadcast-address, time-offset, routers,
 prepend domain-name-servers {{ . }};{{ end }}
 `
 
-func (net centosNetManager) writeDHCPConfiguration(dnsServers []string, dhcpInterfaceConfigurations []DHCPInterfaceConfiguration) (bool, error) {
+const centosDHCPConfigPath = "/etc/dhcp/dhclient.conf"
+
+func (net centosNetManager) renderDHCPConfiguration(dnsServers []string) ([]byte, error) {
 	buffer := bytes.NewBuffer([]byte{})
 	t := template.Must(template.New("dhcp-config").Parse(centosDHCPConfigTemplate))
 
@@ -265,10 +312,20 @@ func (net centosNetManager) writeDHCPConfiguration(dnsServers []string, dhcpInte
 	dnsServersList := strings.Join(dnsServers, ", ")
 	err := t.Execute(buffer, dnsServersList)
 	if err != nil {
-		return false, bosherr.WrapError(err, "Generating config from template")
+		return nil, bosherr.WrapError(err, "Generating config from template")
 	}
-	dhclientConfigFile := "/etc/dhcp/dhclient.conf"
-	changed, err := net.fs.ConvergeFileContents(dhclientConfigFile, buffer.Bytes())
+
+	return buffer.Bytes(), nil
+}
+
+func (net centosNetManager) writeDHCPConfiguration(dnsServers []string, dhcpInterfaceConfigurations []DHCPInterfaceConfiguration) (bool, error) {
+	contents, err := net.renderDHCPConfiguration(dnsServers)
+	if err != nil {
+		return false, err
+	}
+
+	dhclientConfigFile := centosDHCPConfigPath
+	changed, err := net.fs.ConvergeFileContents(dhclientConfigFile, contents)
 
 	if err != nil {
 		return changed, bosherr.WrapErrorf(err, "Writing to %s", dhclientConfigFile)
@@ -619,4 +619,47 @@ DNS1=8.8.8.8
 			})
 		})
 	})
+
+	Describe("PlanNetworking", func() {
+		var networks boshsettings.Networks
+
+		BeforeEach(func() {
+			networks = boshsettings.Networks{
+				"static-network": boshsettings.Network{
+					Type:    "manual",
+					IP:      "1.2.3.4",
+					Netmask: "255.255.255.0",
+					Gateway: "3.4.5.6",
+					Mac:     "fake-static-mac-address",
+				},
+				"dhcp-network": boshsettings.Network{
+					Type: "dynamic",
+					Mac:  "fake-dhcp-mac-address",
+				},
+			}
+
+			fs.SetGlob("/sys/class/net/*", []string{
+				writeNetworkDevice("ethstatic", "fake-static-mac-address", true),
+				writeNetworkDevice("ethdhcp", "fake-dhcp-mac-address", true),
+			})
+		})
+
+		It("returns ifcfg and dhclient configuration paths that would change without writing them", func() {
+			fs.WriteFileString("/etc/sysconfig/network-scripts/ifcfg-ethdhcp", `DEVICE=ethdhcp
+BOOTPROTO=dhcp
+ONBOOT=yes
+PEERDNS=yes
+`)
+
+			paths, err := netManager.PlanNetworking(networks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				"/etc/sysconfig/network-scripts/ifcfg-ethstatic",
+				"/etc/dhcp/dhclient.conf",
+			}))
+
+			Expect(fs.FileExists("/etc/sysconfig/network-scripts/ifcfg-ethstatic")).To(BeFalse())
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+	})
 }
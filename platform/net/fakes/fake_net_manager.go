@@ -10,6 +10,10 @@ type FakeManager struct {
 	SetupNetworkingNetworks boshsettings.Networks
 	SetupNetworkingErr      error
 
+	PlanNetworkingNetworks boshsettings.Networks
+	PlanNetworkingPaths    []string
+	PlanNetworkingErr      error
+
 	GetConfiguredNetworkInterfacesInterfaces []string
 	GetConfiguredNetworkInterfacesErr        error
 
@@ -22,6 +26,11 @@ func (net *FakeManager) SetupNetworking(networks boshsettings.Networks, errCh ch
 	return net.SetupNetworkingErr
 }
 
+func (net *FakeManager) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	net.PlanNetworkingNetworks = networks
+	return net.PlanNetworkingPaths, net.PlanNetworkingErr
+}
+
 func (net *FakeManager) GetConfiguredNetworkInterfaces() ([]string, error) {
 	return net.GetConfiguredNetworkInterfacesInterfaces, net.GetConfiguredNetworkInterfacesErr
 }
@@ -10,6 +10,10 @@ type Manager interface {
 	// upon completion of background network reconfiguration (e.g. arping).
 	SetupNetworking(networks boshsettings.Networks, errCh chan error) error
 
+	// PlanNetworking returns paths of configuration files SetupNetworking
+	// would rewrite without changing any of them.
+	PlanNetworking(networks boshsettings.Networks) ([]string, error)
+
 	// Returns the list of interfaces that have configurations for them present
 	GetConfiguredNetworkInterfaces() ([]string, error)
 }
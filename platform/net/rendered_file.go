@@ -0,0 +1,27 @@
+package net
+
+import (
+	"bytes"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// renderedFile is a configuration file net managers converge to the contents
+type renderedFile struct {
+	Path     string
+	Contents []byte
+}
+
+// changedFilePaths returns paths of files that are missing or differ from rendered contents
+func changedFilePaths(fs boshsys.FileSystem, files []renderedFile) []string {
+	paths := []string{}
+
+	for _, file := range files {
+		currentContents, err := fs.ReadFile(file.Path)
+		if err != nil || !bytes.Equal(currentContents, file.Contents) {
+			paths = append(paths, file.Path)
+		}
+	}
+
+	return paths
+}
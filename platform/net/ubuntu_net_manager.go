@@ -135,6 +135,45 @@ func (net UbuntuNetManager) SetupNetworking(networks boshsettings.Networks, errC
 	return nil
 }
 
+func (net UbuntuNetManager) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	if networks.IsPreconfigured() {
+		dnsNetwork, _ := networks.DefaultNetworkFor("dns")
+		if len(dnsNetwork.DNS) == 0 {
+			return []string{}, nil
+		}
+
+		resolvConf, err := net.renderResolvConf(dnsNetwork.DNS)
+		if err != nil {
+			return nil, err
+		}
+
+		return changedFilePaths(net.fs, []renderedFile{{Path: ubuntuResolvConfBasePath, Contents: resolvConf}}), nil
+	}
+
+	staticConfigs, dhcpConfigs, dnsServers, err := net.ComputeNetworkConfig(networks)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Computing network configuration")
+	}
+
+	interfaces, err := net.renderNetworkInterfaces(dhcpConfigs, staticConfigs, dnsServers)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []renderedFile{{Path: ubuntuNetworkInterfacesPath, Contents: interfaces}}
+
+	if len(dhcpConfigs) > 0 {
+		dhcpConfig, err := net.renderDHCPConfiguration(dnsServers)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, renderedFile{Path: ubuntuDHCPConfigPath, Contents: dhcpConfig})
+	}
+
+	return changedFilePaths(net.fs, files), nil
+}
+
 func (net UbuntuNetManager) GetConfiguredNetworkInterfaces() ([]string, error) {
 	interfaces := []string{}
 
@@ -238,7 +277,13 @@ func (net UbuntuNetManager) restartNetworkingInterfaces(ifaceNames []string) {
 	}
 }
 
-func (net UbuntuNetManager) writeDHCPConfiguration(dnsServers []string) (bool, error) {
+const (
+	ubuntuDHCPConfigPath        = "/etc/dhcp/dhclient.conf"
+	ubuntuNetworkInterfacesPath = "/etc/network/interfaces"
+	ubuntuResolvConfBasePath    = "/etc/resolvconf/resolv.conf.d/base"
+)
+
+func (net UbuntuNetManager) renderDHCPConfiguration(dnsServers []string) ([]byte, error) {
 	buffer := bytes.NewBuffer([]byte{})
 	t := template.Must(template.New("dhcp-config").Parse(ubuntuDHCPConfigTemplate))
 
@@ -247,13 +292,22 @@ func (net UbuntuNetManager) writeDHCPConfiguration(dnsServers []string) (bool, e
 	dnsServersList := strings.Join(dnsServers, ", ")
 	err := t.Execute(buffer, dnsServersList)
 	if err != nil {
-		return false, bosherr.WrapError(err, "Generating config from template")
+		return nil, bosherr.WrapError(err, "Generating config from template")
 	}
-	dhclientConfigFile := "/etc/dhcp/dhclient.conf"
-	changed, err := net.fs.ConvergeFileContents(dhclientConfigFile, buffer.Bytes())
 
+	return buffer.Bytes(), nil
+}
+
+func (net UbuntuNetManager) writeDHCPConfiguration(dnsServers []string) (bool, error) {
+	contents, err := net.renderDHCPConfiguration(dnsServers)
 	if err != nil {
-		return changed, bosherr.WrapErrorf(err, "Writing to %s", dhclientConfigFile)
+		return false, err
+	}
+
+	changed, err := net.fs.ConvergeFileContents(ubuntuDHCPConfigPath, contents)
+
+	if err != nil {
+		return changed, bosherr.WrapErrorf(err, "Writing to %s", ubuntuDHCPConfigPath)
 	}
 
 	return changed, nil
@@ -266,7 +320,7 @@ type networkInterfaceConfig struct {
 	HasDNSNameServers bool
 }
 
-func (net UbuntuNetManager) writeNetworkInterfaces(dhcpConfigs DHCPInterfaceConfigurations, staticConfigs StaticInterfaceConfigurations, dnsServers []string) (bool, error) {
+func (net UbuntuNetManager) renderNetworkInterfaces(dhcpConfigs DHCPInterfaceConfigurations, staticConfigs StaticInterfaceConfigurations, dnsServers []string) ([]byte, error) {
 	sort.Stable(dhcpConfigs)
 	sort.Stable(staticConfigs)
 
@@ -283,10 +337,19 @@ func (net UbuntuNetManager) writeNetworkInterfaces(dhcpConfigs DHCPInterfaceConf
 
 	err := t.Execute(buffer, networkInterfaceValues)
 	if err != nil {
-		return false, bosherr.WrapError(err, "Generating config from template")
+		return nil, bosherr.WrapError(err, "Generating config from template")
 	}
 
-	changed, err := net.fs.ConvergeFileContents("/etc/network/interfaces", buffer.Bytes())
+	return buffer.Bytes(), nil
+}
+
+func (net UbuntuNetManager) writeNetworkInterfaces(dhcpConfigs DHCPInterfaceConfigurations, staticConfigs StaticInterfaceConfigurations, dnsServers []string) (bool, error) {
+	contents, err := net.renderNetworkInterfaces(dhcpConfigs, staticConfigs, dnsServers)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := net.fs.ConvergeFileContents(ubuntuNetworkInterfacesPath, contents)
 	if err != nil {
 		return changed, bosherr.WrapError(err, "Writing to /etc/network/interfaces")
 	}
@@ -350,7 +413,7 @@ func (net UbuntuNetManager) ifaceNames(dhcpConfigs DHCPInterfaceConfigurations,
 	return ifaceNames
 }
 
-func (net UbuntuNetManager) writeResolvConf(networks boshsettings.Networks) error {
+func (net UbuntuNetManager) renderResolvConf(dnsServers []string) ([]byte, error) {
 	buffer := bytes.NewBuffer([]byte{})
 
 	const resolvConfTemplate = `# Generated by bosh-agent
@@ -359,23 +422,32 @@ func (net UbuntuNetManager) writeResolvConf(networks boshsettings.Networks) erro
 
 	t := template.Must(template.New("resolv-conf").Parse(resolvConfTemplate))
 
-	// Keep DNS servers in the order specified by the network
-	dnsNetwork, _ := networks.DefaultNetworkFor("dns")
-
 	type dnsConfigArg struct {
 		DNSServers []string
 	}
 
-	dnsServersArg := dnsConfigArg{dnsNetwork.DNS}
+	dnsServersArg := dnsConfigArg{dnsServers}
 
 	err := t.Execute(buffer, dnsServersArg)
 	if err != nil {
-		return bosherr.WrapError(err, "Generating DNS config from template")
+		return nil, bosherr.WrapError(err, "Generating DNS config from template")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (net UbuntuNetManager) writeResolvConf(networks boshsettings.Networks) error {
+	// Keep DNS servers in the order specified by the network
+	dnsNetwork, _ := networks.DefaultNetworkFor("dns")
+
+	contents, err := net.renderResolvConf(dnsNetwork.DNS)
+	if err != nil {
+		return err
 	}
 
 	if len(dnsNetwork.DNS) > 0 {
 		// Write out base so that releases may overwrite head
-		err = net.fs.WriteFile("/etc/resolvconf/resolv.conf.d/base", buffer.Bytes())
+		err = net.fs.WriteFile(ubuntuResolvConfBasePath, contents)
 		if err != nil {
 			return bosherr.WrapError(err, "Writing to /etc/resolvconf/resolv.conf.d/base")
 		}
@@ -389,7 +461,7 @@ func (net UbuntuNetManager) writeResolvConf(networks boshsettings.Networks) erro
 		}
 
 		if targetPath == "/etc/resolv.conf" {
-			err := net.fs.CopyFile("/etc/resolv.conf", "/etc/resolvconf/resolv.conf.d/base")
+			err := net.fs.CopyFile("/etc/resolv.conf", ubuntuResolvConfBasePath)
 			if err != nil {
 				return bosherr.WrapError(err, "Copying /etc/resolv.conf for backwards compat")
 			}
@@ -914,4 +914,81 @@ iface ethstatic inet static
 			})
 		})
 	})
+
+	Describe("PlanNetworking", func() {
+		var (
+			dhcpNetwork   boshsettings.Network
+			staticNetwork boshsettings.Network
+			networks      boshsettings.Networks
+		)
+
+		BeforeEach(func() {
+			dhcpNetwork = boshsettings.Network{
+				Type:    "dynamic",
+				Default: []string{"dns"},
+				DNS:     []string{"8.8.8.8", "9.9.9.9"},
+				Mac:     "fake-dhcp-mac-address",
+			}
+			staticNetwork = boshsettings.Network{
+				Type:    "manual",
+				IP:      "1.2.3.4",
+				Default: []string{"gateway"},
+				Netmask: "255.255.255.0",
+				Gateway: "3.4.5.6",
+				Mac:     "fake-static-mac-address",
+			}
+			networks = boshsettings.Networks{"dhcp-network": dhcpNetwork, "static-network": staticNetwork}
+
+			stubInterfaces(map[string]boshsettings.Network{"ethdhcp": dhcpNetwork, "ethstatic": staticNetwork})
+		})
+
+		It("returns interfaces and dhclient configuration paths without writing them", func() {
+			paths, err := netManager.PlanNetworking(networks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(Equal([]string{"/etc/network/interfaces", "/etc/dhcp/dhclient.conf"}))
+
+			Expect(fs.FileExists("/etc/network/interfaces")).To(BeFalse())
+			Expect(fs.FileExists("/etc/dhcp/dhclient.conf")).To(BeFalse())
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
+		It("returns only files whose contents would change", func() {
+			fs.WriteFileString("/etc/network/interfaces", `# Generated by bosh-agent
+auto lo
+iface lo inet loopback
+
+auto ethdhcp
+iface ethdhcp inet dhcp
+
+auto ethstatic
+iface ethstatic inet static
+    address 1.2.3.4
+    network 1.2.3.0
+    netmask 255.255.255.0
+    broadcast 1.2.3.255
+    gateway 3.4.5.6
+
+dns-nameservers 8.8.8.8 9.9.9.9`)
+
+			paths, err := netManager.PlanNetworking(networks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(Equal([]string{"/etc/dhcp/dhclient.conf"}))
+		})
+
+		It("returns resolvconf base path when networks are preconfigured", func() {
+			dhcpNetwork.Preconfigured = true
+
+			paths, err := netManager.PlanNetworking(boshsettings.Networks{"dhcp-network": dhcpNetwork})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(Equal([]string{"/etc/resolvconf/resolv.conf.d/base"}))
+		})
+
+		It("returns error when interfaces cannot be detected", func() {
+			fs.GlobErr = errors.New("fake-glob-error")
+
+			_, err := netManager.PlanNetworking(networks)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-glob-error"))
+		})
+	})
 }
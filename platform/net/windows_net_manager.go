@@ -141,6 +141,11 @@ func (net WindowsNetManager) SetupNetworking(networks boshsettings.Networks, err
 	return dns
 }
 
+// PlanNetworking returns no files since interfaces are configured via PowerShell
+func (net WindowsNetManager) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	return []string{}, nil
+}
+
 func (net WindowsNetManager) setupInterfaces(staticConfigs []StaticInterfaceConfiguration) error {
 	for _, conf := range staticConfigs {
 		var gateway string
@@ -6,6 +6,7 @@ import (
 	"log"
 
 	boshdpresolv "github.com/cloudfoundry/bosh-agent/infrastructure/devicepathresolver"
+	boshdisk "github.com/cloudfoundry/bosh-agent/platform/disk"
 	boshvitals "github.com/cloudfoundry/bosh-agent/platform/vitals"
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 	boshdir "github.com/cloudfoundry/bosh-agent/settings/directories"
@@ -61,6 +62,7 @@ type Platform interface {
 
 	// Disk management
 	MountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) error
+	PlanMountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (PersistentDiskMountPlan, error)
 	UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error)
 	MigratePersistentDisk(fromMountPoint, toMountPoint string) (err error)
 	GetEphemeralDiskPath(diskSettings boshsettings.DiskSettings) string
@@ -76,6 +78,7 @@ type Platform interface {
 	GetDefaultNetwork() (boshsettings.Network, error)
 	GetConfiguredNetworkInterfaces() ([]string, error)
 	PrepareForNetworkingChange() error
+	PlanNetworking(networks boshsettings.Networks) (changedFiles []string, err error)
 	DeleteARPEntryWithIP(ip string) error
 	SaveDNSRecords(dnsRecords boshsettings.DNSRecords, hostname string) error
 
@@ -89,3 +92,19 @@ type Platform interface {
 	RemoveDevTools(packageFileListPath string) error
 	RemoveStaticLibraries(packageFileListPath string) error
 }
+
+// PersistentDiskMountPlan describes partition and mount MountPersistentDisk would create
+type PersistentDiskMountPlan struct {
+	DevicePath string `json:"device_path"`
+
+	// Partition related fields are empty when disk is preformatted
+	PartitionPath  string                  `json:"partition_path,omitempty"`
+	Partitioner    string                  `json:"partitioner,omitempty"`
+	FileSystemType boshdisk.FileSystemType `json:"file_system_type,omitempty"`
+
+	// MountedPath is either device or its partition
+	MountedPath    string   `json:"mounted_path"`
+	MountPoint     string   `json:"mount_point"`
+	MountOptions   []string `json:"mount_options,omitempty"`
+	AlreadyMounted bool     `json:"already_mounted"`
+}
@@ -0,0 +1,64 @@
+package vitals
+
+import (
+	"strconv"
+
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const metricsLogTag = "vitalsMetrics"
+
+var loadPeriods = []string{"1m", "5m", "15m"}
+
+// RegisterMetrics exposes vitals as gauges that are refreshed every time metrics are read
+func RegisterMetrics(service Service, metrics *boshmetrics.Registry, logger boshlog.Logger) {
+	load := metrics.Gauge("bosh_agent_load_average", "System load average.", "period")
+	cpu := metrics.Gauge("bosh_agent_cpu_percent", "CPU usage in percent.", "mode")
+	memoryBytes := metrics.Gauge("bosh_agent_memory_used_bytes", "Used memory in bytes.", "type")
+	memoryPercent := metrics.Gauge("bosh_agent_memory_used_percent", "Used memory in percent.", "type")
+	diskPercent := metrics.Gauge("bosh_agent_disk_used_percent", "Used disk space in percent.", "disk")
+	inodePercent := metrics.Gauge("bosh_agent_disk_inode_used_percent", "Used disk inodes in percent.", "disk")
+
+	metrics.OnCollect(func() {
+		vitals, err := service.Get()
+		if err != nil {
+			logger.Warn(metricsLogTag, "Getting vitals: %s", err.Error())
+			return
+		}
+
+		for i, value := range vitals.Load {
+			if i < len(loadPeriods) {
+				setParsed(load, value, 1, loadPeriods[i])
+			}
+		}
+
+		setParsed(cpu, vitals.CPU.User, 1, "user")
+		setParsed(cpu, vitals.CPU.Sys, 1, "sys")
+		setParsed(cpu, vitals.CPU.Wait, 1, "wait")
+
+		setParsed(memoryBytes, vitals.Mem.Kb, 1024, "mem")
+		setParsed(memoryBytes, vitals.Swap.Kb, 1024, "swap")
+		setParsed(memoryPercent, vitals.Mem.Percent, 1, "mem")
+		setParsed(memoryPercent, vitals.Swap.Percent, 1, "swap")
+
+		// Ephemeral and persistent disks may come and go
+		diskPercent.Reset()
+		inodePercent.Reset()
+
+		for name, disk := range vitals.Disk {
+			setParsed(diskPercent, disk.Percent, 1, name)
+			setParsed(inodePercent, disk.InodePercent, 1, name)
+		}
+	})
+}
+
+// setParsed skips values that are not reported on the current platform
+func setParsed(gauge boshmetrics.Gauge, value string, multiplier float64, labelValues ...string) {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	gauge.Set(parsed*multiplier, labelValues...)
+}
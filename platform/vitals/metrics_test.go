@@ -0,0 +1,79 @@
+package vitals_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	boshmetrics "github.com/cloudfoundry/bosh-agent/metrics"
+	. "github.com/cloudfoundry/bosh-agent/platform/vitals"
+	fakevitals "github.com/cloudfoundry/bosh-agent/platform/vitals/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("RegisterMetrics", func() {
+	var (
+		service *fakevitals.FakeService
+		metrics *boshmetrics.Registry
+	)
+
+	BeforeEach(func() {
+		service = fakevitals.NewFakeService()
+		metrics = boshmetrics.NewRegistry()
+
+		RegisterMetrics(service, metrics, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	metricsText := func() string {
+		buf := bytes.NewBufferString("")
+		Expect(metrics.WriteText(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	It("exposes current vitals as gauges", func() {
+		service.GetVitals = Vitals{
+			Load: []string{"0.20", "4.55", "1.12"},
+			CPU:  CPUVitals{User: "56.0", Sys: "10.0", Wait: "1.0"},
+			Mem:  MemoryVitals{Kb: "700", Percent: "70"},
+			Swap: MemoryVitals{Kb: "600", Percent: "60"},
+			Disk: DiskVitals{
+				"system": SpecificDiskVitals{Percent: "50", InodePercent: "10"},
+			},
+		}
+
+		text := metricsText()
+		Expect(text).To(ContainSubstring(`bosh_agent_load_average{period="5m"} 4.55` + "\n"))
+		Expect(text).To(ContainSubstring(`bosh_agent_cpu_percent{mode="user"} 56` + "\n"))
+		Expect(text).To(ContainSubstring(`bosh_agent_memory_used_bytes{type="mem"} 716800` + "\n"))
+		Expect(text).To(ContainSubstring(`bosh_agent_memory_used_percent{type="swap"} 60` + "\n"))
+		Expect(text).To(ContainSubstring(`bosh_agent_disk_used_percent{disk="system"} 50` + "\n"))
+		Expect(text).To(ContainSubstring(`bosh_agent_disk_inode_used_percent{disk="system"} 10` + "\n"))
+	})
+
+	It("removes disks that are no longer reported", func() {
+		service.GetVitals = Vitals{Disk: DiskVitals{"persistent": SpecificDiskVitals{Percent: "20"}}}
+		Expect(metricsText()).To(ContainSubstring(`disk="persistent"`))
+
+		service.GetVitals = Vitals{Disk: DiskVitals{"system": SpecificDiskVitals{Percent: "50"}}}
+		Expect(metricsText()).ToNot(ContainSubstring(`disk="persistent"`))
+	})
+
+	It("skips values that are not reported", func() {
+		service.GetVitals = Vitals{CPU: CPUVitals{User: "56.0"}}
+
+		text := metricsText()
+		Expect(text).To(ContainSubstring(`bosh_agent_cpu_percent{mode="user"} 56`))
+		Expect(text).ToNot(ContainSubstring(`mode="sys"`))
+		Expect(text).ToNot(ContainSubstring("bosh_agent_load_average{"))
+	})
+
+	It("keeps previous values when vitals cannot be retrieved", func() {
+		service.GetVitals = Vitals{CPU: CPUVitals{User: "56.0"}}
+		metricsText()
+
+		service.GetErr = errors.New("fake-vitals-err")
+		Expect(metricsText()).To(ContainSubstring(`bosh_agent_cpu_percent{mode="user"} 56`))
+	})
+})
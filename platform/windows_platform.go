@@ -179,6 +179,10 @@ func (p WindowsPlatform) SetupNetworking(networks boshsettings.Networks) (err er
 	return p.netManager.SetupNetworking(networks, nil)
 }
 
+func (p WindowsPlatform) PlanNetworking(networks boshsettings.Networks) ([]string, error) {
+	return p.netManager.PlanNetworking(networks)
+}
+
 func (p WindowsPlatform) GetConfiguredNetworkInterfaces() (interfaces []string, err error) {
 	return
 }
@@ -285,6 +289,10 @@ func (p WindowsPlatform) MountPersistentDisk(diskSettings boshsettings.DiskSetti
 	return
 }
 
+func (p WindowsPlatform) PlanMountPersistentDisk(diskSettings boshsettings.DiskSettings, mountPoint string) (PersistentDiskMountPlan, error) {
+	return PersistentDiskMountPlan{MountPoint: mountPoint}, nil
+}
+
 func (p WindowsPlatform) UnmountPersistentDisk(diskSettings boshsettings.DiskSettings) (didUnmount bool, err error) {
 	return
 }
@@ -0,0 +1,195 @@
+package settings
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// CacheCipher encrypts settings cached on disk since they include
+// mbus and blobstore credentials and password of vcap user.
+type CacheCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+
+	// Decrypt returns data as is together with encrypted set to false
+	// when data was written before settings were encrypted
+	Decrypt(data []byte) (plaintext []byte, encrypted bool, err error)
+}
+
+// CacheEncryptionOptions configures where secret used
+// to derive key for encrypting cached settings is kept.
+//
+// With default KeyPath secret sits next to settings.json, so encryption
+// only protects copies of settings.json made without the key, e.g. in
+// backups or support bundles. Anyone able to read bosh directory can
+// decrypt settings. Point KeyPath at a separately mounted or
+// infrastructure provided file to protect against more than that.
+type CacheEncryptionOptions struct {
+	// KeyPath defaults to settings.key in bosh directory.
+	// Secret is generated at first boot unless infrastructure already placed one there.
+	KeyPath string
+}
+
+const (
+	encryptedSettingsHeader = "bosh-agent-encrypted-settings:v1\n"
+
+	// Key is derived from secret so that the same secret could be used for other purposes
+	cacheKeyDerivationLabel = "bosh-agent settings cache"
+
+	minCacheSecretLength = 16
+	newCacheSecretLength = 32
+)
+
+type keyfileCacheCipher struct {
+	fs      boshsys.FileSystem
+	keyPath string
+
+	// secretLock keeps concurrent callers from generating different secrets
+	secretLock sync.Mutex
+}
+
+func NewKeyfileCacheCipher(fs boshsys.FileSystem, keyPath string) CacheCipher {
+	return &keyfileCacheCipher{fs: fs, keyPath: keyPath}
+}
+
+// Encrypt returns header followed by nonce and AES-GCM sealed plaintext
+func (c *keyfileCacheCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating nonce")
+	}
+
+	data := append([]byte(encryptedSettingsHeader), nonce...)
+
+	return aead.Seal(data, nonce, plaintext, []byte(encryptedSettingsHeader)), nil
+}
+
+func (c *keyfileCacheCipher) Decrypt(data []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedSettingsHeader)) {
+		return data, false, nil
+	}
+
+	aead, err := c.aead()
+	if err != nil {
+		return nil, true, err
+	}
+
+	sealed := data[len(encryptedSettingsHeader):]
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, true, bosherr.Error("Encrypted settings are truncated")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(encryptedSettingsHeader))
+	if err != nil {
+		return nil, true, bosherr.WrapError(err, "Decrypting settings")
+	}
+
+	return plaintext, true, nil
+}
+
+func (c *keyfileCacheCipher) aead() (cipher.AEAD, error) {
+	secret, err := c.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(cacheKeyDerivationLabel))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating settings cipher")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating settings cipher")
+	}
+
+	return aead, nil
+}
+
+func (c *keyfileCacheCipher) secret() ([]byte, error) {
+	c.secretLock.Lock()
+	defer c.secretLock.Unlock()
+
+	if c.fs.FileExists(c.keyPath) {
+		secret, err := c.fs.ReadFile(c.keyPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading settings key %s", c.keyPath)
+		}
+
+		if len(secret) < minCacheSecretLength {
+			return nil, bosherr.Errorf("Settings key %s must have at least %d bytes", c.keyPath, minCacheSecretLength)
+		}
+
+		return secret, nil
+	}
+
+	secret := make([]byte, newCacheSecretLength)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating settings key")
+	}
+
+	err = c.writeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// writeSecret renames fully written secret into place so that
+// interrupted write does not leave a truncated secret behind
+func (c *keyfileCacheCipher) writeSecret(secret []byte) error {
+	err := c.fs.MkdirAll(filepath.Dir(c.keyPath), os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory of settings key %s", c.keyPath)
+	}
+
+	tmpPath := c.keyPath + ".tmp"
+
+	file, err := c.fs.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating settings key %s", tmpPath)
+	}
+
+	_, err = file.Write(secret)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = c.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Writing settings key %s", tmpPath)
+	}
+
+	err = c.fs.Rename(tmpPath, c.keyPath)
+	if err != nil {
+		_ = c.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Moving settings key into place %s", c.keyPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package settings_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/settings"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("keyfileCacheCipher", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		cacheCipher CacheCipher
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		cacheCipher = NewKeyfileCacheCipher(fs, "/fake-bosh/settings.key")
+	})
+
+	It("decrypts encrypted data", func() {
+		encrypted, err := cacheCipher.Encrypt([]byte(`{"agent_id":"fake-agent-id"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(encrypted)).ToNot(ContainSubstring("fake-agent-id"))
+
+		plaintext, isEncrypted, err := cacheCipher.Decrypt(encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isEncrypted).To(BeTrue())
+		Expect(string(plaintext)).To(Equal(`{"agent_id":"fake-agent-id"}`))
+	})
+
+	It("generates secret readable only by owner when it does not exist", func() {
+		_, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		secret, err := fs.ReadFile("/fake-bosh/settings.key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secret).To(HaveLen(32))
+
+		Expect(fs.GetFileTestStat("/fake-bosh/settings.key").FileMode).To(Equal(os.FileMode(0600)))
+	})
+
+	It("writes secret to temporary file and moves it into place", func() {
+		_, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fs.RenameOldPaths).To(Equal([]string{"/fake-bosh/settings.key.tmp"}))
+		Expect(fs.RenameNewPaths).To(Equal([]string{"/fake-bosh/settings.key"}))
+		Expect(fs.FileExists("/fake-bosh/settings.key.tmp")).To(BeFalse())
+	})
+
+	It("returns error and removes temporary file when secret cannot be moved into place", func() {
+		fs.RenameError = errors.New("fake-rename-err")
+
+		_, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-rename-err"))
+
+		Expect(fs.FileExists("/fake-bosh/settings.key")).To(BeFalse())
+		Expect(fs.FileExists("/fake-bosh/settings.key.tmp")).To(BeFalse())
+	})
+
+	It("uses existing secret placed by infrastructure", func() {
+		fs.WriteFileString("/fake-bosh/settings.key", "fake-infrastructure-secret")
+
+		encrypted, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		otherCipher := NewKeyfileCacheCipher(fs, "/fake-bosh/settings.key")
+
+		plaintext, _, err := otherCipher.Decrypt(encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(plaintext)).To(Equal(`{}`))
+	})
+
+	It("returns error when secret is too short", func() {
+		fs.WriteFileString("/fake-bosh/settings.key", "short")
+
+		_, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Settings key /fake-bosh/settings.key must have at least 16 bytes"))
+	})
+
+	It("returns error when secret cannot be created", func() {
+		fs.OpenFileErr = errors.New("fake-open-err")
+
+		_, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-open-err"))
+	})
+
+	It("returns plaintext data as is", func() {
+		plaintext, isEncrypted, err := cacheCipher.Decrypt([]byte(`{"agent_id":"fake-agent-id"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(isEncrypted).To(BeFalse())
+		Expect(string(plaintext)).To(Equal(`{"agent_id":"fake-agent-id"}`))
+	})
+
+	It("returns error when data was encrypted with other secret", func() {
+		encrypted, err := cacheCipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		fs.WriteFileString("/fake-bosh/settings.key", "fake-other-secret-value")
+
+		_, _, err = cacheCipher.Decrypt(encrypted)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Decrypting settings"))
+	})
+
+	It("returns error when encrypted data is truncated", func() {
+		_, _, err := cacheCipher.Decrypt([]byte("bosh-agent-encrypted-settings:v1\nabc"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Encrypted settings are truncated"))
+	})
+})
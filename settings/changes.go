@@ -0,0 +1,112 @@
+package settings
+
+import (
+	"reflect"
+	"time"
+)
+
+// Field names part of settings that subscribers may react to.
+// Nested fields are also reported as changes of fields containing them.
+type Field string
+
+const (
+	FieldAgentID        Field = "agent_id"
+	FieldBlobstore      Field = "blobstore"
+	FieldDisks          Field = "disks"
+	FieldEnv            Field = "env"
+	FieldAuthorizedKeys Field = "env.bosh.authorized_keys"
+	FieldNetworks       Field = "networks"
+	FieldDNS            Field = "networks.dns"
+	FieldNtp            Field = "ntp"
+	FieldMbus           Field = "mbus"
+	FieldMbusTLS        Field = "mbus_tls"
+	FieldVM             Field = "vm"
+	FieldRequestSigning Field = "request_signing"
+	FieldHTTPSTLS       Field = "https_tls"
+)
+
+var fieldValues = []struct {
+	field Field
+	value func(Settings) interface{}
+}{
+	{FieldAgentID, func(s Settings) interface{} { return s.AgentID }},
+	{FieldBlobstore, func(s Settings) interface{} { return s.Blobstore }},
+	{FieldDisks, func(s Settings) interface{} { return s.Disks }},
+	{FieldEnv, func(s Settings) interface{} { return s.Env }},
+	{FieldAuthorizedKeys, func(s Settings) interface{} { return s.Env.GetAuthorizedKeys() }},
+	{FieldNetworks, func(s Settings) interface{} { return s.Networks }},
+	{FieldDNS, func(s Settings) interface{} { return s.Networks.dnsServers() }},
+	{FieldNtp, func(s Settings) interface{} { return s.Ntp }},
+	{FieldMbus, func(s Settings) interface{} { return s.Mbus }},
+	{FieldMbusTLS, func(s Settings) interface{} { return s.MbusTLS }},
+	{FieldVM, func(s Settings) interface{} { return s.VM }},
+	{FieldRequestSigning, func(s Settings) interface{} { return s.RequestSigning }},
+	{FieldHTTPSTLS, func(s Settings) interface{} { return s.HTTPSTLS }},
+}
+
+// RefreshOptions configures periodic refresh of settings from settings source
+type RefreshOptions struct {
+	// IntervalSeconds disables periodic refresh when zero.
+	// Settings can still be refreshed with refresh_settings action.
+	IntervalSeconds int
+}
+
+func (o RefreshOptions) Interval() time.Duration {
+	return time.Duration(o.IntervalSeconds) * time.Second
+}
+
+// Changes lists fields that differ between two settings
+type Changes []Field
+
+func (c Changes) Contains(field Field) bool {
+	for _, changedField := range c {
+		if changedField == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c Changes) ContainsAny(fields []Field) bool {
+	for _, field := range fields {
+		if c.Contains(field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff returns fields in the order they are declared
+func Diff(previous, current Settings) Changes {
+	changes := Changes{}
+
+	for _, fieldValue := range fieldValues {
+		if !reflect.DeepEqual(fieldValue.value(previous), fieldValue.value(current)) {
+			changes = append(changes, fieldValue.field)
+		}
+	}
+
+	return changes
+}
+
+// ChangeHandler is given settings after they were refreshed
+type ChangeHandler func(settings Settings) error
+
+type subscription struct {
+	handler ChangeHandler
+	fields  []Field
+}
+
+func (n Networks) dnsServers() map[string][]string {
+	servers := map[string][]string{}
+
+	for name, network := range n {
+		if len(network.DNS) > 0 {
+			servers[name] = network.DNS
+		}
+	}
+
+	return servers
+}
@@ -18,6 +18,11 @@ func (p Provider) BoshDir() string {
 	return filepath.Join(p.BaseDir(), "bosh")
 }
 
+// AdminSocketPath is used by operators on the VM to query agent
+func (p Provider) AdminSocketPath() string {
+	return filepath.Join(p.BoshDir(), "admin", "agent.sock")
+}
+
 func (p Provider) BoshBinDir() string {
 	return filepath.Join(p.BoshDir(), "bin")
 }
@@ -66,6 +71,11 @@ func (p Provider) MicroStore() string {
 	return filepath.Join(p.BaseDir(), "micro_bosh", "data", "cache")
 }
 
+// MicroUploadsDir keeps incomplete uploads on the same file system as MicroStore
+func (p Provider) MicroUploadsDir() string {
+	return filepath.Join(p.BaseDir(), "micro_bosh", "data", "uploads")
+}
+
 func (p Provider) SettingsDir() string {
 	return filepath.Join(p.BoshDir(), "settings")
 }
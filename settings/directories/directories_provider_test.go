@@ -18,6 +18,7 @@ var _ = Describe("Provider", func() {
 		},
 		Entry("BaseDir()", p.BaseDir(), "/some/dir"),
 		Entry("BoshDir()", p.BoshDir(), "/some/dir/bosh"),
+		Entry("AdminSocketPath()", p.AdminSocketPath(), "/some/dir/bosh/admin/agent.sock"),
 		Entry("BoshBinDir()", p.BoshBinDir(), "/some/dir/bosh/bin"),
 		Entry("EtcDir()", p.EtcDir(), "/some/dir/bosh/etc"),
 		Entry("StoreDir()", p.StoreDir(), "/some/dir/store"),
@@ -30,6 +31,7 @@ var _ = Describe("Provider", func() {
 		Entry("JobsDir()", p.JobsDir(), "/some/dir/jobs"),
 		Entry("JobBinDir(jobName)", p.JobBinDir("myJob"), "/some/dir/jobs/myJob/bin"),
 		Entry("MicroStore()", p.MicroStore(), "/some/dir/micro_bosh/data/cache"),
+		Entry("MicroUploadsDir()", p.MicroUploadsDir(), "/some/dir/micro_bosh/data/uploads"),
 		Entry("SettingsDir()", p.SettingsDir(), "/some/dir/bosh/settings"),
 		Entry("TmpDir()", p.TmpDir(), "/some/dir/data/tmp"),
 		Entry("LogsDir()", p.LogsDir(), "/some/dir/sys/log"),
@@ -0,0 +1,146 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-agent/platform/disk"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type DiskSettings struct {
+	// ID and FileSystemType are filled in by agent
+	// and are not part of disk settings JSON
+	ID             string
+	DeviceID       string
+	VolumeID       string
+	Lun            string
+	HostDeviceID   string
+	Path           string
+	FileSystemType disk.FileSystemType
+
+	// FileSystemOptions and MountOptions are given by CPIs
+	// whose disks need to be formatted or mounted with specific options.
+	// Only mount options of persistent disks are applied;
+	// Settings.Validate reports other options agent does not apply.
+	FileSystemOptions []string
+	MountOptions      []string
+
+	// Encryption is nil when disk is not encrypted.
+	// Encrypted disks are not supported yet and are reported by Settings.Validate.
+	Encryption *DiskEncryption
+}
+
+type DiskEncryption struct {
+	// Type is e.g. luks
+	Type string `json:"type"`
+
+	// KeyPath is a path to the key on the VM
+	KeyPath string `json:"key_path,omitempty"`
+}
+
+// diskSettingsHash is a shape newer CPIs use for disk settings
+type diskSettingsHash struct {
+	Path              string          `json:"path,omitempty"`
+	VolumeID          string          `json:"volume_id,omitempty"`
+	DeviceID          string          `json:"id,omitempty"`
+	Lun               string          `json:"lun,omitempty"`
+	HostDeviceID      string          `json:"host_device_id,omitempty"`
+	FileSystemOptions []string        `json:"filesystem_options,omitempty"`
+	MountOptions      []string        `json:"mount_options,omitempty"`
+	Encryption        *DiskEncryption `json:"encryption,omitempty"`
+}
+
+// UnmarshalJSON accepts a disk path or volume id string that older CPIs
+// return as well as a hash. Null leaves settings unchanged.
+func (d *DiskSettings) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var pathOrVolumeID string
+
+		err := json.Unmarshal(data, &pathOrVolumeID)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling disk settings string")
+		}
+
+		*d = DiskSettings{Path: pathOrVolumeID, VolumeID: pathOrVolumeID}
+
+	case bytes.HasPrefix(data, []byte("{")):
+		var hash diskSettingsHash
+
+		err := json.Unmarshal(data, &hash)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling disk settings hash")
+		}
+
+		*d = DiskSettings{
+			Path:              hash.Path,
+			VolumeID:          hash.VolumeID,
+			DeviceID:          hash.DeviceID,
+			Lun:               hash.Lun,
+			HostDeviceID:      hash.HostDeviceID,
+			FileSystemOptions: hash.FileSystemOptions,
+			MountOptions:      hash.MountOptions,
+			Encryption:        hash.Encryption,
+		}
+
+	default:
+		var value interface{}
+
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return bosherr.WrapError(err, "Unmarshalling disk settings")
+		}
+
+		return bosherr.Errorf("Expected disk settings to be a string or a hash, got %s", jsonTypeName(value))
+	}
+
+	return nil
+}
+
+// MarshalJSON produces the same shape settings were read from: settings read
+// from a string are written as that string, empty settings are written as null.
+func (d DiskSettings) MarshalJSON() ([]byte, error) {
+	hash := diskSettingsHash{
+		Path:              d.Path,
+		VolumeID:          d.VolumeID,
+		DeviceID:          d.DeviceID,
+		Lun:               d.Lun,
+		HostDeviceID:      d.HostDeviceID,
+		FileSystemOptions: d.FileSystemOptions,
+		MountOptions:      d.MountOptions,
+		Encryption:        d.Encryption,
+	}
+
+	if hash.isEmpty() {
+		return []byte("null"), nil
+	}
+
+	if hash.Path != "" && hash.Path == hash.VolumeID {
+		withoutPath := hash
+		withoutPath.Path = ""
+		withoutPath.VolumeID = ""
+
+		if withoutPath.isEmpty() {
+			return json.Marshal(hash.Path)
+		}
+	}
+
+	return json.Marshal(hash)
+}
+
+func (h diskSettingsHash) isEmpty() bool {
+	return h.Path == "" &&
+		h.VolumeID == "" &&
+		h.DeviceID == "" &&
+		h.Lun == "" &&
+		h.HostDeviceID == "" &&
+		len(h.FileSystemOptions) == 0 &&
+		len(h.MountOptions) == 0 &&
+		h.Encryption == nil
+}
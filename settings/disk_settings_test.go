@@ -0,0 +1,141 @@
+package settings_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-agent/settings"
+)
+
+var _ = Describe("DiskSettings", func() {
+	unmarshal := func(diskSettingsJSON string) (DiskSettings, error) {
+		var diskSettings DiskSettings
+		err := json.Unmarshal([]byte(diskSettingsJSON), &diskSettings)
+		return diskSettings, err
+	}
+
+	Describe("UnmarshalJSON", func() {
+		It("treats string as both path and volume id", func() {
+			diskSettings, err := unmarshal(`"/dev/sdb"`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskSettings).To(Equal(DiskSettings{Path: "/dev/sdb", VolumeID: "/dev/sdb"}))
+		})
+
+		It("reads all keys of a hash", func() {
+			diskSettings, err := unmarshal(`{
+				"path": "/dev/sdc",
+				"volume_id": "fake-volume-id",
+				"id": "fake-device-id",
+				"lun": "0",
+				"host_device_id": "fake-host-device-id",
+				"filesystem_options": ["-L", "fake-label"],
+				"mount_options": ["noatime"],
+				"encryption": {"type": "luks", "key_path": "/var/vcap/data/fake-key"}
+			}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskSettings).To(Equal(DiskSettings{
+				Path:              "/dev/sdc",
+				VolumeID:          "fake-volume-id",
+				DeviceID:          "fake-device-id",
+				Lun:               "0",
+				HostDeviceID:      "fake-host-device-id",
+				FileSystemOptions: []string{"-L", "fake-label"},
+				MountOptions:      []string{"noatime"},
+				Encryption:        &DiskEncryption{Type: "luks", KeyPath: "/var/vcap/data/fake-key"},
+			}))
+		})
+
+		It("returns empty disk settings for null", func() {
+			diskSettings, err := unmarshal(`null`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskSettings).To(Equal(DiskSettings{}))
+		})
+
+		It("returns error instead of panicking for values of unexpected types", func() {
+			_, err := unmarshal(`["/dev/sdb"]`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected disk settings to be a string or a hash, got array"))
+
+			_, err = unmarshal(`3`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected disk settings to be a string or a hash, got number"))
+		})
+
+		It("returns error when hash keys have unexpected types", func() {
+			_, err := unmarshal(`{"path": "/dev/sdc", "lun": 0}`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling disk settings hash"))
+		})
+
+		It("unmarshals settings with invalid disk settings so that validation reports them", func() {
+			var settings Settings
+			err := json.Unmarshal([]byte(`{
+				"disks": {
+					"ephemeral": ["/dev/sdb"],
+					"persistent": {
+						"fake-disk-id": 3,
+						"fake-other-disk-id": {"path": "/dev/sdc", "lun": 0},
+						"fake-valid-disk-id": "/dev/sdd"
+					}
+				}
+			}`), &settings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings.Disks.Persistent["fake-valid-disk-id"]).To(Equal(DiskSettings{Path: "/dev/sdd", VolumeID: "/dev/sdd"}))
+
+			err = settings.Validate(nil)
+			Expect(err).To(HaveOccurred())
+
+			validationErr, ok := err.(ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(validationErr.Problems).To(HaveLen(3))
+			Expect(validationErr.Problems[0]).To(Equal(ValidationProblem{
+				Field:   "disks.ephemeral",
+				Message: "Expected disk settings to be a string or a hash, got array",
+			}))
+			Expect(validationErr.Problems[1]).To(Equal(ValidationProblem{
+				Field:   "disks.persistent.fake-disk-id",
+				Message: "Expected disk settings to be a string or a hash, got number",
+			}))
+			Expect(validationErr.Problems[2].Field).To(Equal("disks.persistent.fake-other-disk-id"))
+			Expect(validationErr.Problems[2].Message).To(ContainSubstring("Unmarshalling disk settings hash"))
+		})
+	})
+
+	Describe("MarshalJSON", func() {
+		It("writes settings read from a string as that string", func() {
+			settingsJSON := `{"system":"/dev/sda","ephemeral":"/dev/sdb","persistent":{"fake-disk-id":"3"},"raw_ephemeral":null}`
+
+			var disks Disks
+			Expect(json.Unmarshal([]byte(settingsJSON), &disks)).To(Succeed())
+
+			marshalled, err := json.Marshal(disks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(marshalled)).To(Equal(settingsJSON))
+		})
+
+		It("writes settings read from a hash as a hash with only keys that are set", func() {
+			diskSettingsJSON := `{"path":"/dev/sdc","lun":"0","host_device_id":"fake-host-device-id","mount_options":["noatime"],"encryption":{"type":"luks"}}`
+
+			diskSettings, err := unmarshal(diskSettingsJSON)
+			Expect(err).ToNot(HaveOccurred())
+
+			marshalled, err := json.Marshal(diskSettings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(marshalled)).To(Equal(diskSettingsJSON))
+		})
+
+		It("does not write fields filled in by agent", func() {
+			marshalled, err := json.Marshal(DiskSettings{ID: "fake-disk-id", FileSystemType: "xfs", Path: "/dev/sdc"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(marshalled)).To(Equal(`{"path":"/dev/sdc"}`))
+		})
+
+		It("writes empty settings as null", func() {
+			marshalled, err := json.Marshal(DiskSettings{ID: "fake-disk-id"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(marshalled)).To(Equal(`null`))
+		})
+	})
+})
@@ -1,6 +1,8 @@
 package fakes
 
 import (
+	"sync"
+
 	boshsettings "github.com/cloudfoundry/bosh-agent/settings"
 )
 
@@ -14,9 +16,24 @@ type FakeSettingsService struct {
 	InvalidateSettingsError error
 	SettingsWereInvalidated bool
 
+	RefreshSettingsChanges boshsettings.Changes
+	RefreshSettingsErr     error
+	refreshSettingsCalls   int
+	refreshSettingsLock    sync.Mutex
+
+	Subscriptions []FakeSubscription
+
+	SavedUpdateSettings   *boshsettings.UpdateSettings
+	SaveUpdateSettingsErr error
+
 	Settings boshsettings.Settings
 }
 
+type FakeSubscription struct {
+	Handler boshsettings.ChangeHandler
+	Fields  []boshsettings.Field
+}
+
 func (service *FakeSettingsService) InvalidateSettings() error {
 	service.SettingsWereInvalidated = true
 	return service.InvalidateSettingsError
@@ -31,6 +48,48 @@ func (service *FakeSettingsService) LoadSettings() error {
 	return service.LoadSettingsError
 }
 
-func (service FakeSettingsService) GetSettings() boshsettings.Settings {
+func (service *FakeSettingsService) GetSettings() boshsettings.Settings {
 	return service.Settings
 }
+
+func (service *FakeSettingsService) RefreshSettings() (boshsettings.Changes, error) {
+	service.refreshSettingsLock.Lock()
+	defer service.refreshSettingsLock.Unlock()
+
+	service.refreshSettingsCalls++
+	return service.RefreshSettingsChanges, service.RefreshSettingsErr
+}
+
+func (service *FakeSettingsService) RefreshSettingsCallCount() int {
+	service.refreshSettingsLock.Lock()
+	defer service.refreshSettingsLock.Unlock()
+
+	return service.refreshSettingsCalls
+}
+
+func (service *FakeSettingsService) Subscribe(handler boshsettings.ChangeHandler, fields ...boshsettings.Field) {
+	service.Subscriptions = append(service.Subscriptions, FakeSubscription{Handler: handler, Fields: fields})
+}
+
+func (service *FakeSettingsService) SaveUpdateSettings(updateSettings boshsettings.UpdateSettings) error {
+	if service.SaveUpdateSettingsErr != nil {
+		return service.SaveUpdateSettingsErr
+	}
+
+	service.SavedUpdateSettings = &updateSettings
+	return nil
+}
+
+// Notify calls handlers subscribed to any of changed fields with current settings
+func (service *FakeSettingsService) Notify(changes ...boshsettings.Field) error {
+	for _, subscription := range service.Subscriptions {
+		if boshsettings.Changes(changes).ContainsAny(subscription.Fields) {
+			err := subscription.Handler(service.Settings)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
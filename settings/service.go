@@ -2,11 +2,11 @@ package settings
 
 import (
 	"encoding/json"
+	"sync"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
-	"sync"
 )
 
 type Service interface {
@@ -18,6 +18,18 @@ type Service interface {
 	PublicSSHKeyForUsername(string) (string, error)
 
 	InvalidateSettings() error
+
+	// RefreshSettings fetches settings from source and calls handlers subscribed
+	// to fields that changed. Current settings are kept when they cannot be fetched.
+	RefreshSettings() (Changes, error)
+
+	// Subscribe registers handler called after refresh changes any of fields
+	Subscribe(handler ChangeHandler, fields ...Field)
+
+	// SaveUpdateSettings keeps settings given via update_settings action;
+	// TLS settings they contain replace TLS settings from settings source.
+	// Handlers subscribed to fields that changed are called like after refresh.
+	SaveUpdateSettings(updateSettings UpdateSettings) error
 }
 
 const settingsServiceLogTag = "settingsService"
@@ -25,11 +37,18 @@ const settingsServiceLogTag = "settingsService"
 type settingsService struct {
 	fs                     boshsys.FileSystem
 	settingsPath           string
+	updateSettingsPath     string
+	cacheCipher            CacheCipher
 	settings               Settings
+	updateSettings         UpdateSettings
 	settingsMutex          sync.Mutex
 	settingsSource         Source
 	defaultNetworkResolver DefaultNetworkResolver
 	logger                 boshlog.Logger
+
+	// refreshMutex keeps handlers of consecutive refreshes from interleaving
+	refreshMutex  sync.Mutex
+	subscriptions []subscription
 }
 
 type DefaultNetworkResolver interface {
@@ -41,6 +60,8 @@ type DefaultNetworkResolver interface {
 func NewService(
 	fs boshsys.FileSystem,
 	settingsPath string,
+	updateSettingsPath string,
+	cacheCipher CacheCipher,
 	settingsSource Source,
 	defaultNetworkResolver DefaultNetworkResolver,
 	logger boshlog.Logger,
@@ -48,6 +69,8 @@ func NewService(
 	return &settingsService{
 		fs:                     fs,
 		settingsPath:           settingsPath,
+		updateSettingsPath:     updateSettingsPath,
+		cacheCipher:            cacheCipher,
 		settings:               Settings{},
 		settingsSource:         settingsSource,
 		defaultNetworkResolver: defaultNetworkResolver,
@@ -60,28 +83,44 @@ func (s *settingsService) PublicSSHKeyForUsername(username string) (string, erro
 }
 
 func (s *settingsService) LoadSettings() error {
+	s.loadUpdateSettings()
+
 	s.logger.Debug(settingsServiceLogTag, "Loading settings from fetcher")
 
 	newSettings, fetchErr := s.settingsSource.Settings()
 	if fetchErr != nil {
 		s.logger.Error(settingsServiceLogTag, "Failed loading settings via fetcher: %v", fetchErr)
 
-		existingSettingsJSON, readError := s.fs.ReadFile(s.settingsPath)
+		existingSettingsData, readError := s.fs.ReadFile(s.settingsPath)
 		if readError != nil {
 			s.logger.Error(settingsServiceLogTag, "Failed reading settings from file %s", readError.Error())
 			return bosherr.WrapError(fetchErr, "Invoking settings fetcher")
 		}
 
+		existingSettingsJSON, encrypted, err := s.cacheCipher.Decrypt(existingSettingsData)
+		if err != nil {
+			s.logger.Error(settingsServiceLogTag, "Failed decrypting settings from file %s", err.Error())
+			return bosherr.WrapError(fetchErr, "Invoking settings fetcher")
+		}
+
 		s.logger.Debug(settingsServiceLogTag, "Successfully read settings from file")
 
 		cachedSettings := Settings{}
 
-		err := json.Unmarshal(existingSettingsJSON, &cachedSettings)
+		err = json.Unmarshal(existingSettingsJSON, &cachedSettings)
 		if err != nil {
 			s.logger.Error(settingsServiceLogTag, "Failed unmarshalling settings from file %s", err.Error())
 			return bosherr.WrapError(fetchErr, "Invoking settings fetcher")
 		}
 
+		// Settings cached before they were encrypted should not stay readable
+		if !encrypted {
+			err = s.writeSettings(existingSettingsJSON)
+			if err != nil {
+				s.logger.Error(settingsServiceLogTag, "Failed encrypting plaintext settings file %s", err.Error())
+			}
+		}
+
 		s.settingsMutex.Lock()
 		s.settings = cachedSettings
 		s.settingsMutex.Unlock()
@@ -99,7 +138,152 @@ func (s *settingsService) LoadSettings() error {
 		return bosherr.WrapError(err, "Marshalling settings json")
 	}
 
-	err = s.fs.WriteFile(s.settingsPath, newSettingsJSON)
+	return s.writeSettings(newSettingsJSON)
+}
+
+func (s *settingsService) RefreshSettings() (Changes, error) {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	s.logger.Debug(settingsServiceLogTag, "Refreshing settings from fetcher")
+
+	newSettings, err := s.settingsSource.Settings()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Invoking settings fetcher")
+	}
+
+	// Mbus schemes are checked by the handler when it reconnects
+	err = newSettings.Validate(nil)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating settings")
+	}
+
+	// Fields replaced by update settings are stored but not reported as changed
+	s.settingsMutex.Lock()
+	sourceChanged := len(Diff(s.settings, newSettings)) > 0
+	changes := Diff(s.updateSettings.apply(s.settings), s.updateSettings.apply(newSettings))
+	subscriptions := s.subscriptions
+	s.settingsMutex.Unlock()
+
+	if !sourceChanged {
+		return changes, nil
+	}
+
+	newSettingsJSON, err := json.Marshal(newSettings)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling settings json")
+	}
+
+	err = s.writeSettings(newSettingsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	s.settingsMutex.Lock()
+	s.settings = newSettings
+	s.settingsMutex.Unlock()
+
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	s.logger.Info(settingsServiceLogTag, "Settings changed: %v", changes)
+
+	return changes, s.notify(changes, subscriptions)
+}
+
+func (s *settingsService) Subscribe(handler ChangeHandler, fields ...Field) {
+	s.settingsMutex.Lock()
+	s.subscriptions = append(s.subscriptions, subscription{handler: handler, fields: fields})
+	s.settingsMutex.Unlock()
+}
+
+func (s *settingsService) SaveUpdateSettings(updateSettings UpdateSettings) error {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	updateSettingsJSON, err := json.Marshal(updateSettings)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling update settings json")
+	}
+
+	err = s.fs.WriteFile(s.updateSettingsPath, updateSettingsJSON)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing update settings json")
+	}
+
+	s.settingsMutex.Lock()
+	changes := Diff(s.updateSettings.apply(s.settings), updateSettings.apply(s.settings))
+	s.updateSettings = updateSettings
+	subscriptions := s.subscriptions
+	s.settingsMutex.Unlock()
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	s.logger.Info(settingsServiceLogTag, "Update settings changed: %v", changes)
+
+	return s.notify(changes, subscriptions)
+}
+
+func (s *settingsService) notify(changes Changes, subscriptions []subscription) error {
+	currentSettings := s.GetSettings()
+
+	var handlerErrs []error
+
+	for _, sub := range subscriptions {
+		if !changes.ContainsAny(sub.fields) {
+			continue
+		}
+
+		err := sub.handler(currentSettings)
+		if err != nil {
+			s.logger.Error(settingsServiceLogTag, "Failed reacting to changed settings: %s", err.Error())
+			handlerErrs = append(handlerErrs, err)
+		}
+	}
+
+	if len(handlerErrs) > 0 {
+		return bosherr.WrapError(bosherr.NewMultiError(handlerErrs...), "Reacting to changed settings")
+	}
+
+	return nil
+}
+
+// loadUpdateSettings keeps current update settings when they cannot be read;
+// they are not found until update_settings action runs for the first time.
+func (s *settingsService) loadUpdateSettings() {
+	if !s.fs.FileExists(s.updateSettingsPath) {
+		return
+	}
+
+	var updateSettings UpdateSettings
+
+	contents, err := s.fs.ReadFile(s.updateSettingsPath)
+	if err != nil {
+		s.logger.Error(settingsServiceLogTag, "Failed reading update settings: %s", err.Error())
+		return
+	}
+
+	err = json.Unmarshal(contents, &updateSettings)
+	if err != nil {
+		s.logger.Error(settingsServiceLogTag, "Failed unmarshalling update settings: %s", err.Error())
+		return
+	}
+
+	s.settingsMutex.Lock()
+	s.updateSettings = updateSettings
+	s.settingsMutex.Unlock()
+}
+
+func (s *settingsService) writeSettings(settingsJSON []byte) error {
+	encryptedSettings, err := s.cacheCipher.Encrypt(settingsJSON)
+	if err != nil {
+		return bosherr.WrapError(err, "Encrypting setting json")
+	}
+
+	err = s.fs.WriteFile(s.settingsPath, encryptedSettings)
 	if err != nil {
 		return bosherr.WrapError(err, "Writing setting json")
 	}
@@ -111,7 +295,7 @@ func (s *settingsService) LoadSettings() error {
 func (s *settingsService) GetSettings() Settings {
 	s.settingsMutex.Lock()
 
-	settingsCopy := s.settings
+	settingsCopy := s.updateSettings.apply(s.settings)
 
 	if s.settings.Networks != nil {
 		settingsCopy.Networks = make(map[string]Network)
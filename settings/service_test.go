@@ -20,17 +20,19 @@ func init() {
 			fs                         *fakesys.FakeFileSystem
 			fakeDefaultNetworkResolver *fakenet.FakeDefaultNetworkResolver
 			fakeSettingsSource         *fakes.FakeSettingsSource
+			cacheCipher                CacheCipher
 		)
 
 		BeforeEach(func() {
 			fs = fakesys.NewFakeFileSystem()
+			cacheCipher = NewKeyfileCacheCipher(fs, "/setting/key")
 			fakeDefaultNetworkResolver = &fakenet.FakeDefaultNetworkResolver{}
 			fakeSettingsSource = &fakes.FakeSettingsSource{}
 		})
 
 		buildService := func() (Service, *fakesys.FakeFileSystem) {
 			logger := boshlog.NewLogger(boshlog.LevelNone)
-			service := NewService(fs, "/setting/path.json", fakeSettingsSource, fakeDefaultNetworkResolver, logger)
+			service := NewService(fs, "/setting/path.json", "/setting/update_settings.json", cacheCipher, fakeSettingsSource, fakeDefaultNetworkResolver, logger)
 			return service, fs
 		}
 
@@ -94,7 +96,7 @@ func init() {
 						Expect(service.GetSettings().AgentID).To(Equal("some-new-agent-id"))
 					})
 
-					It("persists settings to the settings file", func() {
+					It("persists encrypted settings to the settings file", func() {
 						err := service.LoadSettings()
 						Expect(err).NotTo(HaveOccurred())
 
@@ -103,7 +105,12 @@ func init() {
 
 						fileContent, err := fs.ReadFile("/setting/path.json")
 						Expect(err).NotTo(HaveOccurred())
-						Expect(fileContent).To(Equal(json))
+						Expect(string(fileContent)).ToNot(ContainSubstring("some-new-agent-id"))
+
+						decryptedContent, encrypted, err := cacheCipher.Decrypt(fileContent)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(encrypted).To(BeTrue())
+						Expect(decryptedContent).To(Equal(json))
 					})
 
 					It("returns any error from writing to the setting file", func() {
@@ -155,6 +162,58 @@ func init() {
 					})
 				})
 
+				Context("when encrypted settings file exists", func() {
+					BeforeEach(func() {
+						encryptedSettings, err := cacheCipher.Encrypt([]byte(`{"agent_id":"some-agent-id"}`))
+						Expect(err).ToNot(HaveOccurred())
+						fs.WriteFile("/setting/path.json", encryptedSettings)
+					})
+
+					It("returns decrypted settings from the settings file", func() {
+						err := service.LoadSettings()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(service.GetSettings()).To(Equal(Settings{AgentID: "some-agent-id"}))
+					})
+
+					It("returns any error from the fetcher when settings cannot be decrypted", func() {
+						fs.WriteFileString("/setting/key", "fake-other-secret-value")
+
+						err := service.LoadSettings()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("fake-fetch-error"))
+
+						Expect(service.GetSettings()).To(Equal(Settings{}))
+					})
+				})
+
+				Context("when plaintext settings file written by older agent exists", func() {
+					BeforeEach(func() {
+						fs.WriteFileString("/setting/path.json", `{"agent_id":"some-agent-id"}`)
+					})
+
+					It("returns settings and encrypts the settings file", func() {
+						err := service.LoadSettings()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(service.GetSettings()).To(Equal(Settings{AgentID: "some-agent-id"}))
+
+						fileContent, err := fs.ReadFile("/setting/path.json")
+						Expect(err).NotTo(HaveOccurred())
+
+						decryptedContent, encrypted, err := cacheCipher.Decrypt(fileContent)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(encrypted).To(BeTrue())
+						Expect(string(decryptedContent)).To(Equal(`{"agent_id":"some-agent-id"}`))
+					})
+
+					It("returns settings even if settings file cannot be encrypted", func() {
+						fs.WriteFileErrors["/setting/path.json"] = errors.New("fake-write-err")
+
+						err := service.LoadSettings()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(service.GetSettings()).To(Equal(Settings{AgentID: "some-agent-id"}))
+					})
+				})
+
 				Context("when non-unmarshallable settings file exists", func() {
 					It("returns any error from the fetcher", func() {
 						fs.WriteFile("/setting/path.json", []byte(`$%^&*(`))
@@ -208,6 +267,213 @@ func init() {
 			})
 		})
 
+		Describe("RefreshSettings", func() {
+			var (
+				service  Service
+				notified []Settings
+			)
+
+			BeforeEach(func() {
+				fakeSettingsSource.SettingsValue = Settings{AgentID: "fake-agent-id", Ntp: []string{"fake-ntp-1"}}
+				service, fs = buildService()
+
+				err := service.LoadSettings()
+				Expect(err).ToNot(HaveOccurred())
+
+				notified = nil
+				service.Subscribe(func(settings Settings) error {
+					notified = append(notified, settings)
+					return nil
+				}, FieldNtp, FieldBlobstore)
+			})
+
+			It("replaces settings and notifies handlers subscribed to changed fields", func() {
+				otherHandlerCalled := false
+				service.Subscribe(func(Settings) error {
+					otherHandlerCalled = true
+					return nil
+				}, FieldMbus)
+
+				newSettings := Settings{AgentID: "fake-agent-id", Ntp: []string{"fake-ntp-2"}}
+				fakeSettingsSource.SettingsValue = newSettings
+
+				changes, err := service.RefreshSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(Equal(Changes{FieldNtp}))
+
+				Expect(service.GetSettings()).To(Equal(newSettings))
+				Expect(notified).To(Equal([]Settings{newSettings}))
+				Expect(otherHandlerCalled).To(BeFalse())
+
+				cachedSettings, err := fs.ReadFile("/setting/path.json")
+				Expect(err).ToNot(HaveOccurred())
+
+				cachedSettingsJSON, _, err := cacheCipher.Decrypt(cachedSettings)
+				Expect(err).ToNot(HaveOccurred())
+
+				newSettingsJSON, err := json.Marshal(newSettings)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cachedSettingsJSON).To(MatchJSON(newSettingsJSON))
+			})
+
+			It("does not notify handlers when nothing changed", func() {
+				changes, err := service.RefreshSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+				Expect(notified).To(BeEmpty())
+			})
+
+			It("keeps current settings when fetching fails", func() {
+				fakeSettingsSource.SettingsErr = errors.New("fake-fetch-err")
+
+				_, err := service.RefreshSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-fetch-err"))
+
+				Expect(service.GetSettings().Ntp).To(Equal([]string{"fake-ntp-1"}))
+				Expect(notified).To(BeEmpty())
+			})
+
+			It("keeps current settings when fetched settings are invalid", func() {
+				fakeSettingsSource.SettingsValue = Settings{
+					Ntp:      []string{"fake-ntp-2"},
+					Networks: Networks{"default": Network{IP: "fake-ip"}},
+				}
+
+				_, err := service.RefreshSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("networks.default.ip"))
+
+				Expect(service.GetSettings().Ntp).To(Equal([]string{"fake-ntp-1"}))
+				Expect(notified).To(BeEmpty())
+			})
+
+			It("keeps current settings when they cannot be cached", func() {
+				fakeSettingsSource.SettingsValue = Settings{Ntp: []string{"fake-ntp-2"}}
+				fs.WriteFileError = errors.New("fake-write-err")
+
+				_, err := service.RefreshSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+
+				Expect(service.GetSettings().Ntp).To(Equal([]string{"fake-ntp-1"}))
+			})
+
+			It("notifies remaining handlers and returns errors of failed handlers", func() {
+				service.Subscribe(func(Settings) error {
+					return errors.New("fake-handler-err")
+				}, FieldNtp)
+
+				otherHandlerCalled := false
+				service.Subscribe(func(Settings) error {
+					otherHandlerCalled = true
+					return nil
+				}, FieldNtp)
+
+				fakeSettingsSource.SettingsValue = Settings{AgentID: "fake-agent-id", Ntp: []string{"fake-ntp-2"}}
+
+				changes, err := service.RefreshSettings()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Reacting to changed settings: fake-handler-err"))
+				Expect(changes).To(Equal(Changes{FieldNtp}))
+
+				Expect(otherHandlerCalled).To(BeTrue())
+				Expect(service.GetSettings().Ntp).To(Equal([]string{"fake-ntp-2"}))
+			})
+		})
+
+		Describe("SaveUpdateSettings", func() {
+			var (
+				service  Service
+				notified []Settings
+			)
+
+			BeforeEach(func() {
+				fakeSettingsSource.SettingsValue = Settings{
+					AgentID: "fake-agent-id",
+					MbusTLS: MbusTLS{CA: "fake-source-ca"},
+				}
+				service, fs = buildService()
+
+				err := service.LoadSettings()
+				Expect(err).ToNot(HaveOccurred())
+
+				notified = nil
+				service.Subscribe(func(settings Settings) error {
+					notified = append(notified, settings)
+					return nil
+				}, FieldMbusTLS)
+			})
+
+			It("writes update settings and replaces TLS settings with ones they contain", func() {
+				updateSettings := UpdateSettings{
+					TrustedCerts: "fake-certs",
+					MbusTLS:      &MbusTLS{CA: "fake-rotated-ca"},
+				}
+
+				err := service.SaveUpdateSettings(updateSettings)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(service.GetSettings().MbusTLS).To(Equal(MbusTLS{CA: "fake-rotated-ca"}))
+				Expect(notified).To(HaveLen(1))
+				Expect(notified[0].MbusTLS).To(Equal(MbusTLS{CA: "fake-rotated-ca"}))
+
+				updateSettingsJSON, err := fs.ReadFile("/setting/update_settings.json")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updateSettingsJSON).To(MatchJSON(`{
+					"disk_associations": null,
+					"trusted_certs": "fake-certs",
+					"mbus_tls": {"ca": "fake-rotated-ca", "certificate": "", "private_key": ""}
+				}`))
+			})
+
+			It("does not notify handlers when TLS settings do not change", func() {
+				err := service.SaveUpdateSettings(UpdateSettings{TrustedCerts: "fake-certs"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(service.GetSettings().MbusTLS).To(Equal(MbusTLS{CA: "fake-source-ca"}))
+				Expect(notified).To(BeEmpty())
+			})
+
+			It("keeps current update settings when they cannot be written", func() {
+				fs.WriteFileError = errors.New("fake-write-err")
+
+				err := service.SaveUpdateSettings(UpdateSettings{MbusTLS: &MbusTLS{CA: "fake-rotated-ca"}})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-write-err"))
+
+				Expect(service.GetSettings().MbusTLS).To(Equal(MbusTLS{CA: "fake-source-ca"}))
+				Expect(notified).To(BeEmpty())
+			})
+
+			It("uses update settings saved before agent restarted", func() {
+				err := service.SaveUpdateSettings(UpdateSettings{MbusTLS: &MbusTLS{CA: "fake-rotated-ca"}})
+				Expect(err).ToNot(HaveOccurred())
+
+				service, _ = buildService()
+
+				err = service.LoadSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(service.GetSettings().MbusTLS).To(Equal(MbusTLS{CA: "fake-rotated-ca"}))
+			})
+
+			It("does not report changes of TLS settings from source replaced by update settings", func() {
+				err := service.SaveUpdateSettings(UpdateSettings{MbusTLS: &MbusTLS{CA: "fake-rotated-ca"}})
+				Expect(err).ToNot(HaveOccurred())
+
+				notified = nil
+				fakeSettingsSource.SettingsValue = Settings{
+					AgentID: "fake-agent-id",
+					MbusTLS: MbusTLS{Certificate: "fake-other-source-cert"},
+				}
+
+				changes, err := service.RefreshSettings()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+				Expect(notified).To(BeEmpty())
+			})
+		})
+
 		Describe("GetSettings", func() {
 			var (
 				loadedSettings Settings
@@ -1,9 +1,15 @@
 package settings
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/cloudfoundry/bosh-agent/platform/disk"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type DiskAssociations struct {
@@ -32,12 +38,221 @@ type Settings struct {
 	Networks  Networks  `json:"networks"`
 	Ntp       []string  `json:"ntp"`
 	Mbus      string    `json:"mbus"`
+	MbusTLS   MbusTLS   `json:"mbus_tls"`
 	VM        VM        `json:"vm"`
+
+	RequestSigning RequestSigning `json:"request_signing"`
+	HTTPSTLS       HTTPSTLS       `json:"https_tls"`
+}
+
+// MbusTLS contains PEM encoded certificates used to connect to mbus.
+// Connection is only made over TLS when CA is provided;
+// client certificate and private key are optional.
+type MbusTLS struct {
+	CA          string `json:"ca"`
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+func (t MbusTLS) IsEnabled() bool {
+	return t.CA != ""
+}
+
+func (t MbusTLS) CertPool() (*x509.CertPool, error) {
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM([]byte(t.CA)) {
+		return nil, bosherr.Error("Parsing mbus CA certificate")
+	}
+
+	return caPool, nil
+}
+
+// ClientCertificates returns no certificates when neither certificate
+// nor private key is configured.
+func (t MbusTLS) ClientCertificates() ([]tls.Certificate, error) {
+	if t.Certificate == "" && t.PrivateKey == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(t.Certificate), []byte(t.PrivateKey))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing mbus client certificate")
+	}
+
+	return []tls.Certificate{cert}, nil
+}
+
+func (t MbusTLS) Validate() error {
+	_, err := t.CertPool()
+	if err != nil {
+		return err
+	}
+
+	_, err = t.ClientCertificates()
+	return err
+}
+
+const (
+	RequestSigningAlgorithmHMACSHA256 = "hmac-sha256"
+	RequestSigningAlgorithmEd25519    = "ed25519"
+
+	defaultRequestSigningMaxClockSkew = 300
+)
+
+// RequestSigning contains keys that inbound agent requests are signed with.
+// Requests are only required to be signed when at least one key is configured.
+type RequestSigning struct {
+	Keys []RequestSigningKey `json:"keys"`
+
+	// MaxClockSkew is a number of seconds request timestamp
+	// may differ from agent's time
+	MaxClockSkew int `json:"max_clock_skew"`
+}
+
+type RequestSigningKey struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+
+	// Secret is used as is by hmac-sha256
+	Secret string `json:"secret"`
+
+	// PublicKey is base64 encoded ed25519 public key
+	PublicKey string `json:"public_key"`
+}
+
+func (s RequestSigning) IsEnabled() bool {
+	return len(s.Keys) > 0
+}
+
+func (s RequestSigning) FindKey(id string) (RequestSigningKey, bool) {
+	for _, key := range s.Keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+
+	return RequestSigningKey{}, false
+}
+
+func (s RequestSigning) MaxClockSkewDuration() time.Duration {
+	if s.MaxClockSkew <= 0 {
+		return defaultRequestSigningMaxClockSkew * time.Second
+	}
+
+	return time.Duration(s.MaxClockSkew) * time.Second
+}
+
+const (
+	DefaultHTTPSCertificatePath = "agent.cert"
+	DefaultHTTPSPrivateKeyPath  = "agent.key"
+)
+
+var httpsTLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// HTTPSTLS configures TLS of the https mbus listener. Paths are read
+// on every TLS handshake so that rotated files are picked up.
+// Client certificates are only required when client CA is configured.
+type HTTPSTLS struct {
+	CertificatePath string `json:"certificate_path"`
+	PrivateKeyPath  string `json:"private_key_path"`
+	ClientCAPath    string `json:"client_ca_path"`
+
+	// MinVersion is one of 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `json:"min_version"`
+}
+
+// Merge returns settings with fields missing in t taken from defaults.
+func (t HTTPSTLS) Merge(defaults HTTPSTLS) HTTPSTLS {
+	if t.CertificatePath == "" {
+		t.CertificatePath = defaults.CertificatePath
+	}
+	if t.PrivateKeyPath == "" {
+		t.PrivateKeyPath = defaults.PrivateKeyPath
+	}
+	if t.ClientCAPath == "" {
+		t.ClientCAPath = defaults.ClientCAPath
+	}
+	if t.MinVersion == "" {
+		t.MinVersion = defaults.MinVersion
+	}
+	return t
+}
+
+func (t HTTPSTLS) CertificatePathOrDefault() string {
+	if t.CertificatePath == "" {
+		return DefaultHTTPSCertificatePath
+	}
+	return t.CertificatePath
+}
+
+func (t HTTPSTLS) PrivateKeyPathOrDefault() string {
+	if t.PrivateKeyPath == "" {
+		return DefaultHTTPSPrivateKeyPath
+	}
+	return t.PrivateKeyPath
+}
+
+func (t HTTPSTLS) IsClientCertificateRequired() bool {
+	return t.ClientCAPath != ""
+}
+
+// TLSMinVersion defaults to TLS 1.0 to keep supporting older directors.
+// SSLv3 is not supported since it is insecure due to BEAST and POODLE attacks.
+func (t HTTPSTLS) TLSMinVersion() (uint16, error) {
+	if t.MinVersion == "" {
+		return tls.VersionTLS10, nil
+	}
+
+	version, found := httpsTLSVersions[t.MinVersion]
+	if !found {
+		return 0, bosherr.Errorf("Unknown TLS version '%s'", t.MinVersion)
+	}
+
+	return version, nil
+}
+
+func (t HTTPSTLS) Validate() error {
+	_, err := t.TLSMinVersion()
+	if err != nil {
+		return err
+	}
+
+	if (t.CertificatePath == "") != (t.PrivateKeyPath == "") {
+		return bosherr.Error("Certificate path and private key path must be configured together")
+	}
+
+	return nil
 }
 
 type UpdateSettings struct {
 	DiskAssociations []DiskAssociation `json:"disk_associations"`
 	TrustedCerts     string            `json:"trusted_certs"`
+
+	// MbusTLS replaces certificates from settings
+	// the next time agent connects to mbus
+	MbusTLS *MbusTLS `json:"mbus_tls,omitempty"`
+
+	// HTTPSTLS replaces https listener TLS settings
+	// starting with the next TLS handshake
+	HTTPSTLS *HTTPSTLS `json:"https_tls,omitempty"`
+}
+
+// apply replaces TLS settings of settings with ones given via update_settings
+func (u UpdateSettings) apply(settings Settings) Settings {
+	if u.MbusTLS != nil {
+		settings.MbusTLS = *u.MbusTLS
+	}
+
+	if u.HTTPSTLS != nil {
+		settings.HTTPSTLS = *u.HTTPSTLS
+	}
+
+	return settings
 }
 
 type Source interface {
@@ -59,7 +274,7 @@ type Disks struct {
 	// Newer CPIs will populate it in a hash
 	// e.g {"path" => "/dev/sdc", "volume_id" => "3"}
 	//     {"lun" => "0", "host_device_id" => "{host-device-id}"}
-	Ephemeral interface{} `json:"ephemeral"`
+	Ephemeral DiskSettings `json:"ephemeral"`
 
 	// Older CPIs returned disk settings as strings
 	// e.g {"disk-3845-43758-7243-38754" => "/dev/sdc"}
@@ -68,19 +283,69 @@ type Disks struct {
 	// e.g {"disk-3845-43758-7243-38754" => {"path" => "/dev/sdc"}}
 	//     {"disk-3845-43758-7243-38754" => {"volume_id" => "3"}}
 	//     {"disk-3845-43758-7243-38754" => {"lun" => "0", "host_device_id" => "{host-device-id}"}}
-	Persistent map[string]interface{} `json:"persistent"`
+	Persistent map[string]DiskSettings `json:"persistent"`
 
 	RawEphemeral []DiskSettings `json:"raw_ephemeral"`
+
+	// problems are disk settings that could not be unmarshalled
+	problems []ValidationProblem
 }
 
-type DiskSettings struct {
-	ID             string
-	DeviceID       string
-	VolumeID       string
-	Lun            string
-	HostDeviceID   string
-	Path           string
-	FileSystemType disk.FileSystemType
+// UnmarshalJSON keeps disk settings of unexpected shapes as problems
+// reported by Settings.Validate instead of failing to unmarshal whole settings.
+func (d *Disks) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		System       string                     `json:"system"`
+		Ephemeral    json.RawMessage            `json:"ephemeral"`
+		Persistent   map[string]json.RawMessage `json:"persistent"`
+		RawEphemeral []json.RawMessage          `json:"raw_ephemeral"`
+	}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	disks := Disks{System: raw.System}
+
+	disks.Ephemeral = disks.unmarshalDiskSettings("disks.ephemeral", raw.Ephemeral)
+
+	if raw.Persistent != nil {
+		ids := []string{}
+		for id := range raw.Persistent {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		disks.Persistent = map[string]DiskSettings{}
+		for _, id := range ids {
+			disks.Persistent[id] = disks.unmarshalDiskSettings("disks.persistent."+id, raw.Persistent[id])
+		}
+	}
+
+	for i, rawEphemeral := range raw.RawEphemeral {
+		field := fmt.Sprintf("disks.raw_ephemeral[%d]", i)
+		disks.RawEphemeral = append(disks.RawEphemeral, disks.unmarshalDiskSettings(field, rawEphemeral))
+	}
+
+	*d = disks
+
+	return nil
+}
+
+func (d *Disks) unmarshalDiskSettings(field string, data json.RawMessage) DiskSettings {
+	var diskSettings DiskSettings
+
+	if len(data) == 0 {
+		return diskSettings
+	}
+
+	err := json.Unmarshal(data, &diskSettings)
+	if err != nil {
+		d.problems = append(d.problems, ValidationProblem{Field: field, Message: err.Error()})
+	}
+
+	return diskSettings
 }
 
 type VM struct {
@@ -88,70 +353,19 @@ type VM struct {
 }
 
 func (s Settings) PersistentDiskSettings(diskID string) (DiskSettings, bool) {
-	diskSettings := DiskSettings{}
-
-	for key, settings := range s.Disks.Persistent {
-		if key == diskID {
-			diskSettings.ID = diskID
-
-			if hashSettings, ok := settings.(map[string]interface{}); ok {
-				if path, ok := hashSettings["path"]; ok {
-					diskSettings.Path = path.(string)
-				}
-				if volumeID, ok := hashSettings["volume_id"]; ok {
-					diskSettings.VolumeID = volumeID.(string)
-				}
-				if deviceID, ok := hashSettings["id"]; ok {
-					diskSettings.DeviceID = deviceID.(string)
-				}
-				if lun, ok := hashSettings["lun"]; ok {
-					diskSettings.Lun = lun.(string)
-				}
-				if hostDeviceID, ok := hashSettings["host_device_id"]; ok {
-					diskSettings.HostDeviceID = hostDeviceID.(string)
-				}
-			} else {
-				// Old CPIs return disk path (string) or volume id (string) as disk settings
-				diskSettings.Path = settings.(string)
-				diskSettings.VolumeID = settings.(string)
-			}
-
-			diskSettings.FileSystemType = s.Env.PersistentDiskFS
-			return diskSettings, true
-		}
+	diskSettings, found := s.Disks.Persistent[diskID]
+	if !found {
+		return DiskSettings{}, false
 	}
 
-	return diskSettings, false
+	diskSettings.ID = diskID
+	diskSettings.FileSystemType = s.Env.PersistentDiskFS
+
+	return diskSettings, true
 }
 
 func (s Settings) EphemeralDiskSettings() DiskSettings {
-	diskSettings := DiskSettings{}
-
-	if s.Disks.Ephemeral != nil {
-		if hashSettings, ok := s.Disks.Ephemeral.(map[string]interface{}); ok {
-			if path, ok := hashSettings["path"]; ok {
-				diskSettings.Path = path.(string)
-			}
-			if volumeID, ok := hashSettings["volume_id"]; ok {
-				diskSettings.VolumeID = volumeID.(string)
-			}
-			if deviceID, ok := hashSettings["id"]; ok {
-				diskSettings.DeviceID = deviceID.(string)
-			}
-			if lun, ok := hashSettings["lun"]; ok {
-				diskSettings.Lun = lun.(string)
-			}
-			if hostDeviceID, ok := hashSettings["host_device_id"]; ok {
-				diskSettings.HostDeviceID = hostDeviceID.(string)
-			}
-		} else {
-			// Old CPIs return disk path (string) or volume id (string) as disk settings
-			diskSettings.Path = s.Disks.Ephemeral.(string)
-			diskSettings.VolumeID = s.Disks.Ephemeral.(string)
-		}
-	}
-
-	return diskSettings
+	return s.Disks.Ephemeral
 }
 
 func (s Settings) RawEphemeralDiskSettings() (devices []DiskSettings) {
@@ -209,6 +423,7 @@ type DNSRecords struct {
 type NetworkType string
 
 const (
+	NetworkTypeManual  NetworkType = "manual"
 	NetworkTypeDynamic NetworkType = "dynamic"
 	NetworkTypeVIP     NetworkType = "vip"
 )
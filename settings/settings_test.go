@@ -1,7 +1,9 @@
 package settings_test
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -20,13 +22,13 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": map[string]interface{}{
-								"volume_id":      "fake-disk-volume-id",
-								"id":             "fake-disk-device-id",
-								"path":           "fake-disk-path",
-								"lun":            "fake-disk-lun",
-								"host_device_id": "fake-disk-host-device-id",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {
+								VolumeID:     "fake-disk-volume-id",
+								DeviceID:     "fake-disk-device-id",
+								Path:         "fake-disk-path",
+								Lun:          "fake-disk-lun",
+								HostDeviceID: "fake-disk-host-device-id",
 							},
 						},
 					},
@@ -114,8 +116,8 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": "fake-disk-value",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {Path: "fake-disk-value", VolumeID: "fake-disk-value"},
 						},
 					},
 				}
@@ -144,12 +146,12 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": map[string]interface{}{
-								"volume_id":      "fake-disk-volume-id",
-								"path":           "fake-disk-path",
-								"lun":            "fake-disk-lun",
-								"host_device_id": "fake-disk-host-device-id",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {
+								VolumeID:     "fake-disk-volume-id",
+								Path:         "fake-disk-path",
+								Lun:          "fake-disk-lun",
+								HostDeviceID: "fake-disk-host-device-id",
 							},
 						},
 					},
@@ -173,12 +175,12 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": map[string]interface{}{
-								"id":             "fake-disk-device-id",
-								"path":           "fake-disk-path",
-								"lun":            "fake-disk-lun",
-								"host_device_id": "fake-disk-host-device-id",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {
+								DeviceID:     "fake-disk-device-id",
+								Path:         "fake-disk-path",
+								Lun:          "fake-disk-lun",
+								HostDeviceID: "fake-disk-host-device-id",
 							},
 						},
 					},
@@ -202,11 +204,11 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": map[string]interface{}{
-								"volume_id":      "fake-disk-volume-id",
-								"lun":            "fake-disk-lun",
-								"host_device_id": "fake-disk-host-device-id",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {
+								VolumeID:     "fake-disk-volume-id",
+								Lun:          "fake-disk-lun",
+								HostDeviceID: "fake-disk-host-device-id",
 							},
 						},
 					},
@@ -229,10 +231,10 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Persistent: map[string]interface{}{
-							"fake-disk-id": map[string]interface{}{
-								"lun":            "fake-disk-lun",
-								"host_device_id": "fake-disk-host-device-id",
+						Persistent: map[string]DiskSettings{
+							"fake-disk-id": {
+								Lun:          "fake-disk-lun",
+								HostDeviceID: "fake-disk-host-device-id",
 							},
 						},
 					},
@@ -251,12 +253,119 @@ var _ = Describe("Settings", func() {
 		})
 	})
 
+	Describe("RequestSigning", func() {
+		It("is enabled only when keys are configured", func() {
+			Expect(RequestSigning{}.IsEnabled()).To(BeFalse())
+			Expect(RequestSigning{Keys: []RequestSigningKey{{ID: "fake-key-id"}}}.IsEnabled()).To(BeTrue())
+		})
+
+		It("finds key by id", func() {
+			signing := RequestSigning{Keys: []RequestSigningKey{{ID: "fake-key-id-1"}, {ID: "fake-key-id-2", Secret: "fake-secret"}}}
+
+			key, found := signing.FindKey("fake-key-id-2")
+			Expect(found).To(BeTrue())
+			Expect(key.Secret).To(Equal("fake-secret"))
+
+			_, found = signing.FindKey("fake-unknown-key-id")
+			Expect(found).To(BeFalse())
+		})
+
+		It("defaults max clock skew to 5 minutes", func() {
+			Expect(RequestSigning{}.MaxClockSkewDuration()).To(Equal(5 * time.Minute))
+			Expect(RequestSigning{MaxClockSkew: 30}.MaxClockSkewDuration()).To(Equal(30 * time.Second))
+		})
+
+		It("unmarshals from JSON", func() {
+			err := json.Unmarshal([]byte(`{"request_signing":{"max_clock_skew":60,"keys":[{"id":"fake-key-id","algorithm":"ed25519","public_key":"fake-public-key"}]}}`), &settings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings.RequestSigning).To(Equal(RequestSigning{
+				MaxClockSkew: 60,
+				Keys: []RequestSigningKey{
+					{ID: "fake-key-id", Algorithm: "ed25519", PublicKey: "fake-public-key"},
+				},
+			}))
+		})
+	})
+
+	Describe("MbusTLS", func() {
+		It("is enabled only when CA is configured", func() {
+			Expect(MbusTLS{}.IsEnabled()).To(BeFalse())
+			Expect(MbusTLS{Certificate: "fake-cert"}.IsEnabled()).To(BeFalse())
+			Expect(MbusTLS{CA: "fake-ca"}.IsEnabled()).To(BeTrue())
+		})
+
+		It("fails validation when CA cannot be parsed", func() {
+			err := MbusTLS{CA: "fake-invalid-ca"}.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing mbus CA certificate"))
+		})
+
+		It("returns no client certificates when none are configured", func() {
+			certs, err := MbusTLS{CA: "fake-ca"}.ClientCertificates()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(certs).To(BeEmpty())
+		})
+
+		It("fails to return client certificates when private key is missing", func() {
+			_, err := MbusTLS{Certificate: "fake-cert"}.ClientCertificates()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing mbus client certificate"))
+		})
+	})
+
+	Describe("HTTPSTLS", func() {
+		It("defaults to certificate files in working directory and TLS 1.0", func() {
+			Expect(HTTPSTLS{}.CertificatePathOrDefault()).To(Equal("agent.cert"))
+			Expect(HTTPSTLS{}.PrivateKeyPathOrDefault()).To(Equal("agent.key"))
+
+			version, err := HTTPSTLS{}.TLSMinVersion()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version).To(Equal(uint16(tls.VersionTLS10)))
+		})
+
+		It("parses min TLS version", func() {
+			version, err := HTTPSTLS{MinVersion: "1.2"}.TLSMinVersion()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version).To(Equal(uint16(tls.VersionTLS12)))
+
+			err = HTTPSTLS{MinVersion: "1.4"}.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unknown TLS version '1.4'"))
+		})
+
+		It("fails validation when only one of certificate and private key paths is configured", func() {
+			err := HTTPSTLS{CertificatePath: "/fake-cert"}.Validate()
+			Expect(err).To(HaveOccurred())
+
+			Expect(HTTPSTLS{CertificatePath: "/fake-cert", PrivateKeyPath: "/fake-key"}.Validate()).To(Succeed())
+		})
+
+		It("requires client certificates only when client CA is configured", func() {
+			Expect(HTTPSTLS{}.IsClientCertificateRequired()).To(BeFalse())
+			Expect(HTTPSTLS{ClientCAPath: "/fake-ca"}.IsClientCertificateRequired()).To(BeTrue())
+		})
+
+		It("takes missing fields from defaults", func() {
+			merged := HTTPSTLS{MinVersion: "1.2"}.Merge(HTTPSTLS{
+				CertificatePath: "/fake-cert",
+				PrivateKeyPath:  "/fake-key",
+				MinVersion:      "1.1",
+			})
+
+			Expect(merged).To(Equal(HTTPSTLS{
+				CertificatePath: "/fake-cert",
+				PrivateKeyPath:  "/fake-key",
+				MinVersion:      "1.2",
+			}))
+		})
+	})
+
 	Describe("EphemeralDiskSettings", func() {
 		Context("when the disk settings are a string", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Ephemeral: "fake-disk-value",
+						Ephemeral: DiskSettings{Path: "fake-disk-value", VolumeID: "fake-disk-value"},
 					},
 				}
 			})
@@ -273,12 +382,12 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Ephemeral: map[string]interface{}{
-							"id":             "fake-disk-device-id",
-							"volume_id":      "fake-disk-volume-id",
-							"path":           "fake-disk-path",
-							"lun":            "fake-disk-lun",
-							"host_device_id": "fake-disk-host-device-id",
+						Ephemeral: DiskSettings{
+							DeviceID:     "fake-disk-device-id",
+							VolumeID:     "fake-disk-volume-id",
+							Path:         "fake-disk-path",
+							Lun:          "fake-disk-lun",
+							HostDeviceID: "fake-disk-host-device-id",
 						},
 					},
 				}
@@ -299,9 +408,9 @@ var _ = Describe("Settings", func() {
 			BeforeEach(func() {
 				settings = Settings{
 					Disks: Disks{
-						Ephemeral: map[string]interface{}{
-							"id":        "fake-disk-device-id",
-							"volume_id": "fake-disk-volume-id",
+						Ephemeral: DiskSettings{
+							DeviceID: "fake-disk-device-id",
+							VolumeID: "fake-disk-volume-id",
 						},
 					},
 				}
@@ -0,0 +1,247 @@
+package settings
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ValidationProblem describes a single settings field agent cannot act on.
+type ValidationProblem struct {
+	// Field is a path to the field, e.g. networks.default.netmask
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError contains all problems found in settings
+// so that they could be fixed at once.
+type ValidationError struct {
+	Problems []ValidationProblem `json:"problems"`
+}
+
+func (e ValidationError) Error() string {
+	problems := []string{}
+
+	for _, problem := range e.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %s", problem.Field, problem.Message))
+	}
+
+	return fmt.Sprintf("Invalid settings: %s", strings.Join(problems, "; "))
+}
+
+// Options without which blobstore clients cannot work.
+// Other providers are validated by their clients.
+var requiredBlobstoreOptions = map[string][]string{
+	"local": {"blobstore_path"},
+	"dav":   {"endpoint"},
+	"s3":    {"bucket_name"},
+	"gcs":   {"bucket_name"},
+}
+
+// Validate checks shapes and consistency of settings before agent acts on them.
+// Mbus scheme is only checked when mbusSchemes are given. Missing mbus and
+// blobstore are not reported since handlers report them when they are used.
+func (s Settings) Validate(mbusSchemes []string) error {
+	v := &validator{}
+
+	v.validateDisks(s.Disks)
+	v.validateNetworks(s.Networks)
+	v.validateBlobstore(s.Blobstore)
+	v.validateMbus(s.Mbus, mbusSchemes)
+
+	if s.MbusTLS.IsEnabled() {
+		if err := s.MbusTLS.Validate(); err != nil {
+			v.add("mbus_tls", err.Error())
+		}
+	}
+
+	if err := s.HTTPSTLS.Validate(); err != nil {
+		v.add("https_tls", err.Error())
+	}
+
+	if len(v.problems) > 0 {
+		return ValidationError{Problems: v.problems}
+	}
+
+	return nil
+}
+
+type validator struct {
+	problems []ValidationProblem
+}
+
+func (v *validator) add(field, message string) {
+	v.problems = append(v.problems, ValidationProblem{Field: field, Message: message})
+}
+
+func (v *validator) validateDisks(disks Disks) {
+	v.problems = append(v.problems, disks.problems...)
+
+	v.validateDisk("disks.ephemeral", disks.Ephemeral, false)
+
+	ids := []string{}
+	for id := range disks.Persistent {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		v.validateDisk("disks.persistent."+id, disks.Persistent[id], true)
+	}
+
+	for i, rawEphemeral := range disks.RawEphemeral {
+		v.validateDisk(fmt.Sprintf("disks.raw_ephemeral[%d]", i), rawEphemeral, false)
+	}
+}
+
+// validateDisk reports options agent would not apply
+// so that disks are not used differently than CPI asked for
+func (v *validator) validateDisk(field string, disk DiskSettings, mountOptionsSupported bool) {
+	if disk.Encryption != nil {
+		v.add(field+".encryption", "is not supported by agent")
+	}
+
+	if len(disk.FileSystemOptions) > 0 {
+		v.add(field+".filesystem_options", "are not supported by agent")
+	}
+
+	if len(disk.MountOptions) > 0 && !mountOptionsSupported {
+		v.add(field+".mount_options", "are only supported for persistent disks")
+	}
+}
+
+func (v *validator) validateNetworks(networks Networks) {
+	names := []string{}
+
+	for name := range networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		v.validateNetwork("networks."+name, networks[name])
+	}
+}
+
+func (v *validator) validateNetwork(field string, network Network) {
+	switch network.Type {
+	case "", NetworkTypeManual, NetworkTypeDynamic, NetworkTypeVIP:
+	default:
+		v.add(field+".type", fmt.Sprintf("must be one of manual, dynamic or vip, got '%s'", network.Type))
+	}
+
+	ip := v.parseIP(field+".ip", network.IP)
+	netmask := v.parseIP(field+".netmask", network.Netmask)
+	gateway := v.parseIP(field+".gateway", network.Gateway)
+
+	if netmask != nil {
+		if _, bits := ipMask(netmask).Size(); bits == 0 {
+			v.add(field+".netmask", fmt.Sprintf("must have contiguous leading ones, got '%s'", network.Netmask))
+		} else if ip != nil && isIPv4(ip) != isIPv4(netmask) {
+			v.add(field+".netmask", fmt.Sprintf("must be of the same IP version as ip '%s'", network.IP))
+		}
+	}
+
+	if gateway != nil && ip != nil && isIPv4(ip) != isIPv4(gateway) {
+		v.add(field+".gateway", fmt.Sprintf("must be of the same IP version as ip '%s'", network.IP))
+	}
+
+	for i, dns := range network.DNS {
+		v.parseIP(fmt.Sprintf("%s.dns[%d]", field, i), dns)
+	}
+}
+
+// parseIP returns nil without reporting a problem when value is empty
+func (v *validator) parseIP(field, value string) net.IP {
+	if value == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		v.add(field, fmt.Sprintf("must be an IP address, got '%s'", value))
+	}
+
+	return ip
+}
+
+func (v *validator) validateBlobstore(blobstore Blobstore) {
+	if blobstore.Type == "" {
+		if len(blobstore.Options) > 0 {
+			v.add("blobstore.provider", "must be set when options are given")
+		}
+		return
+	}
+
+	for _, option := range requiredBlobstoreOptions[blobstore.Type] {
+		value, found := blobstore.Options[option]
+		if !found {
+			v.add("blobstore.options."+option, fmt.Sprintf("must be set for %s provider", blobstore.Type))
+			continue
+		}
+
+		if str, ok := value.(string); !ok {
+			v.add("blobstore.options."+option, fmt.Sprintf("must be a string, got %s", jsonTypeName(value)))
+		} else if str == "" {
+			v.add("blobstore.options."+option, "must not be empty")
+		}
+	}
+}
+
+func (v *validator) validateMbus(mbus string, schemes []string) {
+	if mbus == "" {
+		return
+	}
+
+	mbusURL, err := url.Parse(mbus)
+	if err != nil {
+		// Error includes URL which may contain credentials
+		v.add("mbus", "must be a URL")
+		return
+	}
+
+	if mbusURL.Scheme == "" {
+		v.add("mbus", "must include a scheme")
+	} else if len(schemes) > 0 && !stringArrayContains(schemes, mbusURL.Scheme) {
+		v.add("mbus", fmt.Sprintf("must have one of schemes %s, got '%s'", strings.Join(schemes, ", "), mbusURL.Scheme))
+	}
+
+	if mbusURL.Host == "" {
+		v.add("mbus", "must include a host")
+	}
+}
+
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
+
+func ipMask(ip net.IP) net.IPMask {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPMask(ip4)
+	}
+
+	return net.IPMask(ip)
+}
+
+// jsonTypeName names types produced by unmarshalling JSON
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "hash"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}